@@ -0,0 +1,810 @@
+package main;
+
+import (
+    "os"
+    "fmt"
+    "flag"
+    "errors"
+    "context"
+    "strings"
+    "path/filepath"
+    "encoding/json"
+    goast "go/ast"
+
+    log "github.com/sirupsen/logrus"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/ast"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/docs"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/facts"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/files"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/journal"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/redact"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/prompts"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/manpage"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/completions"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/openai/calls"
+)
+
+
+// Exit codes, documented in the README
+const (
+    ExitSuccess     = 0
+    // Anything not covered below, e.g. a file that can't be read
+    ExitError       = 1
+    ExitUsage       = 2
+    // The command ran but had nothing to change
+    ExitNothingToDo = 3
+    // A check failed, e.g. the README contradicts the project
+    ExitInvalid     = 4
+    // The model provider couldn't be reached or refused the request
+    ExitProvider    = 5
+    // The run stopped before going over -max-cost or -max-tokens
+    ExitBudget      = 6
+    // The run was interrupted with Ctrl-C, like a shell reports it
+    ExitCanceled    = 130
+)
+
+
+type Command struct {
+    Name     string
+    Args     string
+    Summary  string
+    // Whether the command talks to the model, and so needs an API key
+    NeedsKey bool
+    // Whether the command reads the config itself, or doesn't need it
+    SkipsConfig bool
+    Run      func(result *Result) error
+}
+
+
+// Result is what a command did, printed as JSON with --output json
+type Result struct {
+    Command      string        `json:"command"`
+    Status       string        `json:"status"`
+    ExitCode     int           `json:"exit_code"`
+    Written      []string      `json:"written,omitempty"`
+    Output       string        `json:"output,omitempty"`
+    Problems     []string      `json:"problems,omitempty"`
+    Declarations []Declaration `json:"declarations,omitempty"`
+    Files        []ProjectFile `json:"files,omitempty"`
+    Settings     []config.Setting `json:"settings,omitempty"`
+    Schema       map[string]any   `json:"schema,omitempty"`
+    // What was kept from the model: text replaced by placeholders and files
+    // on the deny list
+    Redacted     []redact.Finding `json:"redacted,omitempty"`
+    Denied       []string         `json:"denied,omitempty"`
+    Usage        *calls.UsageSummary `json:"usage,omitempty"`
+    // The prompts filled in, or with prompts dump all of them
+    Prompts      []prompts.Prompt    `json:"prompts,omitempty"`
+    Error        string        `json:"error,omitempty"`
+}
+
+
+// Declaration is a documentable declaration listed by the docs and graph
+// commands
+type Declaration struct {
+    Name       string   `json:"name"`
+    Kind       string   `json:"kind"`
+    File       string   `json:"file"`
+    Line       int      `json:"line,omitempty"`
+    Documented bool     `json:"documented"`
+    Calls      []string `json:"calls,omitempty"`
+    Uses       []string `json:"uses,omitempty"`
+}
+
+
+// ProjectFile is a file the files command would send to the model, in the
+// order it's added to the prompt
+type ProjectFile struct {
+    Path   string `json:"path"`
+    Tier   string `json:"tier"`
+    Tokens int    `json:"tokens"`
+}
+
+
+var Commands = []Command{
+    {
+        Name:     "readme",
+        Args:     "[project directory]",
+        Summary:  "Write a README.md, or regenerate the managed sections of an existing one",
+        NeedsKey: true,
+        Run:      runReadme,
+    },
+    {
+        Name:     "helpmenu",
+        Args:     "[project directory]",
+        Summary:  "Write a help menu implementation, or add one to a file with -e",
+        NeedsKey: true,
+        Run:      runHelpMenu,
+    },
+    {
+        Name:     "helptext",
+        Args:     "[project directory]",
+        Summary:  "Write the text of a help menu",
+        NeedsKey: true,
+        Run:      runHelpText,
+    },
+    {
+        Name:     "man",
+        Args:     "[project directory]",
+        Summary:  "Write a section 1 man page",
+        NeedsKey: true,
+        Run:      runManPage,
+    },
+    {
+        Name:     "docs",
+        Args:     "file...",
+        Summary:  "Write documentation comments into source files",
+        NeedsKey: true,
+        Run:      runDocs,
+    },
+    {
+        Name:     "graph",
+        Args:     "file...",
+        Summary:  "List the declarations in source files and the calls between them",
+        Run:      runGraph,
+    },
+    {
+        Name:     "check",
+        Args:     "[project directory]",
+        Summary:  "Check the README against the facts read from the project",
+        Run:      runCheck,
+    },
+    {
+        Name:     "files",
+        Args:     "[project directory]",
+        Summary:  "List the files that would be sent to the model",
+        Run:      runFiles,
+    },
+    {
+        Name:     "completions",
+        Args:     "[project directory]",
+        Summary:  "Write shell completions for a Go program",
+        SkipsConfig: true,
+        Run:      runCompletions,
+    },
+    {
+        Name:     "prompts dump",
+        Args:     "[project directory]",
+        Summary:  "Print the prompts in use, or write them out with -o to edit",
+        Run:      runPromptsDump,
+    },
+    {
+        Name:     "config show",
+        Args:     "[project directory]",
+        Summary:  "Print the configuration in effect and where each value came from",
+        Run:      runConfigShow,
+    },
+    {
+        Name:     "config schema",
+        Summary:  "Print the JSON Schema of the config files, for editor completion",
+        SkipsConfig: true,
+        Run:      runConfigSchema,
+    },
+    {
+        Name:     "config validate",
+        Args:     "[project directory]",
+        Summary:  "Check every config file and AUTOSCRIBE_ env var for mistakes",
+        SkipsConfig: true,
+        Run:      runConfigValidate,
+    },
+}
+
+
+func findCommand(name string) (Command, bool) {
+    for _, c := range Commands {
+        if c.Name == name {
+            return c, true
+        }
+    }
+
+    return Command{}, false
+}
+
+
+func printUsage() {
+    fmt.Println("Usage: autoscribe <command> [flags] [arguments]\n\nCommands:")
+    for _, c := range Commands {
+        fmt.Printf("  %-16v %v\n", c.Name, c.Summary)
+    }
+    fmt.Println("\nRun 'autoscribe <command> -h' for the flags of a command.")
+    fmt.Printf("\nExit codes: %v success, %v error, %v usage error, %v nothing to do, %v validation failure, %v provider error, %v over budget, %v interrupted\n",
+        ExitSuccess, ExitError, ExitUsage, ExitNothingToDo, ExitInvalid, ExitProvider, ExitBudget, ExitCanceled)
+}
+
+
+// runCommand parses the command's flags, runs it and reports the result,
+// returning the exit code
+func runCommand(c Command, args []string) int {
+    usage := fmt.Sprintf("Usage: autoscribe %v [flags] %v\n\n%v", c.Name, c.Args, c.Summary)
+
+    calls.CurrentCommand = c.Name
+
+    err := config.ParseCommandCli(c.Name, usage, args)
+    if err == flag.ErrHelp {
+        return ExitSuccess
+    } else if err != nil {
+        fmt.Fprintf(os.Stderr, "autoscribe %v: %v\nRun 'autoscribe %v -h' for usage\n", c.Name, err, c.Name)
+        return ExitUsage
+    }
+
+    if !c.SkipsConfig {
+        // Estimates are worked out locally, so they don't need a key
+        err = config.Load(c.NeedsKey && !config.Estimate)
+    }
+
+    result := &Result{ Command: c.Name }
+
+    var configErr *config.ConfigError
+    if errors.As(err, &configErr) {
+        for _, p := range configErr.Problems {
+            result.Problems = append(result.Problems, p.String())
+        }
+        err = fmt.Errorf("failed to load config, run 'autoscribe config validate' for details: %v", err)
+    } else if err != nil {
+        err = fmt.Errorf("failed to load config: %v", err)
+    } else {
+        err = c.Run(result)
+    }
+
+    result.Redacted = redact.Findings()
+    result.Denied = files.DeniedFiles()
+    if len(result.Prompts) == 0 {
+        result.Prompts = prompts.Used()
+    }
+    if summary := calls.Summary(); summary.Total.Calls > 0 {
+        result.Usage = &summary
+    }
+
+    result.finish(err)
+    result.report()
+
+    return result.ExitCode
+}
+
+
+func (r *Result) finish(err error) {
+    var providerErr *calls.ProviderError
+
+    switch {
+    case err == nil:
+        r.Status, r.ExitCode = "ok", ExitSuccess
+    case errors.Is(err, types.ErrNothingToDo):
+        r.Status, r.ExitCode = "nothing to do", ExitNothingToDo
+    case errors.Is(err, types.ErrInvalid):
+        r.Status, r.ExitCode = "invalid", ExitInvalid
+    case errors.Is(err, calls.ErrEstimated):
+        r.Status, r.ExitCode = "estimated", ExitSuccess
+    case errors.Is(err, calls.ErrBudget):
+        r.Status, r.ExitCode = "budget exceeded", ExitBudget
+    case errors.Is(err, context.Canceled):
+        r.Status, r.ExitCode = "canceled", ExitCanceled
+    case errors.As(err, &providerErr):
+        r.Status, r.ExitCode = "provider error", ExitProvider
+    default:
+        r.Status, r.ExitCode = "error", ExitError
+    }
+
+    if err != nil && !errors.Is(err, calls.ErrEstimated) {
+        r.Error = config.Redact(err.Error())
+    }
+}
+
+
+func (r *Result) report() {
+    if config.OutputFormat == "json" {
+        out, err := json.MarshalIndent(r, "", "  ")
+        if err != nil {
+            log.Errorf("Failed to encode the result: %v", err)
+            return
+        }
+        fmt.Println(string(out))
+        return
+    }
+
+    if r.Output != "" {
+        fmt.Println(r.Output)
+    }
+
+    for _, path := range r.Written {
+        log.Infof("Wrote %v", path)
+    }
+
+    for _, f := range r.Redacted {
+        log.Warnf("Redacted %v", f)
+    }
+
+    if r.Usage != nil {
+        reportUsage(*r.Usage)
+    }
+
+    switch {
+    case r.Status == "estimated":
+        log.Info("Nothing was sent to the model")
+    case r.ExitCode == ExitSuccess:
+        log.Info("AutoScribe-d successfully!")
+    case r.ExitCode == ExitNothingToDo:
+        log.Infof("Nothing to do: %v", r.Error)
+    default:
+        log.Errorf("autoscribe %v failed: %v", r.Command, r.Error)
+    }
+}
+
+
+// reportUsage logs what the model calls of a run used, per file when there
+// was more than one
+func reportUsage(summary calls.UsageSummary) {
+    log.Infof("Usage: %v", summary.Total)
+
+    if len(summary.Commands) > 1 {
+        for _, t := range summary.Commands {
+            log.Infof("  %v: %v", t.Name, t)
+        }
+    }
+
+    if len(summary.Files) > 1 {
+        for _, t := range summary.Files {
+            log.Infof("  %v: %v", t.Name, t)
+        }
+    }
+}
+
+
+func runReadme(result *Result) error {
+    languages, err := files.SelectedLanguages()
+    if err != nil {
+        return fmt.Errorf("failed to detect languages: %v", err)
+    }
+
+    log.Infof("Making README.md for %v", config.ProjectDirectory)
+
+    path, contradictions, err := calls.CreateReadme(languages)
+    if err != nil {
+        return err
+    }
+
+    result.Written = append(result.Written, path)
+    for _, c := range contradictions {
+        result.Problems = append(result.Problems, c.String())
+    }
+
+    return nil
+}
+
+
+func runHelpMenu(result *Result) error {
+    languages, err := files.SelectedLanguages()
+    if err != nil {
+        return fmt.Errorf("failed to detect languages: %v", err)
+    }
+
+    log.Infof("Making Help Menu for %v", config.ProjectDirectory)
+
+    impl, err := calls.CreateHelpMenuImplementation(languages)
+    if err != nil {
+        return fmt.Errorf("failed to create a help menu implementation: %w", err)
+    }
+
+    if config.EditFile != "" {
+        result.Written = append(result.Written, config.EditFile)
+    } else {
+        result.Output = impl
+    }
+
+    return nil
+}
+
+
+func runHelpText(result *Result) error {
+    languages, err := files.SelectedLanguages()
+    if err != nil {
+        return fmt.Errorf("failed to detect languages: %v", err)
+    }
+
+    log.Infof("Making Help Menu for %v", config.ProjectDirectory)
+
+    text, err := calls.CreateHelpMenuText(languages)
+    if err != nil {
+        return fmt.Errorf("failed to create the text for a help menu: %w", err)
+    }
+
+    result.Output = text
+
+    return nil
+}
+
+
+func runManPage(result *Result) error {
+    log.Infof("Making man page for %v", config.ProjectDirectory)
+
+    path, err := manpage.CreateManPage(manpage.Format(config.ManPageFormat))
+    if err != nil {
+        return fmt.Errorf("failed to create a man page: %w", err)
+    }
+
+    result.Written = append(result.Written, path)
+
+    return nil
+}
+
+
+/**
+ * runDocs documents each of the source files in turn, and has nothing to do
+ * when all their declarations are documented already. Every declaration is
+ * journaled as soon as it's documented, so a run stopped by Ctrl-C or an
+ * error can be picked up with -resume without asking for it again.
+ */
+func runDocs(result *Result) (err error) {
+    pending := 0
+    estimated := false
+
+    // Nothing is written with -estimate, so there's nothing to pick up
+    if !config.Estimate {
+        if err := journal.Open(config.Resume); err != nil {
+            return err
+        }
+        defer func() {
+            err = closeJournal(err)
+        }()
+    }
+
+    for _, path := range config.SourceFiles {
+        declarations, written, err := documentSource(path, true)
+        if errors.Is(err, calls.ErrEstimated) {
+            estimated = true
+        } else if err != nil {
+            return err
+        }
+
+        for _, d := range declarations {
+            if !d.Documented {
+                pending++
+            }
+        }
+
+        result.Declarations = append(result.Declarations, declarations...)
+        if written {
+            result.Written = append(result.Written, path)
+        }
+    }
+
+    if pending == 0 {
+        return fmt.Errorf("every declaration in %v is documented: %w", strings.Join(config.SourceFiles, ", "), types.ErrNothingToDo)
+    }
+
+    if estimated {
+        return calls.ErrEstimated
+    }
+
+    return nil
+}
+
+
+// closeJournal removes the journal after a run which finished, and otherwise
+// adds how to pick the run up to err
+func closeJournal(err error) error {
+    finished := err == nil || errors.Is(err, types.ErrNothingToDo)
+
+    if closeErr := journal.Close(finished); closeErr != nil {
+        log.Warn(closeErr)
+    }
+
+    if !finished && journal.Count() > 0 {
+        return fmt.Errorf("%w. Run again with -resume to keep the %v declaration(s) already documented", err, journal.Count())
+    }
+
+    return err
+}
+
+
+func runGraph(result *Result) error {
+    for _, path := range config.SourceFiles {
+        declarations, _, err := documentSource(path, false)
+        if err != nil {
+            return err
+        }
+
+        result.Declarations = append(result.Declarations, declarations...)
+    }
+
+    if config.OutputFormat == "text" {
+        var b strings.Builder
+        for _, d := range result.Declarations {
+            fmt.Fprintf(&b, "%v %v (%v:%v)\n", d.Kind, d.Name, d.File, d.Line)
+            for _, call := range d.Calls {
+                fmt.Fprintf(&b, "  Calls %v\n", call)
+            }
+            if len(d.Uses) > 0 {
+                fmt.Fprintf(&b, "  Uses %v\n", strings.Join(d.Uses, ", "))
+            }
+        }
+        result.Output = strings.TrimRight(b.String(), "\n")
+    }
+
+    return nil
+}
+
+
+/**
+ * documentSource lists the declarations in path, as they were before any
+ * documentation was added, and when write is set documents the ones without
+ * any. It returns whether the file was changed. Files on the deny list are
+ * never documented. With -estimate the declarations are still listed, and
+ * calls.ErrEstimated is returned.
+ */
+func documentSource(path string, write bool) ([]Declaration, bool, error) {
+    if write {
+        if denied, err := files.Denied(filepath.Clean(path)); err != nil {
+            return nil, false, err
+        } else if denied {
+            return nil, false, fmt.Errorf("%v is on the deny list, so it's never sent to the model", path)
+        }
+    }
+
+    if backend, ok := docs.BackendForFile(path); ok {
+        nodes, err := docs.DocumentFile(backend, path, write)
+        if err != nil && !errors.Is(err, calls.ErrEstimated) {
+            return nil, false, fmt.Errorf("failed to document %v: %w", path, err)
+        }
+
+        declarations := []Declaration{}
+        written := false
+        for _, n := range nodes {
+            d := Declaration{
+                Name:       n.FullName(),
+                Kind:       n.Kind,
+                File:       n.File,
+                Line:       n.Line,
+                Documented: n.Doc != "",
+                Uses:       n.Uses,
+            }
+            for _, callee := range n.Callees {
+                d.Calls = append(d.Calls, callee.FullName())
+            }
+            declarations = append(declarations, d)
+            written = written || n.Generated != ""
+        }
+
+        return declarations, written, err
+    }
+
+    lang, ok := types.DetectLanguage(path)
+    if !ok || !lang.Is(types.Golang) {
+        return nil, false, fmt.Errorf("no documentation backend for %v", path)
+    }
+
+    pkgNodes, err := ast.ParsePackage(path)
+    if err != nil {
+        return nil, false, fmt.Errorf("failed to parse package: %v", err)
+    }
+
+    declarations := []Declaration{}
+    written := false
+    estimated := false
+
+    for _, pkg := range pkgNodes {
+        undocumented := 0
+
+        for _, f := range pkg.FunctionDeclarations {
+            fd, isDecl := f.Node.(*goast.FuncDecl)
+            d := Declaration{
+                Name:       f.FullName(),
+                Kind:       string(f.Kind),
+                File:       f.File,
+                Documented: isDecl && fd.Doc != nil,
+            }
+            for _, call := range f.Calls {
+                d.Calls = append(d.Calls, call.FullName())
+            }
+            declarations = append(declarations, d)
+
+            if !d.Documented {
+                undocumented++
+            }
+        }
+
+        if !write || undocumented == 0 {
+            continue
+        }
+
+        estimatedPkg := false
+        for _, f := range pkg.FunctionDeclarations {
+            log.Infof("Documenting %v...", f.Name)
+            if err := ast.DocumentFunctions(f); errors.Is(err, calls.ErrEstimated) {
+                estimatedPkg = true
+            } else if err != nil {
+                return nil, false, fmt.Errorf("failed to document %v: %w", f.Name, err)
+            }
+        }
+
+        if estimatedPkg {
+            estimated = true
+            continue
+        }
+
+        if err := pkg.UpdateDocsInFile(); err != nil {
+            return nil, false, fmt.Errorf("failed to update doc in file: %v", err)
+        }
+        written = true
+    }
+
+    if estimated {
+        return declarations, written, calls.ErrEstimated
+    }
+
+    return declarations, written, nil
+}
+
+
+func runCheck(result *Result) error {
+    projectFacts, err := facts.Collect(config.ProjectDirectory)
+    if err != nil {
+        log.Warnf("Some project facts could not be read: %v", err)
+    }
+
+    name := config.EditFile
+    if name == "" {
+        name = "README.md"
+    }
+    path := filepath.Join(config.OutputDirectory, name)
+
+    contradictions, err := calls.CheckReadmeFacts(path, projectFacts)
+    if err != nil {
+        return err
+    }
+
+    for _, c := range contradictions {
+        result.Problems = append(result.Problems, fmt.Sprintf("%v: %v", path, c))
+    }
+
+    if len(contradictions) > 0 {
+        return fmt.Errorf("%v has %v claim(s) contradicting the project: %w", path, len(contradictions), types.ErrInvalid)
+    }
+
+    return nil
+}
+
+
+// runFiles lists the project files a README would be generated from, in the
+// order they're added to the prompt
+func runFiles(result *Result) error {
+    paths, err := files.FilterForProjectFiles()
+    if err != nil {
+        return fmt.Errorf("failed to select files: %v", err)
+    }
+
+    ranked, err := files.RankFiles(paths)
+    if err != nil {
+        return fmt.Errorf("failed to rank files: %v", err)
+    }
+
+    lines := []string{}
+    total := 0
+    for _, f := range ranked {
+        result.Files = append(result.Files, ProjectFile{ Path: f.Path, Tier: files.TierNames[f.Tier], Tokens: f.Tokens })
+        if config.ShowTokens {
+            lines = append(lines, fmt.Sprintf("%8d  %-12v %v", f.Tokens, files.TierNames[f.Tier], f.Path))
+        } else {
+            lines = append(lines, f.Path)
+        }
+        total += f.Tokens
+    }
+
+    if config.ShowTokens {
+        lines = append(lines, fmt.Sprintf("%8d  total (approximate tokens), budget %v", total, files.ContextBudget()))
+    }
+    result.Output = strings.Join(lines, "\n")
+
+    return nil
+}
+
+
+// runCompletions writes the completion script of a Go program for a shell,
+// from the flags defined in the project
+func runCompletions(result *Result) error {
+    program := config.ProgramName
+    if program == "" {
+        program = facts.ProgramName(config.ProjectDirectory)
+    }
+
+    log.Infof("Generating %v completions for %v", config.CompletionShell, program)
+
+    flags, err := facts.ExtractFlags(config.ProjectDirectory)
+    if err != nil {
+        return fmt.Errorf("failed to extract flags: %v", err)
+    }
+
+    script, err := completions.Generate(completions.Shell(config.CompletionShell), program, flags)
+    if err != nil {
+        return fmt.Errorf("failed to generate completions: %v", err)
+    }
+    result.Output = strings.TrimSuffix(script, "\n")
+
+    return nil
+}
+
+
+// runPromptsDump prints every prompt with where it came from, or writes them
+// into a directory where they can be edited and used as replacements
+func runPromptsDump(result *Result) error {
+    all, err := prompts.All()
+    if err != nil {
+        return err
+    }
+    result.Prompts = all
+
+    if config.PromptsDirectory != "" {
+        if err := os.MkdirAll(config.PromptsDirectory, 0755); err != nil {
+            return fmt.Errorf("failed to create %v: %v", config.PromptsDirectory, err)
+        }
+
+        for _, p := range all {
+            path := filepath.Join(config.PromptsDirectory, p.Name + ".tmpl")
+            if err := os.WriteFile(path, []byte(p.Text + "\n"), 0644); err != nil {
+                return fmt.Errorf("failed to write %v: %v", path, err)
+            }
+            result.Written = append(result.Written, path)
+        }
+
+        return nil
+    }
+
+    if config.OutputFormat == "text" {
+        var b strings.Builder
+        for _, p := range all {
+            fmt.Fprintf(&b, "==> %v (%v) <==\n%v\n\n", p.Version, p.Source, p.Text)
+        }
+        result.Output = strings.TrimRight(b.String(), "\n")
+    }
+
+    return nil
+}
+
+
+func runConfigShow(result *Result) error {
+    result.Settings = config.Settings()
+
+    if config.OutputFormat == "text" {
+        var b strings.Builder
+        for _, s := range result.Settings {
+            fmt.Fprintf(&b, "%-18v %-40v %v\n", s.Key, s.String(), s.Source)
+        }
+        result.Output = strings.TrimRight(b.String(), "\n")
+    }
+
+    return nil
+}
+
+
+func runConfigSchema(result *Result) error {
+    result.Schema = config.Schema()
+
+    if config.OutputFormat == "text" {
+        out, err := json.MarshalIndent(result.Schema, "", "  ")
+        if err != nil {
+            return fmt.Errorf("failed to encode the schema: %v", err)
+        }
+        result.Output = string(out)
+    }
+
+    return nil
+}
+
+
+func runConfigValidate(result *Result) error {
+    for _, p := range config.Validate() {
+        result.Problems = append(result.Problems, p.String())
+    }
+
+    if config.OutputFormat == "text" {
+        result.Output = strings.Join(result.Problems, "\n")
+    }
+
+    if len(result.Problems) > 0 {
+        return fmt.Errorf("found %v problem(s) in the config: %w", len(result.Problems), types.ErrInvalid)
+    }
+
+    return nil
+}
@@ -1,33 +1,105 @@
 package main;
 
 import (
-    // "fmt"
+    "os"
+    "fmt"
+    "errors"
+    "context"
+    "syscall"
+    "os/signal"
+    "slices"
+    "strings"
     log "github.com/sirupsen/logrus"
 
     "github.com/BlankCanvasStudio/AutoScribe/pkg/ast"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/docs"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/files"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
     "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/journal"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/redact"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/manpage"
     "github.com/BlankCanvasStudio/AutoScribe/pkg/openai/calls"
 )
 
 
 func main() {
-    err := config.LoadConfig()
+    if len(os.Args) > 1 && slices.Contains([]string{ "help", "-h", "-help", "--help" }, os.Args[1]) {
+        printUsage()
+        return
+    }
+
+    calls.Context = cancelOnInterrupt()
+
+    if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+        name, args := os.Args[1], os.Args[2:]
+
+        // Commands grouped under a noun, e.g. config show
+        if len(args) > 0 {
+            if _, ok := findCommand(name + " " + args[0]); ok {
+                name, args = name + " " + args[0], args[1:]
+            }
+        }
+
+        c, ok := findCommand(name)
+        if !ok {
+            fmt.Fprintf(os.Stderr, "autoscribe: unknown command %v\n\n", name)
+            printUsage()
+            os.Exit(ExitUsage)
+        }
+        os.Exit(runCommand(c, args))
+    }
+
+    runLegacy()
+}
+
+
+// cancelOnInterrupt returns a context which ends on the first Ctrl-C, so the
+// calls in flight stop and nothing half done is written. A second Ctrl-C
+// quits straight away
+func cancelOnInterrupt() context.Context {
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+    go func() {
+        <-ctx.Done()
+        log.Warn("Interrupted, stopping. Press Ctrl-C again to quit now")
+        stop()
+    }()
+
+    return ctx
+}
+
+
+// runLegacy runs every mode enabled with the old -r, -m, -mt, -man and -a
+// flags, one after the other
+func runLegacy() {
+    log.Warn("The -r, -m, -mt, -man and -a flags are deprecated, use the readme, helpmenu, helptext, man, docs and graph commands instead")
+
+    err := config.ParseCli()
+    if err != nil {
+        log.Fatalf("Failed to parse cli: %v", err)
+    }
+
+    err = config.Load(!config.Estimate)
     if err != nil {
         log.Fatalf("Failed to load config: %v", err)
     }
 
-    err = config.ParseCli()
+    languages, err := files.SelectedLanguages()
     if err != nil {
-        log.Fatalf("Failed to parse cli: %v", err)
+        log.Fatalf("Failed to detect languages: %v", err)
     }
 
 
     if config.MakeReadme {
         log.Infof("Making README.md for %v", config.ProjectDirectory)
+        calls.CurrentCommand = "readme"
 
         // err := calls.CreateReadme(formattedFileContents, config.LanguageFileExtension)
-        err := calls.CreateReadme(config.LanguageFileExtension)
-        if err != nil {
+        _, _, err := calls.CreateReadme(languages)
+        if errors.Is(err, types.ErrNothingToDo) || errors.Is(err, calls.ErrEstimated) {
+            log.Info(err)
+        } else if err != nil {
             log.Fatalf("Failed to create a README: %v", err)
         }
     }
@@ -37,8 +109,11 @@ func main() {
         log.Infof("Making Help Menu for %v", config.ProjectDirectory)
 
         // impl, err := calls.CreateHelpMenuImplementation(formattedFileContents, config.LanguageFileExtension)
-        impl, err := calls.CreateHelpMenuImplementation(config.LanguageFileExtension)
-        if err != nil {
+        calls.CurrentCommand = "helpmenu"
+        impl, err := calls.CreateHelpMenuImplementation(languages)
+        if errors.Is(err, calls.ErrEstimated) {
+            log.Info(err)
+        } else if err != nil {
             log.Fatalf("Failed to create a help menu implementation: %v", err)
         }
 
@@ -50,15 +125,52 @@ func main() {
         log.Infof("Making Help Menu for %v", config.ProjectDirectory)
 
         // text, err := calls.CreateHelpMenuText(formattedFileContents, config.LanguageFileExtension)
-        text, err := calls.CreateHelpMenuText(config.LanguageFileExtension)
-        if err != nil {
+        calls.CurrentCommand = "helptext"
+        text, err := calls.CreateHelpMenuText(languages)
+        if errors.Is(err, calls.ErrEstimated) {
+            log.Info(err)
+        } else if err != nil {
             log.Fatalf("Failed to create the text for a help menu: %v", err)
         }
 
         log.Infof("Help menu:\n\n%v\n\n", text)
     }
 
-    if config.AstFileName != "" {
+    if config.MakeManPage {
+        log.Infof("Making man page for %v", config.ProjectDirectory)
+
+        calls.CurrentCommand = "man"
+        path, err := manpage.CreateManPage(manpage.Format(config.ManPageFormat))
+        if errors.Is(err, calls.ErrEstimated) {
+            log.Info(err)
+        } else if err != nil {
+            log.Fatalf("Failed to create a man page: %v", err)
+        } else {
+            log.Infof("Man page written to %v", path)
+        }
+    }
+
+    if config.DocumentAst && !config.Estimate {
+        if err := journal.Open(config.Resume); err != nil {
+            log.Fatalf("Failed to start the journal: %v", err)
+        }
+    }
+
+    if backend, ok := docs.BackendForFile(config.AstFileName); ok {
+        calls.CurrentCommand = "docs"
+        nodes, err := docs.DocumentFile(backend, config.AstFileName, config.DocumentAst)
+        if errors.Is(err, calls.ErrEstimated) {
+            log.Info(err)
+        } else if err != nil {
+            log.Fatalf("failed to document %v: %v", config.AstFileName, closeJournal(err))
+        }
+
+        if ! config.DocumentAst {
+            for _, n := range nodes {
+                n.PrettyPrint("")
+            }
+        }
+    } else if config.AstFileName != "" {
         pkgNodes, err := ast.ParsePackage(config.AstFileName)
         if err != nil {
             log.Fatalf("failed to parse package: %v", err)
@@ -66,16 +178,22 @@ func main() {
 
         for _, pkg := range pkgNodes {
             if config.DocumentAst {
+                calls.CurrentCommand = "docs"
                 for _, f := range pkg.FunctionDeclarations {
                     log.Infof("Documenting %v...", f.Name)
-                    ast.DocumentFunctions(f)
+                    if err := ast.DocumentFunctions(f); err != nil && !errors.Is(err, calls.ErrEstimated) {
+                        log.Fatalf("failed to document %v: %v", f.Name, closeJournal(err))
+                    }
                 }
                 // for _, f := range pkg.FunctionDeclarations {
                 //     f.PrettyPrint("")
                 // }
+                if config.Estimate {
+                    continue
+                }
                 err := pkg.UpdateDocsInFile()
                 if err != nil {
-                    log.Fatalf("failed to update doc in file: %v", err)
+                    log.Fatalf("failed to update doc in file: %v", closeJournal(err))
                 }
             } else {
                 for _, decl := range pkg.FunctionDeclarations {
@@ -88,7 +206,15 @@ func main() {
     }
 
 
+    closeJournal(nil)
+
+    for _, f := range redact.Findings() {
+        log.Warnf("Redacted %v", f)
+    }
+
+    if summary := calls.Summary(); summary.Total.Calls > 0 {
+        reportUsage(summary)
+    }
 
     log.Info("AutoScribe-d successfully!")
 }
-
@@ -5,6 +5,8 @@ go 1.23.11
 require (
 	github.com/openai/openai-go/v2 v2.1.0
 	github.com/sirupsen/logrus v1.9.3
+	golang.org/x/mod v0.27.0
+	golang.org/x/tools v0.36.0
 	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
 
@@ -13,8 +15,6 @@ require (
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.1 // indirect
 	github.com/tidwall/sjson v1.2.5 // indirect
-	golang.org/x/mod v0.27.0 // indirect
 	golang.org/x/sync v0.16.0 // indirect
 	golang.org/x/sys v0.35.0 // indirect
-	golang.org/x/tools v0.36.0 // indirect
 )
@@ -3,71 +3,23 @@ package ast;
 import (
     "os"
     "fmt"
+    "errors"
+    "strings"
 
     "go/ast"
 
-    // log "github.com/sirupsen/logrus"
+    log "github.com/sirupsen/logrus"
 
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/docs"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/journal"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/prompts"
     "github.com/BlankCanvasStudio/AutoScribe/pkg/openai/calls"
 )
 
-var AiDocumentPromptV1 string = `
-You are a precise code documenter. Read the function below and produce clear, concise documentation.
-
-**IMPORTANT: All of these files are written in the Language: %v. Repond accordingly**
-
-Constraints:
-- Keep less than 250 words unless the function is unusually complex.
-- Do not speculate about code not shown; note external dependencies without guessing behavior.
-- Use exact identifier names and types from the code.
-- Prefer active voice and plain English.
-- **VERY IMPORTANT:** If you can clearly understand the code without your documentation, don't return anything
-- Ouptput the result in a Language Docstring/Comment of the language used: provide an idiomatic doc block (e.g., GoDoc for golang, JSDoc for js, Python docstring for python) for the function.
-- **NEVER RETURN THE SOURCE CODE. ONLY COMMENTS**
-- **DO NOT RETURN ANYTHING OUTSIDE THE COMMENT BLOCK
-- **Respond only with comments, no markdown formatting or code fences.**
-
-Output format (omit any that don’t apply or can be trivially seen):
-1) Summary: what the function does and when to use it.
-2) Signature: An abveriation of the function signature. Do not return code
-3) Parameters: list with name, type, meaning, important constraints.
-4) Returns: what is returned and under which conditions.
-5) Errors/Exceptions: when/why failures occur (panics, error values, thrown exceptions).
-6) Side Effects: state mutation, I/O, network calls, concurrency (locks/goroutines/threads).
-7) Edge Cases & Assumptions: inputs that change behavior; pre/postconditions.
-
---- BEGIN CODE ---
-%v
---- END CODE ---
-`
-
-var AiDocumentPrompt string = `
-You are a precise code documenter. Read the function below and produce clear, concise documentation in the file’s language (Language: %v).
-
-Rules
-- CRITICAL: Respond only when necessary. If the function is obvious from its name/signature, return nothing.
-- Use exact identifier names and types from the code.
-- Prefer active voice and plain English.
-- Keep responses short; expand only if truly needed to clarify function behavior.
-- Do not speculate about unseen code.
-- Output only a language-idiomatic doc block (e.g. GoDoc, JSDoc, Python docstring).
-- Do not wrap in markdown/code fences.
-- Assume the code is valid.
-- Omit any section that is empty, trivial, or you would put "none" in.
-- If a comment in the code helps to explain the code, quote it directly, do not paraphrase it
-
-Output format (omit irrelevant/trivial):
-- Summary: what the function does and when to use it.
-- Signature: accurate language syntax.
-- Parameters: name, type, role, constraints.
-- Returns: values and conditions.
-- Errors/Exceptions: failure cases.
-- Side Effects: mutations, I/O, concurrency.
-- Edge Cases & Assumptions: unusual inputs, pre/postconditions.
-
---- BEGIN CODE ---
-%v
---- END CODE ---`
+
+// The documentation style of Go, for the prompt. DOC_STYLE go replaces it
+var GoDocStyle string = `The comment is GoDoc, so start the summary with the function's name, e.g. "Parse reads ...". Parameters are described in the text rather than listed, and only when they need it.`
 
 
 // Should add parsing to this to drop anything that isn't a comment
@@ -82,8 +34,8 @@ func DocumentFunctions(f *FunctionNode) error {
             // log.Infof("%v: %v", f.Calls[i].Name, f.Calls[i].Documented)
             // Recursively document if we need to
             err := DocumentFunctions(f.Calls[i])
-            if err != nil {
-                return fmt.Errorf("failed to document call %v in %v: %v", f.Calls[i].Name, f.Name, err)
+            if err != nil && !errors.Is(err, calls.ErrEstimated) {
+                return fmt.Errorf("failed to document call %v in %v: %w", f.Calls[i].Name, f.Name, err)
             }
         }
     }
@@ -104,14 +56,60 @@ func DocumentFunctions(f *FunctionNode) error {
         return fmt.Errorf("failed to convert FunctionNode to GPT string: %v", err)
     }
 
-    FullDocumentationQuery := fmt.Sprintf(AiDocumentPrompt, f.Language, NodeAsAiText)
+    data := prompts.Data{
+        Kind:     "function",
+        // go/ast only parses Go, and f.Language isn't always set
+        Language: "Go",
+        Style:    GoDocStyle,
+        Code:     NodeAsAiText,
+    }
+    if fd := f.Node.(*ast.FuncDecl); fd.Recv != nil {
+        data.Kind = "method"
+    }
+    if override, ok := config.DocStyles["go"]; ok {
+        data.Style = override
+    }
+    for _, called := range f.Calls {
+        if called.Documentation != "" {
+            data.Callees = append(data.Callees, prompts.Callee{ Name: called.Name, Doc: called.Documentation })
+        }
+    }
+
+    system, err := prompts.Render("document_system", data)
+    if err != nil {
+        return err
+    }
 
-    DocumentationString, err := calls.Query4_1Nano(FullDocumentationQuery)
+    FullDocumentationQuery, err := prompts.Render("document", data)
     if err != nil {
-        return fmt.Errorf("failed to query 4.1 Nano: %v", err)
+        return err
+    }
+
+    if documentation, ok := journal.Lookup(f.File, f.FullName(), system, FullDocumentationQuery); ok {
+        log.Debugf("Using the documentation of %v from the journal", f.FullName())
+        f.Documentation = documentation
+        f.Documented = true
+        return nil
+    }
+
+    var doc docs.Doc
+    err = calls.QueryJSON(f.File, system, FullDocumentationQuery, docs.DocSchema, &doc)
+    if errors.Is(err, calls.ErrEstimated) {
+        // Counted, so it isn't estimated again when something else calls it
+        f.AiAware = true
+        return err
+    } else if err != nil {
+        return fmt.Errorf("failed to query 4.1 Nano: %w", err)
+    }
+
+    // Left empty, UpdateDocsInFile skips it
+    if !doc.Skip {
+        f.Documentation = GoDocComment(doc)
     }
 
-    f.Documentation = DocumentationString
+    if err := journal.Record(f.File, f.FullName(), f.Documentation, system, FullDocumentationQuery); err != nil {
+        return err
+    }
 
     // Actually moving this outside the loop. That way we can tell if we need to update docs or 
     //  not based on init presence of goDoc
@@ -148,3 +146,35 @@ func insertIntoFile(path string, offset int, insertion string) error {
     return os.WriteFile(path, out, 0644)
 }
 
+
+/**
+ * GoDocComment lays out d as // comment lines. Go doesn't list parameters or
+ * use sections, so everything but the summary becomes a paragraph of the
+ * description.
+ */
+func GoDocComment(d docs.Doc) string {
+    paragraphs := []string{ d.Summary, d.Description }
+
+    params := []string{}
+    for _, p := range d.Params {
+        if strings.TrimSpace(p.Description) != "" {
+            params = append(params, p.Name + " is " + strings.TrimSpace(p.Description))
+        }
+    }
+    paragraphs = append(paragraphs, strings.Join(params, "\n"), d.Returns, strings.Join(d.Errors, "\n"), d.Panics, d.SideEffects)
+
+    lines := []string{}
+    for _, paragraph := range paragraphs {
+        if paragraph = strings.TrimSpace(paragraph); paragraph == "" {
+            continue
+        }
+        if len(lines) > 0 {
+            lines = append(lines, "//")
+        }
+        for _, line := range strings.Split(paragraph, "\n") {
+            lines = append(lines, strings.TrimRight("// " + line, " "))
+        }
+    }
+
+    return strings.Join(lines, "\n")
+}
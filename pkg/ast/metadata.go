@@ -1,25 +1,14 @@
 package ast;
 
 import (
-    "os"
-    "fmt"
-
-    "golang.org/x/mod/modfile"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/facts"
 )
 
 func GetModuleName(folder string) (string, error) {
-    goMod := fmt.Sprintf("%v/go.mod", folder)
-
-    data, err := os.ReadFile(goMod)
-    if err != nil {
-        return "", fmt.Errorf("failed to read %v: %v", goMod, err)
-    }
-
-    file, err := modfile.Parse(goMod, data, nil)
+    file, err := facts.ReadGoMod(folder)
     if err != nil {
-        return "", fmt.Errorf("failed to parse %v: %v", goMod, err)
+        return "", err
     }
 
     return file.Module.Mod.Path, nil
 }
-
@@ -480,8 +480,8 @@ func (p *PackageNode) UpdateDocsInFile() error {
             return fmt.Errorf("p.FunctionDeclarations top level object not *ast.FuncDecl")
         }
 
-        // We read in pre-existing docs
-        if fd.Doc != nil {
+        // We read in pre-existing docs, and the model skips obvious ones
+        if fd.Doc != nil || f.Documentation == "" {
             continue
         }
 
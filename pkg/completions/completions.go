@@ -0,0 +1,215 @@
+package completions
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/facts"
+)
+
+
+type Shell string
+
+const (
+    Bash Shell = "bash"
+    Zsh  Shell = "zsh"
+    Fish Shell = "fish"
+)
+
+var SupportedShells = []Shell{
+    Bash,
+    Zsh,
+    Fish,
+}
+
+
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+
+// Generate writes a completion script for program covering flags in the
+// given shell's syntax
+func Generate(shell Shell, program string, flags []facts.FlagDefinition) (string, error) {
+    if program == "" {
+        return "", fmt.Errorf("no program name to generate completions for")
+    }
+
+    switch shell {
+    case Bash:
+        return generateBash(program, flags), nil
+    case Zsh:
+        return generateZsh(program, flags), nil
+    case Fish:
+        return generateFish(program, flags), nil
+    }
+
+    return "", fmt.Errorf("unsupported shell %v", shell)
+}
+
+
+// The standard flag package takes -name. pflag reads -name as the shorthands
+// -n -a -m -e, so its flags are only offered as --name, plus -x for the
+// shorthand.
+func flagSpellings(f facts.FlagDefinition) []string {
+    if !f.Pflag() {
+        return []string{ "-" + f.Name }
+    }
+
+    spellings := []string{ "--" + f.Name }
+    if f.Shorthand != "" {
+        spellings = append(spellings, "-" + f.Shorthand)
+    }
+
+    return spellings
+}
+
+
+func functionName(program string) string {
+    return "_" + nonIdentifier.ReplaceAllString(program, "_")
+}
+
+
+func generateBash(program string, flags []facts.FlagDefinition) string {
+    var b strings.Builder
+    fn := functionName(program) + "_completions"
+
+    fmt.Fprintf(&b, "# bash completion for %v, generated by AutoScribe\n", program)
+    fmt.Fprintf(&b, "# Source this file from ~/.bashrc or drop it in bash-completion's completions dir\n\n")
+    fmt.Fprintf(&b, "%v() {\n", fn)
+    fmt.Fprintf(&b, "    local cur prev\n")
+    fmt.Fprintf(&b, "    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+    fmt.Fprintf(&b, "    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n\n")
+    fmt.Fprintf(&b, "    case \"$prev\" in\n")
+
+    all := []string{}
+
+    for _, f := range flags {
+        all = append(all, flagSpellings(f)...)
+
+        if !f.TakesValue() {
+            continue
+        }
+
+        fmt.Fprintf(&b, "        %v)\n", strings.Join(flagSpellings(f), "|"))
+
+        switch {
+        case len(f.Values) > 0:
+            fmt.Fprintf(&b, "            COMPREPLY=( $(compgen -W %v -- \"$cur\") )\n", shellSingleQuote(strings.Join(f.Values, " ")))
+        case f.PathKind() == facts.DirPath:
+            fmt.Fprintf(&b, "            COMPREPLY=( $(compgen -d -- \"$cur\") )\n")
+        case f.PathKind() == facts.FilePath:
+            fmt.Fprintf(&b, "            COMPREPLY=( $(compgen -f -- \"$cur\") )\n")
+        default:
+            fmt.Fprintf(&b, "            COMPREPLY=()\n")
+        }
+
+        fmt.Fprintf(&b, "            return 0\n")
+        fmt.Fprintf(&b, "            ;;\n")
+    }
+
+    fmt.Fprintf(&b, "    esac\n\n")
+    fmt.Fprintf(&b, "    if [[ \"$cur\" == -* ]]; then\n")
+    fmt.Fprintf(&b, "        COMPREPLY=( $(compgen -W %v -- \"$cur\") )\n", shellSingleQuote(strings.Join(all, " ")))
+    fmt.Fprintf(&b, "        return 0\n")
+    fmt.Fprintf(&b, "    fi\n\n")
+    fmt.Fprintf(&b, "    COMPREPLY=( $(compgen -f -- \"$cur\") )\n")
+    fmt.Fprintf(&b, "}\n\n")
+    fmt.Fprintf(&b, "complete -o filenames -F %v %v\n", fn, program)
+
+    return b.String()
+}
+
+
+func generateZsh(program string, flags []facts.FlagDefinition) string {
+    var b strings.Builder
+    fn := functionName(program)
+
+    fmt.Fprintf(&b, "#compdef %v\n", program)
+    fmt.Fprintf(&b, "# zsh completion for %v, generated by AutoScribe\n\n", program)
+    fmt.Fprintf(&b, "%v() {\n", fn)
+    fmt.Fprintf(&b, "    _arguments \\\n")
+
+    for _, f := range flags {
+        action := ""
+
+        if f.TakesValue() {
+            switch {
+            case len(f.Values) > 0:
+                action = fmt.Sprintf(":%v:(%v)", f.Name, strings.Join(f.Values, " "))
+            case f.PathKind() == facts.DirPath:
+                action = ":directory:_files -/"
+            case f.PathKind() == facts.FilePath:
+                action = ":file:_files"
+            default:
+                action = fmt.Sprintf(":%v: ", f.Name)
+            }
+        }
+
+        for _, spelling := range flagSpellings(f) {
+            spec := fmt.Sprintf("%v[%v]%v", spelling, zshEscape(f.Usage), action)
+            fmt.Fprintf(&b, "        %v \\\n", shellSingleQuote(spec))
+        }
+    }
+
+    fmt.Fprintf(&b, "        '*:file:_files'\n")
+    fmt.Fprintf(&b, "}\n\n")
+    fmt.Fprintf(&b, "if [ \"$funcstack[1]\" = \"%v\" ]; then\n", fn)
+    fmt.Fprintf(&b, "    %v \"$@\"\n", fn)
+    fmt.Fprintf(&b, "else\n")
+    fmt.Fprintf(&b, "    compdef %v %v\n", fn, program)
+    fmt.Fprintf(&b, "fi\n")
+
+    return b.String()
+}
+
+
+func generateFish(program string, flags []facts.FlagDefinition) string {
+    var b strings.Builder
+
+    fmt.Fprintf(&b, "# fish completion for %v, generated by AutoScribe\n\n", program)
+
+    for _, f := range flags {
+        // -o is fish's old style -name, -l its --name
+        option := fmt.Sprintf("-o %v", fishQuote(f.Name))
+        if f.Pflag() {
+            option = fmt.Sprintf("-l %v", fishQuote(f.Name))
+            if f.Shorthand != "" {
+                option += fmt.Sprintf(" -s %v", fishQuote(f.Shorthand))
+            }
+        }
+
+        line := fmt.Sprintf("complete -c %v %v -d %v", program, option, fishQuote(f.Usage))
+
+        if f.TakesValue() {
+            switch {
+            case len(f.Values) > 0:
+                line += fmt.Sprintf(" -x -a %v", fishQuote(strings.Join(f.Values, " ")))
+            case f.PathKind() == facts.DirPath:
+                line += " -x -a '(__fish_complete_directories (commandline -ct))'"
+            case f.PathKind() == facts.FilePath:
+                line += " -r -F"
+            default:
+                line += " -x"
+            }
+        }
+
+        fmt.Fprintf(&b, "%v\n", line)
+    }
+
+    return b.String()
+}
+
+
+func shellSingleQuote(s string) string {
+    return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+
+func fishQuote(s string) string {
+    return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+
+func zshEscape(s string) string {
+    return strings.NewReplacer(`[`, `\[`, `]`, `\]`, `:`, `\:`).Replace(s)
+}
@@ -0,0 +1,91 @@
+package completions
+
+import (
+    "strings"
+    "testing"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/facts"
+)
+
+
+var stdFlag = facts.FlagDefinition{ Name: "config", Type: "string", Usage: "Config file", Package: "flag" }
+var pflagLong = facts.FlagDefinition{ Name: "verbose", Type: "bool", Usage: "Log more", Package: facts.PflagPackage }
+var pflagShort = facts.FlagDefinition{ Name: "output", Shorthand: "o", Type: "string", Values: []string{ "text", "json" }, Usage: "Format", Package: facts.PflagPackage }
+
+
+func TestFlagSpellings(t *testing.T) {
+    tests := []struct {
+        name string
+        flag facts.FlagDefinition
+        want []string
+    }{
+        { name: "standard flag", flag: stdFlag, want: []string{ "-config" } },
+        { name: "pflag without a shorthand", flag: pflagLong, want: []string{ "--verbose" } },
+        { name: "pflag with a shorthand", flag: pflagShort, want: []string{ "--output", "-o" } },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := flagSpellings(tt.flag)
+            if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+                t.Errorf("flagSpellings() = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+
+func TestGenerate(t *testing.T) {
+    flags := []facts.FlagDefinition{ stdFlag, pflagLong, pflagShort }
+
+    tests := []struct {
+        shell   Shell
+        want    []string
+        notWant []string
+    }{
+        {
+            shell:   Bash,
+            want:    []string{ "'-config --verbose --output -o'", "--output|-o)", "compgen -W 'text json'", "complete -o filenames -F _tool_completions tool" },
+            notWant: []string{ " -verbose", "--config" },
+        },
+        {
+            shell:   Zsh,
+            want:    []string{ "'-config[Config file]:file:_files'", "'--verbose[Log more]'", "'-o[Format]:output:(text json)'" },
+            notWant: []string{ "'-verbose[", "'--config[" },
+        },
+        {
+            shell:   Fish,
+            want:    []string{ "complete -c tool -o 'config' -d 'Config file' -r -F", "complete -c tool -l 'verbose' -d 'Log more'\n", "complete -c tool -l 'output' -s 'o' -d 'Format' -x -a 'text json'" },
+            notWant: []string{ "-o 'verbose'", "-o 'output'" },
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(string(tt.shell), func(t *testing.T) {
+            got, err := Generate(tt.shell, "tool", flags)
+            if err != nil {
+                t.Fatalf("Generate() error = %v", err)
+            }
+            for _, w := range tt.want {
+                if !strings.Contains(got, w) {
+                    t.Errorf("Generate() is missing %q in\n%v", w, got)
+                }
+            }
+            for _, w := range tt.notWant {
+                if strings.Contains(got, w) {
+                    t.Errorf("Generate() has %q in\n%v", w, got)
+                }
+            }
+        })
+    }
+}
+
+
+func TestGenerateErrors(t *testing.T) {
+    if _, err := Generate(Bash, "", nil); err == nil {
+        t.Error("Generate() without a program name succeeded")
+    }
+    if _, err := Generate(Shell("tcsh"), "tool", nil); err == nil {
+        t.Error("Generate() for an unsupported shell succeeded")
+    }
+}
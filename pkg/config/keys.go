@@ -0,0 +1,219 @@
+package config;
+
+import (
+    "os"
+    "fmt"
+    "time"
+    "bytes"
+    "context"
+    "os/exec"
+    "runtime"
+    "strings"
+    "path/filepath"
+
+    log "github.com/sirupsen/logrus"
+)
+
+
+// Settings the API key can come from. Only one is used: a layer setting any
+// of them clears the others set by the layers before it
+var credentialKeys = []string{ "OPENAI_API_KEY", "KEY_COMMAND", "KEY_FILE", "KEYRING" }
+
+// Service the API key is stored under in the OS keyring
+const KeyringService = "autoscribe"
+
+// How long KEY_COMMAND gets, e.g. to unlock a password manager
+var KeyCommandTimeout = 2 * time.Minute
+
+
+/**
+ * ResolveKey sets OpenAIKey from whichever of OPENAI_API_KEY, KEY_COMMAND,
+ * KEY_FILE or KEYRING the config names. The key is never logged: once it's
+ * known, every log line is scrubbed of it.
+ */
+func ResolveKey() error {
+    var err error
+
+    switch {
+    case OpenAIKey != "":
+    case KeyCommand != "":
+        OpenAIKey, err = keyFromCommand(KeyCommand)
+    case KeyFile != "":
+        OpenAIKey, err = keyFromFile(KeyFile)
+    case KeyringAccount != "":
+        OpenAIKey, err = keyFromKeyring(KeyringAccount)
+    default:
+        return fmt.Errorf("no OpenAI API key, set one of %v in a config file or the env", strings.Join(credentialKeys, ", "))
+    }
+
+    if err != nil {
+        return err
+    }
+
+    if OpenAIKey == "" {
+        return fmt.Errorf("the OpenAI API key from %v is empty", Sources[credentialSource()])
+    }
+
+    log.AddHook(&redactHook{})
+
+    return nil
+}
+
+
+func credentialSource() string {
+    switch {
+    case KeyCommand != "":
+        return "KEY_COMMAND"
+    case KeyFile != "":
+        return "KEY_FILE"
+    case KeyringAccount != "":
+        return "KEYRING"
+    }
+
+    return "OPENAI_API_KEY"
+}
+
+
+// keyFromCommand runs command with the shell and reads the key from the first
+// line it prints, as password managers like pass print metadata after it.
+// The output is never put in an error
+func keyFromCommand(command string) (string, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), KeyCommandTimeout)
+    defer cancel()
+
+    var cmd *exec.Cmd
+    if runtime.GOOS == "windows" {
+        cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+    } else {
+        cmd = exec.CommandContext(ctx, "sh", "-c", command)
+    }
+    // Lets the command prompt for a passphrase
+    cmd.Stdin = os.Stdin
+    cmd.Stderr = os.Stderr
+
+    log.Debugf("Reading the API key from KEY_COMMAND %v", command)
+
+    out, err := cmd.Output()
+    if err != nil {
+        return "", fmt.Errorf("failed to run KEY_COMMAND %v: %v", command, err)
+    }
+
+    line, _, _ := strings.Cut(string(out), "\n")
+
+    return strings.TrimSpace(line), nil
+}
+
+
+func keyFromFile(path string) (string, error) {
+    path = expandHome(path)
+
+    info, err := os.Stat(path)
+    if err != nil {
+        return "", fmt.Errorf("failed to read KEY_FILE: %v", err)
+    }
+    warnIfReadable(path, info)
+
+    log.Debugf("Reading the API key from KEY_FILE %v", path)
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return "", fmt.Errorf("failed to read KEY_FILE: %v", err)
+    }
+
+    return strings.TrimSpace(string(data)), nil
+}
+
+
+/**
+ * keyFromKeyring looks account up under KeyringService in the OS keyring.
+ * On Linux and the BSDs this goes through secret-tool, which talks to any
+ * Secret Service provider (GNOME Keyring, KWallet, KeePassXC). On macOS it
+ * uses the login keychain.
+ */
+func keyFromKeyring(account string) (string, error) {
+    var cmd *exec.Cmd
+
+    switch runtime.GOOS {
+    case "darwin":
+        cmd = exec.Command("security", "find-generic-password", "-s", KeyringService, "-a", account, "-w")
+    case "windows":
+        return "", fmt.Errorf("KEYRING isn't supported on windows, use KEY_COMMAND or KEY_FILE")
+    default:
+        cmd = exec.Command("secret-tool", "lookup", "service", KeyringService, "account", account)
+    }
+
+    log.Debugf("Reading the API key from the %v keyring entry %v", KeyringService, account)
+
+    var stderr bytes.Buffer
+    cmd.Stderr = &stderr
+
+    out, err := cmd.Output()
+    if err != nil {
+        if msg := strings.TrimSpace(stderr.String()); msg != "" {
+            err = fmt.Errorf("%v: %v", err, msg)
+        }
+        return "", fmt.Errorf("failed to find the API key in the keyring under service %v account %v: %v", KeyringService, account, err)
+    }
+
+    return strings.TrimSpace(string(out)), nil
+}
+
+
+// warnIfReadable warns when a file holding a key can be read by other users
+func warnIfReadable(path string, info os.FileInfo) {
+    if runtime.GOOS == "windows" {
+        return
+    }
+
+    if info.Mode().Perm() & 0o004 != 0 {
+        log.Warnf("%v holds an API key but is readable by every user, run chmod 600 %v", path, path)
+    } else if info.Mode().Perm() & 0o040 != 0 {
+        log.Debugf("%v holds an API key and is readable by its group", path)
+    }
+}
+
+
+func expandHome(path string) string {
+    if path != "~" && !strings.HasPrefix(path, "~/") {
+        return path
+    }
+
+    home, err := os.UserHomeDir()
+    if err != nil {
+        return path
+    }
+
+    return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+
+// Redact replaces the API key in s, for output which might include it
+func Redact(s string) string {
+    if len(OpenAIKey) < 8 {
+        return s
+    }
+
+    return strings.ReplaceAll(s, OpenAIKey, "[redacted]")
+}
+
+
+// redactHook scrubs the API key from every log line and its fields
+type redactHook struct{}
+
+func (h *redactHook) Levels() []log.Level {
+    return log.AllLevels
+}
+
+func (h *redactHook) Fire(entry *log.Entry) error {
+    entry.Message = Redact(entry.Message)
+
+    for k, v := range entry.Data {
+        if s, ok := v.(string); ok {
+            entry.Data[k] = Redact(s)
+        } else if err, ok := v.(error); ok {
+            entry.Data[k] = Redact(err.Error())
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+    "os"
+    "bytes"
+    "runtime"
+    "strings"
+    "testing"
+    "path/filepath"
+
+    log "github.com/sirupsen/logrus"
+)
+
+
+// credentials sets the places the key can come from, and puts back the ones
+// before once the test is done
+func credentials(t *testing.T, key, command, file, keyring string) {
+    t.Helper()
+
+    openAIKey, keyCommand, keyFile, keyringAccount := OpenAIKey, KeyCommand, KeyFile, KeyringAccount
+    t.Cleanup(func() {
+        OpenAIKey, KeyCommand, KeyFile, KeyringAccount = openAIKey, keyCommand, keyFile, keyringAccount
+    })
+
+    OpenAIKey, KeyCommand, KeyFile, KeyringAccount = key, command, file, keyring
+}
+
+
+// fakeKeyring puts a secret-tool printing key first on the PATH
+func fakeKeyring(t *testing.T, key string) {
+    t.Helper()
+
+    if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+        t.Skip("the keyring is read with secret-tool on Linux and the BSDs only")
+    }
+
+    dir := t.TempDir()
+    if err := os.WriteFile(filepath.Join(dir, "secret-tool"), []byte("#!/bin/sh\necho " + key + "\n"), 0755); err != nil {
+        t.Fatal(err)
+    }
+    t.Setenv("PATH", dir + string(os.PathListSeparator) + os.Getenv("PATH"))
+}
+
+
+func writeKey(t *testing.T, content string, perm os.FileMode) string {
+    t.Helper()
+
+    path := filepath.Join(t.TempDir(), "openai.key")
+    if err := os.WriteFile(path, []byte(content), perm); err != nil {
+        t.Fatal(err)
+    }
+    // WriteFile's mode is cut down by the umask
+    if err := os.Chmod(path, perm); err != nil {
+        t.Fatal(err)
+    }
+
+    return path
+}
+
+
+func TestResolveKey(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("KEY_COMMAND runs with sh")
+    }
+    fakeKeyring(t, "sk-keyring")
+    file := writeKey(t, "sk-file\n", 0600)
+
+    tests := []struct {
+        name    string
+        key     string
+        command string
+        file    string
+        keyring string
+        want    string
+        wantErr string
+    }{
+        { name: "env wins", key: "sk-env", command: "echo sk-command", file: file, keyring: "openai", want: "sk-env" },
+        { name: "then KEY_COMMAND", command: "echo sk-command", file: file, keyring: "openai", want: "sk-command" },
+        { name: "then KEY_FILE", file: file, keyring: "openai", want: "sk-file" },
+        { name: "then KEYRING", keyring: "openai", want: "sk-keyring" },
+        // pass prints metadata after the password
+        { name: "first line", command: "printf 'sk-first\\nlogin: me\\n'", want: "sk-first" },
+        { name: "none", wantErr: "no OpenAI API key" },
+        { name: "command fails", command: "exit 3", wantErr: "failed to run KEY_COMMAND" },
+        { name: "empty command", command: "echo", wantErr: "is empty" },
+        { name: "missing file", file: filepath.Join(t.TempDir(), "missing"), wantErr: "failed to read KEY_FILE" },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            credentials(t, tt.key, tt.command, tt.file, tt.keyring)
+
+            err := ResolveKey()
+            if tt.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                    t.Errorf("ResolveKey() error = %v, want %v", err, tt.wantErr)
+                }
+                return
+            }
+
+            if err != nil || OpenAIKey != tt.want {
+                t.Errorf("ResolveKey() = %v, %v, want %v", OpenAIKey, err, tt.want)
+            }
+        })
+    }
+}
+
+
+func TestResolveKeyReadableFile(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("no file modes on windows")
+    }
+
+    var logged bytes.Buffer
+    log.SetOutput(&logged)
+    defer log.SetOutput(os.Stderr)
+
+    tests := []struct {
+        perm os.FileMode
+        warn bool
+    }{
+        { perm: 0600, warn: false },
+        { perm: 0640, warn: false },
+        { perm: 0644, warn: true },
+    }
+
+    for _, tt := range tests {
+        logged.Reset()
+        credentials(t, "", "", writeKey(t, "sk-file-key", tt.perm), "")
+
+        if err := ResolveKey(); err != nil {
+            t.Fatalf("ResolveKey() error = %v", err)
+        }
+
+        if warned := strings.Contains(logged.String(), "readable by every user"); warned != tt.warn {
+            t.Errorf("ResolveKey() of a %v file warned = %v, want %v", tt.perm, warned, tt.warn)
+        }
+    }
+}
+
+
+// Once the key is known it never shows up in the logs
+func TestResolveKeyRedactsLogs(t *testing.T) {
+    var logged bytes.Buffer
+    log.SetOutput(&logged)
+    defer log.SetOutput(os.Stderr)
+
+    credentials(t, "sk-secret-key", "", "", "")
+    if err := ResolveKey(); err != nil {
+        t.Fatalf("ResolveKey() error = %v", err)
+    }
+
+    log.WithField("key", "sk-secret-key").Warnf("sent sk-secret-key")
+    if strings.Contains(logged.String(), "sk-secret-key") {
+        t.Errorf("logged %q", logged.String())
+    }
+}
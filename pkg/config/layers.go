@@ -0,0 +1,546 @@
+package config;
+
+import (
+    "os"
+    "fmt"
+    "flag"
+    "sort"
+    "slices"
+    "reflect"
+    "strconv"
+    "strings"
+    "maps"
+    "crypto/sha256"
+    "encoding/hex"
+    "path/filepath"
+    "gopkg.in/yaml.v3"
+
+    log "github.com/sirupsen/logrus"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+)
+
+
+// Prefix of the env vars overriding a setting, e.g. AUTOSCRIBE_MODEL
+const EnvPrefix = "AUTOSCRIBE_"
+
+// Name of the config file read from the project directory
+const ProjectConfigName = ".autoscribe.yaml"
+
+// Source of a setting no layer set
+const SourceDefault = "default"
+
+
+// The flag overriding each setting. Flags for INCLUDE and EXCLUDE add to the
+// globs from the other layers rather than replacing them
+var flagKeys = map[string]string{
+    "MODEL":            "model",
+    "LANGUAGES":        "l",
+    "INCLUDE":          "include",
+    "EXCLUDE":          "exclude",
+    "OUTPUT_DIRECTORY": "o",
+    "CACHE_DIRECTORY":  "cache",
+    "CONTEXT_BUDGET":   "budget",
+    "PARALLELISM":      "j",
+    "PROMPT":           "p",
+    "MAX_COST":         "max-cost",
+    "MAX_TOKENS":       "max-tokens",
+}
+
+// Settings whose values add up across the layers instead of the last one
+// winning. Maps are merged key by key
+var accumulated = []string{ "INCLUDE", "EXCLUDE", "PROMPTS", "DOC_STYLE", "CONTEXT_BUDGETS", "REDACT_PATTERNS", "REDACT_SKIP", "DENY", "PRICES" }
+
+// Settings which are never printed
+var secrets = []string{ "OPENAI_API_KEY" }
+
+// The flags given on the command line, by name
+var setFlags = map[string]bool{}
+
+// Sources is where the value of each setting came from, by key: default, a
+// config file, an env var or a flag
+var Sources = map[string]string{}
+
+
+func defaults() *Config {
+    return &Config{
+        PROVIDER:         "openai",
+        MODEL:            "gpt-4.1-nano",
+        LANGUAGES:        []string{ string(types.Shell) },
+        OUTPUT_DIRECTORY: "./",
+        PARALLELISM:      4,
+        PRICES:           maps.Clone(DefaultPrices),
+    }
+}
+
+
+func recordFlags(fs *flag.FlagSet) {
+    fs.Visit(func(f *flag.Flag) {
+        setFlags[f.Name] = true
+    })
+}
+
+
+// Keys lists the settings in the order they're shown
+func Keys() []string {
+    t := reflect.TypeOf(Config{})
+
+    keys := []string{}
+    for i := 0; i < t.NumField(); i++ {
+        keys = append(keys, t.Field(i).Tag.Get("yaml"))
+    }
+
+    return keys
+}
+
+
+// values maps each key to its field in c, so layers can be merged generically
+func (c *Config) values() map[string]reflect.Value {
+    v := reflect.ValueOf(c).Elem()
+
+    values := map[string]reflect.Value{}
+    for i, key := range Keys() {
+        values[key] = v.Field(i)
+    }
+
+    return values
+}
+
+
+// UserConfigFile is the config file of the user, ~/.config/autoscribe/config.yaml
+// on Linux. Empty when there's no home directory to put it in
+func UserConfigFile() string {
+    dir, err := os.UserConfigDir()
+    if err != nil {
+        return ""
+    }
+
+    return filepath.Join(dir, "autoscribe", "config.yaml")
+}
+
+
+func ProjectConfigFile() string {
+    return filepath.Join(ProjectDirectory, ProjectConfigName)
+}
+
+
+// ProjectCacheDirectory is where what's kept between runs on the current
+// project goes: a directory per project under CacheDirectory, or under the
+// user cache directory when that isn't set
+func ProjectCacheDirectory() (string, error) {
+    abs, err := filepath.Abs(ProjectDirectory)
+    if err != nil {
+        return "", fmt.Errorf("failed to resolve %v: %v", ProjectDirectory, err)
+    }
+
+    dir := CacheDirectory
+    if dir == "" {
+        userCache, err := os.UserCacheDir()
+        if err != nil {
+            return "", fmt.Errorf("failed to find a cache directory, set one with -cache: %v", err)
+        }
+        dir = filepath.Join(userCache, "autoscribe")
+    }
+
+    project := sha256.Sum256([]byte(abs))
+
+    return filepath.Join(dir, hex.EncodeToString(project[:8])), nil
+}
+
+
+/**
+ * Load reads the settings in layers, each overriding the one before it:
+ * the system config file (ConfigFile, set with -c), the user config file,
+ * .autoscribe.yaml in the project directory, AUTOSCRIBE_ env vars and
+ * finally the flags given on the command line. Files which don't exist are
+ * skipped. OPENAI_API_KEY is also read from the env without the prefix.
+ * KEY_COMMAND is only run when the key is needed.
+ *
+ * Flags must be parsed first, since they pick the project directory. The
+ * result is applied to the package globals and where each value came from
+ * is kept in Sources. Any mistake in a layer fails with a *ConfigError
+ * listing all of them. needsKey makes a missing API key an error.
+ */
+func Load(needsKey bool) error {
+    cfg, problems := load()
+    if len(problems) > 0 {
+        return &ConfigError{ Problems: problems }
+    }
+
+    if err := cfg.apply(); err != nil {
+        return err
+    }
+
+    if needsKey {
+        return ResolveKey()
+    }
+
+    return nil
+}
+
+
+// load merges every layer but the flags, returning the mistakes found
+func load() (*Config, []Problem) {
+    cfg := defaults()
+    for _, key := range Keys() {
+        Sources[key] = SourceDefault
+    }
+
+    problems := []Problem{}
+    for _, path := range []string{ ConfigFile, UserConfigFile(), ProjectConfigFile() } {
+        problems = append(problems, cfg.mergeFile(path)...)
+    }
+
+    problems = append(problems, cfg.mergeEnv()...)
+
+    return cfg, problems
+}
+
+
+func (c *Config) mergeFile(path string) []Problem {
+    if path == "" {
+        return nil
+    }
+
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        log.Debugf("No config at %v", path)
+        return nil
+    } else if err != nil {
+        return []Problem{{ Source: path, Message: fmt.Sprintf("failed to read config: %v", err) }}
+    }
+
+    log.Debugf("Loading config from %v", path)
+
+    var layer Config
+    set, problems := decodeFile(path, data, &layer)
+
+    if slices.Contains(set, "OPENAI_API_KEY") && layer.OPENAI_API_KEY != "" {
+        if info, err := os.Stat(path); err == nil {
+            warnIfReadable(path, info)
+        }
+    }
+
+    c.merge(&layer, path, func(key string) bool {
+        return slices.Contains(set, key)
+    })
+
+    return problems
+}
+
+
+func (c *Config) mergeEnv() []Problem {
+    problems := unknownEnv()
+
+    for _, key := range Keys() {
+        names := []string{ EnvPrefix + key }
+        if key == "OPENAI_API_KEY" {
+            names = []string{ key, EnvPrefix + key }
+        }
+
+        for _, name := range names {
+            v, ok := os.LookupEnv(name)
+            if !ok {
+                continue
+            }
+
+            var layer Config
+            err := layer.parse(key, v)
+            if err == nil {
+                if msg := checkValue(key, &layer); msg != "" {
+                    err = fmt.Errorf("%v", msg)
+                }
+            }
+            if err != nil {
+                problems = append(problems, Problem{ Source: "env " + name, Message: err.Error() })
+                continue
+            }
+
+            c.merge(&layer, "env " + name, func(k string) bool { return k == key })
+        }
+    }
+
+    return problems
+}
+
+
+// parse sets the setting key from the text of an env var. Lists are comma
+// separated and maps are written as yaml, e.g. {py: "Use numpy style"}
+func (c *Config) parse(key string, v string) error {
+    field := c.values()[key]
+
+    switch field.Kind() {
+    case reflect.String:
+        field.SetString(v)
+    case reflect.Int:
+        n, err := strconv.Atoi(strings.TrimSpace(v))
+        if err != nil {
+            return fmt.Errorf("%v must be a whole number, got %v", key, v)
+        }
+        field.SetInt(int64(n))
+    case reflect.Float64:
+        n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+        if err != nil {
+            return fmt.Errorf("%v must be a number, got %v", key, v)
+        }
+        field.SetFloat(n)
+    case reflect.Slice:
+        list := []string{}
+        for _, item := range strings.Split(v, ",") {
+            if strings.TrimSpace(item) != "" {
+                list = append(list, strings.TrimSpace(item))
+            }
+        }
+        field.Set(reflect.ValueOf(list))
+    case reflect.Map:
+        if err := yaml.Unmarshal([]byte(v), field.Addr().Interface()); err != nil {
+            return fmt.Errorf("%v must be a yaml map, e.g. {key: value}: %v", key, err)
+        }
+    }
+
+    return nil
+}
+
+
+// merge copies the settings of layer which set reports into c, recording
+// source as where they came from
+func (c *Config) merge(layer *Config, source string, set func(key string) bool) {
+    dst, src := c.values(), layer.values()
+
+    for _, key := range Keys() {
+        if !set(key) {
+            continue
+        }
+
+        // An empty key, like the one in the default system config, doesn't
+        // replace a way of getting it set before
+        if slices.Contains(credentialKeys, key) && src[key].String() != "" {
+            for _, other := range credentialKeys {
+                dst[other].SetString("")
+                Sources[other] = SourceDefault
+            }
+        }
+
+        if ! slices.Contains(accumulated, key) {
+            dst[key].Set(src[key])
+            Sources[key] = source
+            continue
+        }
+
+        switch dst[key].Kind() {
+        case reflect.Slice:
+            dst[key].Set(reflect.AppendSlice(dst[key], src[key]))
+        case reflect.Map:
+            if dst[key].IsNil() {
+                dst[key].Set(reflect.MakeMap(dst[key].Type()))
+            }
+            for _, k := range src[key].MapKeys() {
+                dst[key].SetMapIndex(k, src[key].MapIndex(k))
+            }
+        }
+        addSource(key, source)
+    }
+}
+
+
+func addSource(key string, source string) {
+    if Sources[key] == "" || Sources[key] == SourceDefault {
+        Sources[key] = source
+    } else {
+        Sources[key] += ", " + source
+    }
+}
+
+
+// flagged reports whether the flag overriding key was given, in which case
+// the global already holds its value
+func flagged(key string) bool {
+    name, ok := flagKeys[key]
+    if !ok || !setFlags[name] {
+        return false
+    }
+
+    if slices.Contains(accumulated, key) {
+        addSource(key, "flag -" + name)
+    } else {
+        Sources[key] = "flag -" + name
+    }
+
+    return true
+}
+
+
+// apply sets the package globals from the merged layers, leaving the ones
+// given as flags
+func (c *Config) apply() error {
+    Provider = c.PROVIDER
+
+    OpenAIKey = c.OPENAI_API_KEY
+    KeyCommand = c.KEY_COMMAND
+    KeyFile = c.KEY_FILE
+    KeyringAccount = c.KEYRING
+
+    if !flagged("MODEL") {
+        Model = c.MODEL
+    }
+
+    if !flagged("LANGUAGES") {
+        languages, err := types.ParseFormats(strings.Join(c.LANGUAGES, ","))
+        if err != nil {
+            return fmt.Errorf("failed to read LANGUAGES from %v: %v", Sources["LANGUAGES"], err)
+        }
+        Languages = languages
+    }
+
+    // Flag globs are already in the globals, the config ones go first
+    flagged("INCLUDE")
+    IncludeGlobs = append(slices.Clone(c.INCLUDE), IncludeGlobs...)
+    flagged("EXCLUDE")
+    ExcludeGlobs = append(slices.Clone(c.EXCLUDE), ExcludeGlobs...)
+
+    if !flagged("OUTPUT_DIRECTORY") {
+        OutputDirectory = c.OUTPUT_DIRECTORY
+    }
+
+    if !flagged("CACHE_DIRECTORY") {
+        CacheDirectory = c.CACHE_DIRECTORY
+    }
+
+    // -budget wins over everything, then a budget for the model in use
+    ContextBudgets = maps.Clone(c.CONTEXT_BUDGETS)
+    if !flagged("CONTEXT_BUDGET") {
+        ContextBudget = c.CONTEXT_BUDGET
+        if budget, ok := c.CONTEXT_BUDGETS[Model]; ok {
+            ContextBudget = budget
+            Sources["CONTEXT_BUDGET"] = Sources["CONTEXT_BUDGETS"]
+        }
+    }
+
+    if !flagged("PARALLELISM") {
+        Parallelism = c.PARALLELISM
+    }
+
+    if !flagged("PROMPT") {
+        AdditionalPrompt = c.PROMPT
+    }
+
+    PromptOverrides = map[string]string{}
+    for name, prompt := range c.PROMPTS {
+        PromptOverrides[name] = prompt
+    }
+
+    DocStyles = map[string]string{}
+    for format, style := range c.DOC_STYLE {
+        DocStyles[format] = style
+    }
+
+    RedactPatterns = map[string]string{}
+    for name, pattern := range c.REDACT_PATTERNS {
+        RedactPatterns[name] = pattern
+    }
+    RedactSkip = slices.Clone(c.REDACT_SKIP)
+    DenyGlobs = slices.Clone(c.DENY)
+
+    Prices = maps.Clone(c.PRICES)
+
+    if !flagged("MAX_COST") {
+        MaxCost = c.MAX_COST
+    }
+
+    if !flagged("MAX_TOKENS") {
+        MaxTokens = c.MAX_TOKENS
+    }
+
+    return nil
+}
+
+
+// Effective is the config in use: the merged layers with the flags applied
+func Effective() *Config {
+    languages := []string{}
+    for _, format := range Languages {
+        languages = append(languages, string(format))
+    }
+    if len(languages) == 0 {
+        languages = []string{ "auto" }
+    }
+
+    return &Config{
+        PROVIDER:         Provider,
+        MODEL:            Model,
+        OPENAI_API_KEY:   OpenAIKey,
+        KEY_COMMAND:      KeyCommand,
+        KEY_FILE:         KeyFile,
+        KEYRING:          KeyringAccount,
+        LANGUAGES:        languages,
+        INCLUDE:          IncludeGlobs,
+        EXCLUDE:          ExcludeGlobs,
+        OUTPUT_DIRECTORY: OutputDirectory,
+        CACHE_DIRECTORY:  CacheDirectory,
+        CONTEXT_BUDGET:   ContextBudget,
+        CONTEXT_BUDGETS:  ContextBudgets,
+        PARALLELISM:      Parallelism,
+        PROMPT:           AdditionalPrompt,
+        PROMPTS:          PromptOverrides,
+        DOC_STYLE:        DocStyles,
+        REDACT_PATTERNS:  RedactPatterns,
+        REDACT_SKIP:      RedactSkip,
+        DENY:             DenyGlobs,
+        PRICES:           Prices,
+        MAX_COST:         MaxCost,
+        MAX_TOKENS:       MaxTokens,
+    }
+}
+
+
+// Setting is one value of the effective config, as shown by config show
+type Setting struct {
+    Key    string `json:"key"`
+    Value  any    `json:"value"`
+    Source string `json:"source"`
+}
+
+
+// Settings lists the effective config with where each value came from.
+// Secrets are replaced by whether they're set
+func Settings() []Setting {
+    values := Effective().values()
+
+    settings := []Setting{}
+    for _, key := range Keys() {
+        var value any = values[key].Interface()
+        if slices.Contains(secrets, key) {
+            value = values[key].String() != ""
+        }
+
+        settings = append(settings, Setting{ Key: key, Value: value, Source: Sources[key] })
+    }
+
+    return settings
+}
+
+
+// String renders the value of s on one line. Maps list only their keys,
+// prompts and prices being too long to show
+func (s Setting) String() string {
+    switch v := s.Value.(type) {
+    case bool:
+        if v {
+            return "(set)"
+        }
+        return "(not set)"
+    case []string:
+        return strings.Join(v, ", ")
+    }
+
+    if v := reflect.ValueOf(s.Value); v.Kind() == reflect.Map {
+        keys := []string{}
+        for _, k := range v.MapKeys() {
+            keys = append(keys, k.String())
+        }
+        sort.Strings(keys)
+        return strings.Join(keys, ", ")
+    }
+
+    return fmt.Sprint(s.Value)
+}
@@ -0,0 +1,264 @@
+package config
+
+import (
+    "os"
+    "slices"
+    "strings"
+    "testing"
+    "path/filepath"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+)
+
+
+// configLayers gives each test its own system, user and project config
+// files and an env without any settings, and restores the globals Load sets
+func configLayers(t *testing.T) (string, string, string) {
+    t.Helper()
+
+    dir := t.TempDir()
+    system := filepath.Join(dir, "system.yaml")
+    user := filepath.Join(dir, "user", "autoscribe", "config.yaml")
+    project := filepath.Join(dir, "project", ProjectConfigName)
+    for _, path := range []string{ user, project } {
+        if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "user"))
+    for _, entry := range os.Environ() {
+        name, value, _ := strings.Cut(entry, "=")
+        if strings.HasPrefix(name, EnvPrefix) || name == "OPENAI_API_KEY" {
+            t.Setenv(name, "")
+            os.Unsetenv(name)
+            t.Cleanup(func() { os.Setenv(name, value) })
+        }
+    }
+
+    saved := *Effective()
+    savedConfig, savedProject := ConfigFile, ProjectDirectory
+    t.Cleanup(func() {
+        ConfigFile, ProjectDirectory = savedConfig, savedProject
+        saved.restore()
+        setFlags = map[string]bool{}
+        Sources = map[string]string{}
+    })
+
+    ConfigFile, ProjectDirectory = system, filepath.Dir(project)
+    setFlags = map[string]bool{}
+    IncludeGlobs, ExcludeGlobs = []string{}, []string{}
+
+    return system, user, project
+}
+
+
+// restore puts the globals Effective read back
+func (c Config) restore() {
+    Provider, Model, OpenAIKey = c.PROVIDER, c.MODEL, c.OPENAI_API_KEY
+    KeyCommand, KeyFile, KeyringAccount = c.KEY_COMMAND, c.KEY_FILE, c.KEYRING
+    languages, _ := types.ParseFormats(strings.Join(c.LANGUAGES, ","))
+    Languages = languages
+    IncludeGlobs, ExcludeGlobs = c.INCLUDE, c.EXCLUDE
+    OutputDirectory, CacheDirectory = c.OUTPUT_DIRECTORY, c.CACHE_DIRECTORY
+    ContextBudget, ContextBudgets, Parallelism, AdditionalPrompt = c.CONTEXT_BUDGET, c.CONTEXT_BUDGETS, c.PARALLELISM, c.PROMPT
+    PromptOverrides, DocStyles = c.PROMPTS, c.DOC_STYLE
+    RedactPatterns, RedactSkip, DenyGlobs = c.REDACT_PATTERNS, c.REDACT_SKIP, c.DENY
+    Prices, MaxCost, MaxTokens = c.PRICES, c.MAX_COST, c.MAX_TOKENS
+}
+
+
+func writeConfig(t *testing.T, path string, content string) {
+    t.Helper()
+
+    if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+        t.Fatal(err)
+    }
+}
+
+
+func TestLoadLayerOrder(t *testing.T) {
+    system, user, project := configLayers(t)
+
+    writeConfig(t, system, "MODEL: system\nPARALLELISM: 2\nOUTPUT_DIRECTORY: /system\nCONTEXT_BUDGET: 100\n")
+    writeConfig(t, user, "MODEL: user\nPARALLELISM: 3\nOUTPUT_DIRECTORY: /user\n")
+    writeConfig(t, project, "MODEL: project\nPARALLELISM: 5\n")
+    t.Setenv("AUTOSCRIBE_MODEL", "env")
+
+    // Flags are parsed before Load, straight into the globals
+    Model = "flag"
+    setFlags["model"] = true
+
+    if err := Load(false); err != nil {
+        t.Fatalf("Load() error = %v", err)
+    }
+
+    tests := []struct {
+        key    string
+        got    any
+        want   any
+        source string
+    }{
+        { key: "MODEL", got: Model, want: "flag", source: "flag -model" },
+        { key: "PARALLELISM", got: Parallelism, want: 5, source: project },
+        { key: "OUTPUT_DIRECTORY", got: OutputDirectory, want: "/user", source: user },
+        { key: "CONTEXT_BUDGET", got: ContextBudget, want: 100, source: system },
+        { key: "PROVIDER", got: Provider, want: "openai", source: SourceDefault },
+    }
+
+    for _, tt := range tests {
+        if tt.got != tt.want || Sources[tt.key] != tt.source {
+            t.Errorf("%v = %v from %v, want %v from %v", tt.key, tt.got, Sources[tt.key], tt.want, tt.source)
+        }
+    }
+
+    // Without the flag the env wins
+    setFlags = map[string]bool{}
+    if err := Load(false); err != nil {
+        t.Fatalf("Load() error = %v", err)
+    }
+    if Model != "env" || Sources["MODEL"] != "env AUTOSCRIBE_MODEL" {
+        t.Errorf("MODEL = %v from %v, want env from AUTOSCRIBE_MODEL", Model, Sources["MODEL"])
+    }
+}
+
+
+func TestLoadAccumulates(t *testing.T) {
+    system, _, project := configLayers(t)
+
+    writeConfig(t, system, "INCLUDE: [\"*.go\"]\nPROMPTS:\n  summary: \"system {{.Code}}\"\n  readme: \"readme\"\n")
+    writeConfig(t, project, "INCLUDE: [\"cmd/\"]\nPROMPTS:\n  summary: \"project {{.Code}}\"\n")
+    t.Setenv("AUTOSCRIBE_INCLUDE", "docs/, ,scripts/")
+
+    IncludeGlobs = []string{ "flag/" }
+    setFlags["include"] = true
+
+    if err := Load(false); err != nil {
+        t.Fatalf("Load() error = %v", err)
+    }
+
+    // Config globs come first, then the flag's
+    want := []string{ "*.go", "cmd/", "docs/", "scripts/", "flag/" }
+    if !slices.Equal(IncludeGlobs, want) {
+        t.Errorf("IncludeGlobs = %v, want %v", IncludeGlobs, want)
+    }
+    if Sources["INCLUDE"] != system + ", " + project + ", env AUTOSCRIBE_INCLUDE, flag -include" {
+        t.Errorf("INCLUDE sources = %v", Sources["INCLUDE"])
+    }
+
+    // Maps merge key by key
+    if PromptOverrides["summary"] != "project {{.Code}}" || PromptOverrides["readme"] != "readme" {
+        t.Errorf("PromptOverrides = %v", PromptOverrides)
+    }
+}
+
+
+func TestLoadModelBudget(t *testing.T) {
+    _, user, project := configLayers(t)
+
+    writeConfig(t, user, "CONTEXT_BUDGET: 100\nCONTEXT_BUDGETS:\n  gpt-x: 200\n  gpt-y: 300\n")
+    writeConfig(t, project, "MODEL: gpt-x\nCONTEXT_BUDGETS:\n  gpt-x: 400\n")
+
+    tests := []struct {
+        name   string
+        model  string
+        flag   int
+        want   int
+        source string
+    }{
+        { name: "model budget", want: 400, source: user + ", " + project },
+        { name: "other model", model: "gpt-y", want: 300, source: user + ", " + project },
+        { name: "no model budget", model: "gpt-z", want: 100, source: user },
+        { name: "flag", flag: 50, want: 50, source: "flag -budget" },
+    }
+
+    for _, tt := range tests {
+        setFlags = map[string]bool{}
+        if tt.model != "" {
+            Model = tt.model
+            setFlags["model"] = true
+        }
+        if tt.flag != 0 {
+            ContextBudget = tt.flag
+            setFlags["budget"] = true
+        }
+
+        if err := Load(false); err != nil {
+            t.Fatalf("Load() error = %v", err)
+        }
+        if ContextBudget != tt.want || Sources["CONTEXT_BUDGET"] != tt.source {
+            t.Errorf("%v: ContextBudget = %v from %v, want %v from %v", tt.name, ContextBudget, Sources["CONTEXT_BUDGET"], tt.want, tt.source)
+        }
+    }
+}
+
+
+func TestLoadCredentialsReplaceEachOther(t *testing.T) {
+    _, user, project := configLayers(t)
+
+    writeConfig(t, user, "KEY_FILE: ~/.openai\n")
+    writeConfig(t, project, "KEY_COMMAND: pass show openai\n")
+
+    if err := Load(false); err != nil {
+        t.Fatalf("Load() error = %v", err)
+    }
+
+    // Only the last place the key can come from is kept
+    if KeyFile != "" || KeyCommand != "pass show openai" || Sources["KEY_FILE"] != SourceDefault {
+        t.Errorf("KEY_FILE = %q from %v, KEY_COMMAND = %q", KeyFile, Sources["KEY_FILE"], KeyCommand)
+    }
+
+    // OPENAI_API_KEY is read without the prefix too
+    t.Setenv("OPENAI_API_KEY", "sk-from-env")
+    if err := Load(true); err != nil {
+        t.Fatalf("Load() error = %v", err)
+    }
+    if OpenAIKey != "sk-from-env" || KeyCommand != "" {
+        t.Errorf("OpenAIKey = %q, KEY_COMMAND = %q", OpenAIKey, KeyCommand)
+    }
+}
+
+
+func TestParseEnvValues(t *testing.T) {
+    var c Config
+
+    if err := c.parse("LANGUAGES", "go, py,,sh"); err != nil || !slices.Equal(c.LANGUAGES, []string{ "go", "py", "sh" }) {
+        t.Errorf("parse(LANGUAGES) = %v, %v", c.LANGUAGES, err)
+    }
+    if err := c.parse("DOC_STYLE", "{py: Use numpy style}"); err != nil || c.DOC_STYLE["py"] != "Use numpy style" {
+        t.Errorf("parse(DOC_STYLE) = %v, %v", c.DOC_STYLE, err)
+    }
+    if err := c.parse("MAX_COST", " 1.5 "); err != nil || c.MAX_COST != 1.5 {
+        t.Errorf("parse(MAX_COST) = %v, %v", c.MAX_COST, err)
+    }
+    if err := c.parse("PARALLELISM", "four"); err == nil {
+        t.Error("parse(PARALLELISM, four) succeeded")
+    }
+    if err := c.parse("PROMPTS", "[a, b]"); err == nil {
+        t.Error("parse(PROMPTS) of a list succeeded")
+    }
+}
+
+
+func TestSettingsHideSecrets(t *testing.T) {
+    configLayers(t)
+    t.Setenv("OPENAI_API_KEY", "sk-secret")
+
+    if err := Load(false); err != nil {
+        t.Fatalf("Load() error = %v", err)
+    }
+
+    for _, s := range Settings() {
+        if strings.Contains(s.String(), "sk-secret") {
+            t.Errorf("%v is shown as %v", s.Key, s.String())
+        }
+        if s.Key == "OPENAI_API_KEY" && (s.String() != "(set)" || s.Source != "env OPENAI_API_KEY") {
+            t.Errorf("OPENAI_API_KEY is shown as %v from %v", s.String(), s.Source)
+        }
+    }
+
+    prices := Setting{ Value: map[string]Price{ "b": {}, "a": {} } }
+    if prices.String() != "a, b" {
+        t.Errorf("a map is shown as %v, want its keys", prices.String())
+    }
+}
@@ -1,70 +1,187 @@
 package config;
 
 import (
+    "io"
     "os"
     "fmt"
     "flag"
-    "gopkg.in/yaml.v3"
+    "slices"
+    "strings"
 
     log "github.com/sirupsen/logrus"
 
     "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
 )
 
+/**
+ * Config is everything which can be set in a config file, or in the env with
+ * an AUTOSCRIBE_ prefix. See Load for the order the layers are read in.
+ */
 type Config struct {
-    OPENAI_API_KEY string `yaml:"OPENAI_API_KEY"`
+    PROVIDER         string            `yaml:"PROVIDER"`
+    MODEL            string            `yaml:"MODEL"`
+    OPENAI_API_KEY   string            `yaml:"OPENAI_API_KEY"`
+    // Command printing the API key, e.g. a password manager CLI
+    KEY_COMMAND      string            `yaml:"KEY_COMMAND"`
+    // File holding the API key
+    KEY_FILE         string            `yaml:"KEY_FILE"`
+    // Account the API key is stored under in the OS keyring
+    KEYRING          string            `yaml:"KEYRING"`
+    LANGUAGES        []string          `yaml:"LANGUAGES"`
+    INCLUDE          []string          `yaml:"INCLUDE"`
+    EXCLUDE          []string          `yaml:"EXCLUDE"`
+    OUTPUT_DIRECTORY string            `yaml:"OUTPUT_DIRECTORY"`
+    CACHE_DIRECTORY  string            `yaml:"CACHE_DIRECTORY"`
+    CONTEXT_BUDGET   int               `yaml:"CONTEXT_BUDGET"`
+    // Budgets for particular models, which win over CONTEXT_BUDGET
+    CONTEXT_BUDGETS  map[string]int    `yaml:"CONTEXT_BUDGETS"`
+    PARALLELISM      int               `yaml:"PARALLELISM"`
+    // Added to the end of every prompt, like -p
+    PROMPT           string            `yaml:"PROMPT"`
+    // Replacements for the built in prompts, by name
+    PROMPTS          map[string]string `yaml:"PROMPTS"`
+    // Replacements for the documentation style of a language, by format
+    DOC_STYLE        map[string]string `yaml:"DOC_STYLE"`
+    // Extra regexes of text never sent to the model, by name
+    REDACT_PATTERNS  map[string]string `yaml:"REDACT_PATTERNS"`
+    // Built in redaction detectors to turn off
+    REDACT_SKIP      []string          `yaml:"REDACT_SKIP"`
+    // .gitignore style globs of files never sent, on top of the built in ones
+    DENY             []string          `yaml:"DENY"`
+    // Dollars per million tokens, by model
+    PRICES           map[string]Price  `yaml:"PRICES"`
+    // Most a run may spend, in dollars and in tokens. 0 is no limit
+    MAX_COST         float64           `yaml:"MAX_COST"`
+    MAX_TOKENS       int               `yaml:"MAX_TOKENS"`
+}
+
+
+// Price of a model, in dollars per million prompt (INPUT) and completion
+// (OUTPUT) tokens
+type Price struct {
+    INPUT  float64 `yaml:"INPUT" json:"input"`
+    OUTPUT float64 `yaml:"OUTPUT" json:"output"`
+}
+
+
+// DefaultPrices are OpenAI's list prices, which PRICES adds to or overrides
+var DefaultPrices = map[string]Price{
+    "gpt-4.1":      { INPUT: 2.00, OUTPUT: 8.00 },
+    "gpt-4.1-mini": { INPUT: 0.40, OUTPUT: 1.60 },
+    "gpt-4.1-nano": { INPUT: 0.10, OUTPUT: 0.40 },
+    "gpt-4o":       { INPUT: 2.50, OUTPUT: 10.00 },
+    "gpt-4o-mini":  { INPUT: 0.15, OUTPUT: 0.60 },
+    "gpt-5":        { INPUT: 1.25, OUTPUT: 10.00 },
+    "gpt-5-mini":   { INPUT: 0.25, OUTPUT: 2.00 },
+    "gpt-5-nano":   { INPUT: 0.05, OUTPUT: 0.40 },
+    "o4-mini":      { INPUT: 1.10, OUTPUT: 4.40 },
 }
 
 var ConfigFile            string = "/etc/autoscribe/autoscribe.conf"
 
 var OpenAIKey             string
+// Where the API key is read from when it isn't set directly, see ResolveKey
+var KeyCommand            string
+var KeyFile               string
+var KeyringAccount        string
+// Only openai is supported for now
+var Provider              string                = "openai"
 
 var ProjectDirectory      string                = "./"
 var OutputDirectory       string                = "./"
 var EditFile              string                = ""
-var LanguageFileExtension types.SupportedFormat = "sh"
+// Languages of the code files to read. Empty detects them from the project
+var Languages             []types.SupportedFormat = []types.SupportedFormat{ types.Shell }
 var MakeReadme            bool                  = false
+var ReadmeSections        []string              = []string{}
 var MakeHelpMenuImpl      bool                  = false
 var MakeHelpMenuText      bool                  = false
+var MakeManPage           bool                  = false
+var ManPageFormat         string                = "man"
 var AstFileName           string                = ""
 var DocumentAst           bool                  = false
+// Pick up an interrupted docs run from its journal instead of starting over
+var Resume                bool                  = false
 
 var LogLevelDebug         bool                  = false
 
 var AdditionalPrompt      string                = ""
+// Replacements for the built in prompts, by name, see pkg/prompts
+var PromptOverrides       map[string]string     = map[string]string{}
+// Directory prompts dump writes the prompts into
+var PromptsDirectory      string                = ""
+// Replacements for the documentation style of each language, by format
+var DocStyles             map[string]string     = map[string]string{}
+
+// Model the prompts are sent to, used to size the context budget
+var Model                 string                = "gpt-4.1-nano"
+// Max tokens of project files per prompt. 0 picks a default for Model
+var ContextBudget         int                   = 0
+// Max tokens of project files per prompt, by model
+var ContextBudgets        map[string]int        = map[string]int{}
+// Directory summaries are cached in. Empty uses the user cache directory
+var CacheDirectory        string                = ""
+var Parallelism           int                   = 4
+
+var Prices                map[string]Price      = map[string]Price{}
+// Caps on what a run spends, 0 for none
+var MaxCost               float64               = 0
+var MaxTokens             int                   = 0
+// Count what the prompts would cost instead of sending them
+var Estimate              bool                  = false
+
+// .gitignore style globs, relative to ProjectDirectory, files must match one
+// of (when set) and must not match any of to be sent to the model
+var IncludeGlobs          []string              = []string{}
+var ExcludeGlobs          []string              = []string{}
+// Globs of files which are never sent, whatever the other globs say
+var DenyGlobs             []string              = []string{}
+// Extra patterns redacted from prompts, by name, and built in detectors off
+var RedactPatterns        map[string]string     = map[string]string{}
+var RedactSkip            []string              = []string{}
+var ShowTokens            bool                  = false
+
+var CompletionShell       string                = ""
+var ProgramName           string                = ""
+
+// Source files given to the docs and graph commands
+var SourceFiles           []string              = []string{}
+// How commands report their result: text, or json for scripts
+var OutputFormat          string                = "text"
+
+
+func parseLanguages(v string) error {
+    languages, err := types.ParseFormats(v)
+    if err != nil {
+        return err
+    }
 
+    Languages = languages
+    return nil
+}
 
-func LoadConfig() error {
 
-    _, err := os.Stat(ConfigFile)
-        
-    if os.IsNotExist(err) {
-        var exists bool
-        OpenAIKey, exists = os.LookupEnv("OPENAI_API_KEY")                
-        if ! exists {
-            return fmt.Errorf("failed to strip OpenAI API key out of the env. doesn't exist")
-        }
+// addSelectionFlags registers the flags choosing which project files are read
+func addSelectionFlags(fs *flag.FlagSet) {
+    fs.Func("include", "Only send files matching this .gitignore style glob. Repeatable", func(v string) error {
+        IncludeGlobs = append(IncludeGlobs, v)
         return nil
+    })
 
-    } else if err != nil {
-        return fmt.Errorf("failed to check for config %v: %v", ConfigFile, err)
-    }
-
-    log.Infof("Loading config from %v", ConfigFile)
+    fs.Func("exclude", "Never send files matching this .gitignore style glob. Repeatable", func(v string) error {
+        ExcludeGlobs = append(ExcludeGlobs, v)
+        return nil
+    })
+}
 
-    data, err := os.ReadFile(ConfigFile)
-    if err != nil {
-        return fmt.Errorf("error reading config file: %v", err)
-    }
 
-    var cfg Config
-    if err := yaml.Unmarshal(data, &cfg); err != nil {
-        return fmt.Errorf("error parsing yaml: %v", err)
-    }
+// addCostFlags registers the flags capping and estimating what a run spends
+func addCostFlags(fs *flag.FlagSet) {
+    fs.Float64Var(&MaxCost, "max-cost", 0, "Stop before a run spends more than this many dollars. 0 is no limit")
 
-    OpenAIKey = cfg.OPENAI_API_KEY
+    fs.IntVar(&MaxTokens, "max-tokens", 0, "Stop before a run uses more than this many tokens. 0 is no limit")
 
-    return nil
+    fs.BoolVar(&Estimate, "estimate", false, "Work out what the run would cost without sending anything to the model")
 }
 
 
@@ -74,9 +191,23 @@ func ParseCli() error {
 
     flag.BoolVar(&MakeReadme, "r", false, "Make a README.md for a project")
 
+    flag.Func("sections", "Comma separated README sections to regenerate, on top of the autoscribe managed ones", func(v string) error {
+        for _, section := range strings.Split(v, ",") {
+            if strings.TrimSpace(section) != "" {
+                ReadmeSections = append(ReadmeSections, strings.TrimSpace(section))
+            }
+        }
+        return nil
+    })
+
     flag.BoolVar(&MakeHelpMenuImpl, "m", false,  "Make a help 'Menu' implementation for a project")
     flag.BoolVar(&MakeHelpMenuText, "mt", false, "Write the text of a help 'Menu' for a project")
 
+    flag.BoolVar(&MakeManPage, "man", false, "Write a section 1 man page for a project into the output directory")
+    flag.StringVar(&ManPageFormat, "manfmt", "man", "Macro package for the man page: man or mdoc")
+
+    flag.StringVar(&ProgramName, "name", "", "Name of the program being documented. Defaults to the module name")
+
 
     flag.StringVar(&ProjectDirectory, "d", "./", "Project directory to source files from")
 
@@ -84,7 +215,7 @@ func ParseCli() error {
 
     flag.StringVar(&EditFile, "e", "", "Set the file you'd like AutoScribe to edit with new content")
 
-    extPtr := flag.String("l", "sh", "Set the file extensions we should be targetting")
+    flag.Func("l", "Comma separated languages to read, e.g. go,sh,md, or auto to detect them (default sh)", parseLanguages)
 
     flag.BoolVar(&LogLevelDebug, "debug", false, "Set log level to debug")
 
@@ -92,16 +223,29 @@ func ParseCli() error {
 
     flag.StringVar(&AdditionalPrompt, "p", "", "Add additional instructions to the prompt generating your output")
 
-    flag.BoolVar(&DocumentAst, "docs", false, "Set log level to debug")
+    flag.StringVar(&Model, "model", Model, "Model to send the prompts to")
+
+    flag.IntVar(&ContextBudget, "budget", ContextBudget, "Max tokens of project files sent per prompt. 0 uses a default for the model")
+
+    flag.StringVar(&CacheDirectory, "cache", "", "Directory to cache directory summaries and the docs journal in. Defaults to the user cache directory")
+
+    flag.IntVar(&Parallelism, "j", 4, "Number of directories to summarize in parallel on projects over the context budget")
+
+    addSelectionFlags(flag.CommandLine)
+
+    addCostFlags(flag.CommandLine)
+
+    flag.BoolVar(&DocumentAst, "docs", false, "Write generated documentation into the files parsed with -a")
+
+    flag.BoolVar(&Resume, "resume", false, "Pick up an interrupted -docs run, reusing the documentation it had generated")
 
     flag.Parse()
+    recordFlags(flag.CommandLine)
 
-    if ! types.IsSupportedFormat(*extPtr) {
-        return fmt.Errorf("unsupported language format %v", *extPtr)
+    if ! slices.Contains([]string{ "man", "mdoc" }, ManPageFormat) {
+        return fmt.Errorf("unsupported man page format %v", ManPageFormat)
     }
 
-    LanguageFileExtension = types.SupportedFormat(*extPtr)
-
     if len(flag.Args()) > 0 && ProjectDirectory == "./" {
         ProjectDirectory = flag.Arg(0)
     }
@@ -112,3 +256,140 @@ func ParseCli() error {
 
     return nil
 }
+
+
+// addCommonFlags registers the flags every subcommand takes
+func addCommonFlags(fs *flag.FlagSet) {
+    fs.StringVar(&ProjectDirectory, "d", "./", "Project directory to source files from")
+
+    fs.Func("l", "Comma separated languages to read, e.g. go,sh,md, or auto to detect them (default sh)", parseLanguages)
+
+    addSelectionFlags(fs)
+
+    fs.StringVar(&ConfigFile, "c", "/etc/autoscribe/autoscribe.conf", "Set the config file for AutoScribe")
+
+    fs.StringVar(&AdditionalPrompt, "p", "", "Add additional instructions to the prompt generating your output")
+
+    fs.StringVar(&Model, "model", Model, "Model to send the prompts to")
+
+    fs.IntVar(&ContextBudget, "budget", ContextBudget, "Max tokens of project files sent per prompt. 0 uses a default for the model")
+
+    fs.StringVar(&CacheDirectory, "cache", "", "Directory to cache directory summaries and the docs journal in. Defaults to the user cache directory")
+
+    fs.IntVar(&Parallelism, "j", 4, "Number of directories to summarize in parallel on projects over the context budget")
+
+    fs.StringVar(&OutputFormat, "output", "text", "Result format: text, or json for a machine readable summary")
+
+    addCostFlags(fs)
+
+    fs.BoolVar(&LogLevelDebug, "debug", false, "Set log level to debug")
+}
+
+
+// CommandFlags registers the flags of each subcommand on top of the common
+// ones. Commands taking source files rather than a project directory as
+// their arguments are listed in FileCommands
+var CommandFlags = map[string]func(fs *flag.FlagSet){
+    "readme": func(fs *flag.FlagSet) {
+        fs.Func("sections", "Comma separated README sections to regenerate, on top of the autoscribe managed ones", func(v string) error {
+            for _, section := range strings.Split(v, ",") {
+                if strings.TrimSpace(section) != "" {
+                    ReadmeSections = append(ReadmeSections, strings.TrimSpace(section))
+                }
+            }
+            return nil
+        })
+        fs.StringVar(&OutputDirectory, "o", "./", "Directory to write the README into")
+        fs.StringVar(&EditFile, "e", "", "Name of the README file. Defaults to README.md")
+    },
+    "helpmenu": func(fs *flag.FlagSet) {
+        fs.StringVar(&EditFile, "e", "", "Add the help menu to this file instead of printing it")
+    },
+    "helptext": nil,
+    "docs": func(fs *flag.FlagSet) {
+        fs.BoolVar(&Resume, "resume", false, "Pick up an interrupted run, reusing the documentation it had generated")
+    },
+    "graph": nil,
+    "check": func(fs *flag.FlagSet) {
+        fs.StringVar(&OutputDirectory, "o", "./", "Directory the README is in")
+        fs.StringVar(&EditFile, "e", "", "Name of the README file. Defaults to README.md")
+    },
+    "man": func(fs *flag.FlagSet) {
+        fs.StringVar(&ManPageFormat, "manfmt", "man", "Macro package for the man page: man or mdoc")
+        fs.StringVar(&ProgramName, "name", "", "Name of the program being documented. Defaults to the module name")
+        fs.StringVar(&OutputDirectory, "o", "./", "Directory to write the man page into")
+    },
+    "prompts dump": func(fs *flag.FlagSet) {
+        fs.StringVar(&PromptsDirectory, "o", "", "Write each prompt to <name>.tmpl in this directory instead of printing them")
+    },
+    "files": func(fs *flag.FlagSet) {
+        fs.BoolVar(&ShowTokens, "tokens", false, "Show the tier and approximate token count of each file")
+    },
+    "completions": func(fs *flag.FlagSet) {
+        fs.StringVar(&CompletionShell, "shell", "", "Shell to generate completions for: bash, zsh or fish")
+        fs.StringVar(&ProgramName, "name", "", "Name of the program to complete. Defaults to the module name")
+    },
+    "config show": nil,
+    "config schema": nil,
+    "config validate": nil,
+}
+
+var FileCommands = []string{ "docs", "graph" }
+
+
+/**
+ * ParseCommandCli parses the flags of a subcommand. usage is printed above
+ * the flags for -h, in which case flag.ErrHelp is returned. Any other error
+ * is a mistake in the command line.
+ */
+func ParseCommandCli(command string, usage string, args []string) error {
+    fs := flag.NewFlagSet(command, flag.ContinueOnError)
+
+    addCommonFlags(fs)
+    if add := CommandFlags[command]; add != nil {
+        add(fs)
+    }
+
+    // Errors are reported by the caller, so they aren't printed twice
+    fs.SetOutput(io.Discard)
+    fs.Usage = func() {}
+
+    if err := fs.Parse(args); err == flag.ErrHelp {
+        fs.SetOutput(os.Stdout)
+        fmt.Printf("%v\n\nFlags:\n", usage)
+        fs.PrintDefaults()
+        return err
+    } else if err != nil {
+        return err
+    }
+    recordFlags(fs)
+
+    if ! slices.Contains([]string{ "text", "json" }, OutputFormat) {
+        return fmt.Errorf("unsupported output format %v, use text or json", OutputFormat)
+    }
+
+    if ! slices.Contains([]string{ "man", "mdoc" }, ManPageFormat) {
+        return fmt.Errorf("unsupported man page format %v", ManPageFormat)
+    }
+
+    if command == "completions" && CompletionShell == "" {
+        return fmt.Errorf("--shell is required")
+    }
+
+    if slices.Contains(FileCommands, command) {
+        if len(fs.Args()) == 0 {
+            return fmt.Errorf("%v needs at least one source file", command)
+        }
+        SourceFiles = fs.Args()
+    } else if len(fs.Args()) > 1 {
+        return fmt.Errorf("%v takes at most one project directory, got %v", command, strings.Join(fs.Args(), " "))
+    } else if len(fs.Args()) == 1 && ProjectDirectory == "./" {
+        ProjectDirectory = fs.Arg(0)
+    }
+
+    if LogLevelDebug == true { 
+        log.SetLevel(log.DebugLevel); 
+    }
+
+    return nil
+}
@@ -0,0 +1,101 @@
+package config;
+
+import (
+    "reflect"
+)
+
+
+// What each setting does, shown by editors completing a config file
+var descriptions = map[string]string{
+    "PROVIDER":         "Model provider the prompts are sent to",
+    "MODEL":            "Model to send the prompts to, like -model",
+    "OPENAI_API_KEY":   "OpenAI API key. Prefer KEY_COMMAND, KEY_FILE or KEYRING to keep it out of config files",
+    "KEY_COMMAND":      "Command printing the API key on its first line, e.g. a password manager CLI",
+    "KEY_FILE":         "File holding the API key, which should only be readable by you",
+    "KEYRING":          "Account the API key is stored under in the OS keyring, with service autoscribe",
+    "LANGUAGES":        "Languages of the code files to read, like -l. auto detects them from the project",
+    "INCLUDE":          ".gitignore style globs, relative to the project directory, files must match one of to be sent",
+    "EXCLUDE":          ".gitignore style globs, relative to the project directory, of files never sent",
+    "OUTPUT_DIRECTORY": "Directory output is written into, like -o",
+    "CACHE_DIRECTORY":  "Directory summaries are cached in, like -cache. Empty uses the user cache directory",
+    "CONTEXT_BUDGET":   "Max tokens of project files per prompt, like -budget. 0 picks a default for the model",
+    "CONTEXT_BUDGETS":  "Max tokens of project files per prompt, by model. The budget for the model in use wins over CONTEXT_BUDGET, -budget wins over both",
+    "PARALLELISM":      "Number of directories to summarize in parallel, like -j",
+    "PROMPT":           "Instructions added to the end of every prompt, like -p",
+    "PROMPTS":          "Replacements for the built in prompts, by name, as text/template. They win over prompt files",
+    "DOC_STYLE":        "Replacements for the documentation style of a language, by language",
+    "REDACT_PATTERNS":  "Extra regexes of text replaced by a placeholder before prompts are sent, by name. A capture group redacts only its match",
+    "REDACT_SKIP":      "Built in redaction detectors to turn off",
+    "PRICES":           "Dollars per million prompt (INPUT) and completion (OUTPUT) tokens, by model. Adds to or overrides the built in prices",
+    "MAX_COST":         "Most a run may spend in dollars, like -max-cost. 0 is no limit",
+    "MAX_TOKENS":       "Most tokens a run may use, like -max-tokens. 0 is no limit",
+    "DENY":             ".gitignore style globs, relative to the project directory, of files never sent, on top of the built in deny list",
+}
+
+
+// Schema is the JSON Schema of the config files, built from Config
+func Schema() map[string]any {
+    t := reflect.TypeOf(Config{})
+
+    properties := map[string]any{}
+    for i, key := range Keys() {
+        properties[key] = schemaType(key, t.Field(i).Type)
+    }
+
+    return map[string]any{
+        "$schema":              "https://json-schema.org/draft/2020-12/schema",
+        "title":                "AutoScribe config",
+        "type":                 "object",
+        "properties":           properties,
+        "additionalProperties": false,
+    }
+}
+
+
+func schemaType(key string, t reflect.Type) map[string]any {
+    s := map[string]any{ "description": descriptions[key] }
+
+    switch t.Kind() {
+    case reflect.Int:
+        s["type"] = "integer"
+        s["minimum"] = 0
+    case reflect.Float64:
+        s["type"] = "number"
+        s["minimum"] = 0
+    case reflect.Slice:
+        s["type"] = "array"
+        s["items"] = map[string]any{ "type": "string" }
+    case reflect.Map:
+        s["type"] = "object"
+        s["additionalProperties"] = map[string]any{ "type": "string" }
+        if t.Elem().Kind() == reflect.Int {
+            s["additionalProperties"] = map[string]any{ "type": "integer", "minimum": 0 }
+        }
+        if t.Elem().Kind() == reflect.Struct {
+            fields := map[string]any{}
+            for i := 0; i < t.Elem().NumField(); i++ {
+                fields[t.Elem().Field(i).Tag.Get("yaml")] = map[string]any{ "type": "number", "minimum": 0 }
+            }
+            s["additionalProperties"] = map[string]any{ "type": "object", "properties": fields, "additionalProperties": false }
+        }
+    default:
+        s["type"] = "string"
+    }
+
+    switch key {
+    case "PROVIDER":
+        s["enum"] = Providers
+    case "LANGUAGES":
+        s["items"] = map[string]any{ "type": "string", "enum": append(formatNames(), "auto") }
+    case "PARALLELISM":
+        s["minimum"] = 1
+    case "PROMPTS":
+        s["propertyNames"] = map[string]any{ "enum": PromptNames }
+    case "REDACT_SKIP":
+        s["items"] = map[string]any{ "type": "string", "enum": RedactDetectors }
+    case "DOC_STYLE":
+        s["propertyNames"] = map[string]any{ "enum": formatNames() }
+    }
+
+    return s
+}
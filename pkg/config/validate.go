@@ -0,0 +1,394 @@
+package config;
+
+import (
+    "os"
+    "fmt"
+    "regexp"
+    "slices"
+    "strconv"
+    "strings"
+    "reflect"
+    "text/template"
+    "gopkg.in/yaml.v3"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+)
+
+
+// PromptNames are the built in prompts PROMPTS can replace, one for each
+// template in pkg/prompts
+var PromptNames = []string{
+    "document", "document_system", "helpmenu", "helpmenu_patch", "helptext",
+    "man", "man_system", "readme", "readme_guidelines", "readme_section", "summary",
+}
+
+var Providers = []string{ "openai" }
+
+// Names of the built in redaction detectors, which REDACT_SKIP can turn off
+var RedactDetectors = []string{ "private-key", "aws-access-key", "aws-secret-key", "api-token", "jwt", "email", "high-entropy" }
+
+
+// Functions the prompts can call, so a PROMPTS template using them parses.
+// Only the names matter here, pkg/prompts has the real ones
+var promptFuncs = template.FuncMap{
+    "join": strings.Join,
+    "list": func(items []string) string { return "" },
+}
+
+
+// Problem is a mistake in one config file or env var. Line and Column are
+// 0 when the mistake isn't at a position in a file
+type Problem struct {
+    Source  string
+    Line    int
+    Column  int
+    Message string
+}
+
+func (p Problem) String() string {
+    switch {
+    case p.Source == "":
+        return p.Message
+    case p.Line == 0:
+        return fmt.Sprintf("%v: %v", p.Source, p.Message)
+    case p.Column == 0:
+        return fmt.Sprintf("%v:%v: %v", p.Source, p.Line, p.Message)
+    }
+
+    return fmt.Sprintf("%v:%v:%v: %v", p.Source, p.Line, p.Column, p.Message)
+}
+
+
+// ConfigError is returned by Load when any layer has a mistake in it
+type ConfigError struct {
+    Problems []Problem
+}
+
+func (e *ConfigError) Error() string {
+    messages := []string{}
+    for _, p := range e.Problems {
+        messages = append(messages, p.String())
+    }
+
+    return fmt.Sprintf("invalid config: %v", strings.Join(messages, "; "))
+}
+
+
+/**
+ * Validate reads every layer like Load, without applying any of it, and
+ * returns all the mistakes found rather than stopping at the first. A
+ * missing API key is reported too, since any command using the model would
+ * fail on it, as is a KEY_FILE which can't be read. KEY_COMMAND isn't run.
+ */
+func Validate() []Problem {
+    cfg, problems := load()
+
+    switch {
+    case cfg.KEY_FILE != "":
+        if _, err := os.Stat(expandHome(cfg.KEY_FILE)); err != nil {
+            problems = append(problems, Problem{ Source: Sources["KEY_FILE"], Message: fmt.Sprintf("KEY_FILE can't be read: %v", err) })
+        }
+    case cfg.OPENAI_API_KEY == "" && cfg.KEY_COMMAND == "" && cfg.KEYRING == "":
+        problems = append(problems, Problem{
+            Message: fmt.Sprintf("no API key is configured, commands using the model will fail. Set one of %v", strings.Join(credentialKeys, ", ")),
+        })
+    }
+
+    return problems
+}
+
+
+var yamlLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+/**
+ * decodeFile strictly decodes the config file data into layer. Every key
+ * must be a known setting of the right type with a valid value, and each
+ * mistake is reported at its line and column. It returns the keys set.
+ */
+func decodeFile(path string, data []byte, layer *Config) ([]string, []Problem) {
+    var doc yaml.Node
+    if err := yaml.Unmarshal(data, &doc); err != nil {
+        p := Problem{ Source: path, Message: err.Error() }
+        if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+            p.Line, _ = strconv.Atoi(m[1])
+            p.Message = m[2]
+        }
+        return nil, []Problem{ p }
+    }
+
+    // An empty file
+    if len(doc.Content) == 0 {
+        return nil, nil
+    }
+
+    root := doc.Content[0]
+    if root.Kind != yaml.MappingNode {
+        return nil, []Problem{{ Source: path, Line: root.Line, Column: root.Column, Message: "the config must be a map of settings" }}
+    }
+
+    problems := []Problem{}
+    at := func(n *yaml.Node, format string, args ...any) {
+        problems = append(problems, Problem{ Source: path, Line: n.Line, Column: n.Column, Message: fmt.Sprintf(format, args...) })
+    }
+
+    set := []string{}
+    fields := layer.values()
+
+    for i := 0; i + 1 < len(root.Content); i += 2 {
+        key, value := root.Content[i], root.Content[i + 1]
+
+        field, ok := fields[key.Value]
+        if !ok {
+            at(key, "unknown setting %v%v", key.Value, suggest(key.Value))
+            continue
+        }
+
+        if slices.Contains(set, key.Value) {
+            at(key, "%v is set more than once", key.Value)
+            continue
+        }
+
+        if !kindMatches(field, value) || value.Decode(field.Addr().Interface()) != nil {
+            at(value, "%v must be %v", key.Value, describeType(field.Type()))
+            continue
+        }
+
+        if unknown := unknownFields(field.Type(), value); unknown != nil {
+            at(unknown, "unknown field %v in %v, use %v", unknown.Value, key.Value, describeType(field.Type()))
+            continue
+        }
+
+        if msg := checkValue(key.Value, layer); msg != "" {
+            at(value, "%v", msg)
+            continue
+        }
+
+        if field.Kind() == reflect.String && field.String() != "" && slices.Contains(credentialKeys, key.Value) {
+            other := slices.IndexFunc(set, func(k string) bool {
+                return slices.Contains(credentialKeys, k) && fields[k].String() != ""
+            })
+            if other >= 0 {
+                at(key, "%v and %v are both set, only one can say where the API key comes from", set[other], key.Value)
+                continue
+            }
+        }
+
+        set = append(set, key.Value)
+    }
+
+    return set, problems
+}
+
+
+// kindMatches reports whether the yaml node has the shape of the field, as
+// yaml would happily decode a number into a string setting but not the other
+// way around
+func kindMatches(field reflect.Value, n *yaml.Node) bool {
+    switch field.Kind() {
+    case reflect.Slice:
+        return n.Kind == yaml.SequenceNode
+    case reflect.Map:
+        return n.Kind == yaml.MappingNode
+    }
+
+    return n.Kind == yaml.ScalarNode
+}
+
+
+// unknownFields returns the first key of a map of structs which isn't a
+// field of the struct, as decoding a node ignores them
+func unknownFields(t reflect.Type, n *yaml.Node) *yaml.Node {
+    if t.Kind() != reflect.Map || t.Elem().Kind() != reflect.Struct {
+        return nil
+    }
+
+    fields := []string{}
+    for i := 0; i < t.Elem().NumField(); i++ {
+        fields = append(fields, t.Elem().Field(i).Tag.Get("yaml"))
+    }
+
+    for i := 1; i < len(n.Content); i += 2 {
+        entry := n.Content[i]
+        for j := 0; j + 1 < len(entry.Content); j += 2 {
+            if ! slices.Contains(fields, entry.Content[j].Value) {
+                return entry.Content[j]
+            }
+        }
+    }
+
+    return nil
+}
+
+
+func describeType(t reflect.Type) string {
+    switch t.Kind() {
+    case reflect.Int:
+        return "a whole number"
+    case reflect.Float64:
+        return "a number"
+    case reflect.Slice:
+        return "a list of strings"
+    case reflect.Map:
+        if t.Elem().Kind() == reflect.Struct {
+            return "a map of names to {INPUT, OUTPUT} numbers"
+        }
+        if t.Elem().Kind() == reflect.Int {
+            return "a map of names to whole numbers"
+        }
+        return "a map of strings"
+    }
+
+    return "a string"
+}
+
+
+// checkValue returns what's wrong with the value of key in layer, if anything
+func checkValue(key string, layer *Config) string {
+    switch key {
+    case "PROVIDER":
+        if ! slices.Contains(Providers, layer.PROVIDER) {
+            return fmt.Sprintf("unsupported provider %v, use one of %v", layer.PROVIDER, strings.Join(Providers, ", "))
+        }
+    case "LANGUAGES":
+        if _, err := types.ParseFormats(strings.Join(layer.LANGUAGES, ",")); err != nil {
+            return fmt.Sprintf("LANGUAGES: %v", err)
+        }
+    case "CONTEXT_BUDGET":
+        if layer.CONTEXT_BUDGET < 0 {
+            return "CONTEXT_BUDGET can't be negative, use 0 for the model's default"
+        }
+    case "PARALLELISM":
+        if layer.PARALLELISM < 1 {
+            return "PARALLELISM must be at least 1"
+        }
+    case "PROMPTS":
+        for name, prompt := range layer.PROMPTS {
+            if ! slices.Contains(PromptNames, name) {
+                return fmt.Sprintf("unknown prompt %v, use one of %v", name, strings.Join(PromptNames, ", "))
+            }
+            // Prompts used to be filled in with fmt
+            if strings.Contains(prompt, "%v") {
+                return fmt.Sprintf("PROMPTS %v has a %%v, prompts are text/template now, see Prompts in the README", name)
+            }
+            if _, err := template.New(name).Funcs(promptFuncs).Parse(prompt); err != nil {
+                return fmt.Sprintf("PROMPTS %v: %v", name, err)
+            }
+        }
+    case "REDACT_PATTERNS":
+        for name, pattern := range layer.REDACT_PATTERNS {
+            if _, err := regexp.Compile(pattern); err != nil {
+                return fmt.Sprintf("REDACT_PATTERNS %v: %v", name, err)
+            }
+        }
+    case "REDACT_SKIP":
+        for _, name := range layer.REDACT_SKIP {
+            if ! slices.Contains(RedactDetectors, name) {
+                return fmt.Sprintf("unknown detector %v, use one of %v", name, strings.Join(RedactDetectors, ", "))
+            }
+        }
+    case "CONTEXT_BUDGETS":
+        for model, budget := range layer.CONTEXT_BUDGETS {
+            if budget < 0 {
+                return fmt.Sprintf("the budget of %v can't be negative, use 0 for the model's default", model)
+            }
+        }
+    case "PRICES":
+        for model, price := range layer.PRICES {
+            if price.INPUT < 0 || price.OUTPUT < 0 {
+                return fmt.Sprintf("the prices of %v can't be negative", model)
+            }
+        }
+    case "MAX_COST":
+        if layer.MAX_COST < 0 {
+            return "MAX_COST can't be negative, use 0 for no limit"
+        }
+    case "MAX_TOKENS":
+        if layer.MAX_TOKENS < 0 {
+            return "MAX_TOKENS can't be negative, use 0 for no limit"
+        }
+    case "DOC_STYLE":
+        for format := range layer.DOC_STYLE {
+            if ! slices.Contains(formatNames(), format) {
+                return fmt.Sprintf("unknown language %v, use one of %v", format, strings.Join(formatNames(), ", "))
+            }
+        }
+    }
+
+    return ""
+}
+
+
+func formatNames() []string {
+    names := []string{}
+    for _, lang := range types.Languages {
+        names = append(names, string(lang.Format))
+    }
+
+    return names
+}
+
+
+// unknownEnv reports AUTOSCRIBE_ env vars which aren't a setting, most
+// likely a typo
+func unknownEnv() []Problem {
+    problems := []Problem{}
+
+    for _, entry := range os.Environ() {
+        name, _, _ := strings.Cut(entry, "=")
+        if !strings.HasPrefix(name, EnvPrefix) {
+            continue
+        }
+
+        key := strings.TrimPrefix(name, EnvPrefix)
+        if ! slices.Contains(Keys(), key) {
+            problems = append(problems, Problem{ Source: "env " + name, Message: fmt.Sprintf("unknown setting %v%v", key, suggest(key)) })
+        }
+    }
+
+    return problems
+}
+
+
+// suggest returns a hint naming the setting closest to the unknown key, when
+// one is close enough to be a typo
+func suggest(key string) string {
+    best, distance := "", 3
+
+    for _, known := range Keys() {
+        if strings.EqualFold(key, known) {
+            return fmt.Sprintf(", did you mean %v? Settings are upper case", known)
+        }
+        if d := editDistance(key, known); d < distance {
+            best, distance = known, d
+        }
+    }
+
+    if best == "" {
+        return ""
+    }
+
+    return fmt.Sprintf(", did you mean %v?", best)
+}
+
+
+func editDistance(a string, b string) int {
+    prev := make([]int, len(b) + 1)
+    for j := range prev {
+        prev[j] = j
+    }
+
+    for i := 1; i <= len(a); i++ {
+        cur := make([]int, len(b) + 1)
+        cur[0] = i
+        for j := 1; j <= len(b); j++ {
+            cost := 1
+            if a[i - 1] == b[j - 1] {
+                cost = 0
+            }
+            cur[j] = min(prev[j] + 1, cur[j - 1] + 1, prev[j - 1] + cost)
+        }
+        prev = cur
+    }
+
+    return prev[len(b)]
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+    "os"
+    "strings"
+    "testing"
+    "path/filepath"
+)
+
+
+func TestDecodeFile(t *testing.T) {
+    tests := []struct {
+        name    string
+        src     string
+        line    int
+        column  int
+        message string
+    }{
+        { name: "yaml syntax", src: "MODEL: [a\n", line: 1, message: "did not find expected ',' or ']'" },
+        { name: "not a map", src: "- MODEL\n", line: 1, column: 1, message: "the config must be a map of settings" },
+        { name: "typo", src: "MODEL: a\nPARALELISM: 2\n", line: 2, column: 1, message: "unknown setting PARALELISM, did you mean PARALLELISM?" },
+        { name: "lower case", src: "model: a\n", line: 1, column: 1, message: "unknown setting model, did you mean MODEL? Settings are upper case" },
+        { name: "nothing close", src: "COLOUR: red\n", line: 1, column: 1, message: "unknown setting COLOUR" },
+        { name: "set twice", src: "MODEL: a\nMODEL: b\n", line: 2, column: 1, message: "MODEL is set more than once" },
+        { name: "not a number", src: "PARALLELISM: four\n", line: 1, column: 14, message: "PARALLELISM must be a whole number" },
+        { name: "not a list", src: "INCLUDE: \"*.go\"\n", line: 1, column: 10, message: "INCLUDE must be a list of strings" },
+        { name: "not a map of strings", src: "PROMPTS: [a]\n", line: 1, column: 10, message: "PROMPTS must be a map of strings" },
+        { name: "not a string", src: "MODEL: [a]\n", line: 1, column: 8, message: "MODEL must be a string" },
+        { name: "price field", src: "PRICES:\n  gpt-x:\n    INPUT: 1\n    OUTPT: 2\n", line: 4, column: 5, message: "unknown field OUTPT in PRICES" },
+        { name: "provider", src: "PROVIDER: acme\n", line: 1, column: 11, message: "unsupported provider acme, use one of openai" },
+        { name: "language", src: "LANGUAGES: [cobol]\n", line: 1, column: 12, message: "LANGUAGES: " },
+        { name: "negative budget", src: "CONTEXT_BUDGET: -1\n", line: 1, column: 17, message: "CONTEXT_BUDGET can't be negative" },
+        { name: "negative model budget", src: "CONTEXT_BUDGETS:\n  gpt-x: -1\n", line: 2, column: 3, message: "the budget of gpt-x can't be negative" },
+        { name: "not a map of numbers", src: "CONTEXT_BUDGETS:\n  gpt-x: lots\n", line: 2, column: 3, message: "CONTEXT_BUDGETS must be a map of names to whole numbers" },
+        { name: "no parallelism", src: "PARALLELISM: 0\n", line: 1, column: 14, message: "PARALLELISM must be at least 1" },
+        { name: "unknown prompt", src: "PROMPTS:\n  intro: x\n", line: 2, column: 3, message: "unknown prompt intro" },
+        { name: "old prompt", src: "PROMPTS:\n  summary: \"Summarise %v\"\n", line: 2, column: 3, message: "PROMPTS summary has a %v" },
+        { name: "bad template", src: "PROMPTS:\n  summary: \"{{.Code\"\n", line: 2, column: 3, message: "PROMPTS summary: " },
+        { name: "bad pattern", src: "REDACT_PATTERNS:\n  card: \"[0-9\"\n", line: 2, column: 3, message: "REDACT_PATTERNS card: " },
+        { name: "unknown detector", src: "REDACT_SKIP: [mail]\n", line: 1, column: 14, message: "unknown detector mail" },
+        { name: "negative price", src: "PRICES:\n  gpt-x: { INPUT: -1, OUTPUT: 2 }\n", line: 2, column: 3, message: "the prices of gpt-x can't be negative" },
+        { name: "negative cost", src: "MAX_COST: -0.5\n", line: 1, column: 11, message: "MAX_COST can't be negative" },
+        { name: "negative tokens", src: "MAX_TOKENS: -5\n", line: 1, column: 13, message: "MAX_TOKENS can't be negative" },
+        { name: "doc style", src: "DOC_STYLE:\n  cobol: x\n", line: 2, column: 3, message: "unknown language cobol" },
+        { name: "two keys", src: "KEY_FILE: ~/.key\nKEY_COMMAND: pass openai\n", line: 2, column: 1, message: "KEY_FILE and KEY_COMMAND are both set" },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var layer Config
+            _, problems := decodeFile("a.yaml", []byte(tt.src), &layer)
+            if len(problems) != 1 {
+                t.Fatalf("decodeFile() = %v, want one problem", problems)
+            }
+
+            p := problems[0]
+            if p.Source != "a.yaml" || p.Line != tt.line || p.Column != tt.column || !strings.HasPrefix(p.Message, tt.message) {
+                t.Errorf("decodeFile() = %v, want a.yaml:%v:%v: %v", p, tt.line, tt.column, tt.message)
+            }
+        })
+    }
+}
+
+
+func TestDecodeFileKeepsGoing(t *testing.T) {
+    var layer Config
+    src := "MODEL: gpt-x\nPARALLELISM: 0\nCOLOUR: red\nINCLUDE: [\"*.go\"]\nPRICES:\n  gpt-x: { INPUT: 1, OUTPUT: 2 }\n"
+
+    set, problems := decodeFile("a.yaml", []byte(src), &layer)
+    if len(problems) != 2 {
+        t.Errorf("decodeFile() = %v, want PARALLELISM and COLOUR", problems)
+    }
+    if strings.Join(set, ",") != "MODEL,INCLUDE,PRICES" {
+        t.Errorf("decodeFile() set %v", set)
+    }
+    if layer.MODEL != "gpt-x" || layer.PRICES["gpt-x"].OUTPUT != 2 {
+        t.Errorf("decodeFile() decoded %+v", layer)
+    }
+
+    // An empty file is fine
+    if set, problems := decodeFile("a.yaml", []byte("# nothing yet\n"), &layer); len(set) != 0 || len(problems) != 0 {
+        t.Errorf("decodeFile() of an empty file = %v, %v", set, problems)
+    }
+}
+
+
+func TestProblemString(t *testing.T) {
+    tests := []struct {
+        problem Problem
+        want    string
+    }{
+        { problem: Problem{ Message: "no key" }, want: "no key" },
+        { problem: Problem{ Source: "env AUTOSCRIBE_X", Message: "unknown" }, want: "env AUTOSCRIBE_X: unknown" },
+        { problem: Problem{ Source: "a.yaml", Line: 3, Message: "bad" }, want: "a.yaml:3: bad" },
+        { problem: Problem{ Source: "a.yaml", Line: 3, Column: 7, Message: "bad" }, want: "a.yaml:3:7: bad" },
+    }
+
+    for _, tt := range tests {
+        if got := tt.problem.String(); got != tt.want {
+            t.Errorf("String() = %q, want %q", got, tt.want)
+        }
+    }
+
+    err := &ConfigError{ Problems: []Problem{ tests[0].problem, tests[3].problem } }
+    if err.Error() != "invalid config: no key; a.yaml:3:7: bad" {
+        t.Errorf("Error() = %q", err.Error())
+    }
+}
+
+
+func TestLoadReportsEveryLayer(t *testing.T) {
+    system, _, project := configLayers(t)
+
+    writeConfig(t, system, "MODLE: a\n")
+    writeConfig(t, project, "PARALLELISM: 0\n")
+    t.Setenv("AUTOSCRIBE_MODLE", "b")
+    t.Setenv("AUTOSCRIBE_PARALLELISM", "many")
+
+    err := Load(false)
+    cerr, ok := err.(*ConfigError)
+    if !ok {
+        t.Fatalf("Load() error = %v, want a *ConfigError", err)
+    }
+
+    want := []string{
+        system + ":1:1: unknown setting MODLE, did you mean MODEL?",
+        project + ":1:14: PARALLELISM must be at least 1",
+        "env AUTOSCRIBE_PARALLELISM: PARALLELISM must be a whole number, got many",
+        "env AUTOSCRIBE_MODLE: unknown setting MODLE, did you mean MODEL?",
+    }
+    for _, w := range want {
+        if !strings.Contains(cerr.Error(), w) {
+            t.Errorf("Load() error = %v, missing %v", cerr, w)
+        }
+    }
+}
+
+
+func TestValidateKey(t *testing.T) {
+    _, user, _ := configLayers(t)
+
+    problems := Validate()
+    if len(problems) != 1 || !strings.HasPrefix(problems[0].Message, "no API key is configured") {
+        t.Errorf("Validate() = %v, want the missing key", problems)
+    }
+
+    writeConfig(t, user, "KEY_FILE: " + filepath.Join(t.TempDir(), "missing") + "\n")
+    problems = Validate()
+    if len(problems) != 1 || problems[0].Source != user || !strings.HasPrefix(problems[0].Message, "KEY_FILE can't be read") {
+        t.Errorf("Validate() = %v, want the unreadable KEY_FILE", problems)
+    }
+
+    key := filepath.Join(t.TempDir(), "key")
+    if err := os.WriteFile(key, []byte("sk-test\n"), 0600); err != nil {
+        t.Fatal(err)
+    }
+    writeConfig(t, user, "KEY_FILE: " + key + "\n")
+    if problems := Validate(); len(problems) != 0 {
+        t.Errorf("Validate() = %v, want nothing", problems)
+    }
+}
+
+
+func TestSchema(t *testing.T) {
+    schema := Schema()
+    if schema["additionalProperties"] != false {
+        t.Error("Schema() allows unknown settings")
+    }
+
+    properties := schema["properties"].(map[string]any)
+    if len(properties) != len(Keys()) {
+        t.Errorf("Schema() has %v settings, want %v", len(properties), len(Keys()))
+    }
+
+    parallelism := properties["PARALLELISM"].(map[string]any)
+    if parallelism["type"] != "integer" || parallelism["minimum"] != 1 {
+        t.Errorf("PARALLELISM schema = %v", parallelism)
+    }
+
+    prices := properties["PRICES"].(map[string]any)["additionalProperties"].(map[string]any)
+    if prices["additionalProperties"] != false {
+        t.Errorf("PRICES schema = %v, want no unknown fields", prices)
+    }
+}
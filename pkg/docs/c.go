@@ -0,0 +1,680 @@
+package docs
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+
+    log "github.com/sirupsen/logrus"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+)
+
+
+func init() {
+    Register(CBackend{ Lang: types.C })
+    Register(CBackend{ Lang: types.Cpp })
+}
+
+
+/**
+ * CBackend documents C and C++ functions with Doxygen blocks. A function is
+ * documented where it's declared rather than where it's defined: a prototype
+ * in a header is documented with the help of the definition in the source
+ * file of the same name next to it, and a definition declared in a header
+ * the file includes is left for that header. @param and @return are written
+ * from the signature, the model only writes the descriptions.
+ */
+type CBackend struct {
+    Lang types.SupportedFormat
+}
+
+
+func (b CBackend) Language() types.SupportedFormat {
+    return b.Lang
+}
+
+
+func (CBackend) Style() string {
+    return `The comment is Doxygen, and the summary becomes its @brief. Don't write types, they're added from the signature. Note who owns returned or passed pointers and what is returned on error when it matters. In C++, errors are the exceptions thrown, in C the ways it can fail.`
+}
+
+
+// Render lays d out as Doxygen text, with @param, @return and, for C++,
+// @throws tags which DoxygenLines completes from the signature. C has no
+// exceptions, so its errors are written as a paragraph
+func (b CBackend) Render(n *Node, d Doc) string {
+    errors := []string{}
+    if b.Lang != types.Cpp {
+        errors, d.Errors = d.Errors, nil
+    }
+    lines := proseLines(d, d.SideEffects, strings.Join(errors, "\n"))
+
+    tags := []string{}
+    for _, p := range paramEntries(n, d) {
+        tags = append(tags, "@param " + p.Name + " " + p.Description)
+    }
+    if returns := strings.TrimSpace(d.Returns); returns != "" {
+        tags = append(tags, "@return " + returns)
+    }
+    for _, e := range d.Errors {
+        if e = strings.TrimSpace(e); e != "" {
+            tags = append(tags, "@throws " + e)
+        }
+    }
+
+    if len(tags) > 0 {
+        lines = append(append(lines, ""), tags...)
+    }
+
+    return strings.Join(lines, "\n")
+}
+
+
+// A declaration found by parseC, either a prototype or a definition
+type cDecl struct {
+    node       *Node
+    definition bool
+}
+
+
+var cHeaderExtensions = []string{ ".h", ".hh", ".hpp", ".hxx", ".h++" }
+var cSourceExtensions = []string{ ".c", ".cc", ".cpp", ".cxx", ".c++" }
+
+var cName = regexp.MustCompile(`((?:[A-Za-z_]\w*\s*::\s*)*(?:~?[A-Za-z_]\w*|operator\s*(?:\(\)|[^\s(]+)))\s*$`)
+var cScope = regexp.MustCompile(`^(?:namespace(?:\s+[\w:]+)?|extern\s+"_*")\s*$`)
+var cRecord = regexp.MustCompile(`^(?:template\s*<.*>\s*)?(?:typedef\s+)?(?:class|struct|union)\s+(?:\w+\s+)*?([A-Za-z_]\w*)\s*(?:final\s*)?(?::[^{]*)?$`)
+var cAccess = regexp.MustCompile(`^(?:(?:public|private|protected)\s*:\s*)+`)
+var cInclude = regexp.MustCompile(`(?m)^\s*#\s*include\s*"([^"]+)"`)
+var cCall = regexp.MustCompile(`([A-Za-z_][\w:]*(?:(?:\.|->)[A-Za-z_]\w*)*)\s*\(`)
+var cSpace = regexp.MustCompile(`\s+`)
+var cFunctionPointer = regexp.MustCompile(`\(\s*[*&^]\s*([A-Za-z_]\w*)\s*\)`)
+var cParamName = regexp.MustCompile(`([A-Za-z_]\w*)\s*((?:\[[^\]]*\]\s*)*)$`)
+
+var cKeywords = map[string]bool{
+    "if": true, "for": true, "while": true, "switch": true, "return": true, "sizeof": true,
+    "alignof": true, "decltype": true, "typeof": true, "static_assert": true, "_Static_assert": true,
+    "catch": true, "throw": true, "new": true, "delete": true, "defined": true, "do": true,
+    "else": true, "case": true, "typedef": true, "using": true, "goto": true,
+}
+
+// Specifiers which aren't part of the return type
+var cSpecifiers = regexp.MustCompile(`\b(?:static|inline|extern|virtual|explicit|constexpr|consteval|friend|__inline__|__inline|_Noreturn|\[\[[^\]]*\]\]|__attribute__\s*\(\(.*?\)\))\s*`)
+
+
+/**
+ * maskC blanks out comments, preprocessor lines, and string and char
+ * literals, keeping offsets and newlines, and returns the comments it found.
+ * Doc comments are the Doxygen ones: /**, /*!, /// and //!.
+ */
+func maskC(src []byte) ([]byte, []jsComment) {
+    masked := append([]byte{}, src...)
+    comments := []jsComment{}
+
+    blank := func(from int, to int, fill byte) {
+        for k := from; k < to && k < len(masked); k++ {
+            if masked[k] != '\n' {
+                masked[k] = fill
+            }
+        }
+    }
+
+    lineBegins := true
+    for i := 0; i < len(src); i++ {
+        c := src[i]
+
+        switch {
+        case c == '/' && i + 1 < len(src) && src[i + 1] == '/':
+            j := i
+            for j < len(src) && src[j] != '\n' {
+                j++
+            }
+            text := string(src[i:j])
+            doc := (strings.HasPrefix(text, "///") && !strings.HasPrefix(text, "////")) || strings.HasPrefix(text, "//!")
+            comments = append(comments, jsComment{ start: i, end: j, doc: doc })
+            blank(i, j, ' ')
+            i = j - 1
+
+        case c == '/' && i + 1 < len(src) && src[i + 1] == '*':
+            end := strings.Index(string(src[i + 2:]), "*/")
+            j := len(src)
+            if end >= 0 {
+                j = i + 2 + end + 2
+            }
+            text := string(src[i:j])
+            doc := (strings.HasPrefix(text, "/**") && text != "/**/" && !strings.HasPrefix(text, "/***")) || strings.HasPrefix(text, "/*!")
+            comments = append(comments, jsComment{ start: i, end: j, doc: doc })
+            blank(i, j, ' ')
+            i = j - 1
+
+        case c == '#' && lineBegins:
+            // Through any continuation lines
+            j := i
+            for j < len(src) && (src[j] != '\n' || src[j - 1] == '\\') {
+                j++
+            }
+            blank(i, j, ' ')
+            i = j - 1
+
+        case c == '"' || c == '\'':
+            // C++11 raw strings: R"delim( ... )delim"
+            if c == '"' && i > 0 && src[i - 1] == 'R' {
+                if open := strings.IndexByte(string(src[i:]), '('); open >= 0 {
+                    delim := string(src[i + 1:i + open])
+                    if end := strings.Index(string(src[i + open:]), ")" + delim + `"`); end >= 0 {
+                        j := i + open + end + len(delim) + 1
+                        blank(i + 1, j, '_')
+                        i = j
+                        continue
+                    }
+                }
+            }
+
+            j := i + 1
+            for j < len(src) && src[j] != c && src[j] != '\n' {
+                if src[j] == '\\' {
+                    j++
+                }
+                j++
+            }
+            blank(i + 1, j, '_')
+            i = j
+        }
+
+        if i < len(src) && src[i] != ' ' && src[i] != '\t' {
+            lineBegins = src[i] == '\n'
+        }
+    }
+
+    return masked, comments
+}
+
+
+func (b CBackend) Parse(path string, src []byte) ([]*Node, error) {
+    decls, err := parseC(path, src)
+    if err != nil {
+        return nil, err
+    }
+
+    // Definitions which give prototypes their bodies: the ones in this file,
+    // and for a header the ones in the source files next to it
+    definitions := map[string]*Node{}
+    for _, d := range decls {
+        if d.definition {
+            definitions[d.node.FullName()] = d.node
+        }
+    }
+    if isCHeader(path) {
+        for _, source := range cCompanions(path, cSourceExtensions) {
+            for _, d := range readCDecls(source) {
+                if _, ok := definitions[d.node.FullName()]; d.definition && !ok {
+                    definitions[d.node.FullName()] = d.node
+                }
+            }
+        }
+    }
+
+    // Functions declared in a header this file includes are documented there
+    declared := map[string]string{}
+    for _, d := range decls {
+        if !d.definition {
+            declared[d.node.FullName()] = path
+        }
+    }
+    for _, m := range cInclude.FindAllSubmatch(src, -1) {
+        header := filepath.Join(filepath.Dir(path), string(m[1]))
+        for _, d := range readCDecls(header) {
+            if _, ok := declared[d.node.FullName()]; !d.definition && !ok {
+                declared[d.node.FullName()] = header
+            }
+        }
+    }
+
+    nodes := []*Node{}
+    seen := map[string]bool{}
+
+    for _, d := range decls {
+        n := d.node
+        name := n.FullName()
+
+        if d.definition {
+            if where, ok := declared[name]; ok {
+                if where != path {
+                    log.Debugf("%v is declared in %v, documenting it there", name, where)
+                }
+                continue
+            }
+        } else {
+            // Only the first prototype of a function is documented
+            if seen[name] {
+                continue
+            }
+            if def, ok := definitions[name]; ok {
+                cParamNames(n, def)
+                n.Source += "\n\n// Definition in " + filepath.Base(def.File) + "\n" + def.Source
+                n.Calls = def.Calls
+                if n.Doc == "" {
+                    n.Doc = def.Doc
+                }
+            }
+        }
+
+        seen[name] = true
+        nodes = append(nodes, n)
+    }
+
+    return nodes, nil
+}
+
+
+func isCHeader(path string) bool {
+    ext := strings.ToLower(filepath.Ext(path))
+    for _, e := range cHeaderExtensions {
+        if ext == e {
+            return true
+        }
+    }
+
+    return false
+}
+
+
+// cCompanions are the files next to path with the same base name and one of
+// extensions, e.g. list.c for list.h
+func cCompanions(path string, extensions []string) []string {
+    base := strings.TrimSuffix(path, filepath.Ext(path))
+    found := []string{}
+
+    for _, ext := range extensions {
+        if info, err := os.Stat(base + ext); err == nil && !info.IsDir() {
+            found = append(found, base + ext)
+        }
+    }
+
+    return found
+}
+
+
+// readCDecls parses another file for its declarations, ignoring files which
+// are missing or can't be parsed
+func readCDecls(path string) []cDecl {
+    src, err := os.ReadFile(path)
+    if err != nil {
+        return nil
+    }
+
+    decls, err := parseC(path, src)
+    if err != nil {
+        log.Debugf("failed to parse %v: %v", path, err)
+        return nil
+    }
+
+    return decls
+}
+
+
+/**
+ * parseC finds the function prototypes and definitions in src, looking
+ * inside namespaces, extern "C" blocks and class bodies, where functions are
+ * methods of the class.
+ */
+func parseC(path string, src []byte) ([]cDecl, error) {
+    masked, comments := maskC(src)
+    decls := []cDecl{}
+
+    var scan func(from int, to int, container string) error
+    scan = func(from int, to int, container string) error {
+        chunk := from
+
+        for i := from; i < to; i++ {
+            switch masked[i] {
+            case '(', '[':
+                end := matching(masked, i)
+                if end < 0 {
+                    return fmt.Errorf("line %v: unclosed %c", lineNumber(src, i), masked[i])
+                }
+                i = end
+
+            case ';':
+                if n := cFunction(path, src, masked, comments, chunk, i, container); n != nil {
+                    n.End = nextLine(src, i)
+                    n.Source = strings.TrimRight(string(src[n.Start:n.End]), "\n")
+                    decls = append(decls, cDecl{ node: n })
+                }
+                chunk = i + 1
+
+            case '{':
+                end := matching(masked, i)
+                if end < 0 {
+                    return fmt.Errorf("line %v: unclosed {", lineNumber(src, i))
+                }
+
+                header := strings.TrimSpace(cAccess.ReplaceAllString(strings.TrimSpace(string(masked[chunk:i])), ""))
+
+                if cScope.MatchString(header) {
+                    if err := scan(i + 1, end, container); err != nil {
+                        return err
+                    }
+                    chunk = end + 1
+                } else if m := cRecord.FindStringSubmatch(header); m != nil {
+                    if err := scan(i + 1, end, m[1]); err != nil {
+                        return err
+                    }
+                    // The ; after the body ends the record, not a declaration
+                    chunk = -1
+                } else if n := cFunction(path, src, masked, comments, chunk, i, container); n != nil {
+                    n.End = nextLine(src, end)
+                    n.Source = strings.TrimRight(string(src[n.Start:n.End]), "\n")
+                    n.Calls = cCalls(string(masked[i + 1:end]))
+                    decls = append(decls, cDecl{ node: n, definition: true })
+                    chunk = end + 1
+                }
+
+                i = end
+
+                if chunk < 0 {
+                    for i + 1 < to && masked[i + 1] != ';' {
+                        i++
+                    }
+                    chunk = i + 2
+                    i++
+                }
+            }
+        }
+
+        return nil
+    }
+
+    if err := scan(0, len(masked), ""); err != nil {
+        return nil, err
+    }
+
+    return decls, nil
+}
+
+
+/**
+ * cFunction reads the declaration in masked[from:to] as a function header
+ * and returns its node, or nil when it's anything else: a variable, a type,
+ * a macro invocation and so on.
+ */
+func cFunction(path string, src []byte, masked []byte, comments []jsComment, from int, to int, container string) *Node {
+    for from < to && strings.IndexByte(" \t\r\n", masked[from]) >= 0 {
+        from++
+    }
+    if loc := cAccess.FindIndex(masked[from:to]); loc != nil {
+        from += loc[1]
+        for from < to && strings.IndexByte(" \t\r\n", masked[from]) >= 0 {
+            from++
+        }
+    }
+
+    header := string(masked[from:to])
+    if strings.TrimSpace(header) == "" {
+        return nil
+    }
+
+    // Skip a template<...> prefix when looking for the name
+    offset := 0
+    if strings.HasPrefix(header, "template") {
+        if open := strings.IndexByte(header, '<'); open >= 0 {
+            if end := angleEnd(header, open); end >= 0 {
+                offset = end + 1
+            }
+        }
+    }
+
+    paren := strings.IndexByte(header[offset:], '(')
+    if paren < 0 {
+        return nil
+    }
+    paren += offset
+
+    prefix := header[offset:paren]
+    m := cName.FindStringSubmatchIndex(prefix)
+    if m == nil {
+        return nil
+    }
+    qualified := cSpace.ReplaceAllString(prefix[m[2]:m[3]], "")
+    before := strings.TrimSpace(prefix[:m[2]])
+
+    first := strings.Fields(strings.TrimSpace(prefix) + " x")[0]
+    if cKeywords[first] || cKeywords[qualified] || strings.ContainsAny(before, "(=;{}") {
+        return nil
+    }
+
+    // Out of class definitions, e.g. geo::Shape::area, belong to the class
+    name := qualified
+    if i := strings.LastIndex(qualified, "::"); i >= 0 {
+        container, name = qualified[:i], qualified[i + 2:]
+        container = container[strings.LastIndex(container, ":") + 1:]
+    }
+
+    // A name with nothing before it is a call to a macro, unless it's a
+    // constructor or destructor
+    returns := strings.TrimSpace(cSpecifiers.ReplaceAllString(before, ""))
+    if returns == "" && strings.TrimPrefix(name, "~") != container {
+        return nil
+    }
+
+    close := matching(masked, from + paren)
+    if close < 0 || close > to {
+        return nil
+    }
+
+    n := &Node{
+        Kind:      "function",
+        Name:      name,
+        Container: container,
+        File:      path,
+        Line:      lineNumber(src, from),
+        Start:     from,
+        Signature: strings.Join(strings.Fields(string(src[from:to])), " "),
+        Returns:   returns,
+        Indent:    leadingSpace(src, lineStart(src, from)),
+        DocAt:     lineStart(src, from),
+    }
+    if container != "" {
+        n.Kind = "method"
+    }
+
+    // Trailing return types: auto f() -> int
+    if arrow := strings.Index(string(masked[close:to]), "->"); arrow >= 0 && returns == "auto" {
+        n.Returns = strings.TrimSpace(strings.SplitN(string(src[close + arrow + 2:to]), "{", 2)[0])
+    }
+
+    n.Params, n.ParamsKnown = cParams(string(src[from + paren + 1:close])), true
+
+    // A comment ending on the same line as code doesn't belong to it
+    if strings.TrimSpace(string(masked[n.DocAt:from])) != "" {
+        n.DocAt = from
+    }
+    n.Doc = cPrecedingDoc(src, comments, n.DocAt)
+
+    return n
+}
+
+
+// cParams reads a parameter list, naming unnamed parameters param<i>
+func cParams(list string) []Param {
+    params := []Param{}
+
+    for i, part := range splitTopLevel(list, ',') {
+        part = strings.TrimSpace(strings.SplitN(part, "=", 2)[0])
+        if part == "" || part == "void" {
+            continue
+        }
+        if part == "..." {
+            params = append(params, Param{ Name: "...", Optional: true })
+            continue
+        }
+
+        // Function pointers: int (*callback)(int)
+        if m := cFunctionPointer.FindStringSubmatch(part); m != nil {
+            params = append(params, Param{ Name: m[1], Type: strings.Replace(part, m[1], "", 1) })
+            continue
+        }
+
+        typ := part
+        name := ""
+        if m := cParamName.FindStringSubmatchIndex(part); m != nil {
+            candidate := part[m[2]:m[3]]
+            rest := strings.TrimSpace(part[:m[2]])
+            // A lone word like int or size_t is the type of an unnamed parameter
+            if rest != "" && rest != "const" && rest != "struct" && rest != "unsigned" && rest != "signed" && rest != "enum" {
+                name = candidate
+                typ = strings.TrimSpace(rest + part[m[4]:m[5]])
+            }
+        }
+        if name == "" {
+            name = fmt.Sprintf("param%v", i)
+        }
+
+        params = append(params, Param{ Name: name, Type: typ })
+    }
+
+    return params
+}
+
+
+// cParamNames names the parameters a prototype leaves unnamed, like
+// void list_free(struct list *), after those of its definition
+func cParamNames(prototype *Node, definition *Node) {
+    if len(prototype.Params) != len(definition.Params) {
+        return
+    }
+
+    for i := range prototype.Params {
+        if prototype.Params[i].Name == fmt.Sprintf("param%v", i) {
+            prototype.Params[i].Name = definition.Params[i].Name
+        }
+    }
+}
+
+
+func cCalls(body string) []string {
+    calls := []string{}
+
+    for _, m := range cCall.FindAllStringSubmatch(body, -1) {
+        name := strings.ReplaceAll(m[1], "->", ".")
+        if cKeywords[name] {
+            continue
+        }
+        calls = append(calls, name)
+    }
+
+    return calls
+}
+
+
+// cPrecedingDoc is the Doxygen comment, or run of /// lines, ending directly
+// above offset
+func cPrecedingDoc(src []byte, comments []jsComment, offset int) string {
+    doc := []string{}
+
+    for i := len(comments) - 1; i >= 0; i-- {
+        c := comments[i]
+        if c.end > offset {
+            continue
+        }
+        if strings.TrimSpace(string(src[c.end:offset])) != "" || !c.doc {
+            break
+        }
+
+        doc = append([]string{ string(src[c.start:c.end]) }, doc...)
+        offset = c.start
+    }
+
+    return strings.Join(doc, "\n")
+}
+
+
+/**
+ * DoxygenLines builds the lines of a Doxygen block for n from the model's
+ * text: @brief with the summary, the longer description, an @param for every
+ * parameter in the signature and @return unless the function returns void,
+ * then any other tags the model wrote.
+ */
+func DoxygenLines(n *Node, doc string) []string {
+    description, tags := ParseTags(doc)
+
+    paramText := map[string]string{}
+    returns := ""
+    others := []Tag{}
+
+    for _, tag := range tags {
+        switch tag.Name {
+        case "brief":
+            description = append([]string{ tag.Text, "" }, description...)
+        case "param":
+            paramText[tag.Arg] = tag.Text
+        case "returns":
+            returns = tag.Text
+        default:
+            others = append(others, tag)
+        }
+    }
+
+    for len(description) > 0 && strings.TrimSpace(description[0]) == "" {
+        description = description[1:]
+    }
+    for len(description) > 0 && strings.TrimSpace(description[len(description) - 1]) == "" {
+        description = description[:len(description) - 1]
+    }
+
+    lines := []string{}
+    if len(description) > 0 {
+        lines = append(lines, "@brief " + strings.TrimSpace(description[0]))
+        lines = append(lines, description[1:]...)
+    }
+
+    blockTags := []string{}
+    for _, p := range n.Params {
+        line := "@param " + p.Name
+        if text := paramText[p.Name]; text != "" {
+            line += " " + text
+        }
+        blockTags = append(blockTags, line)
+    }
+
+    isVoid := n.Returns == "" || n.Returns == "void" || strings.HasSuffix(n.Returns, " void")
+    if !isVoid {
+        line := "@return"
+        if returns != "" {
+            line += " " + returns
+        }
+        blockTags = append(blockTags, line)
+    }
+
+    for _, tag := range others {
+        line := "@" + tag.Name
+        if tag.Text != "" {
+            line += " " + tag.Text
+        }
+        blockTags = append(blockTags, line)
+    }
+
+    if len(blockTags) > 0 && len(lines) > 0 {
+        lines = append(lines, "")
+    }
+
+    return append(lines, blockTags...)
+}
+
+
+func (CBackend) Insert(src []byte, n *Node, doc string) ([]byte, error) {
+    if n.Doc != "" {
+        return src, nil
+    }
+
+    comment := RenderBlockComment(DoxygenLines(n, doc), n.Indent)
+
+    if n.DocAt != lineStart(src, n.DocAt) {
+        // The declaration shares its line with other code
+        return insertAt(src, n.DocAt, strings.TrimLeft(comment, " \t") + "\n" + n.Indent), nil
+    }
+
+    return insertAt(src, n.DocAt, comment + "\n"), nil
+}
@@ -0,0 +1,278 @@
+package docs
+
+import (
+    "os"
+    "slices"
+    "testing"
+    "path/filepath"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+)
+
+
+const listHeader = `#ifndef LIST_H
+#define LIST_H
+#include <stddef.h>
+
+/** Existing. */
+struct list *list_new(size_t cap);
+int list_push(struct list *l, void *item);
+void list_free(struct list *);
+size_t list_len(const struct list *);
+int list_each(struct list *l, int (*fn)(void *), ...);
+#endif
+`
+
+const listSource = `#include "list.h"
+#include <stdlib.h>
+
+static const char *names[] = { "a(", "b" };
+
+static int grow(struct list *l) {
+    return realloc(l->items, 2) != NULL;
+}
+
+struct list *list_new(size_t cap) {
+    struct list *l = malloc(sizeof(*l));
+    return l;
+}
+
+int list_push(struct list *l, void *item) {
+    if (!grow(l)) return -1;
+    l->ops->add(item);
+    return 0;
+}
+
+void list_free(struct list *l) {
+    free(l);
+}
+
+FOO_MACRO(bar);
+`
+
+const shapeSource = `namespace geo {
+class Shape : public Base {
+public:
+    Shape(int sides);
+    ~Shape();
+    virtual double area() const = 0;
+    template <typename T> T as() const { return T(); }
+private:
+    int sides_;
+};
+
+double Shape::perimeter(double side) const {
+    return side * sides_;
+}
+
+auto make(int n) -> Shape* { return new Shape(n); }
+}
+`
+
+
+type cWant struct {
+    kind    string
+    name    string
+    line    int
+    sig     string
+    params  []Param
+    returns string
+    calls   []string
+}
+
+
+func checkC(t *testing.T, lang types.SupportedFormat, path string, wants []cWant) []*Node {
+    t.Helper()
+
+    src, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    nodes, err := CBackend{ Lang: lang }.Parse(path, src)
+    if err != nil {
+        t.Fatalf("Parse(%v) error = %v", filepath.Base(path), err)
+    }
+    if len(nodes) != len(wants) {
+        t.Fatalf("Parse(%v) found %v functions, want %v", filepath.Base(path), len(nodes), len(wants))
+    }
+
+    for i, w := range wants {
+        n := nodes[i]
+        if n.Kind != w.kind || n.FullName() != w.name || n.Line != w.line || n.Signature != w.sig || n.Returns != w.returns {
+            t.Errorf("node %v = %v %v line %v %q returns %q, want %+v", i, n.Kind, n.FullName(), n.Line, n.Signature, n.Returns, w)
+        }
+        if !slices.Equal(n.Params, w.params) {
+            t.Errorf("%v params = %+v, want %+v", w.name, n.Params, w.params)
+        }
+        if !slices.Equal(n.Calls, w.calls) {
+            t.Errorf("%v calls %v, want %v", w.name, n.Calls, w.calls)
+        }
+    }
+
+    return nodes
+}
+
+
+func TestCParse(t *testing.T) {
+    dir := t.TempDir()
+    writeSource(t, dir, "list.h", listHeader)
+    writeSource(t, dir, "list.c", listSource)
+    writeSource(t, dir, "shape.cpp", shapeSource)
+
+    list := []Param{ { Name: "l", Type: "struct list *" } }
+
+    // Prototypes take their calls from the definitions in list.c
+    header := checkC(t, types.C, filepath.Join(dir, "list.h"), []cWant{
+        { kind: "function", name: "list_new", line: 6, sig: "struct list *list_new(size_t cap)",
+            params: []Param{ { Name: "cap", Type: "size_t" } }, returns: "struct list *", calls: []string{ "malloc" } },
+        { kind: "function", name: "list_push", line: 7, sig: "int list_push(struct list *l, void *item)",
+            params: append(list, Param{ Name: "item", Type: "void *" }), returns: "int", calls: []string{ "grow", "l.ops.add" } },
+        // Unnamed parameters are named after the definition, when there is one
+        { kind: "function", name: "list_free", line: 8, sig: "void list_free(struct list *)",
+            params: []Param{ { Name: "l", Type: "struct list *" } }, returns: "void", calls: []string{ "free" } },
+        { kind: "function", name: "list_len", line: 9, sig: "size_t list_len(const struct list *)",
+            params: []Param{ { Name: "param0", Type: "const struct list *" } }, returns: "size_t" },
+        { kind: "function", name: "list_each", line: 10, sig: "int list_each(struct list *l, int (*fn)(void *), ...)",
+            params: append(list, Param{ Name: "fn", Type: "int (*)(void *)" }, Param{ Name: "...", Optional: true }), returns: "int" },
+    })
+    if header[0].Doc != "/** Existing. */" {
+        t.Errorf("list_new doc = %q", header[0].Doc)
+    }
+
+    // What list.h declares is documented there, leaving the static helper.
+    // Arrays and macro calls aren't functions
+    checkC(t, types.C, filepath.Join(dir, "list.c"), []cWant{
+        { kind: "function", name: "grow", line: 6, sig: "static int grow(struct list *l)",
+            params: list, returns: "int", calls: []string{ "realloc" } },
+    })
+
+    checkC(t, types.Cpp, filepath.Join(dir, "shape.cpp"), []cWant{
+        { kind: "method", name: "Shape.Shape", line: 4, sig: "Shape(int sides)", params: []Param{ { Name: "sides", Type: "int" } } },
+        { kind: "method", name: "Shape.~Shape", line: 5, sig: "~Shape()", params: []Param{} },
+        { kind: "method", name: "Shape.area", line: 6, sig: "virtual double area() const = 0", params: []Param{}, returns: "double" },
+        { kind: "method", name: "Shape.as", line: 7, sig: "template <typename T> T as() const", params: []Param{}, returns: "T", calls: []string{ "T" } },
+        { kind: "method", name: "Shape.perimeter", line: 12, sig: "double Shape::perimeter(double side) const",
+            params: []Param{ { Name: "side", Type: "double" } }, returns: "double" },
+        { kind: "function", name: "make", line: 16, sig: "auto make(int n) -> Shape*",
+            params: []Param{ { Name: "n", Type: "int" } }, returns: "Shape*", calls: []string{ "Shape" } },
+    })
+}
+
+
+func writeSource(t *testing.T, dir string, name string, content string) {
+    t.Helper()
+
+    if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+        t.Fatal(err)
+    }
+}
+
+
+func TestCParseErrors(t *testing.T) {
+    for name, src := range map[string]string{
+        "unclosed body":       "int f(void) {\n    return 0;\n",
+        "unclosed parameters": "int f(int a;\n",
+    } {
+        if _, err := (CBackend{ Lang: types.C }).Parse("bad.c", []byte(src)); err == nil {
+            t.Errorf("Parse() of %v succeeded", name)
+        }
+    }
+}
+
+
+func TestCRender(t *testing.T) {
+    n := &Node{ Kind: "function", Returns: "int", Params: []Param{ { Name: "l" } }, ParamsKnown: true }
+    d := Doc{
+        Summary: "Push an item.",
+        Params:  []ParamDoc{ { Name: "l", Description: "The list." } },
+        Returns: "0, or -1 when out of memory.",
+        Errors:  []string{ "Fails when the list can't grow." },
+    }
+
+    tests := []struct {
+        lang types.SupportedFormat
+        want string
+    }{
+        // No exceptions in C
+        { lang: types.C, want: "Push an item.\n\nFails when the list can't grow.\n\n@param l The list.\n@return 0, or -1 when out of memory." },
+        { lang: types.Cpp, want: "Push an item.\n\n@param l The list.\n@return 0, or -1 when out of memory.\n@throws Fails when the list can't grow." },
+    }
+
+    for _, tt := range tests {
+        if got := (CBackend{ Lang: tt.lang }).Render(n, d); got != tt.want {
+            t.Errorf("Render() in %v =\n%v\nwant\n%v", tt.lang, got, tt.want)
+        }
+    }
+}
+
+
+func TestDoxygenLines(t *testing.T) {
+    n := &Node{ Kind: "function", Returns: "int", Params: []Param{ { Name: "l" }, { Name: "item" } }, ParamsKnown: true }
+
+    got := DoxygenLines(n, "Push an item.\n\nGrows the list when it's full.\n\n@param item What to add.\n@return 0, or -1 when out of memory.\n@note Not thread safe.")
+    want := []string{
+        "@brief Push an item.",
+        "",
+        "Grows the list when it's full.",
+        "",
+        "@param l",
+        "@param item What to add.",
+        "@return 0, or -1 when out of memory.",
+        "@note Not thread safe.",
+    }
+    if !slices.Equal(got, want) {
+        t.Errorf("DoxygenLines() = %q, want %q", got, want)
+    }
+
+    // Nothing is returned from void
+    n.Returns = "static void"
+    for _, line := range DoxygenLines(n, "Free it.\n@return nothing") {
+        if line == "@return nothing" {
+            t.Error("DoxygenLines() added @return to a void function")
+        }
+    }
+}
+
+
+func TestCInsert(t *testing.T) {
+    tests := []struct {
+        name string
+        src  string
+        want string
+    }{
+        {
+            name: "own line",
+            src:  "struct s {\n    int x;\n};\n\n  int get(struct s *p);\n",
+            want: "struct s {\n    int x;\n};\n\n  /**\n   * @brief Get x.\n   *\n   * @param p\n   * @return x.\n   */\n  int get(struct s *p);\n",
+        },
+        {
+            name: "after other code on the line",
+            src:  "int x; int get(struct s *p);\n",
+            want: "int x; /**\n * @brief Get x.\n *\n * @param p\n * @return x.\n */\nint get(struct s *p);\n",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            b := CBackend{ Lang: types.C }
+            nodes, err := b.Parse("s.h", []byte(tt.src))
+            if err != nil || len(nodes) != 1 {
+                t.Fatalf("Parse() = %v, %v", nodes, err)
+            }
+
+            got, err := b.Insert([]byte(tt.src), nodes[0], "Get x.\n@return x.")
+            if err != nil {
+                t.Fatalf("Insert() error = %v", err)
+            }
+            if string(got) != tt.want {
+                t.Fatalf("Insert() =\n%v\nwant\n%v", string(got), tt.want)
+            }
+
+            reparsed, err := b.Parse("s.h", got)
+            if err != nil || len(reparsed) != 1 || reparsed[0].Doc == "" {
+                t.Errorf("documented source reparsed as %v, %v", reparsed, err)
+            }
+        })
+    }
+}
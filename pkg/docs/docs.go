@@ -0,0 +1,424 @@
+package docs
+
+import (
+    "os"
+    "fmt"
+    "sort"
+    "errors"
+    "strings"
+
+    log "github.com/sirupsen/logrus"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/journal"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/prompts"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/openai/calls"
+)
+
+
+/**
+ * Node is one documentable declaration in a source file: a function, method,
+ * class, struct and so on. Start and End span the whole declaration,
+ * including decorators or attributes. New documentation is inserted at DocAt
+ * with Indent, which is where the language expects it: above the
+ * declaration for most languages, inside the body for Python.
+ */
+type Node struct {
+    Kind      string
+    Name      string
+    Container string
+    File      string
+    Line      int
+    Start     int
+    End       int
+    Signature string
+    Source    string
+    Doc       string
+    Calls     []string
+    // Declared parameters and return type, where the language has them
+    Params    []Param
+    Returns   string
+    // The backend read the parameters, so Params are all there are even
+    // when it's empty
+    ParamsKnown bool
+    // Things outside the file the declaration relies on, like the external
+    // commands a shell function runs
+    Uses      []string
+
+    DocAt     int
+    Indent    string
+    // The body starts on the same line as the declaration, e.g. def f(): pass
+    Inline    bool
+
+    // Declarations in the same file this one calls, filled in by CallGraph
+    Callees   []*Node
+    // Documentation generated for the node this run
+    Generated string
+}
+
+
+type Param struct {
+    Name     string
+    Type     string
+    Optional bool
+}
+
+
+// Backend parses the files of one language and writes its doc comments
+type Backend interface {
+    Language() types.SupportedFormat
+    // Parse finds every documentable declaration in src
+    Parse(path string, src []byte) ([]*Node, error)
+    // Style describes the documentation the backend writes, for the prompt
+    Style() string
+    // Render lays out the model's answer about n as the text Insert takes
+    Render(n *Node, d Doc) string
+    // Insert renders doc as a doc comment on n
+    Insert(src []byte, n *Node, doc string) ([]byte, error)
+}
+
+
+// Backends for every language other than Go, which pkg/ast handles
+var Backends = map[types.SupportedFormat]Backend{}
+
+
+func Register(b Backend) {
+    Backends[b.Language()] = b
+}
+
+
+// BackendForFile finds the backend for the language path is written in
+func BackendForFile(path string) (Backend, bool) {
+    info, err := os.Stat(path)
+    if err != nil || info.IsDir() {
+        return nil, false
+    }
+
+    lang, ok := types.DetectLanguage(path)
+    if !ok {
+        return nil, false
+    }
+
+    for format, b := range Backends {
+        if lang.Is(format) {
+            return b, true
+        }
+    }
+
+    return nil, false
+}
+
+
+// FullName is the node's name qualified by its container, e.g. Class.method
+func (n *Node) FullName() string {
+    if n.Container != "" {
+        return n.Container + "." + n.Name
+    }
+
+    return n.Name
+}
+
+
+func (n *Node) PrettyPrint(prefix string) {
+    fmt.Printf("%v%v %v (%v:%v)\n", prefix, n.Kind, n.FullName(), n.File, n.Line)
+
+    if n.Doc != "" {
+        fmt.Printf("%v  Documentation:\n%v\n", prefix, indentLines(n.Doc, prefix + "    "))
+    }
+
+    for _, callee := range n.Callees {
+        fmt.Printf("%v  Calls %v\n", prefix, callee.FullName())
+    }
+
+    if len(n.Uses) > 0 {
+        fmt.Printf("%v  Uses %v\n", prefix, strings.Join(n.Uses, ", "))
+    }
+}
+
+
+/**
+ * CallGraph links each node to the declarations in the same file it calls.
+ * A call is matched by its last name component, preferring a declaration in
+ * the caller's own container so self.save() finds the method on the same
+ * class.
+ */
+func CallGraph(nodes []*Node) {
+    byName := map[string][]*Node{}
+    for _, n := range nodes {
+        byName[n.Name] = append(byName[n.Name], n)
+    }
+
+    for _, n := range nodes {
+        n.Callees = []*Node{}
+
+        for _, call := range n.Calls {
+            name := call[strings.LastIndexAny(call, ".:") + 1:]
+
+            candidates := byName[name]
+            if len(candidates) == 0 {
+                continue
+            }
+
+            callee := candidates[0]
+            for _, c := range candidates {
+                if c.Container == n.Container {
+                    callee = c
+                    break
+                }
+            }
+
+            if callee != n && !containsNode(n.Callees, callee) {
+                n.Callees = append(n.Callees, callee)
+            }
+        }
+    }
+}
+
+
+func containsNode(nodes []*Node, n *Node) bool {
+    for _, other := range nodes {
+        if other == n {
+            return true
+        }
+    }
+
+    return false
+}
+
+
+// Ordered lists the nodes callees first, so each one is documented after
+// everything it calls. Cycles are broken where they're found
+func Ordered(nodes []*Node) []*Node {
+    ordered := []*Node{}
+    visited := map[*Node]bool{}
+
+    var visit func(n *Node)
+    visit = func(n *Node) {
+        if visited[n] {
+            return
+        }
+        visited[n] = true
+
+        for _, callee := range n.Callees {
+            visit(callee)
+        }
+
+        ordered = append(ordered, n)
+    }
+
+    for _, n := range nodes {
+        visit(n)
+    }
+
+    return ordered
+}
+
+
+// Prompt builds the system and user messages asking for the documentation of
+// n, including what's known about the functions it calls
+func Prompt(b Backend, n *Node) (string, string, error) {
+    data := prompts.Data{
+        Kind:     n.Kind,
+        Language: b.Language().Name(),
+        Style:    b.Style(),
+        Uses:     n.Uses,
+        Code:     n.Source,
+    }
+
+    for _, callee := range n.Callees {
+        doc := callee.Doc
+        if callee.Generated != "" {
+            doc = callee.Generated
+        }
+        if doc != "" {
+            data.Callees = append(data.Callees, prompts.Callee{ Name: callee.FullName(), Doc: doc })
+        }
+    }
+
+    if override, ok := config.DocStyles[string(b.Language())]; ok {
+        data.Style = override
+    }
+
+    system, err := prompts.Render("document_system", data)
+    if err != nil {
+        return "", "", err
+    }
+
+    msg, err := prompts.Render("document", data)
+
+    return system, msg, err
+}
+
+
+/**
+ * DocumentFile asks the model for documentation of every declaration in path
+ * which doesn't have any, callees first, and when write is set inserts it
+ * into the file. The result is parsed again before it's written, so a file is
+ * never left in a state the backend can't read.
+ */
+func DocumentFile(b Backend, path string, write bool) ([]*Node, error) {
+    src, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read %v: %v", path, err)
+    }
+
+    nodes, err := b.Parse(path, src)
+    if err != nil {
+        return nil, fmt.Errorf("failed to parse %v: %v", path, err)
+    }
+
+    CallGraph(nodes)
+
+    if !write {
+        return nodes, nil
+    }
+
+    // With -estimate every prompt is still built, so all of them are counted
+    estimated := false
+
+    for _, n := range Ordered(nodes) {
+        if n.Doc != "" {
+            continue
+        }
+
+        log.Infof("Documenting %v...", n.FullName())
+
+        system, prompt, err := Prompt(b, n)
+        if err != nil {
+            return nil, err
+        }
+
+        if generated, ok := journal.Lookup(path, n.FullName(), system, prompt); ok {
+            log.Debugf("Using the documentation of %v from the journal", n.FullName())
+            n.Generated = generated
+            continue
+        }
+
+        var doc Doc
+        err = calls.QueryJSON(path, system, prompt, DocSchema, &doc)
+        if errors.Is(err, calls.ErrEstimated) {
+            estimated = true
+            continue
+        } else if err != nil {
+            return nil, fmt.Errorf("failed to query 4.1 Nano for %v: %w", n.FullName(), err)
+        }
+
+        if doc.Skip || strings.TrimSpace(doc.Summary) == "" {
+            log.Infof("%v is clear from its signature, leaving it undocumented", n.FullName())
+        } else {
+            n.Generated = b.Render(n, doc)
+        }
+
+        if err := journal.Record(path, n.FullName(), n.Generated, system, prompt); err != nil {
+            return nil, err
+        }
+    }
+
+    if estimated {
+        return nodes, calls.ErrEstimated
+    }
+
+    // Insert from the end of the file so earlier offsets stay valid
+    pending := []*Node{}
+    for _, n := range nodes {
+        if n.Generated != "" {
+            pending = append(pending, n)
+        }
+    }
+    if len(pending) == 0 {
+        return nodes, nil
+    }
+    sort.SliceStable(pending, func(i, j int) bool { return pending[i].DocAt > pending[j].DocAt })
+
+    updated := src
+    for _, n := range pending {
+        updated, err = b.Insert(updated, n, n.Generated)
+        if err != nil {
+            return nil, fmt.Errorf("failed to insert docs for %v: %v", n.FullName(), err)
+        }
+    }
+
+    reparsed, err := b.Parse(path, updated)
+    if err != nil {
+        return nil, fmt.Errorf("documented %v no longer parses, leaving it untouched: %v", path, err)
+    }
+    if len(reparsed) != len(nodes) {
+        return nil, fmt.Errorf("documented %v has %v declarations instead of %v, leaving it untouched", path, len(reparsed), len(nodes))
+    }
+
+    if err := os.WriteFile(path, updated, 0644); err != nil {
+        return nil, fmt.Errorf("failed to write %v: %v", path, err)
+    }
+
+    log.Infof("Documented %v declaration(s) in %v", len(pending), path)
+
+    return nodes, nil
+}
+
+
+func indentLines(text string, indent string) string {
+    lines := strings.Split(text, "\n")
+    for i := range lines {
+        if lines[i] != "" {
+            lines[i] = indent + lines[i]
+        }
+    }
+
+    return strings.Join(lines, "\n")
+}
+
+
+// lineStart is the offset of the start of the line containing offset
+func lineStart(src []byte, offset int) int {
+    for offset > 0 && src[offset - 1] != '\n' {
+        offset--
+    }
+
+    return offset
+}
+
+
+// lineNumber is the 1 based line offset is on
+func lineNumber(src []byte, offset int) int {
+    return strings.Count(string(src[:offset]), "\n") + 1
+}
+
+
+// leadingSpace is the indentation of the line starting at offset
+func leadingSpace(src []byte, offset int) string {
+    end := offset
+    for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+        end++
+    }
+
+    return string(src[offset:end])
+}
+
+
+func insertAt(src []byte, offset int, text string) []byte {
+    return append(append(append([]byte{}, src[:offset]...), text...), src[offset:]...)
+}
+
+
+// RenderBlockComment wraps lines in a /** ... */ comment indented with indent
+func RenderBlockComment(lines []string, indent string) string {
+    var b strings.Builder
+
+    b.WriteString(indent + "/**\n")
+    for _, line := range lines {
+        if strings.TrimSpace(line) == "" {
+            b.WriteString(indent + " *\n")
+        } else {
+            b.WriteString(indent + " * " + strings.TrimRight(line, " \t") + "\n")
+        }
+    }
+    b.WriteString(indent + " */")
+
+    return b.String()
+}
+
+
+func replaceSpan(src []byte, start int, end int, text string) []byte {
+    return append(append(append([]byte{}, src[:start]...), text...), src[end:]...)
+}
@@ -0,0 +1,43 @@
+package docs
+
+import (
+    "testing"
+)
+
+
+func TestCallGraphAndOrdered(t *testing.T) {
+    save := &Node{ Name: "save", Container: "Store", Calls: []string{ "write" } }
+    write := &Node{ Name: "write", Calls: []string{ "print" } }
+    otherSave := &Node{ Name: "save", Container: "Cache" }
+    run := &Node{ Name: "run", Container: "Store", Calls: []string{ "self.save", "self.save", "run" } }
+    // A cycle
+    ping := &Node{ Name: "ping", Calls: []string{ "pong" } }
+    pong := &Node{ Name: "pong", Calls: []string{ "ping" } }
+
+    nodes := []*Node{ run, otherSave, save, write, ping, pong }
+    CallGraph(nodes)
+
+    // self.save() finds the method on the same class, once, and recursion
+    // isn't a callee
+    if len(run.Callees) != 1 || run.Callees[0] != save {
+        t.Errorf("run's callees = %v, want Store.save", run.Callees)
+    }
+    if len(save.Callees) != 1 || save.Callees[0] != write {
+        t.Errorf("save's callees = %v, want write", save.Callees)
+    }
+    if len(write.Callees) != 0 {
+        t.Errorf("write's callees = %v, print isn't in the file", write.Callees)
+    }
+
+    order := map[*Node]int{}
+    for i, n := range Ordered(nodes) {
+        order[n] = i
+    }
+
+    if len(order) != len(nodes) {
+        t.Fatalf("Ordered() has %v nodes, want %v", len(order), len(nodes))
+    }
+    if !(order[write] < order[save] && order[save] < order[run]) {
+        t.Errorf("Ordered() doesn't put callees first: %v", order)
+    }
+}
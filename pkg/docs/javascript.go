@@ -0,0 +1,888 @@
+package docs
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+)
+
+
+func init() {
+    Register(JSBackend{ Lang: types.JavaScript })
+    Register(JSBackend{ Lang: types.TypeScript })
+}
+
+
+/**
+ * JSBackend documents JavaScript and TypeScript with JSDoc blocks. It finds
+ * function declarations, arrow functions and function expressions assigned
+ * to consts, CommonJS exports, classes and their methods, and in TypeScript
+ * exported interfaces, type aliases and enums. @param and @returns tags are
+ * written by AutoScribe from the signature, so their types always match the
+ * TypeScript ones; the model only writes the descriptions.
+ */
+type JSBackend struct {
+    Lang types.SupportedFormat
+}
+
+
+func (b JSBackend) Language() types.SupportedFormat {
+    return b.Lang
+}
+
+
+func (JSBackend) Style() string {
+    return `The comment is JSDoc. Don't write types, they're added from the signature. Describe every parameter, and what a Promise resolves to in returns.`
+}
+
+
+// Render lays d out as JSDoc text, with @param, @returns and @throws tags
+// which JSDocLines completes from the signature
+func (JSBackend) Render(n *Node, d Doc) string {
+    lines := proseLines(d, d.SideEffects)
+
+    tags := []string{}
+    for _, p := range paramEntries(n, d) {
+        tags = append(tags, "@param " + p.Name + " - " + p.Description)
+    }
+    if returns := strings.TrimSpace(d.Returns); returns != "" {
+        tags = append(tags, "@returns " + returns)
+    }
+    for _, e := range d.Errors {
+        if e = strings.TrimSpace(e); e != "" {
+            tags = append(tags, "@throws " + e)
+        }
+    }
+
+    if len(tags) > 0 {
+        lines = append(append(lines, ""), tags...)
+    }
+
+    return strings.Join(lines, "\n")
+}
+
+
+// A comment's span in the source. Doc comments start with /**
+type jsComment struct {
+    start int
+    end   int
+    doc   bool
+}
+
+
+var jsFunction = regexp.MustCompile(`^(?:export\s+(?:default\s+)?)?(?:declare\s+)?(?:async\s+)?function\s*\*?\s*([A-Za-z_$][\w$]*)\s*(?:<[^(]*>)?\s*\(`)
+var jsArrow = regexp.MustCompile(`^(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b[^(]*\(|(?:<[^>]*>\s*)?\(|[A-Za-z_$][\w$]*\s*=>)`)
+var jsExports = regexp.MustCompile(`^(?:module\.)?exports\.([A-Za-z_$][\w$]*)\s*=\s*(?:async\s+)?(?:function\b[^(]*\(|\(|[A-Za-z_$][\w$]*\s*=>)`)
+var jsClass = regexp.MustCompile(`^(?:export\s+(?:default\s+)?)?(?:declare\s+)?(?:abstract\s+)?class\s+([A-Za-z_$][\w$]*)`)
+var jsTypeDecl = regexp.MustCompile(`^export\s+(?:declare\s+)?(interface|type|enum|const\s+enum)\s+([A-Za-z_$][\w$]*)`)
+var jsMethod = regexp.MustCompile(`^(?:(?:public|private|protected|static|async|readonly|override|abstract|get|set)\s+)*\*?\s*(#?[A-Za-z_$][\w$]*)\s*\??\s*(?:<[^(]*>)?\s*\(`)
+var jsPropertyArrow = regexp.MustCompile(`^(?:(?:public|private|protected|static|readonly)\s+)*(#?[A-Za-z_$][\w$]*)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:\(|[A-Za-z_$][\w$]*\s*=>)`)
+var jsDecorator = regexp.MustCompile(`^@[A-Za-z_$][\w$.]*(?:\(.*\))?\s*$`)
+var jsCall = regexp.MustCompile(`(#?[A-Za-z_$][\w$.]*)\s*(?:<[^()<>]*>)?\s*\(`)
+
+var jsKeywords = map[string]bool{
+    "if": true, "for": true, "while": true, "switch": true, "catch": true, "function": true,
+    "return": true, "typeof": true, "await": true, "yield": true, "super": true, "with": true,
+    "import": true, "void": true, "delete": true, "in": true, "of": true,
+}
+
+// Words before a / which mean it starts a regex rather than dividing
+var jsRegexAfterWords = []string{ "return", "typeof", "case", "do", "else", "in", "of", "new", "delete", "void", "throw", "yield", "await" }
+
+
+/**
+ * maskJS blanks out comments and the contents of strings, template literals
+ * and regex literals, keeping offsets and the newlines outside them, and
+ * returns the comments it found. Expressions inside ${ } are left as code.
+ */
+func maskJS(src []byte) ([]byte, []jsComment) {
+    masked := append([]byte{}, src...)
+    comments := []jsComment{}
+
+    // Brace depth of each ${ expression we're inside of
+    templates := []int{}
+
+    blank := func(from int, to int, keepNewlines bool) {
+        for k := from; k < to && k < len(masked); k++ {
+            if keepNewlines && masked[k] == '\n' {
+                continue
+            }
+            masked[k] = '_'
+        }
+    }
+
+    // scanTemplate masks template text from i up to the closing backtick or
+    // the next ${, returning where code resumes
+    scanTemplate := func(i int) int {
+        j := i
+        for j < len(src) {
+            if src[j] == '\\' {
+                j += 2
+                continue
+            }
+            if src[j] == '`' {
+                blank(i, j, true)
+                return j + 1
+            }
+            if src[j] == '$' && j + 1 < len(src) && src[j + 1] == '{' {
+                blank(i, j, true)
+                templates = append(templates, 0)
+                return j + 2
+            }
+            j++
+        }
+        blank(i, j, true)
+        return j
+    }
+
+    i := 0
+    for i < len(src) {
+        c := src[i]
+
+        switch {
+        case c == '/' && i + 1 < len(src) && src[i + 1] == '/':
+            j := i
+            for j < len(src) && src[j] != '\n' {
+                masked[j] = ' '
+                j++
+            }
+            comments = append(comments, jsComment{ start: i, end: j })
+            i = j
+
+        case c == '/' && i + 1 < len(src) && src[i + 1] == '*':
+            j := i + 2
+            for j + 1 < len(src) && !(src[j] == '*' && src[j + 1] == '/') {
+                j++
+            }
+            j = min(j + 2, len(src))
+            for k := i; k < j; k++ {
+                if masked[k] != '\n' {
+                    masked[k] = ' '
+                }
+            }
+            doc := strings.HasPrefix(string(src[i:j]), "/**") && string(src[i:j]) != "/**/"
+            comments = append(comments, jsComment{ start: i, end: j, doc: doc })
+            i = j
+
+        case c == '\'' || c == '"':
+            j := i + 1
+            for j < len(src) && src[j] != c && src[j] != '\n' {
+                if src[j] == '\\' {
+                    j++
+                }
+                j++
+            }
+            blank(i + 1, j, false)
+            i = j + 1
+
+        case c == '`':
+            i = scanTemplate(i + 1)
+
+        case c == '{' && len(templates) > 0:
+            templates[len(templates) - 1]++
+            i++
+
+        case c == '}' && len(templates) > 0:
+            if templates[len(templates) - 1] == 0 {
+                templates = templates[:len(templates) - 1]
+                i = scanTemplate(i + 1)
+            } else {
+                templates[len(templates) - 1]--
+                i++
+            }
+
+        case c == '/' && regexAllowed(masked, i):
+            j := i + 1
+            inClass := false
+            for j < len(src) && src[j] != '\n' {
+                if src[j] == '\\' {
+                    j += 2
+                    continue
+                }
+                if src[j] == '[' {
+                    inClass = true
+                } else if src[j] == ']' {
+                    inClass = false
+                } else if src[j] == '/' && !inClass {
+                    break
+                }
+                j++
+            }
+            blank(i + 1, j, false)
+            i = j + 1
+
+        default:
+            i++
+        }
+    }
+
+    return masked, comments
+}
+
+
+// regexAllowed reports whether a / at i starts a regex literal, judging by
+// what comes before it
+func regexAllowed(masked []byte, i int) bool {
+    j := i - 1
+    for j >= 0 && (masked[j] == ' ' || masked[j] == '\t' || masked[j] == '\n' || masked[j] == '\r') {
+        j--
+    }
+    if j < 0 {
+        return true
+    }
+
+    if strings.IndexByte("(,=:[!&|?{;+-*%~^", masked[j]) >= 0 {
+        return true
+    }
+
+    end := j + 1
+    for j >= 0 && isPyIdent(masked[j]) {
+        j--
+    }
+    word := string(masked[j + 1:end])
+    for _, w := range jsRegexAfterWords {
+        if word == w {
+            return true
+        }
+    }
+
+    return false
+}
+
+
+// matching finds the bracket closing the one at open, in masked source
+func matching(masked []byte, open int) int {
+    depth := 0
+    for i := open; i < len(masked); i++ {
+        switch masked[i] {
+        case '(', '[', '{':
+            depth++
+        case ')', ']', '}':
+            depth--
+            if depth == 0 {
+                return i
+            }
+        }
+    }
+
+    return -1
+}
+
+
+// braceDepths is the depth of {} nesting at the start of every line
+func braceDepths(masked []byte) []int {
+    depths := []int{ 0 }
+    depth := 0
+
+    for _, c := range masked {
+        switch c {
+        case '{':
+            depth++
+        case '}':
+            depth--
+        case '\n':
+            depths = append(depths, depth)
+        }
+    }
+
+    return depths
+}
+
+
+func (b JSBackend) Parse(path string, src []byte) ([]*Node, error) {
+    masked, comments := maskJS(src)
+    depths := braceDepths(masked)
+
+    nodes := []*Node{}
+    classes := []*Node{}
+    // Class body spans, to know which lines hold members
+    bodies := map[*Node][2]int{}
+
+    offset := 0
+    for lineIndex, depth := range depths {
+        start := offset
+        offset = nextLine(masked, offset)
+        if start >= len(masked) {
+            break
+        }
+
+        indent := leadingSpace(masked, start)
+        text := strings.TrimRight(string(masked[start + len(indent):offset]), "\r\n")
+        if text == "" {
+            continue
+        }
+
+        var n *Node
+        var m []int
+
+        // Members of the innermost class this line is directly inside of
+        var class *Node
+        for _, c := range classes {
+            span := bodies[c]
+            if start > span[0] && start < span[1] && depth == depths[lineNumber(src, span[0]) - 1] + 1 {
+                class = c
+            }
+        }
+
+        switch {
+        case class != nil:
+            if m = jsMethod.FindStringSubmatchIndex(text); m != nil && !jsKeywords[text[m[2]:m[3]]] {
+                n = &Node{ Kind: "method", Name: text[m[2]:m[3]], Container: class.Name }
+            } else if m = jsPropertyArrow.FindStringSubmatchIndex(text); m != nil {
+                n = &Node{ Kind: "method", Name: text[m[2]:m[3]], Container: class.Name }
+            }
+
+        case depth != 0:
+            // Only module level declarations are documented
+
+        case jsFunction.MatchString(text):
+            m = jsFunction.FindStringSubmatchIndex(text)
+            n = &Node{ Kind: "function", Name: text[m[2]:m[3]] }
+
+        case jsArrow.MatchString(text):
+            m = jsArrow.FindStringSubmatchIndex(text)
+            n = &Node{ Kind: "function", Name: text[m[2]:m[3]] }
+
+        case jsExports.MatchString(text):
+            m = jsExports.FindStringSubmatchIndex(text)
+            n = &Node{ Kind: "function", Name: text[m[2]:m[3]] }
+
+        case jsClass.MatchString(text):
+            m = jsClass.FindStringSubmatchIndex(text)
+            n = &Node{ Kind: "class", Name: text[m[2]:m[3]] }
+
+        case b.Lang == types.TypeScript && jsTypeDecl.MatchString(text):
+            m = jsTypeDecl.FindStringSubmatchIndex(text)
+            n = &Node{ Kind: text[m[2]:m[3]], Name: text[m[4]:m[5]] }
+            if strings.HasSuffix(n.Kind, "enum") {
+                n.Kind = "enum"
+            }
+        }
+
+        if n == nil {
+            continue
+        }
+
+        n.File = path
+        n.Line = lineIndex + 1
+        n.Start = start
+        n.Indent = indent
+        n.DocAt = start
+
+        declStart := start + len(indent)
+        matchEnd := declStart + m[1]
+
+        isFunction, err := b.completeNode(n, src, masked, declStart, matchEnd)
+        if err != nil {
+            return nil, fmt.Errorf("line %v: %v", n.Line, err)
+        }
+        if !isFunction {
+            continue
+        }
+
+        // Decorators above the declaration belong to it
+        for n.DocAt > 0 {
+            prev := lineStart(masked, n.DocAt - 1)
+            if !jsDecorator.MatchString(strings.TrimSpace(string(masked[prev:n.DocAt]))) {
+                break
+            }
+            n.DocAt = prev
+        }
+        n.Start = n.DocAt
+
+        n.Doc = precedingDoc(src, comments, n.DocAt)
+        n.Source = strings.TrimRight(string(src[n.Start:n.End]), "\n")
+
+        if n.Kind == "class" {
+            open := strings.IndexByte(string(masked[declStart:n.End]), '{')
+            bodies[n] = [2]int{ declStart + open, n.End - 1 }
+            classes = append(classes, n)
+        }
+
+        nodes = append(nodes, n)
+    }
+
+    return nodes, nil
+}
+
+
+/**
+ * completeNode reads the rest of a declaration whose header was matched up
+ * to matchEnd: its parameters, return type and body, setting Signature,
+ * Params, Returns, End and Calls. It returns false when what looked like an
+ * arrow function turns out to be some other expression in parentheses.
+ */
+func (b JSBackend) completeNode(n *Node, src []byte, masked []byte, declStart int, matchEnd int) (bool, error) {
+    switch n.Kind {
+    case "class", "interface", "enum":
+        open := strings.IndexByte(string(masked[declStart:]), '{')
+        if open < 0 {
+            return false, fmt.Errorf("%v %v has no body", n.Kind, n.Name)
+        }
+        end := matching(masked, declStart + open)
+        if end < 0 {
+            return false, fmt.Errorf("%v %v isn't closed", n.Kind, n.Name)
+        }
+        n.Signature = strings.TrimSpace(string(src[declStart:declStart + open]))
+        n.End = nextLine(src, end)
+        n.Calls = jsCalls(masked[declStart + open:end])
+        return true, nil
+
+    case "type":
+        end := statementEnd(masked, matchEnd)
+        n.Signature = strings.TrimSpace(string(src[declStart:end]))
+        n.End = nextLine(src, end - 1)
+        return true, nil
+    }
+
+    // Functions: the header match ends just after the ( of the parameters,
+    // or after the => of a single unparenthesized parameter
+    var params []Param
+    afterParams := matchEnd
+
+    if masked[matchEnd - 1] == '(' {
+        close := matching(masked, matchEnd - 1)
+        if close < 0 {
+            return false, fmt.Errorf("parameters of %v aren't closed", n.Name)
+        }
+        params = jsParams(string(src[matchEnd:close]))
+        afterParams = close + 1
+    } else {
+        arrow := strings.LastIndex(string(masked[declStart:matchEnd]), "=>")
+        fields := strings.Fields(strings.TrimSpace(string(masked[declStart:declStart + arrow])))
+        params = []Param{ { Name: fields[len(fields) - 1] } }
+        afterParams = declStart + arrow
+    }
+
+    // Return type, then either a { body or, for arrows, => and an expression
+    i := afterParams
+    for i < len(masked) && (masked[i] == ' ' || masked[i] == '\t') {
+        i++
+    }
+
+    if i < len(masked) && masked[i] == ':' {
+        typeStart := i + 1
+        i = typeEnd(masked, typeStart)
+        n.Returns = strings.TrimSpace(string(src[typeStart:i]))
+    }
+
+    for i < len(masked) && (masked[i] == ' ' || masked[i] == '\t' || masked[i] == '\n' || masked[i] == '\r') {
+        i++
+    }
+    header := string(masked[declStart:matchEnd])
+    isArrow := strings.Contains(header, "=") && !strings.Contains(header, "function")
+
+    if strings.HasPrefix(string(masked[i:]), "=>") {
+        isArrow = false
+        i += 2
+        for i < len(masked) && (masked[i] == ' ' || masked[i] == '\t' || masked[i] == '\n' || masked[i] == '\r') {
+            i++
+        }
+    }
+
+    // An arrow's parameters have to be followed by =>
+    if isArrow && masked[matchEnd - 1] == '(' {
+        return false, nil
+    }
+
+    n.Signature = strings.TrimSpace(string(src[declStart:i]))
+    n.Params, n.ParamsKnown = params, true
+
+    bodyStart := i
+    if i < len(masked) && masked[i] == '{' {
+        end := matching(masked, i)
+        if end < 0 {
+            return false, fmt.Errorf("body of %v isn't closed", n.Name)
+        }
+        n.End = nextLine(src, end)
+    } else if i < len(masked) && masked[i] == ';' {
+        // An overload or abstract method without a body
+        n.End = nextLine(src, i)
+    } else {
+        n.End = nextLine(src, statementEnd(masked, i) - 1)
+    }
+
+    n.Calls = jsCalls(masked[bodyStart:min(n.End, len(masked))])
+
+    return true, nil
+}
+
+
+// typeEnd finds where a return type annotation starting at i ends: at the {
+// of the body or the => of an arrow, outside any brackets of the type itself
+func typeEnd(masked []byte, i int) int {
+    depth := 0
+    for ; i < len(masked); i++ {
+        c := masked[i]
+
+        if depth == 0 && strings.HasPrefix(string(masked[i:min(len(masked), i + 2)]), "=>") {
+            return i
+        }
+
+        switch c {
+        case '(', '[', '<':
+            depth++
+        case ')', ']', '>':
+            depth--
+        case '{':
+            // An object type follows :, | or &, or opens a type argument
+            prev := strings.TrimRight(string(masked[:i]), " \t\r\n")
+            if depth == 0 && prev != "" && strings.IndexByte(":|&<,(", prev[len(prev) - 1]) < 0 {
+                return i
+            }
+            end := matching(masked, i)
+            if end < 0 {
+                return len(masked)
+            }
+            i = end
+        case ';', '\n':
+            if depth == 0 {
+                return i
+            }
+        }
+    }
+
+    return i
+}
+
+
+// statementEnd is the offset after the end of the statement starting at i:
+// a ; or a newline outside brackets which doesn't continue the expression
+func statementEnd(masked []byte, i int) int {
+    depth := 0
+    for ; i < len(masked); i++ {
+        switch masked[i] {
+        case '(', '[', '{':
+            depth++
+        case ')', ']', '}':
+            depth--
+            if depth < 0 {
+                return i
+            }
+        case ';':
+            if depth == 0 {
+                return i + 1
+            }
+        case '\n':
+            if depth == 0 {
+                next := strings.TrimSpace(string(masked[i + 1:nextLine(masked, i + 1)]))
+                if next == "" || strings.IndexByte(".|&?:+-*/,", next[0]) < 0 {
+                    return i + 1
+                }
+            }
+        }
+    }
+
+    return len(masked)
+}
+
+
+// jsParams splits a parameter list into names, TypeScript types and whether
+// they're optional. Destructured parameters are named param0, param1...
+func jsParams(list string) []Param {
+    params := []Param{}
+
+    for i, raw := range splitTopLevel(list, ',') {
+        raw = strings.TrimSpace(raw)
+        if raw == "" {
+            continue
+        }
+
+        // Decorators and constructor parameter properties
+        for {
+            fields := strings.Fields(raw)
+            if len(fields) > 1 && (strings.HasPrefix(fields[0], "@") || fields[0] == "public" || fields[0] == "private" || fields[0] == "protected" || fields[0] == "readonly" || fields[0] == "override") {
+                raw = strings.TrimSpace(strings.TrimPrefix(raw, fields[0]))
+                continue
+            }
+            break
+        }
+
+        p := Param{}
+
+        def := splitTopLevel(raw, '=')
+        if len(def) > 1 {
+            p.Optional = true
+        }
+        raw = strings.TrimSpace(def[0])
+
+        typed := splitTopLevel(raw, ':')
+        name := strings.TrimSpace(typed[0])
+        if len(typed) > 1 {
+            p.Type = strings.TrimSpace(strings.Join(typed[1:], ":"))
+        }
+
+        if strings.HasSuffix(name, "?") {
+            p.Optional = true
+            name = strings.TrimSuffix(name, "?")
+        }
+
+        if strings.HasPrefix(name, "...") {
+            name = strings.TrimPrefix(name, "...")
+            if p.Type != "" {
+                p.Type = "..." + strings.TrimSuffix(p.Type, "[]")
+            }
+        }
+
+        if strings.HasPrefix(name, "{") || strings.HasPrefix(name, "[") {
+            name = fmt.Sprintf("param%v", i)
+        }
+
+        p.Name = name
+        params = append(params, p)
+    }
+
+    return params
+}
+
+
+// splitTopLevel splits s at sep where it isn't inside brackets, strings or
+// an arrow (so a => b isn't split at its =, and Rust's -> doesn't close a <)
+func splitTopLevel(s string, sep byte) []string {
+    parts := []string{}
+    depth := 0
+    var quote byte
+    last := 0
+
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+
+        if quote != 0 {
+            if c == '\\' {
+                i++
+            } else if c == quote {
+                quote = 0
+            }
+            continue
+        }
+
+        switch c {
+        case '\'', '"', '`':
+            quote = c
+        case '(', '[', '{', '<':
+            depth++
+        case ')', ']', '}':
+            depth--
+        case '>':
+            if i == 0 || (s[i - 1] != '=' && s[i - 1] != '-') {
+                depth--
+            }
+        case sep:
+            if depth != 0 {
+                continue
+            }
+            if sep == '=' && ((i + 1 < len(s) && (s[i + 1] == '>' || s[i + 1] == '=')) || (i > 0 && strings.IndexByte("=!<>", s[i - 1]) >= 0)) {
+                continue
+            }
+            parts = append(parts, s[last:i])
+            last = i + 1
+        }
+    }
+
+    return append(parts, s[last:])
+}
+
+
+func jsCalls(body []byte) []string {
+    found := []string{}
+    text := string(body)
+
+    for _, m := range jsCall.FindAllStringSubmatchIndex(text, -1) {
+        name := text[m[2]:m[3]]
+        before := strings.TrimRight(text[:m[2]], " \t")
+        if jsKeywords[name] || strings.HasSuffix(before, "function") {
+            continue
+        }
+        found = append(found, strings.TrimPrefix(name, "this."))
+    }
+
+    return found
+}
+
+
+// precedingDoc is the doc comment ending just before offset, if any
+func precedingDoc(src []byte, comments []jsComment, offset int) string {
+    for i := len(comments) - 1; i >= 0; i-- {
+        c := comments[i]
+        if c.end > offset {
+            continue
+        }
+
+        if strings.TrimSpace(string(src[c.end:offset])) != "" {
+            return ""
+        }
+        if c.doc {
+            return string(src[c.start:c.end])
+        }
+        return ""
+    }
+
+    return ""
+}
+
+
+// Tag is one block tag (@param, @returns...) from documentation text
+type Tag struct {
+    Name string
+    Type string
+    Arg  string
+    Text string
+}
+
+
+var tagLine = regexp.MustCompile(`^[@\\]([A-Za-z]+)\s*(?:\{([^}]*)\})?\s*(.*)$`)
+
+
+/**
+ * ParseTags splits documentation text into its description lines and block
+ * tags. @param tags have their parameter name in Arg, with any "- "
+ * separating it from the text removed. Lines following a tag continue it.
+ */
+func ParseTags(doc string) ([]string, []Tag) {
+    description := []string{}
+    tags := []Tag{}
+
+    for _, line := range strings.Split(doc, "\n") {
+        trimmed := strings.TrimSpace(line)
+
+        m := tagLine.FindStringSubmatch(trimmed)
+        if m == nil {
+            if len(tags) == 0 {
+                description = append(description, line)
+            } else if trimmed != "" {
+                last := &tags[len(tags) - 1]
+                last.Text = strings.TrimSpace(last.Text + " " + trimmed)
+            }
+            continue
+        }
+
+        tag := Tag{ Name: m[1], Type: m[2], Text: m[3] }
+        switch tag.Name {
+        case "return":
+            tag.Name = "returns"
+        case "arg", "argument":
+            tag.Name = "param"
+        case "exception":
+            tag.Name = "throws"
+        }
+
+        if tag.Name == "param" {
+            fields := strings.Fields(tag.Text)
+            if len(fields) > 0 {
+                tag.Arg = strings.Trim(fields[0], "[]")
+                if eq := strings.IndexByte(tag.Arg, '='); eq >= 0 {
+                    tag.Arg = tag.Arg[:eq]
+                }
+                tag.Text = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(tag.Text, fields[0])), "-"))
+            }
+        }
+
+        tags = append(tags, tag)
+    }
+
+    // Drop blank lines left between the description and the tags
+    for len(description) > 0 && strings.TrimSpace(description[len(description) - 1]) == "" {
+        description = description[:len(description) - 1]
+    }
+
+    return description, tags
+}
+
+
+/**
+ * JSDocLines builds the lines of a JSDoc comment for n from the model's text:
+ * its description, an @param for every parameter in the signature (with the
+ * model's description where it wrote one), @returns unless the function
+ * returns nothing, then any other tags the model wrote.
+ */
+func JSDocLines(n *Node, doc string) []string {
+    description, tags := ParseTags(doc)
+
+    lines := append([]string{}, description...)
+    blockTags := []string{}
+
+    paramText := map[string]Tag{}
+    var returns *Tag
+    others := []Tag{}
+
+    for i, tag := range tags {
+        switch tag.Name {
+        case "param":
+            paramText[tag.Arg] = tag
+        case "returns":
+            returns = &tags[i]
+        default:
+            others = append(others, tag)
+        }
+    }
+
+    for _, p := range n.Params {
+        typ := p.Type
+        if typ == "" {
+            typ = paramText[p.Name].Type
+        }
+
+        name := p.Name
+        if p.Optional {
+            name = "[" + name + "]"
+        }
+
+        line := "@param "
+        if typ != "" {
+            line += "{" + typ + "} "
+        }
+        line += name
+        if text := paramText[p.Name].Text; text != "" {
+            line += " - " + text
+        }
+        blockTags = append(blockTags, line)
+    }
+
+    if (n.Kind == "function" || n.Kind == "method") && n.Name != "constructor" {
+        typ := n.Returns
+        if typ == "" && returns != nil {
+            typ = returns.Type
+        }
+
+        if typ != "void" && typ != "Promise<void>" && typ != "never" && (returns != nil || typ != "") {
+            line := "@returns"
+            if typ != "" {
+                line += " {" + typ + "}"
+            }
+            if returns != nil && returns.Text != "" {
+                line += " " + returns.Text
+            }
+            blockTags = append(blockTags, line)
+        }
+    }
+
+    for _, tag := range others {
+        line := "@" + tag.Name
+        if tag.Type != "" {
+            line += " {" + tag.Type + "}"
+        }
+        if tag.Text != "" {
+            line += " " + tag.Text
+        }
+        blockTags = append(blockTags, line)
+    }
+
+    if len(blockTags) > 0 && len(lines) > 0 {
+        lines = append(lines, "")
+    }
+
+    return append(lines, blockTags...)
+}
+
+
+func (JSBackend) Insert(src []byte, n *Node, doc string) ([]byte, error) {
+    if n.Doc != "" {
+        return src, nil
+    }
+
+    comment := RenderBlockComment(JSDocLines(n, doc), n.Indent)
+
+    return insertAt(src, n.DocAt, comment + "\n"), nil
+}
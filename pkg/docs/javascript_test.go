@@ -0,0 +1,240 @@
+package docs
+
+import (
+    "slices"
+    "strings"
+    "testing"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+)
+
+
+const tsSource = `import { x } from "./x";
+
+/** Adds. */
+export function add(a: number, b = 2): number {
+    return sum(a, b);
+}
+
+// plain comment
+export const greet = async (name?: string): Promise<void> => {
+    const re = /\(not(a)call/g;
+    console.log(` + "`hi ${format(name)} fake(`" + `);
+};
+
+const double = x => x * 2;
+const total = (1 + 2);
+
+exports.handler = function (event, ...rest) {
+    return double(event);
+};
+
+@Component({})
+export class Widget extends Base {
+    private count = 0;
+
+    constructor(private readonly el: Element) {
+        super();
+    }
+
+    async render({ title }: Props, ...children: Node[]): Promise<string> {
+        if (title) {
+            return this.format(title);
+        }
+        return "";
+    }
+
+    handle = (e) => this.render(e);
+}
+
+export interface Props {
+    title: string;
+}
+
+export type Id = string | number;
+
+export enum Color { Red, Green }
+
+function outer() {
+    function inner() {}
+}
+`
+
+
+func TestJSParse(t *testing.T) {
+    type want struct {
+        kind    string
+        name    string
+        line    int
+        sig     string
+        params  []Param
+        returns string
+        calls   []string
+    }
+
+    common := []want{
+        { kind: "function", name: "add", line: 4, sig: "export function add(a: number, b = 2): number",
+            params: []Param{ { Name: "a", Type: "number" }, { Name: "b", Optional: true } }, returns: "number", calls: []string{ "sum" } },
+        // Calls in regexes and template text aren't calls, ones in ${} are
+        { kind: "function", name: "greet", line: 9, sig: "export const greet = async (name?: string): Promise<void> =>",
+            params: []Param{ { Name: "name", Type: "string", Optional: true } }, returns: "Promise<void>", calls: []string{ "console.log", "format" } },
+        { kind: "function", name: "double", line: 14, sig: "const double = x =>", params: []Param{ { Name: "x" } } },
+        { kind: "function", name: "handler", line: 17, sig: "exports.handler = function (event, ...rest)",
+            params: []Param{ { Name: "event" }, { Name: "rest" } }, calls: []string{ "double" } },
+        { kind: "class", name: "Widget", line: 22, sig: "export class Widget extends Base" },
+        { kind: "method", name: "Widget.constructor", line: 25, sig: "constructor(private readonly el: Element)",
+            params: []Param{ { Name: "el", Type: "Element" } } },
+        { kind: "method", name: "Widget.render", line: 29, sig: "async render({ title }: Props, ...children: Node[]): Promise<string>",
+            params: []Param{ { Name: "param0", Type: "Props" }, { Name: "children", Type: "...Node" } }, returns: "Promise<string>", calls: []string{ "format" } },
+        { kind: "method", name: "Widget.handle", line: 36, sig: "handle = (e) =>", params: []Param{ { Name: "e" } }, calls: []string{ "render" } },
+    }
+    typescript := []want{
+        { kind: "interface", name: "Props", line: 39, sig: "export interface Props" },
+        { kind: "type", name: "Id", line: 43, sig: "export type Id = string | number;" },
+        { kind: "enum", name: "Color", line: 45, sig: "export enum Color" },
+    }
+    outer := want{ kind: "function", name: "outer", line: 47, sig: "function outer()" }
+
+    tests := map[types.SupportedFormat][]want{
+        types.TypeScript: append(append(append([]want{}, common...), typescript...), outer),
+        // Type declarations are only looked for in TypeScript
+        types.JavaScript: append(append([]want{}, common...), outer),
+    }
+
+    for lang, wants := range tests {
+        nodes, err := JSBackend{ Lang: lang }.Parse("widget", []byte(tsSource))
+        if err != nil {
+            t.Fatalf("%v Parse() error = %v", lang, err)
+        }
+        if len(nodes) != len(wants) {
+            t.Fatalf("%v Parse() found %v declarations, want %v", lang, len(nodes), len(wants))
+        }
+
+        for i, w := range wants {
+            n := nodes[i]
+            if n.Kind != w.kind || n.FullName() != w.name || n.Line != w.line || n.Signature != w.sig || n.Returns != w.returns {
+                t.Errorf("%v node %v = %v %v line %v %q returns %q, want %+v", lang, i, n.Kind, n.FullName(), n.Line, n.Signature, n.Returns, w)
+            }
+            if w.params != nil && !slices.Equal(n.Params, w.params) {
+                t.Errorf("%v %v params = %+v, want %+v", lang, w.name, n.Params, w.params)
+            }
+            if w.kind != "class" && !slices.Equal(n.Calls, w.calls) {
+                t.Errorf("%v %v calls %v, want %v", lang, w.name, n.Calls, w.calls)
+            }
+        }
+
+        if nodes[0].Doc != "/** Adds. */" || nodes[1].Doc != "" {
+            t.Errorf("%v docs = %q and %q, want only the /** */ one", lang, nodes[0].Doc, nodes[1].Doc)
+        }
+        if !strings.HasPrefix(nodes[4].Source, "@Component({})\nexport class") {
+            t.Errorf("%v decorated class source = %q", lang, nodes[4].Source)
+        }
+    }
+}
+
+
+func TestJSParseErrors(t *testing.T) {
+    tests := map[string]string{
+        "unclosed body":       "function f() {\n    return 1;\n",
+        "unclosed parameters": "function f(a, b {\n}\n",
+        "class without body":  "class A extends B\n",
+    }
+
+    for name, src := range tests {
+        if _, err := (JSBackend{ Lang: types.JavaScript }).Parse("bad.js", []byte(src)); err == nil {
+            t.Errorf("Parse() of %v succeeded", name)
+        }
+    }
+}
+
+
+func TestParseTags(t *testing.T) {
+    description, tags := ParseTags("Load a file.\n\nMore text.\n\n@param {string} [path=\".\"] - Where it is,\n  relative to the root.\n@return {Buffer} The contents.\n@exception NotFound when missing.")
+
+    if strings.Join(description, "|") != "Load a file.||More text." {
+        t.Errorf("description = %q", description)
+    }
+
+    want := []Tag{
+        { Name: "param", Type: "string", Arg: "path", Text: "Where it is, relative to the root." },
+        { Name: "returns", Type: "Buffer", Text: "The contents." },
+        { Name: "throws", Text: "NotFound when missing." },
+    }
+    if !slices.Equal(tags, want) {
+        t.Errorf("tags = %+v, want %+v", tags, want)
+    }
+}
+
+
+func TestJSInsert(t *testing.T) {
+    tests := []struct {
+        name string
+        lang types.SupportedFormat
+        src  string
+        doc  string
+        want string
+    }{
+        {
+            // Types come from the signature, whatever the model wrote
+            name: "typescript types win",
+            lang: types.TypeScript,
+            src:  "export function add(a: number, b?: number): number {\n    return a + (b ?? 0);\n}\n",
+            doc:  "Add two numbers.\n\n@param {string} a - First.\n@returns The sum.",
+            want: "/**\n * Add two numbers.\n *\n * @param {number} a - First.\n * @param {number} [b]\n * @returns {number} The sum.\n */\nexport function add(a: number, b?: number): number {\n    return a + (b ?? 0);\n}\n",
+        },
+        {
+            name: "javascript keeps the model's types",
+            lang: types.JavaScript,
+            src:  "function add(a, b) {\n    return a + b;\n}\n",
+            doc:  "Add.\n@param {number} a - First.\n@returns {number} The sum.\n@throws {TypeError} Never.",
+            want: "/**\n * Add.\n *\n * @param {number} a - First.\n * @param b\n * @returns {number} The sum.\n * @throws {TypeError} Never.\n */\nfunction add(a, b) {\n    return a + b;\n}\n",
+        },
+        {
+            name: "void methods have no returns, above the decorator",
+            lang: types.TypeScript,
+            src:  "class A {\n    @log()\n    run(): void {\n    }\n}\n",
+            doc:  "Run it.\n@returns Nothing.",
+            want: "class A {\n    /**\n     * Run it.\n     */\n    @log()\n    run(): void {\n    }\n}\n",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            b := JSBackend{ Lang: tt.lang }
+            nodes, err := b.Parse("f", []byte(tt.src))
+            if err != nil {
+                t.Fatalf("Parse() error = %v", err)
+            }
+
+            n := nodes[len(nodes) - 1]
+            got, err := b.Insert([]byte(tt.src), n, tt.doc)
+            if err != nil {
+                t.Fatalf("Insert() error = %v", err)
+            }
+            if string(got) != tt.want {
+                t.Fatalf("Insert() =\n%v\nwant\n%v", string(got), tt.want)
+            }
+
+            reparsed, err := b.Parse("f", got)
+            if err != nil || len(reparsed) != len(nodes) || reparsed[len(reparsed) - 1].Doc == "" {
+                t.Errorf("documented source reparsed as %v, %v", reparsed, err)
+            }
+        })
+    }
+}
+
+
+func TestJSRender(t *testing.T) {
+    n := &Node{ Params: []Param{ { Name: "a" }, { Name: "b" } }, ParamsKnown: true }
+    d := Doc{
+        Summary: "Add.",
+        Params:  []ParamDoc{ { Name: "b", Description: "Second." }, { Name: "a", Description: "First." } },
+        Returns: "The sum.",
+        Errors:  []string{ "TypeError when they aren't numbers." },
+    }
+
+    want := "Add.\n\n@param a - First.\n@param b - Second.\n@returns The sum.\n@throws TypeError when they aren't numbers."
+    if got := (JSBackend{}).Render(n, d); got != want {
+        t.Errorf("Render() =\n%v\nwant\n%v", got, want)
+    }
+}
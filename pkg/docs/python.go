@@ -0,0 +1,531 @@
+package docs
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+)
+
+
+func init() {
+    Register(PythonBackend{})
+}
+
+
+/**
+ * PythonBackend documents Python with PEP 257 docstrings. It reads the source
+ * with its own scanner (no interpreter needed) which understands indentation,
+ * brackets, line continuations, comments and every form of string literal,
+ * which is enough to find def and class blocks, their existing docstrings
+ * and the calls they make.
+ */
+type PythonBackend struct {}
+
+
+func (PythonBackend) Language() types.SupportedFormat {
+    return types.Python
+}
+
+
+func (PythonBackend) Style() string {
+    return `The docstring follows PEP 257, with Google style sections. Write the summary as one line ending in a period. Leave out self and cls, and use the type hints when present. Name the exception class at the start of each error. For a generator, say what it yields in returns.`
+}
+
+
+// Render lays d out as a docstring body with Args, Returns and Raises sections
+func (PythonBackend) Render(n *Node, d Doc) string {
+    lines := proseLines(d, d.SideEffects)
+
+    args := []string{}
+    for _, p := range paramEntries(n, d) {
+        if p.Name != "self" && p.Name != "cls" {
+            args = append(args, p.Name + ": " + p.Description)
+        }
+    }
+
+    heading := "Returns:"
+    if strings.Contains(n.Source, "yield") {
+        heading = "Yields:"
+    }
+
+    lines = section(lines, "Args:", "    ", args)
+    lines = section(lines, heading, "    ", []string{ d.Returns })
+    lines = section(lines, "Raises:", "    ", d.Errors)
+
+    return strings.Join(lines, "\n")
+}
+
+
+// A logical line: one or more physical lines joined by brackets or a
+// trailing backslash
+type pyLine struct {
+    start  int
+    end    int
+    indent string
+    text   string
+}
+
+
+// A string literal's span in the source
+type pyString struct {
+    start int
+    end   int
+}
+
+
+var pyHeader = regexp.MustCompile(`^(?:async\s+)?(def|class)\s+([A-Za-z_]\w*)`)
+var pyCall = regexp.MustCompile(`([A-Za-z_][\w.]*)\s*\(`)
+var pyStringPrefix = regexp.MustCompile(`(?i)^(?:r|u|b|br|rb|f|fr|rf)?$`)
+
+var pyKeywords = map[string]bool{
+    "if": true, "elif": true, "while": true, "for": true, "return": true, "yield": true,
+    "not": true, "and": true, "or": true, "in": true, "is": true, "await": true,
+    "assert": true, "del": true, "lambda": true, "with": true,
+    "except": true, "raise": true, "def": true, "class": true, "import": true,
+}
+
+
+/**
+ * maskPython blanks out comments and the insides of string literals, keeping
+ * every offset (and newline outside strings) where it was, so the structure
+ * of the code can be read with simple scanning. It returns the masked source
+ * and the spans of the string literals.
+ */
+func maskPython(src []byte) ([]byte, []pyString, error) {
+    masked := append([]byte{}, src...)
+    strs := []pyString{}
+
+    for i := 0; i < len(src); i++ {
+        c := src[i]
+
+        if c == '#' {
+            for i < len(src) && src[i] != '\n' {
+                masked[i] = ' '
+                i++
+            }
+            i--
+            continue
+        }
+
+        if c != '"' && c != '\'' {
+            continue
+        }
+
+        // Include a prefix like rb or f in the literal
+        start := i
+        for start > 0 && isPyIdent(src[start - 1]) {
+            start--
+        }
+        if !pyStringPrefix.Match(src[start:i]) {
+            start = i
+        }
+
+        quote := string(c)
+        if i + 2 < len(src) && src[i + 1] == c && src[i + 2] == c {
+            quote = strings.Repeat(string(c), 3)
+        }
+
+        j := i + len(quote)
+        closed := false
+        for j < len(src) {
+            // Even a raw string can't end with an escaped quote
+            if src[j] == '\\' {
+                j += 2
+                continue
+            }
+            if len(quote) == 1 && src[j] == '\n' {
+                break
+            }
+            if strings.HasPrefix(string(src[j:min(len(src), j + len(quote))]), quote) {
+                j += len(quote)
+                closed = true
+                break
+            }
+            j++
+        }
+
+        if !closed {
+            return nil, nil, fmt.Errorf("unterminated string on line %v", lineNumber(src, i))
+        }
+
+        for k := i + len(quote); k < j - len(quote); k++ {
+            masked[k] = '_'
+        }
+
+        strs = append(strs, pyString{ start: start, end: j })
+        i = j - 1
+    }
+
+    return masked, strs, nil
+}
+
+
+func isPyIdent(c byte) bool {
+    return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+
+// pythonLines splits the masked source into its non blank logical lines
+func pythonLines(masked []byte) ([]pyLine, error) {
+    lines := []pyLine{}
+    depth := 0
+    start := 0
+
+    flush := func(end int) {
+        text := strings.TrimSpace(string(masked[start:end]))
+        if text != "" {
+            first := lineStart(masked, start)
+            // Skip blank physical lines at the start of the logical line
+            for first < end && strings.TrimSpace(string(masked[first:nextLine(masked, first)])) == "" {
+                first = nextLine(masked, first)
+            }
+            lines = append(lines, pyLine{
+                start:  first,
+                end:    end,
+                indent: leadingSpace(masked, first),
+                text:   text,
+            })
+        }
+        start = end
+    }
+
+    for i := 0; i < len(masked); i++ {
+        switch masked[i] {
+        case '(', '[', '{':
+            depth++
+        case ')', ']', '}':
+            if depth > 0 {
+                depth--
+            }
+        case '\\':
+            if i + 1 < len(masked) && masked[i + 1] == '\n' {
+                i++
+            }
+        case '\n':
+            if depth == 0 {
+                flush(i + 1)
+            }
+        }
+    }
+
+    if depth != 0 {
+        return nil, fmt.Errorf("unbalanced brackets")
+    }
+    flush(len(masked))
+
+    return lines, nil
+}
+
+
+func nextLine(src []byte, offset int) int {
+    for offset < len(src) && src[offset] != '\n' {
+        offset++
+    }
+    if offset < len(src) {
+        offset++
+    }
+
+    return offset
+}
+
+
+// headerColon finds the colon ending a def or class header in a logical
+// line, the first one outside any brackets
+func headerColon(masked []byte, line pyLine) int {
+    depth := 0
+    for i := line.start; i < line.end; i++ {
+        switch masked[i] {
+        case '(', '[', '{':
+            depth++
+        case ')', ']', '}':
+            depth--
+        case ':':
+            if depth == 0 {
+                return i
+            }
+        }
+    }
+
+    return -1
+}
+
+
+func (PythonBackend) Parse(path string, src []byte) ([]*Node, error) {
+    masked, strs, err := maskPython(src)
+    if err != nil {
+        return nil, err
+    }
+
+    lines, err := pythonLines(masked)
+    if err != nil {
+        return nil, err
+    }
+
+    isDocstring := func(start int, end int) (string, bool) {
+        text := strings.TrimSpace(string(src[start:end]))
+        trimmedStart := start + strings.Index(string(src[start:end]), text)
+        for _, s := range strs {
+            if s.start == trimmedStart && s.end == trimmedStart + len(text) {
+                return text, true
+            }
+        }
+        return "", false
+    }
+
+    type open struct {
+        node   *Node
+        indent string
+    }
+
+    nodes := []*Node{}
+    stack := []open{}
+
+    for i, line := range lines {
+        // Close the blocks this line is outside of
+        for len(stack) > 0 && len(line.indent) <= len(stack[len(stack) - 1].indent) {
+            stack = stack[:len(stack) - 1]
+        }
+
+        m := pyHeader.FindStringSubmatch(line.text)
+        if m == nil {
+            continue
+        }
+
+        colon := headerColon(masked, line)
+        if colon < 0 {
+            return nil, fmt.Errorf("line %v: %v header without a colon", lineNumber(src, line.start), m[1])
+        }
+
+        n := &Node{
+            Kind:      "function",
+            Name:      m[2],
+            File:      path,
+            Line:      lineNumber(src, line.start),
+            Start:     line.start,
+            Signature: strings.TrimSpace(string(src[line.start:colon + 1])),
+        }
+        if m[1] == "class" {
+            n.Kind = "class"
+        } else if open := strings.IndexByte(string(masked[line.start:colon]), '('); open >= 0 {
+            open += line.start
+            if close := matching(masked, open); close > open && close < colon {
+                n.Params, n.ParamsKnown = pyParams(src, masked, open + 1, close), true
+            }
+        }
+
+        // Decorators belong to the declaration
+        for j := i - 1; j >= 0 && lines[j].indent == line.indent && strings.HasPrefix(lines[j].text, "@"); j-- {
+            n.Start = lines[j].start
+        }
+
+        if len(stack) > 0 {
+            parent := stack[len(stack) - 1].node
+            n.Container = parent.Name
+            if parent.Kind == "class" && n.Kind == "function" {
+                n.Kind = "method"
+            }
+        }
+
+        rest := strings.TrimSpace(string(masked[colon + 1:line.end]))
+        n.End = line.end
+
+        if rest != "" {
+            // def f(): return 1
+            n.Inline = true
+            n.DocAt = colon + 1
+            n.Indent = line.indent + indentUnit(lines)
+            if doc, ok := isDocstring(colon + 1, firstStatementEnd(masked, colon + 1, line.end)); ok {
+                n.Doc = doc
+            }
+        } else {
+            body := []pyLine{}
+            for _, next := range lines[i + 1:] {
+                if len(next.indent) <= len(line.indent) {
+                    break
+                }
+                body = append(body, next)
+            }
+            if len(body) == 0 {
+                return nil, fmt.Errorf("line %v: %v %v has no body", n.Line, m[1], n.Name)
+            }
+
+            n.End = body[len(body) - 1].end
+            n.DocAt = line.end
+            n.Indent = body[0].indent
+            if doc, ok := isDocstring(body[0].start, firstStatementEnd(masked, body[0].start, body[0].end)); ok {
+                n.Doc = doc
+            }
+        }
+
+        n.Source = strings.TrimRight(string(src[n.Start:n.End]), "\n")
+
+        body := string(masked[colon + 1:n.End])
+        for _, call := range pyCall.FindAllStringSubmatchIndex(body, -1) {
+            name := body[call[2]:call[3]]
+            before := strings.TrimRight(body[:call[2]], " \t")
+            // Nested declarations aren't calls
+            if pyKeywords[name] || strings.HasSuffix(before, "def") || strings.HasSuffix(before, "class") {
+                continue
+            }
+            n.Calls = append(n.Calls, name)
+        }
+
+        nodes = append(nodes, n)
+        stack = append(stack, open{ node: n, indent: line.indent })
+    }
+
+    return nodes, nil
+}
+
+
+/**
+ * pyParams reads the parameters of a def between start and end: their names,
+ * with * or ** for variadic ones, their annotations and whether they have a
+ * default. The self or cls of a method and the bare * and / markers aren't
+ * parameters a caller passes, and are left out.
+ */
+func pyParams(src []byte, masked []byte, start int, end int) []Param {
+    params := []Param{}
+
+    // Where the top level commas, and the first colon and equals sign of
+    // each parameter, are
+    from, depth, colon, equals := start, 0, -1, -1
+    for i := start; i <= end; i++ {
+        c := byte(',')
+        if i < end {
+            c = masked[i]
+        }
+
+        switch c {
+        case '(', '[', '{':
+            depth++
+        case ')', ']', '}':
+            depth--
+        case ':':
+            if depth == 0 && colon < 0 && equals < 0 {
+                colon = i
+            }
+        case '=':
+            if depth == 0 && equals < 0 {
+                equals = i
+            }
+        }
+        if c != ',' || depth != 0 {
+            continue
+        }
+
+        nameEnd := i
+        if equals >= 0 {
+            nameEnd = equals
+        }
+        p := Param{ Optional: equals >= 0 }
+        if colon >= 0 {
+            p.Type = strings.TrimSpace(string(src[colon + 1:nameEnd]))
+            nameEnd = colon
+        }
+        p.Name = strings.TrimSpace(string(masked[from:nameEnd]))
+
+        first := len(params) == 0 && strings.TrimSpace(string(masked[start:from])) == ""
+        if p.Name != "" && p.Name != "*" && p.Name != "/" && !(first && (p.Name == "self" || p.Name == "cls")) {
+            params = append(params, p)
+        }
+
+        from, colon, equals = i + 1, -1, -1
+    }
+
+    return params
+}
+
+
+// firstStatementEnd is where the first statement of a logical line ends, at
+// a semicolon outside brackets or the end of the line
+func firstStatementEnd(masked []byte, start int, end int) int {
+    depth := 0
+    for i := start; i < end; i++ {
+        switch masked[i] {
+        case '(', '[', '{':
+            depth++
+        case ')', ']', '}':
+            depth--
+        case ';':
+            if depth == 0 {
+                return i
+            }
+        }
+    }
+
+    return end
+}
+
+
+// indentUnit is the indentation the file adds per block, four spaces if it
+// has no blocks to learn from
+func indentUnit(lines []pyLine) string {
+    for i := 1; i < len(lines); i++ {
+        prev, cur := lines[i - 1].indent, lines[i].indent
+        if len(cur) > len(prev) && strings.HasPrefix(cur, prev) {
+            return cur[len(prev):]
+        }
+    }
+
+    return "    "
+}
+
+
+/**
+ * RenderDocstring formats doc as a PEP 257 docstring indented with indent:
+ * """Summary.""" on one line when it's a single line, otherwise the summary
+ * on the opening line and the closing quotes on their own line. Docstrings
+ * containing backslashes are raw.
+ */
+func RenderDocstring(doc string, indent string) string {
+    doc = strings.TrimSpace(strings.ReplaceAll(doc, `"""`, `\"\"\"`))
+
+    prefix := ""
+    if strings.Contains(doc, `\`) && !strings.Contains(doc, `\"\"\"`) {
+        prefix = "r"
+    }
+
+    lines := strings.Split(doc, "\n")
+    if len(lines) == 1 {
+        return fmt.Sprintf(`%v%v"""%v"""`, indent, prefix, doc)
+    }
+
+    var b strings.Builder
+    fmt.Fprintf(&b, `%v%v"""%v`, indent, prefix, strings.TrimSpace(lines[0]))
+    b.WriteString("\n")
+
+    for _, line := range lines[1:] {
+        if strings.TrimSpace(line) == "" {
+            b.WriteString("\n")
+        } else {
+            b.WriteString(indent + strings.TrimRight(line, " \t") + "\n")
+        }
+    }
+
+    b.WriteString(indent + `"""`)
+
+    return b.String()
+}
+
+
+func (PythonBackend) Insert(src []byte, n *Node, doc string) ([]byte, error) {
+    if n.Doc != "" {
+        return src, nil
+    }
+
+    docstring := RenderDocstring(doc, n.Indent)
+
+    if n.Inline {
+        // Move the body onto its own line below the docstring
+        at := n.DocAt
+        for at < len(src) && (src[at] == ' ' || src[at] == '\t') {
+            at++
+        }
+        return replaceSpan(src, n.DocAt, at, "\n" + docstring + "\n" + n.Indent), nil
+    }
+
+    return insertAt(src, n.DocAt, docstring + "\n"), nil
+}
@@ -0,0 +1,260 @@
+package docs
+
+import (
+    "slices"
+    "strings"
+    "testing"
+)
+
+
+const pythonSource = `import os
+
+
+@dataclass
+class Store:
+    """Keeps things."""
+
+    def __init__(self, path):
+        self.path = path
+
+    @property
+    def size(self) -> int:
+        return len(self.load())
+
+    def load(self):
+        # not a call: fake(
+        text = "also not a call: fake("
+        return open(self.path).read().split(
+            "\n",
+        )
+
+
+def helper(x): return x * 2
+
+
+async def fetch(url, *, retries=3):
+    '''Fetch url.'''
+    def inner():
+        pass
+    return await inner()
+`
+
+
+func TestPythonParse(t *testing.T) {
+    nodes, err := PythonBackend{}.Parse("store.py", []byte(pythonSource))
+    if err != nil {
+        t.Fatalf("Parse() error = %v", err)
+    }
+
+    type want struct {
+        kind   string
+        name   string
+        line   int
+        doc    string
+        inline bool
+        calls  []string
+    }
+
+    wants := []want{
+        { kind: "class", name: "Store", line: 5, doc: `"""Keeps things."""` },
+        { kind: "method", name: "Store.__init__", line: 8 },
+        { kind: "method", name: "Store.size", line: 12, calls: []string{ "len", "self.load" } },
+        { kind: "method", name: "Store.load", line: 15, calls: []string{ "open", "read", "split" } },
+        { kind: "function", name: "helper", line: 23, inline: true },
+        { kind: "function", name: "fetch", line: 26, doc: `'''Fetch url.'''`, calls: []string{ "inner" } },
+        { kind: "function", name: "fetch.inner", line: 28 },
+    }
+
+    if len(nodes) != len(wants) {
+        t.Fatalf("Parse() found %v declarations, want %v", len(nodes), len(wants))
+    }
+
+    for i, w := range wants {
+        n := nodes[i]
+        if n.Kind != w.kind || n.FullName() != w.name || n.Line != w.line || n.Doc != w.doc || n.Inline != w.inline {
+            t.Errorf("node %v = %v %v line %v doc %q inline %v, want %+v", i, n.Kind, n.FullName(), n.Line, n.Doc, n.Inline, w)
+        }
+        if w.calls != nil && !slices.Equal(n.Calls, w.calls) {
+            t.Errorf("%v calls %v, want %v", w.name, n.Calls, w.calls)
+        }
+    }
+
+    // Decorators are part of the declaration, Line is the def itself
+    if !strings.HasPrefix(nodes[0].Source, "@dataclass") || !strings.HasPrefix(nodes[2].Source, "    @property") {
+        t.Errorf("decorated sources = %q and %q", nodes[0].Source, nodes[2].Source)
+    }
+
+    if nodes[3].Signature != "def load(self):" || nodes[5].Signature != "async def fetch(url, *, retries=3):" {
+        t.Errorf("signatures = %q and %q", nodes[3].Signature, nodes[5].Signature)
+    }
+}
+
+
+func TestPythonParseErrors(t *testing.T) {
+    tests := map[string]string{
+        "unterminated string": "def f():\n    return 'oops\n",
+        "unbalanced brackets": "def f():\n    return g(1\n",
+        "no body":             "def f():\n\nx = 1\n",
+        "no colon":            "def f()\n    pass\n",
+    }
+
+    for name, src := range tests {
+        if _, err := (PythonBackend{}).Parse("bad.py", []byte(src)); err == nil {
+            t.Errorf("Parse() of %v succeeded", name)
+        }
+    }
+}
+
+
+func TestPythonInsert(t *testing.T) {
+    tests := []struct {
+        name string
+        src  string
+        doc  string
+        want string
+    }{
+        {
+            name: "one line",
+            src:  "def f():\n    return 1\n",
+            doc:  "Return one.",
+            want: "def f():\n    \"\"\"Return one.\"\"\"\n    return 1\n",
+        },
+        {
+            name: "several lines",
+            src:  "class A:\n\tdef f(self):\n\t\tpass\n",
+            doc:  "Do nothing.\n\nReturns:\n    None",
+            want: "class A:\n\tdef f(self):\n\t\t\"\"\"Do nothing.\n\n\t\tReturns:\n\t\t    None\n\t\t\"\"\"\n\t\tpass\n",
+        },
+        {
+            name: "inline body",
+            src:  "def f(): return 1\n",
+            doc:  "Return one.",
+            want: "def f():\n    \"\"\"Return one.\"\"\"\n    return 1\n",
+        },
+        {
+            name: "backslashes make it raw",
+            src:  "def f():\n    pass\n",
+            doc:  `Match \d.`,
+            want: "def f():\n    r\"\"\"Match \\d.\"\"\"\n    pass\n",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            b := PythonBackend{}
+            nodes, err := b.Parse("f.py", []byte(tt.src))
+            if err != nil {
+                t.Fatalf("Parse() error = %v", err)
+            }
+
+            n := nodes[len(nodes) - 1]
+            got, err := b.Insert([]byte(tt.src), n, tt.doc)
+            if err != nil {
+                t.Fatalf("Insert() error = %v", err)
+            }
+            if string(got) != tt.want {
+                t.Fatalf("Insert() =\n%v\nwant\n%v", string(got), tt.want)
+            }
+
+            // The docstring is found when the result is read again
+            reparsed, err := b.Parse("f.py", got)
+            if err != nil || reparsed[len(reparsed) - 1].Doc == "" {
+                t.Errorf("documented source reparsed as %v, %v", reparsed, err)
+            }
+        })
+    }
+}
+
+
+func TestPythonInsertKeepsExistingDocstring(t *testing.T) {
+    src := []byte("def f():\n    \"\"\"Old.\"\"\"\n    pass\n")
+
+    nodes, _ := PythonBackend{}.Parse("f.py", src)
+    got, err := PythonBackend{}.Insert(src, nodes[0], "New.")
+    if err != nil || string(got) != string(src) {
+        t.Errorf("Insert() = %q, %v, want the source unchanged", string(got), err)
+    }
+}
+
+
+func TestPythonRender(t *testing.T) {
+    n := &Node{ Params: []Param{ { Name: "path" } }, ParamsKnown: true, Source: "def walk(self, path):\n    yield path" }
+    d := Doc{
+        Summary: "Walk path.",
+        Params:  []ParamDoc{ { Name: "self", Description: "The store." }, { Name: "path", Description: "Where to start." } },
+        Returns: "Each file found.",
+        Errors:  []string{ "OSError: when path can't be read." },
+    }
+
+    want := "Walk path.\n\nArgs:\n    path: Where to start.\n\nYields:\n    Each file found.\n\nRaises:\n    OSError: when path can't be read."
+    if got := (PythonBackend{}).Render(n, d); got != want {
+        t.Errorf("Render() =\n%v\nwant\n%v", got, want)
+    }
+}
+
+
+func TestPythonParams(t *testing.T) {
+    src := `class Point:
+    def __init__(self, x):
+        self.x = x
+
+    def value(self):
+        return self.x
+
+    @classmethod
+    def make(cls, n): return cls(n)
+
+
+def mixed(a, b: int = 1, *args, c: Dict[str, int] = {}, **kwargs) -> None:
+    pass
+
+def markers(x, /, y, *, z="a,b"): pass
+
+def sort(items, key=lambda v: v[0]): pass
+
+def split(
+    a: "Forward",
+    b,
+):
+    pass
+`
+
+    nodes, err := PythonBackend{}.Parse("point.py", []byte(src))
+    if err != nil {
+        t.Fatalf("Parse() error = %v", err)
+    }
+
+    wants := map[string][]Param{
+        "Point":          nil,
+        "Point.__init__": { { Name: "x" } },
+        "Point.value":    {},
+        "Point.make":     { { Name: "n" } },
+        "mixed":          {
+            { Name: "a" }, { Name: "b", Type: "int", Optional: true }, { Name: "*args" },
+            { Name: "c", Type: "Dict[str, int]", Optional: true }, { Name: "**kwargs" },
+        },
+        "markers":        { { Name: "x" }, { Name: "y" }, { Name: "z", Optional: true } },
+        "sort":           { { Name: "items" }, { Name: "key", Optional: true } },
+        "split":          { { Name: "a", Type: `"Forward"` }, { Name: "b" } },
+    }
+
+    if len(nodes) != len(wants) {
+        t.Fatalf("Parse() found %v declarations, want %v", len(nodes), len(wants))
+    }
+
+    for _, n := range nodes {
+        want := wants[n.FullName()]
+        if !slices.Equal(n.Params, want) || n.ParamsKnown != (n.Kind != "class") {
+            t.Errorf("%v params = %+v (known %v), want %+v", n.FullName(), n.Params, n.ParamsKnown, want)
+        }
+    }
+
+    // Args only has what the def takes, whatever the model says
+    d := Doc{ Summary: "Make one.", Params: []ParamDoc{ { Name: "x", Description: "Across." }, { Name: "a", Description: "Made up." } } }
+    if got, want := (PythonBackend{}).Render(nodes[1], d), "Make one.\n\nArgs:\n    x: Across."; got != want {
+        t.Errorf("Render() of __init__ =\n%v\nwant\n%v", got, want)
+    }
+    if got := (PythonBackend{}).Render(nodes[2], d); got != "Make one." {
+        t.Errorf("Render() of value =\n%v\nwant no Args", got)
+    }
+}
@@ -0,0 +1,131 @@
+package docs
+
+import (
+    "strings"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/openai/calls"
+)
+
+
+/**
+ * Doc is the documentation of one declaration, as the model answers it. The
+ * model only fills in the fields; each backend lays them out in its
+ * language's comment style with Render. Skip is set instead when the
+ * declaration is obvious from its name and signature.
+ */
+type Doc struct {
+    Skip        bool       `json:"skip"`
+    Summary     string     `json:"summary"`
+    Description string     `json:"description"`
+    Params      []ParamDoc `json:"params"`
+    Returns     string     `json:"returns"`
+    Errors      []string   `json:"errors"`
+    Panics      string     `json:"panics"`
+    SideEffects string     `json:"side_effects"`
+    Example     string     `json:"example"`
+}
+
+
+type ParamDoc struct {
+    Name        string `json:"name"`
+    Description string `json:"description"`
+}
+
+
+// DocSchema holds the model's answer to a documentation prompt to Doc
+var DocSchema = calls.Schema{
+    Name:   "documentation",
+    Schema: calls.Object(map[string]any{
+        "skip":         calls.Bool("True when the declaration is obvious from its name and signature and needs no documentation. Every other field is then empty"),
+        "summary":      calls.String("One sentence saying what it does"),
+        "description":  calls.String("Longer description, only if the summary isn't enough. Empty otherwise"),
+        "params":       calls.Array(calls.Object(map[string]any{
+            "name":        calls.String("Name of the parameter exactly as in the code"),
+            "description": calls.String("What it is, and any constraints on it"),
+        }), "Every parameter which needs explaining, in order"),
+        "returns":      calls.String("What is returned and when. Empty if nothing is"),
+        "errors":       calls.Array(calls.String(""), "Each error returned or exception raised, and when"),
+        "panics":       calls.String("When it panics, in languages which panic. Empty otherwise"),
+        "side_effects": calls.String("State it changes, I/O it does, output it writes and concurrency it starts. Empty if none"),
+        "example":      calls.String("Code only, no fences, of a short example of using it, where the documentation style asks for one. Empty otherwise"),
+    }),
+}
+
+
+// paramDoc is what the model wrote about the parameter called name
+func (d Doc) paramDoc(name string) string {
+    for _, p := range d.Params {
+        if strings.TrimLeft(p.Name, "$*&") == strings.TrimLeft(name, "$*&") {
+            return strings.TrimSpace(p.Description)
+        }
+    }
+
+    return ""
+}
+
+
+/**
+ * proseLines are the summary, the description and then any of extra which
+ * aren't empty, separated by blank lines. They're the start of the comment
+ * in every language; extra is for fields a language has no section for.
+ */
+func proseLines(d Doc, extra ...string) []string {
+    lines := []string{ strings.TrimSpace(d.Summary) }
+
+    for _, paragraph := range append([]string{ d.Description }, extra...) {
+        if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+            lines = append(lines, "")
+            lines = append(lines, strings.Split(paragraph, "\n")...)
+        }
+    }
+
+    return lines
+}
+
+
+// section adds a heading followed by entries, each indented, after a blank
+// line. Nothing is added when there are no entries
+func section(lines []string, heading string, indent string, entries []string) []string {
+    kept := []string{}
+    for _, entry := range entries {
+        if entry = strings.TrimSpace(entry); entry != "" {
+            kept = append(kept, entry)
+        }
+    }
+
+    if len(kept) == 0 {
+        return lines
+    }
+
+    lines = append(lines, "", heading)
+    for _, entry := range kept {
+        lines = append(lines, indent + strings.ReplaceAll(entry, "\n", "\n" + indent))
+    }
+
+    return lines
+}
+
+
+// paramEntries pairs the parameters with what the model wrote about them,
+// in the order of the signature when the backend read one. Parameters the
+// model made up, which the signature doesn't have, are left out
+func paramEntries(n *Node, d Doc) []ParamDoc {
+    entries := []ParamDoc{}
+
+    if !n.ParamsKnown {
+        for _, p := range d.Params {
+            if strings.TrimSpace(p.Description) != "" {
+                entries = append(entries, ParamDoc{ Name: p.Name, Description: strings.TrimSpace(p.Description) })
+            }
+        }
+        return entries
+    }
+
+    for _, p := range n.Params {
+        if text := d.paramDoc(p.Name); text != "" {
+            entries = append(entries, ParamDoc{ Name: p.Name, Description: text })
+        }
+    }
+
+    return entries
+}
@@ -0,0 +1,64 @@
+package docs
+
+import (
+    "slices"
+    "strings"
+    "testing"
+)
+
+
+func TestParamEntries(t *testing.T) {
+    d := Doc{ Params: []ParamDoc{ { Name: "x", Description: "The x." }, { Name: "a", Description: " The a. " }, { Name: "n", Description: "" } } }
+
+    tests := []struct {
+        name string
+        node *Node
+        want []ParamDoc
+    }{
+        // Without a signature all the model has is its word
+        { name: "unknown", node: &Node{}, want: []ParamDoc{ { Name: "x", Description: "The x." }, { Name: "a", Description: "The a." } } },
+        { name: "none", node: &Node{ ParamsKnown: true }, want: []ParamDoc{} },
+        {
+            name: "made up",
+            node: &Node{ Params: []Param{ { Name: "a" }, { Name: "b" } }, ParamsKnown: true },
+            want: []ParamDoc{ { Name: "a", Description: "The a." } },
+        },
+    }
+
+    for _, tt := range tests {
+        if got := paramEntries(tt.node, d); !slices.Equal(got, tt.want) {
+            t.Errorf("paramEntries() of %v = %v, want %v", tt.name, got, tt.want)
+        }
+    }
+}
+
+
+// The model invents parameters for functions which take none
+func TestRenderNoParams(t *testing.T) {
+    d := Doc{ Summary: "Get it.", Params: []ParamDoc{ { Name: "x", Description: "The x." }, { Name: "a", Description: "The a." } } }
+
+    tests := []struct {
+        name    string
+        backend Backend
+        path    string
+        src     string
+    }{
+        { name: "rust method", backend: RustBackend{}, path: "a.rs", src: "impl A {\n    fn get(&self) -> i32 {\n        0\n    }\n}\n" },
+        { name: "rust function", backend: RustBackend{}, path: "a.rs", src: "pub fn go() {\n}\n" },
+        { name: "shell", backend: ShellBackend{}, path: "a.sh", src: "both() {\n    echo hi\n}\n" },
+        { name: "javascript", backend: JSBackend{}, path: "a.js", src: "function go() {\n}\n" },
+        { name: "c", backend: CBackend{}, path: "a.c", src: "int go(void) {\n    return 0;\n}\n" },
+    }
+
+    for _, tt := range tests {
+        nodes, err := tt.backend.Parse(tt.path, []byte(tt.src))
+        if err != nil || len(nodes) == 0 {
+            t.Fatalf("Parse() of %v = %v, %v", tt.name, nodes, err)
+        }
+
+        n := nodes[len(nodes) - 1]
+        if got := tt.backend.Render(n, d); strings.Contains(got, "x") || strings.Contains(got, "The a.") {
+            t.Errorf("Render() of %v =\n%v\nwant no parameters", tt.name, got)
+        }
+    }
+}
@@ -0,0 +1,563 @@
+package docs
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+)
+
+
+func init() {
+    Register(RustBackend{})
+}
+
+
+/**
+ * RustBackend documents Rust with /// rustdoc comments on functions, methods
+ * in inherent impls and traits, traits, structs and enums. Methods in an impl
+ * of a trait are left alone, rustdoc shows the trait's documentation for
+ * them. Generated examples are fenced as ignore, since nothing has checked
+ * that they compile.
+ */
+type RustBackend struct {}
+
+
+func (RustBackend) Language() types.SupportedFormat {
+    return types.Rust
+}
+
+
+func (RustBackend) Style() string {
+    return `The comment is rustdoc, in Markdown. Errors are when each error of a returned Result happens, and only apply to functions returning one. Panics is when it can panic, e.g. through unwrap, expect, panic! or indexing. Write an example for public items only.`
+}
+
+
+// Render lays d out as rustdoc, with Arguments, Errors, Panics and Examples
+// sections. Only functions and methods get Arguments and Panics, and only
+// those returning a Result get Errors
+func (RustBackend) Render(n *Node, d Doc) string {
+    lines := proseLines(d, d.Returns, d.SideEffects)
+
+    if n.Kind == "function" || n.Kind == "method" {
+        params := []string{}
+        for _, p := range paramEntries(n, d) {
+            params = append(params, "* `" + p.Name + "` - " + p.Description)
+        }
+
+        lines = section(lines, "# Arguments", "", params)
+        if rustResult.MatchString(n.Returns) {
+            lines = section(lines, "# Errors", "", d.Errors)
+        }
+        lines = section(lines, "# Panics", "", []string{ d.Panics })
+    }
+    if example := strings.TrimSpace(d.Example); example != "" {
+        lines = section(lines, "# Examples", "", []string{ "```rust\n" + example + "\n```" })
+    }
+
+    return strings.Join(lines, "\n")
+}
+
+
+var rustItem = regexp.MustCompile(`^(?:pub(?:\s*\([^)]*\))?\s+)?(?:default\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?(?:extern\s+(?:"[^"]*"\s+)?)?(fn|struct|enum|union|trait)\s+([A-Za-z_]\w*)`)
+// Result and its aliases, like io::Result<()> or fmt::Result
+var rustResult = regexp.MustCompile(`\bResult\b`)
+var rustImpl = regexp.MustCompile(`^(?:unsafe\s+)?impl\b`)
+var rustCall = regexp.MustCompile(`([A-Za-z_][\w.:]*)\s*(?:::<[^()]*>)?\s*\(`)
+var rustSection = regexp.MustCompile(`^#*\s*(Errors|Panics|Examples|Safety)\s*:?\s*$`)
+var rustRawString = regexp.MustCompile(`^b?r(#*)"`)
+var rustChar = regexp.MustCompile(`^'(?:\\(?:u\{[0-9a-fA-F]+\}|x[0-9a-fA-F]{2}|.)|[^\\'\n])'`)
+var rustLifetime = regexp.MustCompile(`'[A-Za-z_]\w*`)
+var rustIdent = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+
+var rustKeywords = map[string]bool{
+    "if": true, "while": true, "for": true, "match": true, "return": true, "loop": true,
+    "fn": true, "in": true, "as": true, "move": true, "Some": true, "Ok": true, "Err": true,
+    "Box::new": true, "where": true, "impl": true, "dyn": true,
+}
+
+
+/**
+ * maskRust blanks out comments, string, byte string, raw string and char
+ * literals, keeping offsets and the newlines outside them. Lifetimes like 'a
+ * are left alone.
+ */
+func maskRust(src []byte) ([]byte, error) {
+    masked := append([]byte{}, src...)
+
+    blank := func(from int, to int, fill byte) {
+        for k := from; k < to && k < len(masked); k++ {
+            if masked[k] != '\n' {
+                masked[k] = fill
+            }
+        }
+    }
+
+    for i := 0; i < len(src); i++ {
+        c := src[i]
+
+        switch {
+        case c == '/' && i + 1 < len(src) && src[i + 1] == '/':
+            j := i
+            for j < len(src) && src[j] != '\n' {
+                j++
+            }
+            blank(i, j, ' ')
+            i = j - 1
+
+        case c == '/' && i + 1 < len(src) && src[i + 1] == '*':
+            // Block comments nest in Rust
+            depth := 0
+            j := i
+            for j < len(src) {
+                if strings.HasPrefix(string(src[j:min(len(src), j + 2)]), "/*") {
+                    depth++
+                    j += 2
+                    continue
+                }
+                if strings.HasPrefix(string(src[j:min(len(src), j + 2)]), "*/") {
+                    depth--
+                    j += 2
+                    if depth == 0 {
+                        break
+                    }
+                    continue
+                }
+                j++
+            }
+            if depth != 0 {
+                return nil, fmt.Errorf("unterminated comment on line %v", lineNumber(src, i))
+            }
+            blank(i, j, ' ')
+            i = j - 1
+
+        case (c == 'r' || c == 'b') && (i == 0 || !isPyIdent(src[i - 1])):
+            m := rustRawString.FindSubmatch(src[i:])
+            if m == nil {
+                continue
+            }
+            end := strings.Index(string(src[i + len(m[0]):]), `"` + string(m[1]))
+            if end < 0 {
+                return nil, fmt.Errorf("unterminated raw string on line %v", lineNumber(src, i))
+            }
+            j := i + len(m[0]) + end
+            blank(i + len(m[0]), j, '_')
+            i = j + len(m[1])
+
+        case c == '"':
+            j := i + 1
+            for j < len(src) && src[j] != '"' {
+                if src[j] == '\\' {
+                    j++
+                }
+                j++
+            }
+            if j >= len(src) {
+                return nil, fmt.Errorf("unterminated string on line %v", lineNumber(src, i))
+            }
+            blank(i + 1, j, '_')
+            i = j
+
+        case c == '\'':
+            // 'x', '\n' and '\u{1F600}' are chars, 'a alone is a lifetime
+            m := rustChar.Find(src[i:])
+            if m != nil {
+                blank(i + 1, i + len(m) - 1, '_')
+                i += len(m) - 1
+            }
+        }
+    }
+
+    return masked, nil
+}
+
+
+func (RustBackend) Parse(path string, src []byte) ([]*Node, error) {
+    masked, err := maskRust(src)
+    if err != nil {
+        return nil, err
+    }
+
+    // The impl, trait and fn bodies a line is inside of
+    type block struct {
+        name string
+        kind string
+        end  int
+    }
+
+    nodes := []*Node{}
+    stack := []block{}
+
+    for offset := 0; offset < len(masked); {
+        start := offset
+        offset = nextLine(masked, offset)
+
+        for len(stack) > 0 && start > stack[len(stack) - 1].end {
+            stack = stack[:len(stack) - 1]
+        }
+
+        indent := leadingSpace(masked, start)
+        text := string(masked[start + len(indent):offset])
+
+        if loc := rustImpl.FindStringIndex(text); loc != nil {
+            open, end := rustBlock(masked, start + len(indent) + loc[1])
+            if open < 0 {
+                continue
+            }
+            header := strings.TrimSpace(string(masked[start + len(indent):open]))
+            kind, name := rustImplTarget(header)
+            stack = append(stack, block{ name: name, kind: kind, end: end })
+            continue
+        }
+
+        m := rustItem.FindStringSubmatchIndex(text)
+        if m == nil {
+            continue
+        }
+
+        keyword := text[m[2]:m[3]]
+        n := &Node{
+            Kind:   keyword,
+            Name:   text[m[4]:m[5]],
+            File:   path,
+            Line:   lineNumber(src, start),
+            Start:  start,
+            Indent: indent,
+        }
+        if keyword == "fn" {
+            n.Kind = "function"
+        }
+
+        var parent *block
+        if len(stack) > 0 {
+            parent = &stack[len(stack) - 1]
+        }
+        if parent != nil {
+            n.Container = parent.name
+            if n.Kind == "function" && (parent.kind == "impl" || parent.kind == "trait") {
+                n.Kind = "method"
+            }
+        }
+
+        open, end := rustBlock(masked, start + len(indent) + m[5])
+        if end < 0 {
+            return nil, fmt.Errorf("line %v: %v %v isn't closed", n.Line, keyword, n.Name)
+        }
+
+        sigEnd := end + 1
+        if open >= 0 {
+            sigEnd = open
+        }
+        n.Signature = strings.TrimSpace(string(src[start + len(indent):sigEnd]))
+        n.End = nextLine(src, end)
+
+        if keyword == "fn" {
+            if err := rustSignature(n, string(masked[start + len(indent):sigEnd])); err != nil {
+                return nil, fmt.Errorf("line %v: %v", n.Line, err)
+            }
+        }
+
+        if open >= 0 {
+            stack = append(stack, block{ name: n.Name, kind: keyword, end: end })
+
+            body := string(masked[open + 1:end])
+            for _, call := range rustCall.FindAllStringSubmatchIndex(body, -1) {
+                name := body[call[2]:call[3]]
+                before := strings.TrimRight(body[:call[2]], " \t")
+                // Nested fns aren't calls
+                if rustKeywords[name] || strings.HasSuffix(before, "fn") {
+                    continue
+                }
+                n.Calls = append(n.Calls, name)
+            }
+        }
+
+        // Methods of a trait impl take their docs from the trait
+        if parent != nil && parent.kind == "trait impl" {
+            continue
+        }
+
+        n.Start, n.DocAt, n.Doc = rustAttributes(src, start)
+        n.Source = strings.TrimRight(string(src[n.Start:n.End]), "\n")
+
+        nodes = append(nodes, n)
+    }
+
+    return nodes, nil
+}
+
+
+/**
+ * rustBlock finds the body of the item whose header continues from offset:
+ * the braces after the header, or the semicolon ending a tuple struct or a
+ * bodiless fn. Brackets and parentheses in the header are skipped, so a
+ * return type like [u8; 4] doesn't end it. open is -1 when there's no body.
+ */
+func rustBlock(masked []byte, offset int) (int, int) {
+    depth := 0
+    for i := offset; i < len(masked); i++ {
+        switch masked[i] {
+        case '(', '[':
+            depth++
+        case ')', ']':
+            depth--
+        case '{':
+            if depth == 0 {
+                return i, matching(masked, i)
+            }
+        case ';':
+            if depth == 0 {
+                return -1, i
+            }
+        }
+    }
+
+    return -1, -1
+}
+
+
+// rustImplTarget names the type an impl header is for, and whether it's an
+// impl of a trait
+func rustImplTarget(header string) (string, string) {
+    header = strings.TrimSpace(strings.SplitN(header, " where ", 2)[0])
+    header = strings.TrimPrefix(strings.TrimPrefix(header, "unsafe "), "impl")
+    header = strings.TrimSpace(header)
+
+    // Skip impl<T: Clone>
+    if strings.HasPrefix(header, "<") {
+        depth := 0
+        for i := 0; i < len(header); i++ {
+            if header[i] == '<' {
+                depth++
+            } else if header[i] == '>' && (i == 0 || header[i - 1] != '-') {
+                depth--
+                if depth == 0 {
+                    header = strings.TrimSpace(header[i + 1:])
+                    break
+                }
+            }
+        }
+    }
+
+    kind := "impl"
+    if parts := strings.SplitN(header, " for ", 2); len(parts) == 2 {
+        kind = "trait impl"
+        header = parts[1]
+    }
+
+    name := strings.TrimLeft(strings.TrimSpace(header), "&")
+    name = strings.TrimPrefix(name, "mut ")
+    if i := strings.IndexAny(name, "< "); i >= 0 {
+        name = name[:i]
+    }
+    name = name[strings.LastIndex(name, ":") + 1:]
+
+    return kind, name
+}
+
+
+// rustSignature reads the parameters and return type of a fn header, without
+// the self receiver
+func rustSignature(n *Node, header string) error {
+    loc := regexp.MustCompile(`\bfn\s+` + n.Name + `\b`).FindStringIndex(header)
+    open := -1
+    if loc != nil {
+        open = strings.Index(header[loc[1]:], "(")
+    }
+    if open < 0 {
+        return fmt.Errorf("fn %v has no parameter list", n.Name)
+    }
+    // Generics come first, and may hold parentheses of their own, e.g. <F: Fn(i32)>
+    open += loc[1]
+    if generics := strings.TrimSpace(header[loc[1]:open]); strings.HasPrefix(generics, "<") {
+        close := angleEnd(header, strings.Index(header[loc[1]:], "<") + loc[1])
+        if close < 0 {
+            return fmt.Errorf("fn %v has unclosed generics", n.Name)
+        }
+        open = strings.Index(header[close:], "(") + close
+    }
+
+    end := matching([]byte(header), open)
+    if end < 0 {
+        return fmt.Errorf("fn %v has an unclosed parameter list", n.Name)
+    }
+
+    // Lifetimes would read as the start of a char to splitTopLevel
+    list := header[open + 1:end]
+    plain := rustLifetime.ReplaceAllStringFunc(list, func(l string) string { return strings.Repeat("_", len(l)) })
+
+    n.ParamsKnown = true
+    offset := 0
+    for i, part := range splitTopLevel(plain, ',') {
+        part, offset = list[offset:offset + len(part)], offset + len(part) + 1
+        part = strings.TrimSpace(part)
+        if part == "" || strings.HasSuffix(part, "self") || strings.Contains(part, "self:") {
+            continue
+        }
+
+        name, typ, ok := strings.Cut(part, ":")
+        name = strings.TrimPrefix(strings.TrimSpace(name), "mut ")
+        if !ok || !rustIdent.MatchString(name) {
+            name = fmt.Sprintf("param%v", i)
+        }
+
+        n.Params = append(n.Params, Param{ Name: name, Type: strings.TrimSpace(typ) })
+    }
+
+    rest := header[end + 1:]
+    if i := strings.Index(rest, "->"); i >= 0 {
+        returns := rest[i + 2:]
+        if w := strings.Index(returns, " where "); w >= 0 {
+            returns = returns[:w]
+        }
+        n.Returns = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(returns), ";"))
+    }
+
+    return nil
+}
+
+
+// angleEnd finds the > closing the < at open, skipping the > of ->
+func angleEnd(s string, open int) int {
+    depth := 0
+    for i := open; i < len(s); i++ {
+        switch {
+        case s[i] == '<':
+            depth++
+        case s[i] == '>' && s[i - 1] != '-':
+            depth--
+            if depth == 0 {
+                return i
+            }
+        }
+    }
+
+    return -1
+}
+
+
+/**
+ * rustAttributes walks up from the item at start over its attributes and doc
+ * comments. It returns where the item begins, including attributes, where a
+ * new doc comment goes (above the attributes, as rustfmt leaves them) and the
+ * existing documentation: /// lines, a /** block or #[doc = ...] attributes.
+ */
+func rustAttributes(src []byte, start int) (int, int, string) {
+    top := start
+    itemStart := start
+    doc := []string{}
+
+    for top > 0 {
+        prev := lineStart(src, top - 1)
+        line := strings.TrimSpace(string(src[prev:top]))
+
+        if strings.HasPrefix(line, "///") && !strings.HasPrefix(line, "////") || strings.HasPrefix(line, "#[doc") {
+            doc = append([]string{ line }, doc...)
+            top = prev
+            continue
+        }
+
+        if strings.HasPrefix(line, "#[") {
+            top, itemStart = prev, prev
+            continue
+        }
+
+        // The last line of an attribute split over several lines
+        if strings.HasSuffix(line, "]") && !strings.HasPrefix(line, "//") {
+            attr := prev
+            for attr > 0 && !strings.HasPrefix(strings.TrimSpace(string(src[attr:nextLine(src, attr)])), "#[") {
+                attr = lineStart(src, attr - 1)
+            }
+            if strings.HasPrefix(strings.TrimSpace(string(src[attr:nextLine(src, attr)])), "#[") {
+                top, itemStart = attr, attr
+                continue
+            }
+        }
+
+        if strings.HasSuffix(line, "*/") {
+            block := prev
+            for block > 0 && !strings.Contains(string(src[block:nextLine(src, block)]), "/*") {
+                block = lineStart(src, block - 1)
+            }
+            text := strings.TrimSpace(string(src[block:top]))
+            if strings.HasPrefix(text, "/**") && !strings.HasPrefix(text, "/***") {
+                doc = append([]string{ text }, doc...)
+                top = block
+                continue
+            }
+        }
+
+        break
+    }
+
+    // Docs written between the attributes and the item still count, but new
+    // ones go above the attributes
+    docAt := top
+    if len(doc) == 0 {
+        docAt = itemStart
+    }
+
+    return itemStart, docAt, strings.Join(doc, "\n")
+}
+
+
+/**
+ * RustDocLines lays out the model's text as rustdoc: section headings become
+ * "# Errors" and the like however the model wrote them, and code blocks with
+ * no language are marked ignore so cargo test doesn't run untested examples.
+ */
+func RustDocLines(doc string) []string {
+    lines := []string{}
+    inCode := false
+
+    for _, line := range strings.Split(doc, "\n") {
+        line = strings.TrimRight(line, " \t")
+        trimmed := strings.TrimSpace(line)
+
+        if strings.HasPrefix(trimmed, "```") {
+            if !inCode && (trimmed == "```" || trimmed == "```rust") {
+                line = "```ignore"
+            }
+            inCode = !inCode
+            lines = append(lines, line)
+            continue
+        }
+
+        if !inCode {
+            if m := rustSection.FindStringSubmatch(trimmed); m != nil {
+                if len(lines) > 0 && lines[len(lines) - 1] != "" {
+                    lines = append(lines, "")
+                }
+                lines = append(lines, "# " + m[1])
+                continue
+            }
+        }
+
+        lines = append(lines, line)
+    }
+
+    if inCode {
+        lines = append(lines, "```")
+    }
+
+    return lines
+}
+
+
+func (RustBackend) Insert(src []byte, n *Node, doc string) ([]byte, error) {
+    if n.Doc != "" {
+        return src, nil
+    }
+
+    var b strings.Builder
+    for _, line := range RustDocLines(doc) {
+        if line == "" {
+            b.WriteString(n.Indent + "///\n")
+        } else {
+            b.WriteString(n.Indent + "/// " + line + "\n")
+        }
+    }
+
+    return insertAt(src, n.DocAt, b.String()), nil
+}
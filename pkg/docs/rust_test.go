@@ -0,0 +1,250 @@
+package docs
+
+import (
+    "slices"
+    "strings"
+    "testing"
+)
+
+
+const rustSource = `use std::fmt;
+
+/// Existing docs.
+#[derive(Debug, Clone)]
+pub struct Point { x: i32, y: i32 }
+
+#[derive(
+    Debug,
+)]
+pub enum Shape {
+    Circle(f64),
+}
+
+impl Point {
+    pub fn new(x: i32, y: i32) -> Self {
+        let s = "fn fake() {";
+        let c = '{';
+        Self::check(x);
+        Point { x, y }
+    }
+
+    fn check<'a, F: Fn(i32) -> bool>(&self, f: F, name: &'a str) -> Result<[u8; 4], String> where F: Clone {
+        helper(r#"raw "fn" {"#);
+        Ok([0; 4])
+    }
+}
+
+impl fmt::Display for Point {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "({}, {})", self.x, self.y)
+    }
+}
+
+pub trait Area {
+    fn area(&self) -> f64;
+}
+
+/* not doc */
+pub(crate) async unsafe fn helper(s: &str) {
+    /* nested /* comment */ */
+    inner::go();
+}
+
+pub struct Pair(i32, i32);
+`
+
+
+func TestRustParse(t *testing.T) {
+    nodes, err := RustBackend{}.Parse("point.rs", []byte(rustSource))
+    if err != nil {
+        t.Fatalf("Parse() error = %v", err)
+    }
+
+    type want struct {
+        kind    string
+        name    string
+        line    int
+        sig     string
+        params  []Param
+        returns string
+        calls   []string
+    }
+
+    // Point's Display impl is left out, its fmt is documented by the trait
+    wants := []want{
+        { kind: "struct", name: "Point", line: 5, sig: "pub struct Point" },
+        { kind: "enum", name: "Shape", line: 10, sig: "pub enum Shape" },
+        { kind: "method", name: "Point.new", line: 15, sig: "pub fn new(x: i32, y: i32) -> Self",
+            params: []Param{ { Name: "x", Type: "i32" }, { Name: "y", Type: "i32" } }, returns: "Self", calls: []string{ "Self::check" } },
+        { kind: "method", name: "Point.check", line: 22, sig: "fn check<'a, F: Fn(i32) -> bool>(&self, f: F, name: &'a str) -> Result<[u8; 4], String> where F: Clone",
+            params: []Param{ { Name: "f", Type: "F" }, { Name: "name", Type: "&'a str" } }, returns: "Result<[u8; 4], String>", calls: []string{ "helper" } },
+        { kind: "trait", name: "Area", line: 34, sig: "pub trait Area" },
+        { kind: "method", name: "Area.area", line: 35, sig: "fn area(&self) -> f64;", returns: "f64" },
+        { kind: "function", name: "helper", line: 39, sig: "pub(crate) async unsafe fn helper(s: &str)",
+            params: []Param{ { Name: "s", Type: "&str" } }, calls: []string{ "inner::go" } },
+        { kind: "struct", name: "Pair", line: 44, sig: "pub struct Pair(i32, i32);" },
+    }
+
+    if len(nodes) != len(wants) {
+        t.Fatalf("Parse() found %v items, want %v", len(nodes), len(wants))
+    }
+
+    for i, w := range wants {
+        n := nodes[i]
+        if n.Kind != w.kind || n.FullName() != w.name || n.Line != w.line || n.Signature != w.sig || n.Returns != w.returns {
+            t.Errorf("node %v = %v %v line %v %q returns %q, want %+v", i, n.Kind, n.FullName(), n.Line, n.Signature, n.Returns, w)
+        }
+        if !slices.Equal(n.Params, w.params) {
+            t.Errorf("%v params = %+v, want %+v", w.name, n.Params, w.params)
+        }
+        if strings.HasSuffix(w.kind, "method") || w.kind == "function" {
+            if !slices.Equal(n.Calls, w.calls) {
+                t.Errorf("%v calls %v, want %v", w.name, n.Calls, w.calls)
+            }
+        }
+    }
+
+    // Attributes are part of the item, and docs above them are found
+    if nodes[0].Doc != "/// Existing docs." || !strings.HasPrefix(rustSource[nodes[0].Start:], "#[derive(Debug, Clone)]") {
+        t.Errorf("Point doc = %q, source = %q", nodes[0].Doc, nodes[0].Source)
+    }
+    if !strings.HasPrefix(rustSource[nodes[1].DocAt:], "#[derive(\n") {
+        t.Errorf("Shape docs go at %q, want above its attribute", rustSource[nodes[1].DocAt:nodes[1].End])
+    }
+    if nodes[6].Doc != "" {
+        t.Errorf("helper doc = %q, a plain block comment isn't doc", nodes[6].Doc)
+    }
+}
+
+
+func TestRustParseErrors(t *testing.T) {
+    tests := map[string]string{
+        "unterminated string":     "fn f() {\n    let s = \"oops;\n}\n",
+        "unterminated raw string": "fn f() {\n    let s = r#\"oops\"\n}\n",
+        "unterminated comment":    "/* /* */\nfn f() {}\n",
+        "unclosed body":           "fn f() {\n    g();\n",
+    }
+
+    for name, src := range tests {
+        if _, err := (RustBackend{}).Parse("bad.rs", []byte(src)); err == nil {
+            t.Errorf("Parse() of %v succeeded", name)
+        }
+    }
+}
+
+
+func TestRustDocLines(t *testing.T) {
+    doc := "Parse a point.\nErrors:\nWhen it's malformed.\n```\nlet p = parse(\"1,2\")?;\n```\n```text\nplain\n```"
+    want := []string{
+        "Parse a point.",
+        "",
+        "# Errors",
+        "When it's malformed.",
+        "```ignore",
+        "let p = parse(\"1,2\")?;",
+        "```",
+        "```text",
+        "plain",
+        "```",
+    }
+
+    if got := RustDocLines(doc); !slices.Equal(got, want) {
+        t.Errorf("RustDocLines() =\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+    }
+
+    // An unclosed example is closed
+    if got := RustDocLines("Example\n```rust\nx();"); got[len(got) - 1] != "```" {
+        t.Errorf("RustDocLines() left the code block open: %q", got)
+    }
+}
+
+
+func TestRustInsert(t *testing.T) {
+    src := "impl Point {\n    #[inline]\n    pub fn norm(&self) -> f64 {\n        0.0\n    }\n}\n"
+
+    nodes, err := RustBackend{}.Parse("point.rs", []byte(src))
+    if err != nil {
+        t.Fatalf("Parse() error = %v", err)
+    }
+
+    got, err := RustBackend{}.Insert([]byte(src), nodes[0], "The length.\n\nPanics:\nNever.")
+    if err != nil {
+        t.Fatalf("Insert() error = %v", err)
+    }
+
+    want := "impl Point {\n    /// The length.\n    ///\n    /// # Panics\n    /// Never.\n    #[inline]\n    pub fn norm(&self) -> f64 {\n        0.0\n    }\n}\n"
+    if string(got) != want {
+        t.Fatalf("Insert() =\n%v\nwant\n%v", string(got), want)
+    }
+
+    reparsed, err := RustBackend{}.Parse("point.rs", got)
+    if err != nil || len(reparsed) != 1 || reparsed[0].Doc == "" {
+        t.Errorf("documented source reparsed as %v, %v", reparsed, err)
+    }
+}
+
+
+func TestRustRender(t *testing.T) {
+    d := Doc{
+        Summary: "Make a point.",
+        Params:  []ParamDoc{ { Name: "x", Description: "Across." } },
+        Errors:  []string{ "When x is negative." },
+        Panics:  "Never.",
+        Example: "let p = Point::new(1);",
+    }
+
+    tests := []struct {
+        name string
+        node *Node
+        want string
+    }{
+        {
+            name: "result",
+            node: &Node{ Kind: "function", Params: []Param{ { Name: "x", Type: "i32" } }, ParamsKnown: true, Returns: "Result<Point, Error>" },
+            want: "Make a point.\n\n# Arguments\n* `x` - Across.\n\n# Errors\nWhen x is negative.\n\n# Panics\nNever.\n\n# Examples\n```rust\nlet p = Point::new(1);\n```",
+        },
+        {
+            name: "alias",
+            node: &Node{ Kind: "method", ParamsKnown: true, Returns: "io::Result<()>" },
+            want: "Make a point.\n\n# Errors\nWhen x is negative.\n\n# Panics\nNever.\n\n# Examples\n```rust\nlet p = Point::new(1);\n```",
+        },
+        // Nothing to fail with
+        {
+            name: "no result",
+            node: &Node{ Kind: "function", Params: []Param{ { Name: "x", Type: "i32" } }, ParamsKnown: true, Returns: "i32" },
+            want: "Make a point.\n\n# Arguments\n* `x` - Across.\n\n# Panics\nNever.\n\n# Examples\n```rust\nlet p = Point::new(1);\n```",
+        },
+        { name: "struct", node: &Node{ Kind: "struct" }, want: "Make a point.\n\n# Examples\n```rust\nlet p = Point::new(1);\n```" },
+        { name: "enum", node: &Node{ Kind: "enum" }, want: "Make a point.\n\n# Examples\n```rust\nlet p = Point::new(1);\n```" },
+    }
+
+    for _, tt := range tests {
+        if got := (RustBackend{}).Render(tt.node, d); got != tt.want {
+            t.Errorf("Render() of %v =\n%v\nwant\n%v", tt.name, got, tt.want)
+        }
+    }
+}
+
+
+// The sections follow what Parse reads from the source
+func TestRustRenderParsed(t *testing.T) {
+    src := "pub struct B {\n    x: i32,\n}\n\nimpl B {\n    pub fn get(&self) -> i32 {\n        self.x\n    }\n\n    pub fn load(path: &str) -> std::io::Result<B> {\n        todo!()\n    }\n}\n"
+    d := Doc{ Summary: "Do it.", Params: []ParamDoc{ { Name: "path", Description: "Where." } }, Errors: []string{ "When it fails." } }
+
+    want := map[string]string{
+        "B":      "Do it.",
+        "B.get":  "Do it.",
+        "B.load": "Do it.\n\n# Arguments\n* `path` - Where.\n\n# Errors\nWhen it fails.",
+    }
+
+    nodes, err := (RustBackend{}).Parse("b.rs", []byte(src))
+    if err != nil || len(nodes) != len(want) {
+        t.Fatalf("Parse() = %v, %v, want %v declarations", nodes, err, len(want))
+    }
+
+    for _, n := range nodes {
+        if got := (RustBackend{}).Render(n, d); got != want[n.FullName()] {
+            t.Errorf("Render() of %v %v =\n%v\nwant\n%v", n.Kind, n.FullName(), got, want[n.FullName()])
+        }
+    }
+}
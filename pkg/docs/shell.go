@@ -0,0 +1,564 @@
+package docs
+
+import (
+    "fmt"
+    "regexp"
+    "slices"
+    "strings"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+)
+
+
+func init() {
+    Register(ShellBackend{})
+}
+
+
+/**
+ * ShellBackend documents shell functions with the header comments from
+ * Google's shell style guide: a summary followed by Globals, Arguments,
+ * Outputs and Returns sections between lines of #. Functions are found in
+ * both the name() { and function name { forms. The globals and positional
+ * arguments a function uses are read from its body, and listed even when the
+ * model leaves them out.
+ */
+type ShellBackend struct {}
+
+
+func (ShellBackend) Language() types.SupportedFormat {
+    return types.Shell
+}
+
+
+func (ShellBackend) Style() string {
+    return `The comment is a function header in the style of Google's shell style guide. Parameters are the positional arguments, named $1, $2 and so on. Side effects are what it writes to stdout or stderr. Returns are its exit statuses and when they're returned, and errors the failing ones.`
+}
+
+
+// Render lays d out as a header with Arguments, Outputs and Returns
+// sections. ShellHeaderLines adds Globals, and Arguments if there are none
+func (ShellBackend) Render(n *Node, d Doc) string {
+    lines := proseLines(d)
+
+    args := []string{}
+    for _, p := range paramEntries(n, d) {
+        args = append(args, p.Name + " - " + p.Description)
+    }
+
+    lines = section(lines, "Arguments:", "  ", args)
+    lines = section(lines, "Outputs:", "  ", []string{ d.SideEffects })
+    lines = section(lines, "Returns:", "  ", append([]string{ d.Returns }, d.Errors...))
+
+    return strings.Join(lines, "\n")
+}
+
+
+const shellBanner = "#######################################"
+
+var shellFunction = regexp.MustCompile(`^(?:function\s+([A-Za-z_][\w.:-]*)\s*(?:\(\s*\))?|([A-Za-z_][\w.:-]*)\s*\(\s*\))\s*`)
+var shellCommand = regexp.MustCompile("(?:^|[;&|(){}\\n`]|\\$\\()\\s*((?:(?:if|then|else|elif|do|while|until|!|time|exec|command|sudo|nohup)\\s+)*)([A-Za-z_./][\\w./:+-]*)")
+var shellVariable = regexp.MustCompile(`\$\{?#?([A-Za-z_]\w*)`)
+var shellAssignment = regexp.MustCompile(`(?m)^\s*(?:export\s+|readonly\s+|declare\s+(?:-\w+\s+)*)?([A-Za-z_]\w*)(?:\[[^\]]*\])?\+?=`)
+var shellLocal = regexp.MustCompile(`(?m)(?:^|[;&|\s])(?:local|declare|typeset|readonly)\s+((?:-\w+\s+)*)([^;&|\n]*)`)
+var shellLoopVar = regexp.MustCompile(`(?:^|[;\s])(?:for|select)\s+([A-Za-z_]\w*)\s+in\b`)
+var shellReadVars = regexp.MustCompile(`(?:^|[;&|\s])read\s+((?:-\w+\s+(?:\S+\s+)?)*)([A-Za-z_][\w\s]*)`)
+var shellPositional = regexp.MustCompile(`\$\{?([1-9@*#])(\w?)`)
+var shellHeredoc = regexp.MustCompile(`^<<-?\s*(['"]?)([A-Za-z_]\w*)(['"]?)`)
+
+var shellKeywords = []string{
+    "if", "then", "else", "elif", "fi", "case", "esac", "for", "select", "while", "until",
+    "do", "done", "in", "function", "time", "coproc",
+}
+
+var shellBuiltins = []string{
+    ".", ":", "[", "[[", "alias", "bg", "break", "builtin", "caller", "cd", "command", "compgen",
+    "complete", "continue", "declare", "dirs", "disown", "echo", "enable", "eval", "exec",
+    "exit", "export", "false", "fc", "fg", "getopts", "hash", "help", "history", "jobs",
+    "kill", "let", "local", "logout", "mapfile", "popd", "printf", "pushd", "pwd", "read",
+    "readarray", "readonly", "return", "set", "shift", "shopt", "source", "suspend", "test",
+    "times", "trap", "true", "type", "typeset", "ulimit", "umask", "unalias", "unset", "wait",
+}
+
+
+/**
+ * maskShell blanks out comments, quoted strings and here-documents, keeping
+ * offsets and newlines. Variables and command substitutions in double quotes
+ * are left visible, so the globals and commands inside them are still found.
+ */
+func maskShell(src []byte) []byte {
+    masked := append([]byte{}, src...)
+    heredocs := []string{}
+
+    for i := 0; i < len(src); i++ {
+        c := src[i]
+
+        switch {
+        case c == '\\':
+            i++
+
+        case c == '#' && (i == 0 || strings.IndexByte(" \t\n;&|(", src[i - 1]) >= 0):
+            for i < len(src) && src[i] != '\n' {
+                masked[i] = ' '
+                i++
+            }
+            i--
+
+        case c == '\'':
+            j := i + 1
+            for j < len(src) && src[j] != '\'' {
+                if src[j] != '\n' {
+                    masked[j] = ' '
+                }
+                j++
+            }
+            i = j
+
+        case c == '"':
+            j := i + 1
+            for j < len(src) && src[j] != '"' {
+                if src[j] == '\\' {
+                    masked[j] = ' '
+                    j++
+                    if j < len(src) && src[j] != '\n' {
+                        masked[j] = ' '
+                    }
+                    j++
+                    continue
+                }
+                if src[j] == '$' {
+                    // Leave $VAR, $@, ${...} and $(...) readable
+                    k := j + 1
+                    if k < len(src) && (src[k] == '{' || src[k] == '(') {
+                        close := map[byte]byte{ '{': '}', '(': ')' }[src[k]]
+                        for k < len(src) && src[k] != close && src[k] != '"' {
+                            k++
+                        }
+                        if k < len(src) && src[k] == close {
+                            k++
+                        }
+                    } else if k < len(src) && strings.IndexByte("@*#?$!-", src[k]) >= 0 {
+                        k++
+                    } else {
+                        for k < len(src) && isPyIdent(src[k]) {
+                            k++
+                        }
+                    }
+                    j = k
+                    continue
+                }
+                if src[j] != '\n' {
+                    masked[j] = ' '
+                }
+                j++
+            }
+            i = j
+
+        case c == '<' && i + 1 < len(src) && src[i + 1] == '<' && (i + 2 >= len(src) || src[i + 2] != '<'):
+            m := shellHeredoc.FindSubmatch(src[i:])
+            if m != nil {
+                heredocs = append(heredocs, string(m[2]))
+                i += len(m[0]) - 1
+            }
+
+        case c == '\n' && len(heredocs) > 0:
+            // Here-document bodies start on the next line
+            j := i + 1
+            for len(heredocs) > 0 && j < len(src) {
+                end := nextLine(src, j)
+                line := strings.TrimSpace(strings.TrimRight(string(src[j:end]), "\n"))
+                // The terminator is masked too, so it isn't read as a command
+                for k := j; k < end; k++ {
+                    if masked[k] != '\n' {
+                        masked[k] = ' '
+                    }
+                }
+                if line == heredocs[0] {
+                    heredocs = heredocs[1:]
+                }
+                j = end
+            }
+            i = j - 1
+        }
+    }
+
+    return masked
+}
+
+
+func (ShellBackend) Parse(path string, src []byte) ([]*Node, error) {
+    masked := maskShell(src)
+    nodes := []*Node{}
+
+    for offset := 0; offset < len(masked); {
+        start := offset
+        offset = nextLine(masked, offset)
+
+        indent := leadingSpace(masked, start)
+        text := string(masked[start + len(indent):offset])
+
+        m := shellFunction.FindStringSubmatchIndex(text)
+        if m == nil {
+            continue
+        }
+
+        name := ""
+        if m[2] >= 0 {
+            name = text[m[2]:m[3]]
+        } else {
+            name = text[m[4]:m[5]]
+        }
+        if slices.Contains(shellKeywords, name) {
+            continue
+        }
+
+        // The body may start on the next line
+        open := start + len(indent) + m[1]
+        for open < len(masked) && strings.IndexByte(" \t\r\n", masked[open]) >= 0 {
+            open++
+        }
+        if open >= len(masked) || (masked[open] != '{' && masked[open] != '(') {
+            continue
+        }
+
+        end := shellBodyEnd(masked, open)
+        if end < 0 {
+            return nil, fmt.Errorf("line %v: body of %v isn't closed", lineNumber(src, start), name)
+        }
+
+        n := &Node{
+            Kind:      "function",
+            Name:      name,
+            File:      path,
+            Line:      lineNumber(src, start),
+            Start:     start,
+            End:       nextLine(src, end),
+            Signature: strings.TrimSpace(string(src[start:open])),
+            Indent:    indent,
+        }
+        n.Source = strings.TrimRight(string(src[n.Start:n.End]), "\n")
+        n.DocAt, n.Doc = shellHeader(src, start)
+
+        body := string(masked[open + 1:end])
+        n.Calls = shellCommands(body)
+        n.Params, n.ParamsKnown = shellArguments(body), true
+
+        nodes = append(nodes, n)
+
+        // Functions defined inside this one are found on later lines
+        offset = nextLine(masked, open)
+    }
+
+    names := []string{}
+    for _, n := range nodes {
+        names = append(names, n.Name)
+    }
+
+    globals := shellGlobals(masked, nodes)
+
+    for _, n := range nodes {
+        for _, command := range n.Calls {
+            if !slices.Contains(names, command) && !slices.Contains(n.Uses, command) {
+                n.Uses = append(n.Uses, command)
+            }
+        }
+
+        body := string(masked[n.Start:n.End])
+        for _, name := range shellFunctionGlobals(body, globals) {
+            n.Uses = append(n.Uses, "$" + name)
+        }
+    }
+
+    return nodes, nil
+}
+
+
+// shellBodyEnd finds the brace or parenthesis closing the body opened at
+// open. Only the opening kind is counted, as case patterns leave the others
+// unbalanced
+func shellBodyEnd(masked []byte, open int) int {
+    close := byte('}')
+    if masked[open] == '(' {
+        close = ')'
+    }
+
+    depth := 0
+    for i := open; i < len(masked); i++ {
+        switch masked[i] {
+        case masked[open]:
+            depth++
+        case close:
+            depth--
+            if depth == 0 {
+                return i
+            }
+        }
+    }
+
+    return -1
+}
+
+
+/**
+ * shellHeader finds the comment lines directly above the function at start.
+ * The doc is the text of those comments, without the shebang or shellcheck
+ * directives, and docAt is where a new header goes: above any directives.
+ */
+func shellHeader(src []byte, start int) (int, string) {
+    docAt := start
+    lines := []string{}
+
+    for docAt > 0 {
+        prev := lineStart(src, docAt - 1)
+        line := strings.TrimSpace(string(src[prev:docAt]))
+        if !strings.HasPrefix(line, "#") || strings.HasPrefix(line, "#!") {
+            break
+        }
+        lines = append([]string{ line }, lines...)
+        docAt = prev
+    }
+
+    doc := []string{}
+    for _, line := range lines {
+        if !strings.HasPrefix(strings.TrimSpace(strings.TrimLeft(line, "#")), "shellcheck ") {
+            doc = append(doc, line)
+        }
+    }
+
+    return docAt, strings.Join(doc, "\n")
+}
+
+
+// shellCommands lists the commands run in a function body other than shell
+// keywords, builtins and variable assignments
+func shellCommands(body string) []string {
+    commands := []string{}
+    body = maskExpansionBraces(body)
+
+    for _, m := range shellCommand.FindAllStringSubmatchIndex(body, -1) {
+        name := body[m[4]:m[5]]
+
+        // Arithmetic, $(( ... )) and (( ... ))
+        if strings.HasSuffix(strings.TrimRight(body[:m[2]], " \t"), "((") {
+            continue
+        }
+        if m[5] < len(body) && (body[m[5]] == '=' || (body[m[5]] == '+' && m[5] + 1 < len(body) && body[m[5] + 1] == '=') || body[m[5]] == '[') {
+            continue
+        }
+        if slices.Contains(shellKeywords, name) || slices.Contains(shellBuiltins, name) || slices.Contains(commands, name) {
+            continue
+        }
+        // Patterns of a case statement, e.g. start|stop), but not $(cmd)
+        rest := strings.TrimLeft(body[m[5]:], " \t")
+        substitution := strings.HasSuffix(strings.TrimRight(body[:m[2]], " \t"), "$(")
+        if !substitution && (strings.HasPrefix(rest, ")") || strings.HasPrefix(rest, "|") && !strings.HasPrefix(rest, "||")) {
+            continue
+        }
+
+        commands = append(commands, name)
+    }
+
+    return commands
+}
+
+
+// maskExpansionBraces blanks the braces of every ${...}, so they aren't read
+// as a group command around the variable's name. Command substitutions in a
+// default value, ${name:-$(cmd)}, are left to be found
+func maskExpansionBraces(body string) string {
+    masked := []byte(body)
+
+    for i := 0; i + 1 < len(masked); i++ {
+        if masked[i] != '$' || masked[i + 1] != '{' {
+            continue
+        }
+
+        depth := 0
+        for j := i + 1; j < len(masked); j++ {
+            if masked[j] == '{' {
+                depth++
+            } else if masked[j] == '}' {
+                depth--
+                if depth == 0 {
+                    masked[i + 1], masked[j] = ' ', ' '
+                    break
+                }
+            }
+        }
+    }
+
+    return string(masked)
+}
+
+
+// shellArguments are the positional parameters a function reads
+func shellArguments(body string) []Param {
+    params := []Param{}
+    seen := map[string]bool{}
+
+    for _, m := range shellPositional.FindAllStringSubmatch(body, -1) {
+        // ${#name} is the length of a variable
+        if m[1] == "#" && m[2] != "" {
+            continue
+        }
+
+        name := "$" + m[1]
+        if m[1] == "*" || m[1] == "#" {
+            name = "$@"
+        }
+        if !seen[name] {
+            seen[name] = true
+            params = append(params, Param{ Name: name })
+        }
+    }
+
+    slices.SortFunc(params, func(a, b Param) int { return strings.Compare(a.Name, b.Name) })
+
+    return params
+}
+
+
+// shellGlobals are the variables assigned outside any function
+func shellGlobals(masked []byte, nodes []*Node) []string {
+    outside := append([]byte{}, masked...)
+    for _, n := range nodes {
+        for i := n.Start; i < n.End && i < len(outside); i++ {
+            if outside[i] != '\n' {
+                outside[i] = ' '
+            }
+        }
+    }
+
+    globals := []string{}
+    for _, m := range shellAssignment.FindAllStringSubmatch(string(outside), -1) {
+        if !slices.Contains(globals, m[1]) {
+            globals = append(globals, m[1])
+        }
+    }
+
+    return globals
+}
+
+
+/**
+ * shellFunctionGlobals lists the global variables a function body reads or
+ * assigns: anything not declared local (or a loop or read variable) which is
+ * either assigned at the top level of the script or named in upper case, as
+ * environment variables are.
+ */
+func shellFunctionGlobals(body string, globals []string) []string {
+    locals := []string{}
+    for _, m := range shellLocal.FindAllStringSubmatch(body, -1) {
+        for _, word := range strings.Fields(m[2]) {
+            name := strings.SplitN(word, "=", 2)[0]
+            locals = append(locals, name)
+        }
+    }
+    for _, m := range shellLoopVar.FindAllStringSubmatch(body, -1) {
+        locals = append(locals, m[1])
+    }
+    for _, m := range shellReadVars.FindAllStringSubmatch(body, -1) {
+        locals = append(locals, strings.Fields(m[2])...)
+    }
+
+    found := []string{}
+    add := func(name string) {
+        if slices.Contains(locals, name) || slices.Contains(found, name) {
+            return
+        }
+        if slices.Contains(globals, name) || (name == strings.ToUpper(name) && strings.ContainsAny(name, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")) {
+            found = append(found, name)
+        }
+    }
+
+    for _, m := range shellVariable.FindAllStringSubmatch(body, -1) {
+        add(m[1])
+    }
+    for _, m := range shellAssignment.FindAllStringSubmatch(body, -1) {
+        add(m[1])
+    }
+
+    return found
+}
+
+
+/**
+ * ShellHeaderLines lays out the model's text as the lines of a Google style
+ * header: description lines as they are, section headings, and section
+ * entries indented by two more spaces. Globals and Arguments sections are
+ * added from the function body when the model left them out.
+ */
+func ShellHeaderLines(n *Node, doc string) []string {
+    sections := map[string][]string{}
+    order := []string{ "Globals", "Arguments", "Outputs", "Returns" }
+    description := []string{}
+    current := ""
+
+    for _, line := range strings.Split(doc, "\n") {
+        trimmed := strings.TrimSpace(line)
+        heading := strings.TrimSuffix(trimmed, ":")
+
+        if strings.HasSuffix(trimmed, ":") && slices.Contains(order, heading) {
+            current = heading
+            sections[current] = []string{}
+            continue
+        }
+
+        if current == "" {
+            description = append(description, trimmed)
+        } else if trimmed != "" {
+            sections[current] = append(sections[current], trimmed)
+        }
+    }
+
+    if _, ok := sections["Globals"]; !ok {
+        for _, use := range n.Uses {
+            if strings.HasPrefix(use, "$") {
+                sections["Globals"] = append(sections["Globals"], strings.TrimPrefix(use, "$"))
+            }
+        }
+    }
+
+    if _, ok := sections["Arguments"]; !ok {
+        for _, p := range n.Params {
+            sections["Arguments"] = append(sections["Arguments"], p.Name)
+        }
+    }
+
+    for len(description) > 0 && description[len(description) - 1] == "" {
+        description = description[:len(description) - 1]
+    }
+
+    lines := description
+    for _, name := range order {
+        if len(sections[name]) == 0 {
+            continue
+        }
+        lines = append(lines, name + ":")
+        for _, entry := range sections[name] {
+            lines = append(lines, "  " + entry)
+        }
+    }
+
+    return lines
+}
+
+
+func (ShellBackend) Insert(src []byte, n *Node, doc string) ([]byte, error) {
+    if n.Doc != "" {
+        return src, nil
+    }
+
+    var b strings.Builder
+    b.WriteString(n.Indent + shellBanner + "\n")
+    for _, line := range ShellHeaderLines(n, doc) {
+        if line == "" {
+            b.WriteString(n.Indent + "#\n")
+        } else {
+            b.WriteString(n.Indent + "# " + line + "\n")
+        }
+    }
+    b.WriteString(n.Indent + shellBanner + "\n")
+
+    return insertAt(src, n.DocAt, b.String()), nil
+}
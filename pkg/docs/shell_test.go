@@ -0,0 +1,209 @@
+package docs
+
+import (
+    "slices"
+    "strings"
+    "testing"
+)
+
+
+const shellSource = `#!/bin/bash
+set -eu
+
+CONFIG_PATH=/etc/app.conf
+readonly LOG_DIR=/var/log/app
+
+# shellcheck disable=SC2034
+deploy() {
+    local target="$1" tag
+    tag="${2:-latest}"
+    read -r answer
+    cp "${CONFIG_PATH}" "${LOG_DIR}/$(date +%F).conf"
+    ${DOCKER:-docker} push "$target:$tag" | tee -a "$LOG_DIR/push.log"
+    for host in $HOSTS; do
+        ssh "$host" 'restart now; fake_command'
+    done
+    cat <<EOF
+fake_heredoc_command $HOME
+EOF
+    notify "$@"
+}
+
+function notify {
+    case "$1" in
+        start|stop) logger "$1" ;;
+        *) echo "${#1}" ;;
+    esac
+    count=$(( count + 1 ))
+}
+
+function cleanup() (
+    rm -rf "$TMPDIR"
+)
+`
+
+
+func TestShellParse(t *testing.T) {
+    nodes, err := ShellBackend{}.Parse("deploy.sh", []byte(shellSource))
+    if err != nil {
+        t.Fatalf("Parse() error = %v", err)
+    }
+
+    type want struct {
+        name   string
+        line   int
+        sig    string
+        calls  []string
+        params []string
+        uses   []string
+    }
+
+    wants := []want{
+        {
+            name:   "deploy",
+            line:   8,
+            sig:    "deploy()",
+            // ${CONFIG_PATH} and ${LOG_DIR}/ aren't commands, nor is anything
+            // in single quotes or a here-document
+            calls:  []string{ "cp", "date", "tee", "ssh", "cat", "notify" },
+            params: []string{ "$1", "$2", "$@" },
+            uses:   []string{ "cp", "date", "tee", "ssh", "cat", "$CONFIG_PATH", "$LOG_DIR", "$DOCKER", "$HOSTS" },
+        },
+        {
+            name:   "notify",
+            line:   23,
+            sig:    "function notify",
+            calls:  []string{ "logger" },
+            params: []string{ "$1" },
+            uses:   []string{ "logger" },
+        },
+        {
+            name:   "cleanup",
+            line:   31,
+            sig:    "function cleanup()",
+            calls:  []string{ "rm" },
+            params: []string{},
+            uses:   []string{ "rm", "$TMPDIR" },
+        },
+    }
+
+    if len(nodes) != len(wants) {
+        t.Fatalf("Parse() found %v functions, want %v", len(nodes), len(wants))
+    }
+
+    for i, w := range wants {
+        n := nodes[i]
+        params := []string{}
+        for _, p := range n.Params {
+            params = append(params, p.Name)
+        }
+
+        if n.Name != w.name || n.Line != w.line || n.Signature != w.sig {
+            t.Errorf("node %v = %v line %v %q, want %+v", i, n.Name, n.Line, n.Signature, w)
+        }
+        if !slices.Equal(n.Calls, w.calls) {
+            t.Errorf("%v calls %v, want %v", w.name, n.Calls, w.calls)
+        }
+        if !slices.Equal(params, w.params) {
+            t.Errorf("%v params %v, want %v", w.name, params, w.params)
+        }
+        if !slices.Equal(n.Uses, w.uses) {
+            t.Errorf("%v uses %v, want %v", w.name, n.Uses, w.uses)
+        }
+    }
+
+    // The header goes above the shellcheck directive, which isn't doc
+    if nodes[0].Doc != "" || !strings.HasPrefix(shellSource[nodes[0].DocAt:], "# shellcheck") {
+        t.Errorf("deploy doc = %q at %q", nodes[0].Doc, shellSource[nodes[0].DocAt:nodes[0].Start])
+    }
+}
+
+
+func TestShellCommands(t *testing.T) {
+    tests := []struct {
+        body string
+        want []string
+    }{
+        { body: "\n    ${CONFIG_PATH}\n", want: []string{} },
+        { body: "\n    echo ${A}/bin/tool\n", want: []string{} },
+        { body: "\n    x=${A:-$(hostname)}\n", want: []string{ "hostname" } },
+        { body: "\n    { make; make install; } > log\n", want: []string{ "make" } },
+        { body: "\n    if ! grep -q x f; then sudo systemctl restart x; fi\n", want: []string{ "grep", "systemctl" } },
+        { body: "\n    (( n++ )); v=$(( n * 2 ))\n", want: []string{} },
+        { body: "\n    arr[0]=x; PATH+=:/opt\n", want: []string{} },
+    }
+
+    for _, tt := range tests {
+        if got := shellCommands(tt.body); !slices.Equal(got, tt.want) {
+            t.Errorf("shellCommands(%q) = %v, want %v", tt.body, got, tt.want)
+        }
+    }
+}
+
+
+func TestShellParseUnclosed(t *testing.T) {
+    if _, err := (ShellBackend{}).Parse("bad.sh", []byte("f() {\n    echo hi\n")); err == nil {
+        t.Error("Parse() of an unclosed function succeeded")
+    }
+}
+
+
+func TestShellInsert(t *testing.T) {
+    src := "#!/bin/sh\nOUT=/tmp/out\n\n  greet() {\n    printf '%s\\n' \"$1\" > \"$OUT\"\n  }\n"
+
+    nodes, err := ShellBackend{}.Parse("greet.sh", []byte(src))
+    if err != nil {
+        t.Fatalf("Parse() error = %v", err)
+    }
+
+    // Globals and Arguments are added from the body, and the sections
+    // follow the summary straight away as in Google's examples
+    doc := "Greet someone.\n\nOutputs:\nWrites the greeting to $OUT"
+    got, err := ShellBackend{}.Insert([]byte(src), nodes[0], doc)
+    if err != nil {
+        t.Fatalf("Insert() error = %v", err)
+    }
+
+    want := "#!/bin/sh\nOUT=/tmp/out\n\n" +
+        "  " + shellBanner + "\n" +
+        "  # Greet someone.\n" +
+        "  # Globals:\n" +
+        "  #   OUT\n" +
+        "  # Arguments:\n" +
+        "  #   $1\n" +
+        "  # Outputs:\n" +
+        "  #   Writes the greeting to $OUT\n" +
+        "  " + shellBanner + "\n" +
+        "  greet() {\n    printf '%s\\n' \"$1\" > \"$OUT\"\n  }\n"
+    if string(got) != want {
+        t.Fatalf("Insert() =\n%v\nwant\n%v", string(got), want)
+    }
+
+    reparsed, err := ShellBackend{}.Parse("greet.sh", got)
+    if err != nil || len(reparsed) != 1 || reparsed[0].Doc == "" {
+        t.Errorf("documented source reparsed as %v, %v", reparsed, err)
+    }
+
+    // A function with a header is left alone
+    again, _ := ShellBackend{}.Insert(got, reparsed[0], doc)
+    if string(again) != string(got) {
+        t.Errorf("Insert() changed a documented function")
+    }
+}
+
+
+func TestShellRender(t *testing.T) {
+    n := &Node{ Params: []Param{ { Name: "$1" } }, ParamsKnown: true }
+    d := Doc{
+        Summary:     "Greet someone.",
+        Params:      []ParamDoc{ { Name: "1", Description: "Their name." } },
+        SideEffects: "Writes the greeting to stdout.",
+        Returns:     "0 on success.",
+        Errors:      []string{ "1 if no name was given." },
+    }
+
+    want := "Greet someone.\n\nArguments:\n  $1 - Their name.\n\nOutputs:\n  Writes the greeting to stdout.\n\nReturns:\n  0 on success.\n  1 if no name was given."
+    if got := (ShellBackend{}).Render(n, d); got != want {
+        t.Errorf("Render() =\n%v\nwant\n%v", got, want)
+    }
+}
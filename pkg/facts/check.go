@@ -0,0 +1,215 @@
+package facts
+
+import (
+    "fmt"
+    "regexp"
+    "slices"
+    "strings"
+    "path/filepath"
+
+    "golang.org/x/mod/semver"
+)
+
+
+type Contradiction struct {
+    Line  int
+    Claim string
+    Fact  string
+}
+
+func (c Contradiction) String() string {
+    return fmt.Sprintf("line %v: README says %v, but %v", c.Line, c.Claim, c.Fact)
+}
+
+
+var (
+    goVersionClaim = regexp.MustCompile(`(?i)\bgo(?:lang)?\b[ \t(]*(?:version)?[ \t]*(?:>=|≥|=|v|at least)?[ \t]*(\d+\.\d+(?:\.\d+)?)`)
+    codeSpan       = regexp.MustCompile("`([^`\n]+)`")
+    flagToken      = regexp.MustCompile(`^--?([A-Za-z][\w-]*)(?:=.*)?$`)
+    envReference   = regexp.MustCompile(`\$\{?([A-Z][A-Z0-9]*_[A-Z0-9_]+)\b`)
+    exportStmt     = regexp.MustCompile(`\bexport\s+([A-Z_][A-Z0-9_]*)=`)
+    envAssignment  = regexp.MustCompile(`^([A-Z][A-Z0-9]*_[A-Z0-9_]+)=`)
+)
+
+
+// Flags prose uses as stand-ins, as in "pflag reads -x as a shorthand"
+var placeholderFlags = []string{ "x", "flag", "foo" }
+
+
+// A snippet of code in the README: a code span or a line of a fenced block
+type snippet struct {
+    Line int
+    Text string
+}
+
+
+func codeSnippets(readme string) []snippet {
+    snippets := []snippet{}
+    inFence := false
+
+    for i, line := range strings.Split(readme, "\n") {
+        trimmed := strings.TrimSpace(line)
+
+        if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+            inFence = !inFence
+            continue
+        }
+
+        if inFence {
+            snippets = append(snippets, snippet{ Line: i + 1, Text: trimmed })
+            continue
+        }
+
+        for _, m := range codeSpan.FindAllStringSubmatch(line, -1) {
+            snippets = append(snippets, snippet{ Line: i + 1, Text: strings.TrimSpace(m[1]) })
+        }
+    }
+
+    return snippets
+}
+
+
+/**
+ * Check looks for claims in readme which the facts contradict: an older Go
+ * version than go.mod requires, flags the program doesn't define, make
+ * targets the Makefile doesn't have, and environment variables or config
+ * files the code never reads. Variables read under a prefix, like
+ * AUTOSCRIBE_MODEL, count when the rest is a config key.
+ */
+func (f *Facts) Check(readme string) []Contradiction {
+    found := []Contradiction{}
+    report := func(line int, claim string, fact string, args ...any) {
+        found = append(found, Contradiction{ Line: line, Claim: claim, Fact: fmt.Sprintf(fact, args...) })
+    }
+
+    if f.GoVersion != "" {
+        for i, line := range strings.Split(readme, "\n") {
+            for _, m := range goVersionClaim.FindAllStringSubmatch(line, -1) {
+                if semver.Compare("v" + m[1], "v" + f.GoVersion) < 0 {
+                    report(i + 1, fmt.Sprintf("Go %v", m[1]), "go.mod requires go %v", f.GoVersion)
+                }
+            }
+        }
+    }
+
+    for _, s := range codeSnippets(readme) {
+        words := strings.Fields(s.Text)
+        if len(words) > 0 && words[0] == "sudo" {
+            words = words[1:]
+        }
+        assigned := []string{}
+        for len(words) > 1 && envAssignment.MatchString(words[0]) {
+            assigned = append(assigned, envAssignment.FindStringSubmatch(words[0])[1])
+            words = words[1:]
+        }
+        if len(words) == 0 {
+            continue
+        }
+
+        command := filepath.Base(words[0])
+
+        switch {
+        case command == "make" && f.Makefile != "":
+            for _, target := range words[1:] {
+                if strings.HasPrefix(target, "-") || strings.Contains(target, "=") {
+                    continue
+                }
+                if !slices.Contains(f.MakeTargets, target) {
+                    report(s.Line, fmt.Sprintf("`make %v`", target), "%v has no %v target", f.Makefile, target)
+                }
+            }
+
+        case f.HasGoCode && len(f.FlagDefinitions) > 0 && (command == f.Program || flagToken.MatchString(words[0])):
+            args := words
+            if command == f.Program {
+                args = words[1:]
+            }
+            // A code span holding a single flag, e.g. `-r`, or a command line
+            for _, arg := range args {
+                m := flagToken.FindStringSubmatch(arg)
+                if m == nil {
+                    continue
+                }
+                if !f.definesFlag(m[1]) && !slices.Contains(placeholderFlags, m[1]) {
+                    report(s.Line, fmt.Sprintf("`%v`", arg), "%v defines no flag named %v", f.Program, m[1])
+                }
+                if command != f.Program {
+                    break
+                }
+            }
+        }
+
+        if !f.HasGoCode {
+            continue
+        }
+
+        for _, env := range envClaims(s.Text, assigned) {
+            if !f.readsEnv(env) {
+                report(s.Line, fmt.Sprintf("environment variable %v", env), "the code never reads it")
+            }
+        }
+
+        for _, word := range words {
+            path := strings.Trim(word, `"'`)
+            if IsConfigPath(path) && !slices.Contains(f.ConfigPaths, path) && !slices.Contains(f.MakeTargets, path) {
+                report(s.Line, fmt.Sprintf("config file %v", path), "the code never refers to it")
+            }
+        }
+    }
+
+    return found
+}
+
+
+/**
+ * envClaims returns the environment variables text says are read. A name
+ * alone, like `DOC_STYLE`, could as well be a config key or a constant, so
+ * it takes an export, a $NAME or ${NAME} reference, or an assignment before
+ * a command (assigned, taken off the command line already).
+ */
+func envClaims(text string, assigned []string) []string {
+    envs := slices.Clone(assigned)
+
+    for _, m := range exportStmt.FindAllStringSubmatch(text, -1) {
+        envs = appendUnique(envs, m[1])
+    }
+    for _, m := range envReference.FindAllStringSubmatch(text, -1) {
+        envs = appendUnique(envs, m[1])
+    }
+
+    return envs
+}
+
+
+// readsEnv reports whether the code reads env, by name or as a prefix and a
+// config key
+func (f *Facts) readsEnv(env string) bool {
+    if slices.Contains(f.EnvVars, env) {
+        return true
+    }
+
+    for _, prefix := range f.EnvPrefixes {
+        key, ok := strings.CutPrefix(env, prefix)
+        if ok && (len(f.ConfigKeys) == 0 || slices.Contains(f.ConfigKeys, key)) {
+            return true
+        }
+    }
+
+    return false
+}
+
+
+func (f *Facts) definesFlag(name string) bool {
+    // The flag package answers -h and -help itself
+    if name == "h" || name == "help" {
+        return true
+    }
+
+    for _, def := range f.FlagDefinitions {
+        if def.Name == name || def.Shorthand == name {
+            return true
+        }
+    }
+
+    return false
+}
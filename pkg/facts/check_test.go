@@ -0,0 +1,103 @@
+package facts
+
+import (
+    "os"
+    "slices"
+    "testing"
+    "os/exec"
+    "path/filepath"
+)
+
+
+func TestEnvClaims(t *testing.T) {
+    tests := []struct {
+        text     string
+        assigned []string
+        want     []string
+    }{
+        // A name alone is as likely a config key or a constant
+        { text: "OPENAI_API_KEY", want: []string{} },
+        { text: "DOC_STYLE: {py: numpy}", want: []string{} },
+        { text: "export TOOL_HOME=/opt/tool", want: []string{ "TOOL_HOME" } },
+        { text: "$TOOL_HOME/bin", want: []string{ "TOOL_HOME" } },
+        { text: "${XDG_CONFIG_HOME:-~/.config}/tool", want: []string{ "XDG_CONFIG_HOME" } },
+        { text: "export A_B=1; echo $A_B $C_D", want: []string{ "A_B", "C_D" } },
+        // $HOME and the like are left to the shell
+        { text: "$HOME/.tool", want: []string{} },
+        { text: "tool -v", assigned: []string{ "TOOL_MODEL" }, want: []string{ "TOOL_MODEL" } },
+    }
+
+    for _, tt := range tests {
+        if got := envClaims(tt.text, tt.assigned); !slices.Equal(got, tt.want) {
+            t.Errorf("envClaims(%q) = %v, want %v", tt.text, got, tt.want)
+        }
+    }
+}
+
+
+func TestCheck(t *testing.T) {
+    f := &Facts{
+        Program:         "tool",
+        GoVersion:       "1.22",
+        Makefile:        "Makefile",
+        MakeTargets:     []string{ "build", "test" },
+        FlagDefinitions: []FlagDefinition{ { Name: "config", Shorthand: "c" }, { Name: "v" } },
+        EnvVars:         []string{ "TOOL_HOME", "XDG_CONFIG_HOME" },
+        EnvPrefixes:     []string{ "TOOL_" },
+        ConfigKeys:      []string{ "MODEL" },
+        ConfigPaths:     []string{ "/etc/tool.conf", "~/.config/tool/config.yaml" },
+        HasGoCode:       true,
+    }
+
+    tests := []struct {
+        readme string
+        want   []string
+    }{
+        { readme: "Needs Go 1.20 or later.", want: []string{ "Go 1.20" } },
+        { readme: "Needs Go 1.22.", want: []string{} },
+        { readme: "Run `make install`, then `make build test`.", want: []string{ "`make install`" } },
+        { readme: "```sh\ntool --config a.yaml -q\nsudo tool -c a.yaml\n```", want: []string{ "`-q`" } },
+        { readme: "Pass `-v`, `-h` or `--help`, or `-w`.", want: []string{ "`-w`" } },
+        // -x stands in for any shorthand
+        { readme: "Shorthands complete as `-x`, but `-y` isn't defined.", want: []string{ "`-y`" } },
+        { readme: "Set `MODEL` or `OPENAI_API_KEY` in the config.", want: []string{} },
+        { readme: "`export TOOL_HOME=/opt` and `export OTHER_KEY=1`", want: []string{ "environment variable OTHER_KEY" } },
+        { readme: "Under `$XDG_CONFIG_HOME` or `${MISSING_DIR}/tool`.", want: []string{ "environment variable MISSING_DIR" } },
+        { readme: "`TOOL_MODEL=gpt tool` or `TOOL_COLOUR=red tool -v`", want: []string{ "environment variable TOOL_COLOUR" } },
+        { readme: "`FOO_BAR=1 tool -q`", want: []string{ "`-q`", "environment variable FOO_BAR" } },
+        { readme: "Reads `/etc/tool.conf`, `~/.config/tool/config.yaml` and `~/.toolrc.yaml`.", want: []string{ "config file ~/.toolrc.yaml" } },
+    }
+
+    for _, tt := range tests {
+        claims := []string{}
+        for _, c := range f.Check(tt.readme) {
+            claims = append(claims, c.Claim)
+        }
+        if !slices.Equal(claims, tt.want) {
+            t.Errorf("Check(%q) = %q, want %q", tt.readme, claims, tt.want)
+        }
+    }
+}
+
+
+// The README of this project describes it, and so must pass
+func TestCheckOwnReadme(t *testing.T) {
+    if _, err := exec.LookPath("go"); err != nil {
+        t.Skip("needs the go command")
+    }
+
+    root := filepath.Join("..", "..")
+    readme, err := os.ReadFile(filepath.Join(root, "README.md"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    f, err := Collect(root)
+    if err != nil {
+        t.Fatalf("Collect() error = %v", err)
+    }
+
+    for _, c := range f.Check(string(readme)) {
+        t.Errorf("README.md %v", c)
+    }
+}
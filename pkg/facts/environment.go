@@ -0,0 +1,282 @@
+package facts
+
+import (
+    "regexp"
+    "slices"
+    "reflect"
+    "strconv"
+    "strings"
+    "path/filepath"
+
+    "go/ast"
+    "go/token"
+    "go/types"
+
+    "golang.org/x/tools/go/packages"
+)
+
+
+// Functions reading an environment variable named by their first argument
+var envReaders = map[string][]string{
+    "os":      { "Getenv", "LookupEnv" },
+    "syscall": { "Getenv" },
+}
+
+
+var configExtensions = []string{
+    ".conf", ".cfg", ".ini", ".yaml", ".yml", ".toml", ".json", ".env",
+}
+
+
+// Functions which read environment variables without being given their
+// names, and what they read on Linux
+var impliedEnv = map[string][]string{
+    "os.UserConfigDir": { "XDG_CONFIG_HOME", "HOME" },
+    "os.UserCacheDir":  { "XDG_CACHE_HOME", "HOME" },
+    "os.UserHomeDir":   { "HOME" },
+    "os.TempDir":       { "TMPDIR" },
+}
+
+
+// Where the directories returned by these functions are on Linux, for the
+// config paths built on them
+var userDirs = map[string]string{
+    "os.UserConfigDir": "~/.config",
+    "os.UserHomeDir":   "~",
+}
+
+
+var (
+    envName   = regexp.MustCompile(`^[A-Z][A-Z0-9]*_[A-Z0-9_]+$`)
+    envPrefix = regexp.MustCompile(`^[A-Z][A-Z0-9_]*_$`)
+)
+
+
+/**
+ * EnvVarsInPackages returns the names of the environment variables read
+ * with os.Getenv or os.LookupEnv, and the prefixes of names built up in the
+ * code, like EnvPrefix + key. A constant name is read as is. When a function
+ * reads a name it builds, the constant prefixes it adds to and the variable
+ * names it mentions are taken instead. Variables read by os.UserConfigDir
+ * and the like are included too.
+ */
+func EnvVarsInPackages(pkgs []*packages.Package) ([]string, []string) {
+    vars, prefixes := []string{}, []string{}
+
+    for _, pkg := range pkgs {
+        for _, file := range pkg.Syntax {
+            for _, decl := range file.Decls {
+                built := false
+
+                ast.Inspect(decl, func(n ast.Node) bool {
+                    call, ok := n.(*ast.CallExpr)
+                    if !ok {
+                        return true
+                    }
+
+                    name := calledFunc(pkg.TypesInfo, call)
+                    vars = appendUnique(vars, impliedEnv[name]...)
+
+                    pkgPath, fn, _ := strings.Cut(name, ".")
+                    if len(call.Args) == 0 || !slices.Contains(envReaders[pkgPath], fn) {
+                        return true
+                    }
+
+                    if env := stringValue(pkg.TypesInfo, call.Args[0]); env != "" {
+                        vars = appendUnique(vars, env)
+                    } else {
+                        built = true
+                    }
+
+                    return true
+                })
+
+                if !built {
+                    continue
+                }
+
+                ast.Inspect(decl, func(n ast.Node) bool {
+                    switch x := n.(type) {
+                    case *ast.BinaryExpr:
+                        if prefix := stringValue(pkg.TypesInfo, x.X); x.Op == token.ADD && envPrefix.MatchString(prefix) {
+                            prefixes = appendUnique(prefixes, prefix)
+                        }
+                    case *ast.BasicLit:
+                        if value, err := strconv.Unquote(x.Value); err == nil && x.Kind == token.STRING && envName.MatchString(value) {
+                            vars = appendUnique(vars, value)
+                        }
+                    }
+
+                    return true
+                })
+            }
+        }
+    }
+
+    slices.Sort(vars)
+    slices.Sort(prefixes)
+
+    return vars, prefixes
+}
+
+
+// calledFunc returns the package path and name of the function call calls,
+// like os.Getenv, or nothing for methods and func values
+func calledFunc(info *types.Info, call *ast.CallExpr) string {
+    var id *ast.Ident
+    switch fun := call.Fun.(type) {
+    case *ast.SelectorExpr:
+        id = fun.Sel
+    case *ast.Ident:
+        id = fun
+    default:
+        return ""
+    }
+
+    fn, ok := info.Uses[id].(*types.Func)
+    if !ok || fn.Pkg() == nil || fn.Type().(*types.Signature).Recv() != nil {
+        return ""
+    }
+
+    return fn.Pkg().Path() + "." + fn.Name()
+}
+
+
+/**
+ * ConfigPathsInPackages returns string literals which look like paths to
+ * configuration files: absolute or home relative paths under /etc or with a
+ * config file extension. Paths joined onto os.UserConfigDir or
+ * os.UserHomeDir are included as they'd be on Linux, e.g.
+ * ~/.config/tool/config.yaml.
+ */
+func ConfigPathsInPackages(pkgs []*packages.Package) []string {
+    paths := []string{}
+
+    for _, pkg := range pkgs {
+        for _, file := range pkg.Syntax {
+            ast.Inspect(file, func(n ast.Node) bool {
+                lit, ok := n.(*ast.BasicLit)
+                if !ok || lit.Kind != token.STRING {
+                    return true
+                }
+
+                value, err := strconv.Unquote(lit.Value)
+                if err != nil || !IsConfigPath(value) {
+                    return true
+                }
+
+                paths = appendUnique(paths, value)
+                return true
+            })
+
+            for _, decl := range file.Decls {
+                paths = appendUnique(paths, userConfigPaths(pkg.TypesInfo, decl)...)
+            }
+        }
+    }
+
+    slices.Sort(paths)
+
+    return paths
+}
+
+
+// userConfigPaths returns the config paths decl joins onto the user
+// directory it asks for
+func userConfigPaths(info *types.Info, decl ast.Decl) []string {
+    base := ""
+    joins := [][]string{}
+
+    ast.Inspect(decl, func(n ast.Node) bool {
+        call, ok := n.(*ast.CallExpr)
+        if !ok {
+            return true
+        }
+
+        name := calledFunc(info, call)
+        if dir, ok := userDirs[name]; ok && base == "" {
+            base = dir
+        }
+        if name != "path/filepath.Join" || len(call.Args) < 2 {
+            return true
+        }
+
+        parts := []string{}
+        for _, arg := range call.Args[1:] {
+            part := stringValue(info, arg)
+            if part == "" {
+                return true
+            }
+            parts = append(parts, part)
+        }
+        joins = append(joins, parts)
+
+        return true
+    })
+
+    paths := []string{}
+    if base == "" {
+        return paths
+    }
+
+    for _, parts := range joins {
+        if path := base + "/" + strings.Join(parts, "/"); IsConfigPath(path) {
+            paths = append(paths, path)
+        }
+    }
+
+    return paths
+}
+
+
+/**
+ * ConfigKeysInPackages returns the keys of the config file, read from the
+ * yaml tags of struct fields.
+ */
+func ConfigKeysInPackages(pkgs []*packages.Package) []string {
+    keys := []string{}
+
+    for _, pkg := range pkgs {
+        for _, file := range pkg.Syntax {
+            ast.Inspect(file, func(n ast.Node) bool {
+                field, ok := n.(*ast.Field)
+                if !ok || field.Tag == nil {
+                    return true
+                }
+
+                tag, err := strconv.Unquote(field.Tag.Value)
+                if err != nil {
+                    return true
+                }
+
+                key, _, _ := strings.Cut(reflect.StructTag(tag).Get("yaml"), ",")
+                if key != "" && key != "-" {
+                    keys = appendUnique(keys, key)
+                }
+
+                return true
+            })
+        }
+    }
+
+    slices.Sort(keys)
+
+    return keys
+}
+
+
+func IsConfigPath(value string) bool {
+    if strings.ContainsAny(value, " \n\t%*") {
+        return false
+    }
+
+    if !strings.HasPrefix(value, "/") && !strings.HasPrefix(value, "~/") && !strings.HasPrefix(value, "$HOME/") {
+        return false
+    }
+
+    if strings.HasPrefix(value, "/etc/") && filepath.Ext(value) != "" {
+        return true
+    }
+
+    return slices.Contains(configExtensions, filepath.Ext(value))
+}
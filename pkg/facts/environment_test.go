@@ -0,0 +1,87 @@
+package facts
+
+import (
+    "os"
+    "slices"
+    "testing"
+    "os/exec"
+    "path/filepath"
+)
+
+
+const envSource = `package main
+
+import (
+    "os"
+    "path/filepath"
+)
+
+const prefix = "TOOL_"
+
+type Config struct {
+    Model string ` + "`yaml:\"MODEL\"`" + `
+    Debug bool   ` + "`yaml:\"DEBUG,omitempty\"`" + `
+    Skip  string ` + "`yaml:\"-\"`" + `
+}
+
+// lookup reads TOOL_<key>, and API_KEY without the prefix too
+func lookup(key string) string {
+    names := []string{ prefix + key }
+    if key == "API_KEY" {
+        names = append(names, key)
+    }
+    for _, name := range names {
+        if v, ok := os.LookupEnv(name); ok {
+            return v
+        }
+    }
+    return ""
+}
+
+func main() {
+    _ = os.Getenv("TOOL_HOME")
+    dir, _ := os.UserConfigDir()
+    _ = filepath.Join(dir, "tool", "config.yaml")
+    _ = filepath.Join(dir, "tool", "cache")
+    _ = lookup("MODEL")
+    _ = "/etc/tool.conf"
+    _ = "NOT_READ_HERE"
+}
+`
+
+
+func TestEnvVarsInPackages(t *testing.T) {
+    if _, err := exec.LookPath("go"); err != nil {
+        t.Skip("needs the go command")
+    }
+
+    dir := t.TempDir()
+    for name, content := range map[string]string{ "go.mod": "module example.com/tool\n\ngo 1.21\n", "main.go": envSource } {
+        if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    pkgs, err := LoadPackages(dir)
+    if err != nil {
+        t.Fatalf("LoadPackages() error = %v", err)
+    }
+
+    // lookup builds the name it reads, so its prefix and the names it
+    // mentions are taken. main only reads a constant name
+    vars, prefixes := EnvVarsInPackages(pkgs)
+    if want := []string{ "API_KEY", "HOME", "TOOL_HOME", "XDG_CONFIG_HOME" }; !slices.Equal(vars, want) {
+        t.Errorf("EnvVarsInPackages() vars = %v, want %v", vars, want)
+    }
+    if want := []string{ "TOOL_" }; !slices.Equal(prefixes, want) {
+        t.Errorf("EnvVarsInPackages() prefixes = %v, want %v", prefixes, want)
+    }
+
+    if got, want := ConfigPathsInPackages(pkgs), []string{ "/etc/tool.conf", "~/.config/tool/config.yaml" }; !slices.Equal(got, want) {
+        t.Errorf("ConfigPathsInPackages() = %v, want %v", got, want)
+    }
+
+    if got, want := ConfigKeysInPackages(pkgs), []string{ "DEBUG", "MODEL" }; !slices.Equal(got, want) {
+        t.Errorf("ConfigKeysInPackages() = %v, want %v", got, want)
+    }
+}
@@ -0,0 +1,103 @@
+package facts
+
+import (
+    "fmt"
+    "encoding/json"
+
+    log "github.com/sirupsen/logrus"
+)
+
+
+type Flag struct {
+    Name    string   `json:"name"`
+    Type    string   `json:"type"`
+    Default string   `json:"default,omitempty"`
+    Usage   string   `json:"usage"`
+    Values  []string `json:"values,omitempty"`
+}
+
+
+// Facts are things about a project we can read straight from its files, and
+// so don't need (or want) the model to guess
+type Facts struct {
+    Program     string           `json:"program,omitempty"`
+    ModulePath  string           `json:"module_path,omitempty"`
+    GoVersion   string           `json:"go_version,omitempty"`
+    Requires    []string         `json:"requires,omitempty"`
+    Makefile    string           `json:"makefile,omitempty"`
+    MakeTargets []string         `json:"make_targets,omitempty"`
+    Flags       []Flag           `json:"flags,omitempty"`
+    EnvVars     []string         `json:"env_vars,omitempty"`
+    EnvPrefixes []string         `json:"env_prefixes,omitempty"`
+    ConfigPaths []string         `json:"config_paths,omitempty"`
+    ConfigKeys  []string         `json:"config_keys,omitempty"`
+
+    // Full definitions, for the generators which need more than the summary
+    FlagDefinitions []FlagDefinition `json:"-"`
+    HasGoCode       bool             `json:"-"`
+}
+
+
+/**
+ * Collect reads the facts for the project in folder: module path, go version
+ * and requirements from go.mod, Makefile targets, and the flags, environment
+ * variables, config file paths and config keys used by the Go code. Whatever
+ * can't be read is left empty.
+ */
+func Collect(folder string) (*Facts, error) {
+    f := &Facts{ Program: ProgramName(folder) }
+
+    if mod, err := ReadGoMod(folder); err == nil {
+        if mod.Module != nil {
+            f.ModulePath = mod.Module.Mod.Path
+        }
+        if mod.Go != nil {
+            f.GoVersion = mod.Go.Version
+        }
+        for _, req := range mod.Require {
+            if !req.Indirect {
+                f.Requires = append(f.Requires, fmt.Sprintf("%v %v", req.Mod.Path, req.Mod.Version))
+            }
+        }
+    } else {
+        log.Debugf("No go.mod facts: %v", err)
+    }
+
+    f.MakeTargets, f.Makefile = MakeTargets(folder)
+
+    if f.ModulePath != "" {
+        pkgs, err := LoadPackages(folder)
+        if err != nil {
+            return f, fmt.Errorf("failed to load go packages for facts: %v", err)
+        }
+
+        f.HasGoCode = true
+        f.FlagDefinitions = FlagsInPackages(pkgs)
+        f.EnvVars, f.EnvPrefixes = EnvVarsInPackages(pkgs)
+        f.ConfigPaths = ConfigPathsInPackages(pkgs)
+        f.ConfigKeys = ConfigKeysInPackages(pkgs)
+
+        for _, def := range f.FlagDefinitions {
+            f.Flags = append(f.Flags, Flag{
+                Name:    def.Name,
+                Type:    def.Type,
+                Default: def.Default,
+                Usage:   def.Usage,
+                Values:  def.Values,
+            })
+        }
+    }
+
+    return f, nil
+}
+
+
+// Format renders the facts as the JSON block we put in prompts
+func (f *Facts) Format() string {
+    data, err := json.MarshalIndent(f, "", "  ")
+    if err != nil {
+        return "{}"
+    }
+
+    return string(data)
+}
@@ -0,0 +1,544 @@
+package facts
+
+import (
+    "fmt"
+    "slices"
+    "strings"
+
+    "go/ast"
+    "go/types"
+    "go/constant"
+
+    "golang.org/x/tools/go/packages"
+
+    log "github.com/sirupsen/logrus"
+)
+
+
+type PathKind string
+
+const (
+    NotAPath PathKind = ""
+    FilePath PathKind = "file"
+    DirPath  PathKind = "dir"
+)
+
+
+type FlagDefinition struct {
+    Name      string
+    Shorthand string
+    Type      string
+    Default   string
+    Usage     string
+    Values    []string
+    File      string
+    // Import path of the package defining the flag, one of flagPackages
+    Package   string
+    variable  types.Object
+}
+
+
+const PflagPackage = "github.com/spf13/pflag"
+
+// Packages whose flag definitions we know how to read
+var flagPackages = []string{
+    "flag",
+    PflagPackage,
+}
+
+
+/**
+ * Guesses whether a flag takes a file or directory from its name and usage.
+ * Flags with a fixed set of values are never paths.
+ */
+func (f FlagDefinition) PathKind() PathKind {
+    if f.Type != "string" || len(f.Values) > 0 {
+        return NotAPath
+    }
+
+    text := strings.ToLower(f.Name + " " + f.Usage)
+
+    for _, word := range []string{ "directory", "folder", "dir" } {
+        if strings.Contains(text, word) {
+            return DirPath
+        }
+    }
+
+    for _, word := range []string{ "file", "path", "config" } {
+        if strings.Contains(text, word) {
+            return FilePath
+        }
+    }
+
+    return NotAPath
+}
+
+
+// Pflag is whether the flag is defined with pflag, which takes --name and -x
+// for the shorthand rather than the standard library's -name
+func (f FlagDefinition) Pflag() bool {
+    return f.Package == PflagPackage
+}
+
+
+func (f FlagDefinition) TakesValue() bool {
+    return f.Type != "bool" && f.Type != "boolfunc"
+}
+
+
+/**
+ * ExtractFlags loads every package under folder and returns the command line
+ * flags defined through the standard flag package (or pflag). Flags whose
+ * values are checked against a fixed list, either by a validator such as
+ * IsSupportedFormat, a switch, or a slices.Contains over a literal, have that
+ * list recorded in Values.
+ */
+func ExtractFlags(folder string) ([]FlagDefinition, error) {
+    pkgs, err := LoadPackages(folder)
+    if err != nil {
+        return nil, err
+    }
+
+    return FlagsInPackages(pkgs), nil
+}
+
+
+func FlagsInPackages(pkgs []*packages.Package) []FlagDefinition {
+    flags := []FlagDefinition{}
+    seen := map[string]bool{}
+
+    for _, pkg := range pkgs {
+        for i, file := range pkg.Syntax {
+            for _, def := range flagsInFile(pkg, file) {
+                if seen[def.Name] {
+                    continue
+                }
+                seen[def.Name] = true
+                def.File = pkg.CompiledGoFiles[i]
+                flags = append(flags, def)
+            }
+        }
+    }
+
+    decls := funcDeclsByObject(pkgs)
+    vars := packageVarValues(pkgs)
+
+    for i := range flags {
+        if flags[i].variable == nil {
+            continue
+        }
+        for _, pkg := range pkgs {
+            for _, file := range pkg.Syntax {
+                flags[i].Values = appendUnique(flags[i].Values, enumValuesFor(flags[i].variable, pkg, file, decls, vars)...)
+            }
+        }
+    }
+
+    log.Debugf("Found %v flag definition(s)", len(flags))
+
+    return flags
+}
+
+
+/**
+ * LoadPackages loads all packages below folder with syntax and type info.
+ * Packages with errors are still returned so a half broken project can be
+ * inspected.
+ */
+func LoadPackages(folder string) ([]*packages.Package, error) {
+    cfg := &packages.Config{
+        Dir:  folder,
+        Mode: packages.NeedName            |
+              packages.NeedFiles           |
+              packages.NeedSyntax          |
+              packages.NeedCompiledGoFiles |
+              packages.NeedTypes           |
+              packages.NeedTypesInfo       |
+              packages.NeedImports         |
+              packages.NeedDeps            ,
+    }
+
+    pkgs, err := packages.Load(cfg, "./...")
+    if err != nil {
+        return nil, fmt.Errorf("failed to load packages in %v: %v", folder, err)
+    }
+
+    for _, pkg := range pkgs {
+        for _, err := range pkg.Errors {
+            log.Debugf("Error in %v: %v", pkg.ID, err)
+        }
+    }
+
+    return pkgs, nil
+}
+
+
+func flagsInFile(pkg *packages.Package, file *ast.File) []FlagDefinition {
+    defs := []FlagDefinition{}
+
+    // Flags created with flag.String & co. are bound by an assignment
+    bound := map[*ast.CallExpr]types.Object{}
+
+    ast.Inspect(file, func(n ast.Node) bool {
+        switch s := n.(type) {
+        case *ast.AssignStmt:
+            for i, rhs := range s.Rhs {
+                if call, ok := rhs.(*ast.CallExpr); ok && i < len(s.Lhs) {
+                    if id, ok := s.Lhs[i].(*ast.Ident); ok {
+                        bound[call] = pkg.TypesInfo.ObjectOf(id)
+                    }
+                }
+            }
+        case *ast.ValueSpec:
+            for i, rhs := range s.Values {
+                if call, ok := rhs.(*ast.CallExpr); ok && i < len(s.Names) {
+                    bound[call] = pkg.TypesInfo.ObjectOf(s.Names[i])
+                }
+            }
+        }
+        return true
+    })
+
+    ast.Inspect(file, func(n ast.Node) bool {
+        call, ok := n.(*ast.CallExpr)
+        if !ok {
+            return true
+        }
+
+        def, ok := flagFromCall(pkg.TypesInfo, call)
+        if !ok {
+            return true
+        }
+
+        if def.variable == nil {
+            def.variable = bound[call]
+        }
+
+        defs = append(defs, def)
+        return true
+    })
+
+    return defs
+}
+
+
+// flagFromCall recognises flag.XxxVar(&v, name, value, usage),
+// flag.Xxx(name, value, usage), the FlagSet methods and pflag's P variants.
+func flagFromCall(info *types.Info, call *ast.CallExpr) (FlagDefinition, bool) {
+    sel, ok := call.Fun.(*ast.SelectorExpr)
+    if !ok {
+        return FlagDefinition{}, false
+    }
+
+    fn, ok := info.Uses[sel.Sel].(*types.Func)
+    if !ok || fn.Pkg() == nil || !slices.Contains(flagPackages, fn.Pkg().Path()) {
+        return FlagDefinition{}, false
+    }
+
+    method := fn.Name()
+    args := call.Args
+    def := FlagDefinition{ Package: fn.Pkg().Path() }
+
+    switch method {
+    case "Var", "VarP":
+        // Var(value Value, name, usage): the type is whatever implements Value
+        if len(args) < 3 {
+            return FlagDefinition{}, false
+        }
+        def.Type = "string"
+        def.variable = referencedObject(info, args[0])
+        args = args[1:]
+        if method == "VarP" {
+            def.Shorthand = stringValue(info, args[1])
+            args = append([]ast.Expr{ args[0] }, args[2:]...)
+        }
+        def.Name = stringValue(info, args[0])
+        def.Usage = stringValue(info, args[len(args) - 1])
+        return def, def.Name != ""
+
+    case "Func", "BoolFunc":
+        if len(args) < 2 {
+            return FlagDefinition{}, false
+        }
+        def.Type = strings.ToLower(method)
+        def.Name = stringValue(info, args[0])
+        def.Usage = stringValue(info, args[1])
+        return def, def.Name != ""
+    }
+
+    base := method
+    isVar := false
+    isP := false
+
+    if strings.HasSuffix(base, "P") && base != "P" {
+        base, isP = strings.TrimSuffix(base, "P"), true
+    }
+    if strings.HasSuffix(base, "Var") {
+        base, isVar = strings.TrimSuffix(base, "Var"), true
+    }
+
+    if base == "" || !isFlagType(base) {
+        return FlagDefinition{}, false
+    }
+
+    if isVar {
+        if len(args) == 0 {
+            return FlagDefinition{}, false
+        }
+        def.variable = referencedObject(info, args[0])
+        args = args[1:]
+    }
+
+    if isP {
+        if len(args) < 2 {
+            return FlagDefinition{}, false
+        }
+        def.Shorthand = stringValue(info, args[1])
+        args = append([]ast.Expr{ args[0] }, args[2:]...)
+    }
+
+    if len(args) < 3 {
+        return FlagDefinition{}, false
+    }
+
+    def.Type = strings.ToLower(base)
+    def.Name = stringValue(info, args[0])
+    def.Default = defaultValue(info, args[1])
+    def.Usage = stringValue(info, args[2])
+
+    return def, def.Name != ""
+}
+
+
+func isFlagType(name string) bool {
+    if name == "Text" {
+        return true
+    }
+
+    for _, prefix := range []string{ "Bool", "Int", "Uint", "String", "Float", "Duration", "IP", "Count", "Bytes" } {
+        if strings.HasPrefix(name, prefix) {
+            return true
+        }
+    }
+
+    return false
+}
+
+
+func stringValue(info *types.Info, e ast.Expr) string {
+    if tv, ok := info.Types[e]; ok && tv.Value != nil && tv.Value.Kind() == constant.String {
+        return constant.StringVal(tv.Value)
+    }
+
+    return ""
+}
+
+
+func defaultValue(info *types.Info, e ast.Expr) string {
+    if tv, ok := info.Types[e]; ok && tv.Value != nil {
+        if tv.Value.Kind() == constant.String {
+            return constant.StringVal(tv.Value)
+        }
+        return tv.Value.ExactString()
+    }
+
+    return types.ExprString(e)
+}
+
+
+// referencedObject returns the object behind &x, *x, x or T(x)
+func referencedObject(info *types.Info, e ast.Expr) types.Object {
+    for {
+        switch x := e.(type) {
+        case *ast.UnaryExpr:
+            e = x.X
+        case *ast.StarExpr:
+            e = x.X
+        case *ast.ParenExpr:
+            e = x.X
+        case *ast.CallExpr:
+            // Only follow conversions, T(x)
+            if tv, ok := info.Types[x.Fun]; !ok || !tv.IsType() || len(x.Args) != 1 {
+                return nil
+            }
+            e = x.Args[0]
+        case *ast.Ident:
+            return info.ObjectOf(x)
+        case *ast.SelectorExpr:
+            return info.ObjectOf(x.Sel)
+        default:
+            return nil
+        }
+    }
+}
+
+
+func funcDeclsByObject(pkgs []*packages.Package) map[types.Object]*declInPackage {
+    decls := map[types.Object]*declInPackage{}
+
+    for _, pkg := range pkgs {
+        for _, file := range pkg.Syntax {
+            for _, d := range file.Decls {
+                if fd, ok := d.(*ast.FuncDecl); ok && fd.Body != nil {
+                    if obj := pkg.TypesInfo.Defs[fd.Name]; obj != nil {
+                        decls[obj] = &declInPackage{ Decl: fd, Package: pkg }
+                    }
+                }
+            }
+        }
+    }
+
+    return decls
+}
+
+
+type declInPackage struct {
+    Decl    *ast.FuncDecl
+    Package *packages.Package
+}
+
+
+// packageVarValues records the constant string elements of package level
+// variables initialised with a composite literal, e.g. SupportedFormats
+func packageVarValues(pkgs []*packages.Package) map[types.Object][]string {
+    values := map[types.Object][]string{}
+
+    for _, pkg := range pkgs {
+        for _, file := range pkg.Syntax {
+            for _, d := range file.Decls {
+                gd, ok := d.(*ast.GenDecl)
+                if !ok {
+                    continue
+                }
+                for _, spec := range gd.Specs {
+                    vs, ok := spec.(*ast.ValueSpec)
+                    if !ok {
+                        continue
+                    }
+                    for i, v := range vs.Values {
+                        if lit, ok := v.(*ast.CompositeLit); ok && i < len(vs.Names) {
+                            if strs := literalStrings(pkg.TypesInfo, lit); len(strs) > 0 {
+                                values[pkg.TypesInfo.Defs[vs.Names[i]]] = strs
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    }
+
+    return values
+}
+
+
+func literalStrings(info *types.Info, lit *ast.CompositeLit) []string {
+    strs := []string{}
+
+    for _, elt := range lit.Elts {
+        if kv, ok := elt.(*ast.KeyValueExpr); ok {
+            elt = kv.Key
+        }
+        if s := stringValue(info, elt); s != "" {
+            strs = appendUnique(strs, s)
+        }
+    }
+
+    return strs
+}
+
+
+/**
+ * Finds the fixed set of values obj is checked against in file. Looks at
+ * switch statements on obj, calls passing obj next to a literal list
+ * (slices.Contains([]string{...}, v)) and validator functions whose body
+ * compares against a package level list.
+ */
+func enumValuesFor(obj types.Object, pkg *packages.Package, file *ast.File, decls map[types.Object]*declInPackage, vars map[types.Object][]string) []string {
+    values := []string{}
+
+    ast.Inspect(file, func(n ast.Node) bool {
+        switch s := n.(type) {
+        case *ast.SwitchStmt:
+            if s.Tag == nil || referencedObject(pkg.TypesInfo, s.Tag) != obj {
+                return true
+            }
+            for _, stmt := range s.Body.List {
+                if cc, ok := stmt.(*ast.CaseClause); ok {
+                    for _, e := range cc.List {
+                        if v := stringValue(pkg.TypesInfo, e); v != "" {
+                            values = appendUnique(values, v)
+                        }
+                    }
+                }
+            }
+
+        case *ast.CallExpr:
+            passesObj := false
+            for _, arg := range s.Args {
+                if referencedObject(pkg.TypesInfo, arg) == obj {
+                    passesObj = true
+                }
+            }
+            if !passesObj {
+                return true
+            }
+
+            for _, arg := range s.Args {
+                if lit, ok := arg.(*ast.CompositeLit); ok {
+                    values = appendUnique(values, literalStrings(pkg.TypesInfo, lit)...)
+                }
+            }
+
+            if callee := referencedObject(pkg.TypesInfo, s.Fun); callee != nil && isValidatorName(callee.Name()) {
+                if decl, ok := decls[callee]; ok {
+                    values = appendUnique(values, valuesInValidator(decl, vars)...)
+                }
+            }
+        }
+
+        return true
+    })
+
+    return values
+}
+
+
+func isValidatorName(name string) bool {
+    for _, prefix := range []string{ "Is", "is", "Valid", "valid", "Check", "check" } {
+        if strings.HasPrefix(name, prefix) {
+            return true
+        }
+    }
+
+    return false
+}
+
+
+func valuesInValidator(decl *declInPackage, vars map[types.Object][]string) []string {
+    values := []string{}
+
+    ast.Inspect(decl.Decl.Body, func(n ast.Node) bool {
+        switch x := n.(type) {
+        case *ast.Ident:
+            if strs, ok := vars[decl.Package.TypesInfo.Uses[x]]; ok {
+                values = appendUnique(values, strs...)
+            }
+        case *ast.CompositeLit:
+            values = appendUnique(values, literalStrings(decl.Package.TypesInfo, x)...)
+        }
+        return true
+    })
+
+    return values
+}
+
+
+func appendUnique(list []string, values ...string) []string {
+    for _, v := range values {
+        if !slices.Contains(list, v) {
+            list = append(list, v)
+        }
+    }
+
+    return list
+}
@@ -0,0 +1,72 @@
+package facts
+
+import (
+    "os"
+    "testing"
+    "os/exec"
+    "path/filepath"
+)
+
+
+func TestExtractFlags(t *testing.T) {
+    if _, err := exec.LookPath("go"); err != nil {
+        t.Skip("needs the go command")
+    }
+
+    dir := t.TempDir()
+    files := map[string]string{
+        "go.mod":  "module example.com/tool\n\ngo 1.21\n",
+        "main.go": `package main
+
+import "flag"
+
+var format string
+
+func main() {
+    config := flag.String("config", "/etc/tool.conf", "Config file to read")
+    flag.StringVar(&format, "format", "text", "Output format")
+    verbose := flag.Bool("v", false, "Log more")
+    flag.Parse()
+    _, _ = config, verbose
+}
+`,
+    }
+    for name, content := range files {
+        if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    flags, err := ExtractFlags(dir)
+    if err != nil {
+        t.Fatalf("ExtractFlags() error = %v", err)
+    }
+
+    want := map[string]FlagDefinition{
+        "config": { Type: "string", Default: "/etc/tool.conf", Usage: "Config file to read" },
+        "format": { Type: "string", Default: "text", Usage: "Output format" },
+        "v":      { Type: "bool", Default: "false", Usage: "Log more" },
+    }
+
+    if len(flags) != len(want) {
+        t.Fatalf("ExtractFlags() found %v flags, want %v: %+v", len(flags), len(want), flags)
+    }
+
+    for _, f := range flags {
+        w, ok := want[f.Name]
+        if !ok {
+            t.Errorf("unexpected flag %v", f.Name)
+            continue
+        }
+        if f.Type != w.Type || f.Default != w.Default || f.Usage != w.Usage {
+            t.Errorf("flag %v = %+v, want %+v", f.Name, f, w)
+        }
+        if f.Package != "flag" || f.Pflag() {
+            t.Errorf("flag %v has package %q, want the standard flag package", f.Name, f.Package)
+        }
+    }
+
+    if kind := (FlagDefinition{ Name: "config", Type: "string", Usage: "Config file to read" }).PathKind(); kind != FilePath {
+        t.Errorf("PathKind() of a config flag = %q, want %q", kind, FilePath)
+    }
+}
@@ -0,0 +1,56 @@
+package facts
+
+import (
+    "os"
+    "regexp"
+    "strings"
+    "path/filepath"
+)
+
+
+var makeNames = []string{ "GNUmakefile", "makefile", "Makefile" }
+
+// A rule line: one or more targets, a single colon (:= is an assignment)
+var makeRule = regexp.MustCompile(`^([^\s:=#][^:=#]*?)\s*::?(?:[^=]|$)`)
+
+
+/**
+ * MakeTargets lists the explicit targets of the Makefile at the root of
+ * folder. Special targets (.PHONY & co.), pattern rules and variable
+ * assignments are skipped.
+ */
+func MakeTargets(folder string) ([]string, string) {
+    for _, name := range makeNames {
+        path := filepath.Join(folder, name)
+
+        data, err := os.ReadFile(path)
+        if err != nil {
+            continue
+        }
+
+        targets := []string{}
+
+        for _, line := range strings.Split(string(data), "\n") {
+            // Recipe lines start with a tab
+            if strings.HasPrefix(line, "\t") {
+                continue
+            }
+
+            m := makeRule.FindStringSubmatch(line)
+            if m == nil {
+                continue
+            }
+
+            for _, target := range strings.Fields(m[1]) {
+                if strings.HasPrefix(target, ".") || strings.ContainsAny(target, "%$(") {
+                    continue
+                }
+                targets = appendUnique(targets, target)
+            }
+        }
+
+        return targets, path
+    }
+
+    return nil, ""
+}
@@ -0,0 +1,54 @@
+package facts
+
+import (
+    "os"
+    "fmt"
+    "strings"
+    "path/filepath"
+
+    "golang.org/x/mod/modfile"
+)
+
+
+func ReadGoMod(folder string) (*modfile.File, error) {
+    goMod := filepath.Join(folder, "go.mod")
+
+    data, err := os.ReadFile(goMod)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read %v: %v", goMod, err)
+    }
+
+    file, err := modfile.Parse(goMod, data, nil)
+    if err != nil {
+        return nil, fmt.Errorf("failed to parse %v: %v", goMod, err)
+    }
+
+    return file, nil
+}
+
+
+/**
+ * Guesses the name of the binary built from folder: the last element of the
+ * module path (or the folder name without a go.mod), lower cased to match how
+ * binaries are usually named.
+ */
+func ProgramName(folder string) string {
+    mod, err := ReadGoMod(folder)
+    if err != nil || mod.Module == nil {
+        abs, err := filepath.Abs(folder)
+        if err != nil {
+            return ""
+        }
+        return strings.ToLower(filepath.Base(abs))
+    }
+
+    parts := strings.Split(mod.Module.Mod.Path, "/")
+    name := parts[len(parts) - 1]
+
+    // Skip major version suffixes, e.g. example.com/tool/v2
+    if len(parts) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+        name = parts[len(parts) - 2]
+    }
+
+    return strings.ToLower(name)
+}
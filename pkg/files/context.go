@@ -0,0 +1,289 @@
+package files
+
+import (
+    "os"
+    "fmt"
+    "sort"
+    "bytes"
+    "regexp"
+    "strings"
+    "go/ast"
+    "go/token"
+    "go/parser"
+    "go/printer"
+    "path/filepath"
+
+    log "github.com/sirupsen/logrus"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/tokens"
+)
+
+
+// Relevance tiers, most relevant first
+const (
+    TierBuild      = iota
+    TierEntryPoint
+    TierExported
+    TierOther
+    TierTest
+)
+
+var TierNames = []string{ "build", "entry point", "exported", "other", "test" }
+
+
+var generatedMarker = regexp.MustCompile(`(?m)^// Code generated .* DO NOT EDIT\.$`)
+var exportedDecl = regexp.MustCompile(`(?m)^(?:func(?: \([^)]*\))? |type |var |const )[A-Z]`)
+var mainPackage = regexp.MustCompile(`(?m)^package main\b`)
+var mainFunc = regexp.MustCompile(`(?m)^func main\(\)`)
+
+
+type ContextFile struct {
+    Path     string
+    Language types.SupportedFormat
+    Contents string
+    Tokens   int
+    Tier     int
+    Score    int
+}
+
+
+// ContextBudget is the number of tokens of project files we allow per prompt
+func ContextBudget() int {
+    if config.ContextBudget > 0 {
+        return config.ContextBudget
+    }
+
+    return tokens.DefaultBudget(config.Model)
+}
+
+
+/**
+ * RankFiles reads files and orders them by how useful they are to a model
+ * trying to understand the project: build files, then entry points (main
+ * packages, cmd/), then files with many exported declarations, then the rest,
+ * with tests and generated code last.
+ */
+func RankFiles(paths []string) ([]ContextFile, error) {
+    ranked := []ContextFile{}
+
+    for _, path := range paths {
+        content, err := os.ReadFile(path)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read file %v: %v", path, err)
+        }
+
+        f := ContextFile{
+            Path:     path,
+            Contents: string(content),
+            Tokens:   tokens.Count(string(content)),
+            Tier:     TierOther,
+        }
+
+        if lang, ok := types.DetectLanguage(path); ok {
+            f.Language = lang.Format
+        }
+
+        base := filepath.Base(path)
+        slashed := filepath.ToSlash(path)
+
+        switch {
+        case IsBuildFile(path) || base == "go.mod":
+            f.Tier = TierBuild
+        case strings.HasSuffix(base, "_test.go") || strings.Contains(slashed, "/testdata/") || generatedMarker.Match(content):
+            f.Tier = TierTest
+        case strings.Contains(slashed, "/cmd/") || strings.HasPrefix(slashed, "cmd/") || isMainFile(string(content)):
+            f.Tier = TierEntryPoint
+        default:
+            if f.Score = len(exportedDecl.FindAllIndex(content, -1)); f.Score > 0 {
+                f.Tier = TierExported
+            }
+        }
+
+        ranked = append(ranked, f)
+    }
+
+    sort.SliceStable(ranked, func(i, j int) bool {
+        if ranked[i].Tier != ranked[j].Tier {
+            return ranked[i].Tier < ranked[j].Tier
+        }
+        if ranked[i].Score != ranked[j].Score {
+            return ranked[i].Score > ranked[j].Score
+        }
+        return strings.Count(ranked[i].Path, "/") < strings.Count(ranked[j].Path, "/")
+    })
+
+    return ranked, nil
+}
+
+
+func isMainFile(content string) bool {
+    return mainPackage.MatchString(content) && mainFunc.MatchString(content)
+}
+
+
+/**
+ * BuildContext concatenates the ranked files into a single block of at most
+ * budget tokens. Files are added whole while they fit; after that Go files
+ * are reduced to an outline of their declarations and other files are cut
+ * short. Files which don't fit at all are listed by name at the end.
+ */
+func BuildContext(files []ContextFile, budget int) types.ConcatenatedFileContents {
+    var b strings.Builder
+    used := 0
+    omitted := []string{}
+
+    // Counting the pieces separately can be a little off from counting the
+    // whole, so keep a margin
+    limit := budget - budget / 100
+
+    for _, f := range files {
+        entry := formatEntry(f, f.Contents, "")
+        cost := tokens.Count(entry)
+
+        if used + cost > limit {
+            entry, cost = reduceEntry(f, limit - used)
+        }
+
+        if entry == "" {
+            omitted = append(omitted, f.Path)
+            continue
+        }
+
+        b.WriteString(entry)
+        used += cost
+    }
+
+    if len(omitted) > 0 {
+        log.Warnf("Context budget of %v tokens reached, %v file(s) left out", budget, len(omitted))
+        listing, cost := listOmitted(omitted, limit - used)
+        b.WriteString(listing)
+        used += cost
+    }
+
+    log.Debugf("Built context of ~%v tokens from %v file(s)", used, len(files) - len(omitted))
+
+    return types.ConcatenatedFileContents(b.String())
+}
+
+
+// listOmitted names as many of the left out files as the budget allows
+func listOmitted(omitted []string, remaining int) (string, int) {
+    header := "Files left out to fit the context budget:\n"
+    cost := tokens.Count(header) + 8
+    listed := 0
+
+    for listed < len(omitted) {
+        next := tokens.Count(omitted[listed] + "\n")
+        if cost + next > remaining {
+            break
+        }
+        cost += next
+        listed++
+    }
+
+    if listed == 0 {
+        return "", 0
+    }
+
+    listing := header + strings.Join(omitted[:listed], "\n") + "\n"
+    if listed < len(omitted) {
+        listing += fmt.Sprintf("... and %v more\n", len(omitted) - listed)
+    }
+
+    return listing + "\n", cost
+}
+
+
+// formatEntry labels the contents of f with its path and, when known, its
+// language
+func formatEntry(f ContextFile, contents string, note string) string {
+    header := fmt.Sprintf("File:\n%v\n", f.Path)
+    if note != "" {
+        header = fmt.Sprintf("File:\n%v (%v)\n", f.Path, note)
+    }
+
+    if f.Language != "" {
+        header += fmt.Sprintf("Language:\n%v\n", f.Language.Name())
+    }
+
+    return fmt.Sprintf("%vContents:\n%v\n\n", header, contents)
+}
+
+
+// reduceEntry shrinks a file to fit in the remaining budget, returning an
+// empty entry when nothing useful fits
+func reduceEntry(f ContextFile, remaining int) (string, int) {
+    if remaining <= 0 {
+        return "", 0
+    }
+
+    if strings.HasSuffix(f.Path, ".go") {
+        if outline, err := OutlineGoSource(f.Path, f.Contents); err == nil {
+            entry := formatEntry(f, outline, "outline, bodies omitted")
+            if cost := tokens.Count(entry); cost <= remaining {
+                return entry, cost
+            }
+        }
+    }
+
+    // Keep as many leading lines as fit, but don't bother with a stub
+    lines := strings.SplitAfter(f.Contents, "\n")
+    kept := 0
+    cost := tokens.Count(formatEntry(f, "", "truncated"))
+
+    for kept < len(lines) {
+        next := tokens.Count(lines[kept])
+        if cost + next > remaining {
+            break
+        }
+        cost += next
+        kept++
+    }
+
+    if kept < 10 {
+        return "", 0
+    }
+
+    return formatEntry(f, strings.Join(lines[:kept], ""), fmt.Sprintf("truncated to %v of %v lines", kept, len(lines))), cost
+}
+
+
+/**
+ * OutlineGoSource prints the package clause, imports and declarations of a
+ * go file with all function bodies removed.
+ */
+func OutlineGoSource(path string, src string) (string, error) {
+    fset := token.NewFileSet()
+
+    file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+    if err != nil {
+        return "", fmt.Errorf("failed to parse %v: %v", path, err)
+    }
+
+    for _, decl := range file.Decls {
+        if fd, ok := decl.(*ast.FuncDecl); ok {
+            fd.Body = nil
+        }
+    }
+
+    // Comments inside the removed bodies would otherwise be printed
+    comments := []*ast.CommentGroup{}
+    for _, decl := range file.Decls {
+        if fd, ok := decl.(*ast.FuncDecl); ok && fd.Doc != nil {
+            comments = append(comments, fd.Doc)
+        }
+        if gd, ok := decl.(*ast.GenDecl); ok && gd.Doc != nil {
+            comments = append(comments, gd.Doc)
+        }
+    }
+    file.Comments = comments
+
+    var buf bytes.Buffer
+    if err := printer.Fprint(&buf, fset, file); err != nil {
+        return "", fmt.Errorf("failed to print outline of %v: %v", path, err)
+    }
+
+    return buf.String(), nil
+}
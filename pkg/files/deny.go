@@ -0,0 +1,91 @@
+package files
+
+import (
+    "fmt"
+    "sync"
+    "slices"
+    "path/filepath"
+
+    log "github.com/sirupsen/logrus"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+)
+
+
+// DenyGlobs are files which usually hold credentials, and so are never sent
+// to the model even when they're selected. DENY in the config adds to them
+var DenyGlobs = []string{
+    ".env",
+    ".env.*",
+    "*.pem",
+    "*.key",
+    "*.p12",
+    "*.pfx",
+    "*.jks",
+    "*.keystore",
+    "id_rsa*",
+    "id_dsa*",
+    "id_ecdsa*",
+    "id_ed25519*",
+    ".netrc",
+    ".npmrc",
+    ".pypirc",
+    ".git-credentials",
+    "*.tfstate",
+    "*.tfstate.backup",
+    "credentials.json",
+    "service-account*.json",
+}
+
+
+var deniedMu sync.Mutex
+var denied = []string{}
+
+
+func denyPatterns() ([]Pattern, error) {
+    patterns, err := CompileGlobs(append(slices.Clone(DenyGlobs), config.DenyGlobs...))
+    if err != nil {
+        return nil, fmt.Errorf("bad DENY glob: %v", err)
+    }
+
+    return patterns, nil
+}
+
+
+// Denied reports whether path, relative to the project directory, is on the
+// deny list. Denied files are remembered for DeniedFiles
+func Denied(rel string) (bool, error) {
+    patterns, err := denyPatterns()
+    if err != nil {
+        return false, err
+    }
+
+    rel = filepath.ToSlash(rel)
+    if !MatchAny(patterns, rel, false) {
+        return false, nil
+    }
+
+    noteDenied(rel)
+
+    return true, nil
+}
+
+
+func noteDenied(rel string) {
+    deniedMu.Lock()
+    defer deniedMu.Unlock()
+
+    if ! slices.Contains(denied, rel) {
+        log.Warnf("Refusing to send %v to the model, it's on the deny list", rel)
+        denied = append(denied, rel)
+    }
+}
+
+
+// DeniedFiles lists the files which were kept from the model this run
+func DeniedFiles() []string {
+    deniedMu.Lock()
+    defer deniedMu.Unlock()
+
+    return slices.Clone(denied)
+}
@@ -0,0 +1,54 @@
+package files
+
+import (
+    "slices"
+    "testing"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+)
+
+
+func TestDenied(t *testing.T) {
+    defer func(globs []string) { config.DenyGlobs = globs }(config.DenyGlobs)
+    defer func(files []string) { denied = files }(denied)
+
+    config.DenyGlobs = []string{ "secrets/**", "*.sqlite" }
+    denied = []string{}
+
+    tests := []struct {
+        rel  string
+        want bool
+    }{
+        { rel: ".env", want: true },
+        { rel: "deploy/.env.production", want: true },
+        { rel: "certs/server.pem", want: true },
+        { rel: "home/.ssh/id_ed25519.pub", want: true },
+        { rel: "infra/terraform.tfstate", want: true },
+        { rel: "gcp/service-account-ci.json", want: true },
+        { rel: "secrets/db/password.txt", want: true },
+        { rel: "data/app.sqlite", want: true },
+        { rel: "environment.go", want: false },
+        { rel: "docs/keys.md", want: false },
+        { rel: "src/credentials.go", want: false },
+        { rel: "secrets.go", want: false },
+    }
+
+    for _, tt := range tests {
+        got, err := Denied(tt.rel)
+        if err != nil {
+            t.Fatalf("Denied(%v) error = %v", tt.rel, err)
+        }
+        if got != tt.want {
+            t.Errorf("Denied(%v) = %v, want %v", tt.rel, got, tt.want)
+        }
+    }
+
+    // Each denied file is listed once, in the order it was refused
+    Denied(".env")
+    want := []string{ ".env", "deploy/.env.production", "certs/server.pem", "home/.ssh/id_ed25519.pub",
+        "infra/terraform.tfstate", "gcp/service-account-ci.json", "secrets/db/password.txt", "data/app.sqlite" }
+    if got := DeniedFiles(); !slices.Equal(got, want) {
+        t.Errorf("DeniedFiles() = %v, want %v", got, want)
+    }
+}
+
@@ -3,6 +3,7 @@ package files
 import (
     "os"
     "fmt"
+    "slices"
 
     "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
     "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
@@ -23,44 +24,26 @@ func CombineFilesForContext(files []string) (types.ConcatenatedFileContents, err
     return types.ConcatenatedFileContents(data), nil
 }
 
-func FormatCodeFilesForContext() (types.ConcatenatedFileContents, error) {
-    files, err := FilterForCodeFiles(config.ProjectDirectory)
+// FilterForProjectFiles returns the code files followed by any build files
+// which aren't code files too
+func FilterForProjectFiles() ([]string, error) {
+    codeFiles, err := FilterForCodeFiles(config.ProjectDirectory)
     if err != nil {
-        return types.ConcatenatedFileContents(""), fmt.Errorf("Failed to filter for code files: %v", config.ProjectDirectory, err)
+        return nil, fmt.Errorf("Failed to filter for code files in %v: %v", config.ProjectDirectory, err)
     }
 
-    data := ""
-
-    for _, file := range files {
-        content, err := os.ReadFile(file)
-        if err != nil {
-            return types.ConcatenatedFileContents(""), fmt.Errorf("failed to read file %v: %v", file, err)
-        }
-
-        data += fmt.Sprintf("File:\n%v\nContents:\n%v\n\n", file, string(content))
-    }
-
-    return types.ConcatenatedFileContents(data), nil
-}
-
-
-func FormatBuildFilesForContext() (types.ConcatenatedFileContents, error) {
-    files, err := FilterForBuildFiles(config.ProjectDirectory)
+    buildFiles, err := FilterForBuildFiles(config.ProjectDirectory)
     if err != nil {
-        return types.ConcatenatedFileContents(""), fmt.Errorf("Failed to filter for code files: %v", config.ProjectDirectory, err)
+        return nil, fmt.Errorf("Failed to filter for build files in %v: %v", config.ProjectDirectory, err)
     }
 
-    data := ""
-
-    for _, file := range files {
-        content, err := os.ReadFile(file)
-        if err != nil {
-            return types.ConcatenatedFileContents(""), fmt.Errorf("failed to read file %v: %v", file, err)
+    files := codeFiles
+    for _, f := range buildFiles {
+        if !slices.Contains(files, f) {
+            files = append(files, f)
         }
-
-        data += fmt.Sprintf("File:\n%v\nContents:\n%v\n\n", file, string(content))
     }
 
-    return types.ConcatenatedFileContents(data), nil
+    return files, nil
 }
 
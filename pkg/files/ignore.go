@@ -0,0 +1,308 @@
+package files
+
+import (
+    "os"
+    "fmt"
+    "bufio"
+    "regexp"
+    "strings"
+    "io/fs"
+    "path/filepath"
+
+    log "github.com/sirupsen/logrus"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+)
+
+
+// Files with .gitignore syntax listing what shouldn't be sent to the model,
+// read in every directory of the project. Later ones take precedence
+var IgnoreFileNames = []string{ ".gitignore", ".autoscribeignore" }
+
+
+/**
+ * Pattern is one line of a .gitignore style file. Patterns without a slash
+ * (other than a trailing one) match a name at any depth below Base, the rest
+ * are anchored to Base. A trailing slash only matches directories and a
+ * leading ! re-includes what an earlier pattern ignored.
+ */
+type Pattern struct {
+    Source   string
+    Base     string
+    Negate   bool
+    DirOnly  bool
+    re       *regexp.Regexp
+}
+
+
+type IgnoreRules struct {
+    Patterns []Pattern
+}
+
+
+// CompilePattern compiles a .gitignore style pattern relative to the
+// directory base. ok is false for blank lines and comments
+func CompilePattern(line string, base string) (Pattern, bool, error) {
+    p := Pattern{ Source: line, Base: filepath.ToSlash(filepath.Clean(base)) }
+
+    // Trailing spaces are ignored unless escaped
+    for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+        line = line[:len(line) - 1]
+    }
+
+    if line == "" || strings.HasPrefix(line, "#") {
+        return p, false, nil
+    }
+
+    if strings.HasPrefix(line, "!") {
+        p.Negate = true
+        line = line[1:]
+    } else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+        line = line[1:]
+    }
+
+    if strings.HasSuffix(line, "/") {
+        p.DirOnly = true
+        line = strings.TrimRight(line, "/")
+    }
+
+    if line == "" {
+        return p, false, nil
+    }
+
+    anchored := strings.Contains(line, "/")
+    line = strings.TrimPrefix(line, "/")
+
+    expr := globToRegexp(line)
+    if !anchored {
+        expr = "(?:.*/)?" + expr
+    }
+
+    re, err := regexp.Compile("^" + expr + "$")
+    if err != nil {
+        return p, false, fmt.Errorf("failed to compile pattern %v: %v", p.Source, err)
+    }
+    p.re = re
+
+    return p, true, nil
+}
+
+
+func globToRegexp(glob string) string {
+    var b strings.Builder
+
+    for i := 0; i < len(glob); i++ {
+        c := glob[i]
+
+        switch {
+        case strings.HasPrefix(glob[i:], "**/"):
+            // Zero or more directories
+            b.WriteString("(?:.*/)?")
+            i += 2
+        case strings.HasPrefix(glob[i:], "**") && i + 2 == len(glob):
+            b.WriteString(".*")
+            i++
+        case c == '*':
+            b.WriteString("[^/]*")
+        case c == '?':
+            b.WriteString("[^/]")
+        case c == '\\' && i + 1 < len(glob):
+            i++
+            b.WriteString(regexp.QuoteMeta(string(glob[i])))
+        case c == '[':
+            end := strings.IndexByte(glob[i + 1:], ']')
+            if end < 0 {
+                b.WriteString("\\[")
+                continue
+            }
+            class := glob[i + 1:i + 1 + end]
+            if strings.HasPrefix(class, "!") {
+                class = "^" + class[1:]
+            }
+            b.WriteString("[" + strings.ReplaceAll(class, "\\", "\\\\") + "]")
+            i += end + 1
+        default:
+            b.WriteString(regexp.QuoteMeta(string(c)))
+        }
+    }
+
+    return b.String()
+}
+
+
+// Match reports whether the pattern matches path, a slash separated path
+// relative to the project
+func (p Pattern) Match(path string, isDir bool) bool {
+    if p.DirOnly && !isDir {
+        return false
+    }
+
+    rel := path
+    if p.Base != "." && p.Base != "" {
+        if !strings.HasPrefix(path, p.Base + "/") {
+            return false
+        }
+        rel = strings.TrimPrefix(path, p.Base + "/")
+    }
+
+    return p.re.MatchString(rel)
+}
+
+
+// MatchAny reports whether any of the patterns match path
+func MatchAny(patterns []Pattern, path string, isDir bool) bool {
+    for _, p := range patterns {
+        if p.Match(path, isDir) {
+            return true
+        }
+    }
+
+    return false
+}
+
+
+// CompileGlobs compiles the -include / -exclude globs, which use the same
+// syntax as .gitignore relative to the project directory
+func CompileGlobs(globs []string) ([]Pattern, error) {
+    patterns := []Pattern{}
+
+    for _, glob := range globs {
+        p, ok, err := CompilePattern(glob, ".")
+        if err != nil {
+            return nil, err
+        }
+        if ok {
+            patterns = append(patterns, p)
+        }
+    }
+
+    return patterns, nil
+}
+
+
+// Load adds the patterns in the ignore file at path, relative to base. A
+// missing file is not an error
+func (r *IgnoreRules) Load(path string, base string) error {
+    fd, err := os.Open(path)
+    if os.IsNotExist(err) {
+        return nil
+    } else if err != nil {
+        return fmt.Errorf("failed to open %v: %v", path, err)
+    }
+    defer fd.Close()
+
+    scanner := bufio.NewScanner(fd)
+    for scanner.Scan() {
+        p, ok, err := CompilePattern(strings.TrimSuffix(scanner.Text(), "\r"), base)
+        if err != nil {
+            log.Warnf("%v: %v", path, err)
+            continue
+        }
+        if ok {
+            r.Patterns = append(r.Patterns, p)
+        }
+    }
+
+    if err := scanner.Err(); err != nil {
+        return fmt.Errorf("failed to read %v: %v", path, err)
+    }
+
+    log.Debugf("Loaded ignore rules from %v", path)
+
+    return nil
+}
+
+
+// Ignored reports whether path is ignored. The last matching pattern wins,
+// so a negated pattern can re-include a file
+func (r *IgnoreRules) Ignored(path string, isDir bool) bool {
+    ignored := false
+
+    for _, p := range r.Patterns {
+        if p.Match(path, isDir) {
+            ignored = !p.Negate
+        }
+    }
+
+    return ignored
+}
+
+
+/**
+ * WalkProject calls fn with every file in config.ProjectDirectory which isn't
+ * ignored by .git/info/exclude, a .gitignore or .autoscribeignore, or the
+ * -exclude globs, and which matches the -include globs if there are any.
+ * Files on the deny list are always left out. Ignored directories aren't
+ * descended into.
+ */
+func WalkProject(fn func(path string) error) error {
+    root := config.ProjectDirectory
+
+    rules := &IgnoreRules{}
+    if err := rules.Load(filepath.Join(root, ".git", "info", "exclude"), "."); err != nil {
+        return err
+    }
+
+    includes, err := CompileGlobs(config.IncludeGlobs)
+    if err != nil {
+        return fmt.Errorf("bad -include glob: %v", err)
+    }
+
+    excludes, err := CompileGlobs(config.ExcludeGlobs)
+    if err != nil {
+        return fmt.Errorf("bad -exclude glob: %v", err)
+    }
+
+    deny, err := denyPatterns()
+    if err != nil {
+        return err
+    }
+
+    return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+
+        rel, err := filepath.Rel(root, path)
+        if err != nil {
+            return fmt.Errorf("failed to make %v relative to %v: %v", path, root, err)
+        }
+        rel = filepath.ToSlash(rel)
+
+        if d.IsDir() {
+            if d.Name() == ".git" {
+                return filepath.SkipDir
+            }
+
+            if rel != "." && (rules.Ignored(rel, true) || MatchAny(excludes, rel, true)) {
+                log.Debugf("Skipping ignored directory %v", path)
+                return filepath.SkipDir
+            }
+
+            for _, name := range IgnoreFileNames {
+                if err := rules.Load(filepath.Join(path, name), rel); err != nil {
+                    return err
+                }
+            }
+
+            return nil
+        }
+
+        if rules.Ignored(rel, false) || MatchAny(excludes, rel, false) {
+            log.Debugf("Skipping ignored file %v", path)
+            return nil
+        }
+
+        if len(includes) > 0 && !MatchAny(includes, rel, false) {
+            log.Debugf("Skipping %v, not matched by -include", path)
+            return nil
+        }
+
+        if MatchAny(deny, rel, false) {
+            noteDenied(rel)
+            return nil
+        }
+
+        return fn(path)
+    })
+}
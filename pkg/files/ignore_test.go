@@ -0,0 +1,182 @@
+package files
+
+import (
+    "os"
+    "slices"
+    "testing"
+    "path/filepath"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+)
+
+
+func TestCompilePattern(t *testing.T) {
+    tests := []struct {
+        line  string
+        base  string
+        path  string
+        isDir bool
+        want  bool
+    }{
+        // Without a slash a pattern matches at any depth
+        { line: "*.log", base: ".", path: "debug.log", want: true },
+        { line: "*.log", base: ".", path: "a/b/debug.log", want: true },
+        { line: "*.log", base: ".", path: "debug.log.txt", want: false },
+        // A leading or inner slash anchors it to the base
+        { line: "/build", base: ".", path: "build", isDir: true, want: true },
+        { line: "/build", base: ".", path: "src/build", isDir: true, want: false },
+        { line: "doc/*.md", base: ".", path: "doc/a.md", want: true },
+        { line: "doc/*.md", base: ".", path: "x/doc/a.md", want: false },
+        { line: "doc/*.md", base: ".", path: "doc/x/a.md", want: false },
+        // Patterns from a nested ignore file are relative to its directory
+        { line: "/out", base: "sub", path: "sub/out", want: true },
+        { line: "/out", base: "sub", path: "out", want: false },
+        { line: "*.tmp", base: "sub", path: "other/a.tmp", want: false },
+        // Trailing slashes only match directories
+        { line: "cache/", base: ".", path: "cache", isDir: true, want: true },
+        { line: "cache/", base: ".", path: "cache", want: false },
+        { line: "cache/", base: ".", path: "a/cache", isDir: true, want: true },
+        // Double stars, classes and escapes
+        { line: "a/**/z", base: ".", path: "a/z", want: true },
+        { line: "a/**/z", base: ".", path: "a/b/c/z", want: true },
+        { line: "logs/**", base: ".", path: "logs/x/y", want: true },
+        { line: "file[0-9].txt", base: ".", path: "file7.txt", want: true },
+        { line: "file[!0-9].txt", base: ".", path: "file7.txt", want: false },
+        { line: "\\#notes", base: ".", path: "#notes", want: true },
+        { line: "trailing  ", base: ".", path: "trailing", want: true },
+    }
+
+    for _, tt := range tests {
+        p, ok, err := CompilePattern(tt.line, tt.base)
+        if err != nil || !ok {
+            t.Fatalf("CompilePattern(%q) = %v, %v", tt.line, ok, err)
+        }
+        if got := p.Match(tt.path, tt.isDir); got != tt.want {
+            t.Errorf("%q in %v matching %v (dir %v) = %v, want %v", tt.line, tt.base, tt.path, tt.isDir, got, tt.want)
+        }
+    }
+}
+
+
+func TestCompilePatternSkipsBlankLines(t *testing.T) {
+    for _, line := range []string{ "", "   ", "# a comment", "!", "/" } {
+        if _, ok, err := CompilePattern(line, "."); ok || err != nil {
+            t.Errorf("CompilePattern(%q) = %v, %v, want it skipped", line, ok, err)
+        }
+    }
+
+    p, ok, _ := CompilePattern("!keep.log", ".")
+    if !ok || !p.Negate {
+        t.Errorf("CompilePattern(!keep.log) = %+v, want a negated pattern", p)
+    }
+
+    p, ok, _ = CompilePattern("\\!bang", ".")
+    if !ok || p.Negate || !p.Match("!bang", false) {
+        t.Errorf("CompilePattern(\\!bang) = %+v, want a literal !", p)
+    }
+}
+
+
+func TestIgnoredLastMatchWins(t *testing.T) {
+    rules := &IgnoreRules{}
+    for _, line := range []string{ "*.log", "!keep.log", "tmp/" } {
+        p, _, _ := CompilePattern(line, ".")
+        rules.Patterns = append(rules.Patterns, p)
+    }
+
+    tests := []struct {
+        path  string
+        isDir bool
+        want  bool
+    }{
+        { path: "debug.log", want: true },
+        { path: "keep.log", want: false },
+        { path: "sub/keep.log", want: false },
+        { path: "main.go", want: false },
+        { path: "tmp", isDir: true, want: true },
+        { path: "tmp", want: false },
+    }
+
+    for _, tt := range tests {
+        if got := rules.Ignored(tt.path, tt.isDir); got != tt.want {
+            t.Errorf("Ignored(%v, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+        }
+    }
+
+    // Once negated, a later pattern can ignore it again
+    p, _, _ := CompilePattern("keep.log", ".")
+    rules.Patterns = append(rules.Patterns, p)
+    if !rules.Ignored("keep.log", false) {
+        t.Error("Ignored(keep.log) = false after it was ignored again")
+    }
+}
+
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+    t.Helper()
+
+    for name, content := range files {
+        path := filepath.Join(root, filepath.FromSlash(name))
+        if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+}
+
+
+func walk(t *testing.T, root string) []string {
+    t.Helper()
+
+    found := []string{}
+    err := WalkProject(func(path string) error {
+        rel, _ := filepath.Rel(root, path)
+        found = append(found, filepath.ToSlash(rel))
+        return nil
+    })
+    if err != nil {
+        t.Fatalf("WalkProject() error = %v", err)
+    }
+
+    slices.Sort(found)
+    return found
+}
+
+
+func TestWalkProject(t *testing.T) {
+    root := t.TempDir()
+    writeFiles(t, root, map[string]string{
+        // .git/info/exclude is read first, so the .gitignore files win
+        ".git/info/exclude":       "*.out\nlocal/\n",
+        ".gitignore":              "!keep.out\n*.log\n",
+        "sub/.autoscribeignore":   "/generated.go\n",
+        "main.go":                 "",
+        "a.out":                   "",
+        "keep.out":                "",
+        "debug.log":               "",
+        "local/notes.go":          "",
+        "sub/lib.go":              "",
+        "sub/generated.go":        "",
+        "sub/deeper/generated.go": "",
+    })
+
+    defer func(dir string, inc []string, exc []string) {
+        config.ProjectDirectory, config.IncludeGlobs, config.ExcludeGlobs = dir, inc, exc
+    }(config.ProjectDirectory, config.IncludeGlobs, config.ExcludeGlobs)
+    config.ProjectDirectory, config.IncludeGlobs, config.ExcludeGlobs = root, nil, nil
+
+    want := []string{ ".gitignore", "keep.out", "main.go", "sub/.autoscribeignore", "sub/deeper/generated.go", "sub/lib.go" }
+    if got := walk(t, root); !slices.Equal(got, want) {
+        t.Errorf("WalkProject() = %v, want %v", got, want)
+    }
+
+    config.IncludeGlobs = []string{ "*.go" }
+    config.ExcludeGlobs = []string{ "deeper/" }
+
+    want = []string{ "main.go", "sub/lib.go" }
+    if got := walk(t, root); !slices.Equal(got, want) {
+        t.Errorf("WalkProject() with -include and -exclude = %v, want %v", got, want)
+    }
+}
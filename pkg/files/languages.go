@@ -0,0 +1,74 @@
+package files
+
+import (
+    "slices"
+
+    log "github.com/sirupsen/logrus"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+)
+
+
+// LanguageOf is the first of the candidate languages path is written in
+func LanguageOf(path string, candidates []types.SupportedFormat) (types.SupportedFormat, bool) {
+    lang, ok := types.DetectLanguage(path)
+    if !ok {
+        return "", false
+    }
+
+    for _, candidate := range candidates {
+        if lang.Is(candidate) {
+            return candidate, true
+        }
+    }
+
+    return "", false
+}
+
+
+// DetectLanguages finds every supported language with at least one file in
+// the project, in the order of types.SupportedFormats
+func DetectLanguages() ([]types.SupportedFormat, error) {
+    found := []types.SupportedFormat{}
+
+    err := WalkProject(func(path string) error {
+        if lang, ok := types.DetectLanguage(path); ok && !slices.Contains(found, lang.Format) {
+            found = append(found, lang.Format)
+        }
+
+        return nil
+    })
+
+    if err != nil {
+        return nil, err
+    }
+
+    sorted := []types.SupportedFormat{}
+    for _, lang := range types.SupportedFormats {
+        if slices.Contains(found, lang) {
+            sorted = append(sorted, lang)
+        }
+    }
+
+    return sorted, nil
+}
+
+
+// SelectedLanguages is config.Languages, detecting them first when -l auto
+// was given
+func SelectedLanguages() ([]types.SupportedFormat, error) {
+    if len(config.Languages) > 0 {
+        return config.Languages, nil
+    }
+
+    languages, err := DetectLanguages()
+    if err != nil {
+        return nil, err
+    }
+
+    log.Infof("Detected languages: %v", languages)
+    config.Languages = languages
+
+    return languages, nil
+}
@@ -0,0 +1,66 @@
+package files
+
+import (
+    "slices"
+    "testing"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+)
+
+
+func TestLanguageOf(t *testing.T) {
+    root := t.TempDir()
+    writeFiles(t, root, map[string]string{
+        "main.go":   "package main\n",
+        "run.bash":  "echo hi\n",
+        "README.md": "# Title\n",
+    })
+
+    tests := []struct {
+        file       string
+        candidates []types.SupportedFormat
+        want       types.SupportedFormat
+        ok         bool
+    }{
+        { file: "main.go", candidates: []types.SupportedFormat{ types.Shell, types.Golang }, want: types.Golang, ok: true },
+        // A dialect is picked up by its parent
+        { file: "run.bash", candidates: []types.SupportedFormat{ types.Shell }, want: types.Shell, ok: true },
+        { file: "README.md", candidates: []types.SupportedFormat{ types.Golang }, ok: false },
+    }
+
+    for _, tt := range tests {
+        got, ok := LanguageOf(root + "/" + tt.file, tt.candidates)
+        if got != tt.want || ok != tt.ok {
+            t.Errorf("LanguageOf(%v, %v) = %v, %v, want %v, %v", tt.file, tt.candidates, got, ok, tt.want, tt.ok)
+        }
+    }
+}
+
+
+func TestDetectLanguages(t *testing.T) {
+    root := t.TempDir()
+    writeFiles(t, root, map[string]string{
+        "README.md":     "# Title\n",
+        "cmd/main.go":   "package main\n",
+        "scripts/build": "#!/bin/sh\necho build\n",
+        "vendor/x.py":   "import os\n",
+        ".gitignore":    "vendor/\n",
+    })
+
+    defer func(dir string, inc []string, exc []string) {
+        config.ProjectDirectory, config.IncludeGlobs, config.ExcludeGlobs = dir, inc, exc
+    }(config.ProjectDirectory, config.IncludeGlobs, config.ExcludeGlobs)
+    config.ProjectDirectory, config.IncludeGlobs, config.ExcludeGlobs = root, nil, nil
+
+    got, err := DetectLanguages()
+    if err != nil {
+        t.Fatalf("DetectLanguages() error = %v", err)
+    }
+
+    // In registry order, and ignored files don't count
+    want := []types.SupportedFormat{ types.Golang, types.Shell, types.MarkDown }
+    if !slices.Equal(got, want) {
+        t.Errorf("DetectLanguages() = %v, want %v", got, want)
+    }
+}
@@ -0,0 +1,326 @@
+package files
+
+import (
+    "os"
+    "fmt"
+    "bytes"
+    "strconv"
+    "strings"
+    "os/exec"
+    "go/format"
+    "path/filepath"
+
+    log "github.com/sirupsen/logrus"
+)
+
+
+// A Hunk is a single `@@ -a,b +c,d @@` block of a unified diff. Lines keep
+// their leading marker (' ' for context, '+' for additions, '-' for deletions)
+type Hunk struct {
+    OldStart int
+    OldCount int
+    Lines    []string
+}
+
+
+var hunkHeaderFields = func(r rune) bool { return r == ' ' || r == ',' }
+
+
+// ParseUnifiedDiff pulls the hunks out of a unified diff. File headers, code
+// fences and "\ No newline at end of file" markers are skipped.
+func ParseUnifiedDiff(diff string) ([]Hunk, error) {
+    hunks := []Hunk{}
+    var current *Hunk
+
+    for _, line := range strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n") {
+        switch {
+        case strings.HasPrefix(line, "```"):
+            current = nil
+            continue
+
+        case strings.HasPrefix(line, "@@"):
+            hunk, err := parseHunkHeader(line)
+            if err != nil {
+                return nil, err
+            }
+            hunks = append(hunks, hunk)
+            current = &hunks[len(hunks) - 1]
+            continue
+
+        case strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ "):
+            // File headers only count as headers outside of a hunk
+            if current == nil {
+                continue
+            }
+
+        case strings.HasPrefix(line, "\\"):
+            continue
+        }
+
+        if current == nil {
+            continue
+        }
+
+        // Models regularly drop the leading space on blank context lines
+        if line == "" {
+            line = " "
+        }
+
+        switch line[0] {
+        case ' ', '+', '-':
+            current.Lines = append(current.Lines, line)
+        default:
+            return nil, fmt.Errorf("malformed diff line in hunk starting at %v: %q", current.OldStart, line)
+        }
+    }
+
+    // Trailing blank "context" is an artifact of the split, not part of the hunk
+    for i := range hunks {
+        for len(hunks[i].Lines) > 0 && hunks[i].Lines[len(hunks[i].Lines) - 1] == " " {
+            hunks[i].Lines = hunks[i].Lines[:len(hunks[i].Lines) - 1]
+        }
+    }
+
+    if len(hunks) == 0 {
+        return nil, fmt.Errorf("no hunks found in diff")
+    }
+
+    return hunks, nil
+}
+
+
+func parseHunkHeader(line string) (Hunk, error) {
+    // @@ -12,3 +12,7 @@ optional section heading
+    fields := strings.FieldsFunc(strings.Trim(strings.SplitN(line[2:], "@@", 2)[0], " "), hunkHeaderFields)
+    if len(fields) < 2 || !strings.HasPrefix(fields[0], "-") {
+        return Hunk{}, fmt.Errorf("malformed hunk header: %q", line)
+    }
+
+    start, err := strconv.Atoi(fields[0][1:])
+    if err != nil {
+        return Hunk{}, fmt.Errorf("malformed hunk header %q: %v", line, err)
+    }
+
+    count := 1
+    if !strings.HasPrefix(fields[1], "+") {
+        count, err = strconv.Atoi(fields[1])
+        if err != nil {
+            return Hunk{}, fmt.Errorf("malformed hunk header %q: %v", line, err)
+        }
+    }
+
+    return Hunk{ OldStart: start, OldCount: count }, nil
+}
+
+
+// ApplyAdditiveDiff applies the hunks to original, refusing any hunk that
+// deletes or changes an existing line. Context lines are matched against the
+// file (the line numbers in the header are only a hint) and the original
+// bytes are always kept for them.
+func ApplyAdditiveDiff(original []byte, hunks []Hunk) ([]byte, error) {
+    lines := strings.SplitAfter(string(original), "\n")
+    if len(lines) > 0 && lines[len(lines) - 1] == "" {
+        lines = lines[:len(lines) - 1]
+    }
+
+    out := []string{}
+    cursor := 0
+
+    for _, hunk := range hunks {
+        context := []string{}
+        for _, l := range hunk.Lines {
+            if l[0] == '-' {
+                return nil, fmt.Errorf("patch modifies or deletes an existing line near line %v: %q", hunk.OldStart, l[1:])
+            }
+            if l[0] == ' ' {
+                context = append(context, l[1:])
+            }
+        }
+
+        at, err := locateHunk(lines, cursor, hunk, context)
+        if err != nil {
+            return nil, err
+        }
+
+        out = append(out, lines[cursor:at]...)
+        cursor = at
+
+        for _, l := range hunk.Lines {
+            if l[0] == '+' {
+                out = append(out, l[1:] + "\n")
+                continue
+            }
+
+            // Context: keep the file's own line
+            if cursor < len(lines) && !strings.HasSuffix(lines[cursor], "\n") {
+                lines[cursor] += "\n"
+            }
+            out = append(out, lines[cursor])
+            cursor++
+        }
+    }
+
+    out = append(out, lines[cursor:]...)
+
+    return []byte(strings.Join(out, "")), nil
+}
+
+
+// locateHunk finds where the context of a hunk starts in lines, searching
+// outward from the line number the diff claims.
+func locateHunk(lines []string, from int, hunk Hunk, context []string) (int, error) {
+    hint := hunk.OldStart - 1
+    // A pure insertion with -N,0 goes after line N
+    if hunk.OldCount == 0 {
+        hint = hunk.OldStart
+    }
+    if hint < from {
+        hint = from
+    }
+
+    if len(context) == 0 {
+        if hint > len(lines) {
+            return 0, fmt.Errorf("hunk inserts at line %v but the file only has %v lines", hunk.OldStart, len(lines))
+        }
+        return hint, nil
+    }
+
+    matches := func(at int, trim bool) bool {
+        if at + len(context) > len(lines) {
+            return false
+        }
+        for i, c := range context {
+            l := strings.TrimSuffix(lines[at + i], "\n")
+            if trim {
+                l, c = strings.TrimRight(l, " \t"), strings.TrimRight(c, " \t")
+            }
+            if l != c {
+                return false
+            }
+        }
+        return true
+    }
+
+    for _, trim := range []bool{ false, true } {
+        for dist := 0; hint - dist >= from || hint + dist < len(lines); dist++ {
+            if hint + dist < len(lines) && matches(hint + dist, trim) {
+                return hint + dist, nil
+            }
+            if dist > 0 && hint - dist >= from && matches(hint - dist, trim) {
+                return hint - dist, nil
+            }
+        }
+    }
+
+    return 0, fmt.Errorf("context for hunk at line %v does not match the file (changed or reordered lines?): %q", hunk.OldStart, context[0])
+}
+
+
+// VerifyOnlyAdditions checks every line of original is still present, in
+// order and unchanged, in updated.
+func VerifyOnlyAdditions(original []byte, updated []byte) error {
+    before := strings.Split(string(original), "\n")
+    after := strings.Split(string(updated), "\n")
+
+    j := 0
+    for i, line := range before {
+        for j < len(after) && after[j] != line {
+            j++
+        }
+        if j == len(after) {
+            return fmt.Errorf("line %v was changed or deleted: %q", i + 1, line)
+        }
+        j++
+    }
+
+    return nil
+}
+
+
+// FormatGoSource runs gofmt over src. gofmt's output is only used when the
+// original file was already gofmt clean; otherwise it serves as a syntax
+// check so we don't reflow a file that follows its own spacing rules.
+func FormatGoSource(original []byte, src []byte) ([]byte, error) {
+    formatted, err := format.Source(src)
+    if err != nil {
+        return nil, fmt.Errorf("patched file is not valid go: %v", err)
+    }
+
+    if origFormatted, err := format.Source(original); err == nil && bytes.Equal(origFormatted, original) {
+        return formatted, nil
+    }
+
+    return src, nil
+}
+
+
+// BuildGoPackage runs `go build` over the package containing path
+func BuildGoPackage(path string) error {
+    cmd := exec.Command("go", "build", "-o", os.DevNull, ".")
+    cmd.Dir = filepath.Dir(path)
+
+    out, err := cmd.CombinedOutput()
+    if err != nil {
+        return fmt.Errorf("go build failed in %v: %v\n%s", cmd.Dir, err, out)
+    }
+
+    return nil
+}
+
+
+// PatchFileWithAdditions applies an additions-only unified diff to path. Go
+// files are run through gofmt and `go build`; the original contents are put
+// back if any step fails.
+func PatchFileWithAdditions(path string, diff string) error {
+    original, err := os.ReadFile(path)
+    if err != nil {
+        return fmt.Errorf("failed to read %v: %v", path, err)
+    }
+
+    info, err := os.Stat(path)
+    if err != nil {
+        return fmt.Errorf("failed to stat %v: %v", path, err)
+    }
+
+    hunks, err := ParseUnifiedDiff(diff)
+    if err != nil {
+        return fmt.Errorf("failed to parse patch for %v: %v", path, err)
+    }
+
+    updated, err := ApplyAdditiveDiff(original, hunks)
+    if err != nil {
+        return fmt.Errorf("rejected patch for %v: %v", path, err)
+    }
+
+    isGo := filepath.Ext(path) == ".go"
+
+    if isGo {
+        updated, err = FormatGoSource(original, updated)
+        if err != nil {
+            return fmt.Errorf("rejected patch for %v: %v", path, err)
+        }
+    }
+
+    if err := VerifyOnlyAdditions(original, updated); err != nil {
+        return fmt.Errorf("rejected patch for %v: %v", path, err)
+    }
+
+    if err := os.WriteFile(path, updated, info.Mode().Perm()); err != nil {
+        return fmt.Errorf("failed to write %v: %v", path, err)
+    }
+
+    if !isGo {
+        return nil
+    }
+
+    log.Infof("Building %v to verify the patch...", filepath.Dir(path))
+
+    if buildErr := BuildGoPackage(path); buildErr != nil {
+        if err := os.WriteFile(path, original, info.Mode().Perm()); err != nil {
+            return fmt.Errorf("failed to restore %v after a failed build: %v (build error: %v)", path, err, buildErr)
+        }
+        return fmt.Errorf("rejected patch for %v, original restored: %v", path, buildErr)
+    }
+
+    return nil
+}
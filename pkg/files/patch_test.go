@@ -0,0 +1,277 @@
+package files
+
+import (
+    "os"
+    "strings"
+    "testing"
+    "os/exec"
+    "path/filepath"
+)
+
+
+func TestParseUnifiedDiff(t *testing.T) {
+    tests := []struct {
+        name    string
+        diff    string
+        want    []Hunk
+        wantErr string
+    }{
+        {
+            name: "headers and fences are skipped",
+            diff: "```diff\n--- a/main.go\n+++ b/main.go\n@@ -2,2 +2,3 @@\n a\n+b\n c\n```\n",
+            want: []Hunk{ { OldStart: 2, OldCount: 2, Lines: []string{ " a", "+b", " c" } } },
+        },
+        {
+            name: "count defaults to one",
+            diff: "@@ -5 +5,2 @@\n x\n+y\n",
+            want: []Hunk{ { OldStart: 5, OldCount: 1, Lines: []string{ " x", "+y" } } },
+        },
+        {
+            name: "blank context lines keep their place",
+            diff: "@@ -1,3 +1,4 @@\n a\n\n+b\n c\n\n",
+            want: []Hunk{ { OldStart: 1, OldCount: 3, Lines: []string{ " a", " ", "+b", " c" } } },
+        },
+        {
+            name: "no newline marker is skipped",
+            diff: "@@ -1,1 +1,2 @@\n a\n\\ No newline at end of file\n+b\n",
+            want: []Hunk{ { OldStart: 1, OldCount: 1, Lines: []string{ " a", "+b" } } },
+        },
+        {
+            name: "two hunks",
+            diff: "@@ -1,1 +1,2 @@\n a\n+b\n@@ -9,0 +10,1 @@\n+z\n",
+            want: []Hunk{
+                { OldStart: 1, OldCount: 1, Lines: []string{ " a", "+b" } },
+                { OldStart: 9, OldCount: 0, Lines: []string{ "+z" } },
+            },
+        },
+        {
+            name:    "no hunks",
+            diff:    "just some prose\n",
+            wantErr: "no hunks",
+        },
+        {
+            name:    "bad header",
+            diff:    "@@ 3,4 @@\n a\n",
+            wantErr: "malformed hunk header",
+        },
+        {
+            name:    "line without a marker",
+            diff:    "@@ -1,2 +1,2 @@\n a\nb\n",
+            wantErr: "malformed diff line",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := ParseUnifiedDiff(tt.diff)
+            if tt.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                    t.Fatalf("ParseUnifiedDiff() error = %v, want it to contain %q", err, tt.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("ParseUnifiedDiff() error = %v", err)
+            }
+            if len(got) != len(tt.want) {
+                t.Fatalf("ParseUnifiedDiff() = %v hunks, want %v", len(got), len(tt.want))
+            }
+            for i := range got {
+                if got[i].OldStart != tt.want[i].OldStart || got[i].OldCount != tt.want[i].OldCount ||
+                    strings.Join(got[i].Lines, "|") != strings.Join(tt.want[i].Lines, "|") {
+                    t.Errorf("hunk %v = %+v, want %+v", i, got[i], tt.want[i])
+                }
+            }
+        })
+    }
+}
+
+
+func TestApplyAdditiveDiff(t *testing.T) {
+    original := "package main\n\nfunc main() {\n    run()\n}\n"
+
+    tests := []struct {
+        name    string
+        diff    string
+        want    string
+        wantErr string
+    }{
+        {
+            name: "addition in place",
+            diff: "@@ -3,3 +3,4 @@\n func main() {\n+    usage()\n     run()\n }\n",
+            want: "package main\n\nfunc main() {\n    usage()\n    run()\n}\n",
+        },
+        {
+            name: "wrong line numbers are only a hint",
+            diff: "@@ -40,2 +40,3 @@\n func main() {\n+    usage()\n     run()\n",
+            want: "package main\n\nfunc main() {\n    usage()\n    run()\n}\n",
+        },
+        {
+            name: "trailing whitespace in the context is forgiven",
+            diff: "@@ -4,1 +4,2 @@\n     run()   \n+    done()\n",
+            want: "package main\n\nfunc main() {\n    run()\n    done()\n}\n",
+        },
+        {
+            name: "pure insertion after a line",
+            diff: "@@ -5,0 +6,2 @@\n+\n+func usage() {}\n",
+            want: original + "\nfunc usage() {}\n",
+        },
+        {
+            name:    "deletions are rejected",
+            diff:    "@@ -4,1 +4,1 @@\n-    run()\n+    start()\n",
+            wantErr: "modifies or deletes",
+        },
+        {
+            name:    "context which isn't in the file",
+            diff:    "@@ -4,1 +4,2 @@\n     start()\n+    done()\n",
+            wantErr: "does not match",
+        },
+        {
+            name:    "insertion past the end",
+            diff:    "@@ -30,0 +31,1 @@\n+x\n",
+            wantErr: "only has",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            hunks, err := ParseUnifiedDiff(tt.diff)
+            if err != nil {
+                t.Fatalf("ParseUnifiedDiff() error = %v", err)
+            }
+
+            got, err := ApplyAdditiveDiff([]byte(original), hunks)
+            if tt.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                    t.Fatalf("ApplyAdditiveDiff() error = %v, want it to contain %q", err, tt.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("ApplyAdditiveDiff() error = %v", err)
+            }
+            if string(got) != tt.want {
+                t.Errorf("ApplyAdditiveDiff() =\n%v\nwant\n%v", string(got), tt.want)
+            }
+        })
+    }
+}
+
+
+func TestLocateHunk(t *testing.T) {
+    lines := []string{ "a\n", "b\n", "c\n", "b\n", "c\n" }
+
+    tests := []struct {
+        name    string
+        from    int
+        hunk    Hunk
+        context []string
+        want    int
+        wantErr bool
+    }{
+        { name: "at the hint", hunk: Hunk{ OldStart: 4, OldCount: 2 }, context: []string{ "b", "c" }, want: 3 },
+        { name: "nearest to the hint", hunk: Hunk{ OldStart: 1, OldCount: 2 }, context: []string{ "b", "c" }, want: 1 },
+        { name: "not before from", from: 2, hunk: Hunk{ OldStart: 1, OldCount: 2 }, context: []string{ "b", "c" }, want: 3 },
+        { name: "no context uses the hint", hunk: Hunk{ OldStart: 2, OldCount: 0 }, want: 2 },
+        { name: "missing context", hunk: Hunk{ OldStart: 1, OldCount: 1 }, context: []string{ "d" }, wantErr: true },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := locateHunk(lines, tt.from, tt.hunk, tt.context)
+            if tt.wantErr {
+                if err == nil {
+                    t.Fatalf("locateHunk() = %v, want an error", got)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("locateHunk() error = %v", err)
+            }
+            if got != tt.want {
+                t.Errorf("locateHunk() = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+
+func TestVerifyOnlyAdditions(t *testing.T) {
+    if err := VerifyOnlyAdditions([]byte("a\nb\n"), []byte("a\nx\nb\n")); err != nil {
+        t.Errorf("VerifyOnlyAdditions() error = %v for an addition", err)
+    }
+    if err := VerifyOnlyAdditions([]byte("a\nb\n"), []byte("b\na\n")); err == nil {
+        t.Error("VerifyOnlyAdditions() accepted reordered lines")
+    }
+    if err := VerifyOnlyAdditions([]byte("a\nb\n"), []byte("a\n")); err == nil {
+        t.Error("VerifyOnlyAdditions() accepted a deleted line")
+    }
+}
+
+
+func writeModule(t *testing.T, main string) string {
+    t.Helper()
+
+    dir := t.TempDir()
+    if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/patched\n\ngo 1.21\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    path := filepath.Join(dir, "main.go")
+    if err := os.WriteFile(path, []byte(main), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    return path
+}
+
+
+func TestPatchFileWithAdditions(t *testing.T) {
+    if _, err := exec.LookPath("go"); err != nil {
+        t.Skip("needs the go command")
+    }
+
+    main := "package main\n\nfunc main() {\n\trun()\n}\n\nfunc run() {}\n"
+
+    t.Run("builds", func(t *testing.T) {
+        path := writeModule(t, main)
+
+        err := PatchFileWithAdditions(path, "@@ -4,1 +4,2 @@\n \trun()\n+\trun()\n")
+        if err != nil {
+            t.Fatalf("PatchFileWithAdditions() error = %v", err)
+        }
+
+        got, _ := os.ReadFile(path)
+        if strings.Count(string(got), "run()\n") != 2 {
+            t.Errorf("patched file =\n%v", string(got))
+        }
+    })
+
+    t.Run("original restored when the build fails", func(t *testing.T) {
+        path := writeModule(t, main)
+
+        err := PatchFileWithAdditions(path, "@@ -4,1 +4,2 @@\n \trun()\n+\tundefined()\n")
+        if err == nil || !strings.Contains(err.Error(), "original restored") {
+            t.Fatalf("PatchFileWithAdditions() error = %v, want a failed build", err)
+        }
+
+        got, _ := os.ReadFile(path)
+        if string(got) != main {
+            t.Errorf("file after a failed build =\n%v\nwant the original", string(got))
+        }
+    })
+
+    t.Run("invalid go is never written", func(t *testing.T) {
+        path := writeModule(t, main)
+
+        err := PatchFileWithAdditions(path, "@@ -4,1 +4,2 @@\n \trun()\n+\trun(\n")
+        if err == nil || !strings.Contains(err.Error(), "not valid go") {
+            t.Fatalf("PatchFileWithAdditions() error = %v, want invalid go", err)
+        }
+
+        got, _ := os.ReadFile(path)
+        if string(got) != main {
+            t.Errorf("file after invalid go =\n%v\nwant the original", string(got))
+        }
+    })
+}
@@ -1,10 +1,8 @@
 package files;
 
 import (
-    "os"
     "fmt"
     "strings"
-    "path/filepath"
 
     log "github.com/sirupsen/logrus"
 
@@ -13,26 +11,21 @@ import (
 
 
 func FilterForCodeFiles(directory string) ([]string, error) {
-    log.Debugf("Filtering for %v code files in: %v", config.LanguageFileExtension, config.ProjectDirectory)
+    languages, err := SelectedLanguages()
+    if err != nil {
+        return nil, fmt.Errorf("Failed to detect languages in %v: %v", config.ProjectDirectory, err)
+    }
+
+    log.Debugf("Filtering for %v code files in: %v", languages, config.ProjectDirectory)
 
     // Collect all the files in question
     var files []string
 
-    err := filepath.Walk(config.ProjectDirectory, func(path string, info os.FileInfo, err error) error {
-        if err != nil {
-            return err
-        }
-
-        if ! info.IsDir() {
-            if ext, is := config.LanguageFileExtension.FileIsThisFormat(path); ! is {
-                log.Debugf("File %v with extension `%v` doens't pass language filter. Ignoring...\n", path, ext)
-            } else {
-                files = append(files, path)
-            }
+    err = WalkProject(func(path string) error {
+        if _, is := LanguageOf(path, languages); ! is {
+            log.Debugf("File %v doesn't pass language filter. Ignoring...", path)
         } else {
-            if info.Name() == ".git" {
-                return filepath.SkipDir
-            }
+            files = append(files, path)
         }
 
         return nil
@@ -43,7 +36,7 @@ func FilterForCodeFiles(directory string) ([]string, error) {
     }
 
     if len(files) == 0 {
-        return files, fmt.Errorf("Cannot AutoScribe: language set to `%v` but none found.", config.LanguageFileExtension)
+        return files, fmt.Errorf("Cannot AutoScribe: language set to `%v` but none found.", languages)
     }
 
     return files, nil
@@ -54,26 +47,8 @@ func FilterForBuildFiles(directory string) ([]string, error) {
     // Collect all the files in question
     var files []string
 
-    err := filepath.Walk(config.ProjectDirectory, func(path string, info os.FileInfo, err error) error {
-        if err != nil {
-            return err
-        }
-
-        if info.IsDir() {
-            if info.Name() == ".git" {
-                return filepath.SkipDir
-            }
-
-            return nil
-        }
-
-        if strings.Contains(path, "Makefile") {
-            files = append(files, path)
-        } else if strings.Contains(path, "build.sh") {
-            files = append(files, path)
-        } else if strings.Contains(path, "configure.sh") {
-            files = append(files, path)
-        } else if strings.Contains(path, "deps.sh") {
+    err := WalkProject(func(path string) error {
+        if IsBuildFile(path) {
             files = append(files, path)
         }
 
@@ -85,9 +60,19 @@ func FilterForBuildFiles(directory string) ([]string, error) {
     }
 
     if len(files) == 0 {
-        log.Debug("No build files found in %v", config.ProjectDirectory)
+        log.Debugf("No build files found in %v", config.ProjectDirectory)
     }
 
     return files, nil
 }
 
+
+func IsBuildFile(path string) bool {
+    for _, name := range []string{ "Makefile", "build.sh", "configure.sh", "deps.sh" } {
+        if strings.Contains(path, name) {
+            return true
+        }
+    }
+
+    return false
+}
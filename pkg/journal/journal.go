@@ -0,0 +1,229 @@
+package journal
+
+import (
+    "os"
+    "fmt"
+    "sync"
+    "bufio"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "path/filepath"
+
+    log "github.com/sirupsen/logrus"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+)
+
+
+/**
+ * Entry is one declaration documented during a docs run. It's appended to
+ * the journal as soon as the model answers, so an interrupted run keeps what
+ * it had done and -resume picks up from there.
+ */
+type Entry struct {
+    File          string `json:"file"`
+    Name          string `json:"name"`
+    // Hash of the model and the prompts sent, so an entry is only reused when
+    // the same question would be asked again
+    Prompt        string `json:"prompt"`
+    // The comment generated, empty when the model said none was needed
+    Documentation string `json:"documentation"`
+}
+
+
+var mu sync.Mutex
+// The journal of the run, nil when there isn't one
+var current *os.File
+var entries = map[string]Entry{}
+
+
+func key(file string, name string) string {
+    return filepath.Clean(file) + "\x00" + name
+}
+
+
+// hash covers the model and prompts of a declaration
+func hash(prompts ...string) string {
+    h := sha256.New()
+    fmt.Fprintf(h, "%v\n", config.Model)
+    for _, p := range prompts {
+        fmt.Fprintf(h, "%v\n", p)
+    }
+
+    return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+
+// Path is where the journal of the current project is kept
+func Path() (string, error) {
+    dir, err := config.ProjectCacheDirectory()
+    if err != nil {
+        return "", err
+    }
+
+    return filepath.Join(dir, "journal.jsonl"), nil
+}
+
+
+/**
+ * Open starts the journal of a docs run. With resume the entries of the run
+ * before are kept and Lookup returns them, otherwise any left over journal
+ * is thrown away. Without a call to Open, Lookup finds nothing and Record
+ * does nothing.
+ */
+func Open(resume bool) error {
+    mu.Lock()
+    defer mu.Unlock()
+
+    path, err := Path()
+    if err != nil {
+        return err
+    }
+
+    entries = map[string]Entry{}
+
+    if resume {
+        if err := read(path); err != nil {
+            return err
+        }
+
+        if len(entries) == 0 {
+            log.Info("There is no interrupted run to resume, starting from the beginning")
+        } else {
+            log.Infof("Resuming with %v declaration(s) documented in %v", len(entries), path)
+        }
+    } else if _, err := os.Stat(path); err == nil {
+        log.Warnf("Starting over, the last docs run was interrupted. Use -resume to pick up where it stopped instead")
+    }
+
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+        return fmt.Errorf("failed to create %v: %v", filepath.Dir(path), err)
+    }
+
+    flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+    if !resume {
+        flags |= os.O_TRUNC
+    }
+
+    current, err = os.OpenFile(path, flags, 0644)
+    if err != nil {
+        return fmt.Errorf("failed to open the journal %v: %v", path, err)
+    }
+
+    return nil
+}
+
+
+// read loads the entries in the journal at path. A line cut short when the
+// last run was killed is skipped
+func read(path string) error {
+    f, err := os.Open(path)
+    if os.IsNotExist(err) {
+        return nil
+    } else if err != nil {
+        return fmt.Errorf("failed to read the journal %v: %v", path, err)
+    }
+    defer f.Close()
+
+    scanner := bufio.NewScanner(f)
+    scanner.Buffer(make([]byte, 64 * 1024), 16 * 1024 * 1024)
+
+    for line := 1; scanner.Scan(); line++ {
+        var e Entry
+        if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+            log.Warnf("Skipping unreadable line %v of the journal %v: %v", line, path, err)
+            continue
+        }
+        entries[key(e.File, e.Name)] = e
+    }
+
+    if err := scanner.Err(); err != nil {
+        return fmt.Errorf("failed to read the journal %v: %v", path, err)
+    }
+
+    return nil
+}
+
+
+// Lookup returns the documentation recorded for name in file, when it was
+// generated from the same prompts
+func Lookup(file string, name string, prompts ...string) (string, bool) {
+    mu.Lock()
+    defer mu.Unlock()
+
+    e, ok := entries[key(file, name)]
+    if !ok || e.Prompt != hash(prompts...) {
+        return "", false
+    }
+
+    return e.Documentation, true
+}
+
+
+// Record adds the documentation generated for name in file from prompts to
+// the journal, straight away
+func Record(file string, name string, documentation string, prompts ...string) error {
+    mu.Lock()
+    defer mu.Unlock()
+
+    if current == nil {
+        return nil
+    }
+
+    e := Entry{ File: filepath.Clean(file), Name: name, Prompt: hash(prompts...), Documentation: documentation }
+
+    line, err := json.Marshal(e)
+    if err != nil {
+        return fmt.Errorf("failed to encode the journal entry of %v: %v", name, err)
+    }
+
+    if _, err := current.Write(append(line, '\n')); err != nil {
+        return fmt.Errorf("failed to write the journal %v: %v", current.Name(), err)
+    }
+
+    entries[key(file, name)] = e
+
+    return nil
+}
+
+
+// Count is how many declarations the journal holds
+func Count() int {
+    mu.Lock()
+    defer mu.Unlock()
+
+    return len(entries)
+}
+
+
+/**
+ * Close ends the journal. When the run finished, everything in it has been
+ * written into the source files, so it's removed. Otherwise it's kept for
+ * -resume.
+ */
+func Close(finished bool) error {
+    mu.Lock()
+    defer mu.Unlock()
+
+    if current == nil {
+        return nil
+    }
+
+    path := current.Name()
+    err := current.Close()
+    current = nil
+
+    if err != nil {
+        return fmt.Errorf("failed to close the journal %v: %v", path, err)
+    }
+
+    if finished {
+        entries = map[string]Entry{}
+        if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+            return fmt.Errorf("failed to remove the journal %v: %v", path, err)
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,165 @@
+package journal
+
+import (
+    "os"
+    "testing"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+)
+
+
+// fresh points the journal at a cache directory of its own and closes it
+// once the test is done
+func fresh(t *testing.T) string {
+    t.Helper()
+
+    cache, project, model := config.CacheDirectory, config.ProjectDirectory, config.Model
+    t.Cleanup(func() {
+        Close(false)
+        entries = map[string]Entry{}
+        config.CacheDirectory, config.ProjectDirectory, config.Model = cache, project, model
+    })
+
+    config.CacheDirectory, config.ProjectDirectory, config.Model = t.TempDir(), t.TempDir(), "gpt-x"
+
+    path, err := Path()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    return path
+}
+
+
+func TestResume(t *testing.T) {
+    fresh(t)
+
+    if err := Open(false); err != nil {
+        t.Fatalf("Open() error = %v", err)
+    }
+    if err := Record("a.go", "Run", "// Run runs.", "prompt"); err != nil {
+        t.Fatalf("Record() error = %v", err)
+    }
+    if err := Close(false); err != nil {
+        t.Fatalf("Close() error = %v", err)
+    }
+
+    if err := Open(true); err != nil {
+        t.Fatalf("Open(true) error = %v", err)
+    }
+
+    tests := []struct {
+        name    string
+        file    string
+        model   string
+        prompt  string
+        want    string
+        found   bool
+    }{
+        { name: "same question", file: "a.go", model: "gpt-x", prompt: "prompt", want: "// Run runs.", found: true },
+        { name: "same file", file: "./a.go", model: "gpt-x", prompt: "prompt", want: "// Run runs.", found: true },
+        { name: "other file", file: "b.go", model: "gpt-x", prompt: "prompt" },
+        { name: "changed prompt", file: "a.go", model: "gpt-x", prompt: "new prompt" },
+        { name: "changed model", file: "a.go", model: "gpt-y", prompt: "prompt" },
+    }
+
+    for _, tt := range tests {
+        config.Model = tt.model
+        got, found := Lookup(tt.file, "Run", tt.prompt)
+        if got != tt.want || found != tt.found {
+            t.Errorf("Lookup() with %v = %q, %v, want %q, %v", tt.name, got, found, tt.want, tt.found)
+        }
+    }
+}
+
+
+func TestOpenStartsOver(t *testing.T) {
+    path := fresh(t)
+
+    if err := Open(false); err != nil {
+        t.Fatalf("Open() error = %v", err)
+    }
+    Record("a.go", "Run", "// Run runs.", "prompt")
+    Close(false)
+
+    if err := Open(false); err != nil {
+        t.Fatalf("Open() error = %v", err)
+    }
+
+    if _, found := Lookup("a.go", "Run", "prompt"); found || Count() != 0 {
+        t.Errorf("Open(false) kept %v declaration(s)", Count())
+    }
+    if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+        t.Errorf("Open(false) left the journal at %v, %v", info, err)
+    }
+}
+
+
+// A half written last line is what a killed run leaves behind
+func TestResumeSkipsCutLine(t *testing.T) {
+    path := fresh(t)
+
+    if err := Open(false); err != nil {
+        t.Fatalf("Open() error = %v", err)
+    }
+    Record("a.go", "Run", "// Run runs.", "prompt")
+    Close(false)
+
+    f, err := os.OpenFile(path, os.O_APPEND | os.O_WRONLY, 0644)
+    if err != nil {
+        t.Fatal(err)
+    }
+    f.WriteString(`{"file":"a.go","name":"Stop","prom`)
+    f.Close()
+
+    if err := Open(true); err != nil {
+        t.Fatalf("Open(true) error = %v", err)
+    }
+    if Count() != 1 {
+        t.Errorf("Count() = %v, want 1", Count())
+    }
+}
+
+
+func TestClose(t *testing.T) {
+    tests := []struct {
+        finished bool
+        kept     bool
+    }{
+        { finished: true, kept: false },
+        { finished: false, kept: true },
+    }
+
+    for _, tt := range tests {
+        path := fresh(t)
+
+        if err := Open(false); err != nil {
+            t.Fatalf("Open() error = %v", err)
+        }
+        Record("a.go", "Run", "// Run runs.", "prompt")
+
+        if err := Close(tt.finished); err != nil {
+            t.Fatalf("Close(%v) error = %v", tt.finished, err)
+        }
+
+        if _, err := os.Stat(path); (err == nil) != tt.kept {
+            t.Errorf("Close(%v) kept the journal = %v, want %v", tt.finished, err == nil, tt.kept)
+        }
+    }
+}
+
+
+// Without Open there's no journal to write to
+func TestRecordWithoutOpen(t *testing.T) {
+    path := fresh(t)
+
+    if err := Record("a.go", "Run", "// Run runs.", "prompt"); err != nil {
+        t.Errorf("Record() error = %v", err)
+    }
+    if _, found := Lookup("a.go", "Run", "prompt"); found {
+        t.Error("Lookup() found a declaration never journalled")
+    }
+    if _, err := os.Stat(path); err == nil {
+        t.Error("Record() without Open wrote a journal")
+    }
+}
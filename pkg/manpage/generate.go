@@ -0,0 +1,207 @@
+package manpage
+
+import (
+    "os"
+    "fmt"
+    "time"
+    "strings"
+    "path/filepath"
+
+    log "github.com/sirupsen/logrus"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/facts"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/prompts"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/openai/calls"
+)
+
+
+// What we ask the model for. Everything which can be read from the code
+// (flags, files, env vars) is filled in by us; the model only describes it.
+type modelResponse struct {
+    Summary     string    `json:"summary"`
+    Description []string  `json:"description"`
+    Options     []Option  `json:"options"`
+    Files       []Entry   `json:"files"`
+    Environment []Entry   `json:"environment"`
+    Examples    []Example `json:"examples"`
+}
+
+
+// The schema the provider holds the model's answer to
+var responseSchema = calls.Schema{
+    Name:   "man_page",
+    Schema: calls.Object(map[string]any{
+        "summary":     calls.String("One line, lower case, no trailing period, used in the NAME section after the program name"),
+        "description": calls.Array(calls.String(""), "Paragraphs of the DESCRIPTION section"),
+        "examples":    calls.Array(calls.Object(map[string]any{
+            "command":     calls.String("Full shell command"),
+            "description": calls.String("One sentence"),
+        }), "Examples of running the program"),
+        "files":       calls.Array(entrySchema("Path exactly as listed under FILES"), "One for each path listed under FILES"),
+        "environment": calls.Array(entrySchema("Variable exactly as listed under ENVIRONMENT"), "One for each variable listed under ENVIRONMENT"),
+        "options":     calls.Array(calls.Object(map[string]any{
+            "flag":        calls.String("Name without dashes"),
+            "argument":    calls.String("Placeholder for its value, empty if it takes none"),
+            "description": calls.String("What it does"),
+        }), "Only if no OPTIONS are listed, the program's options. Empty otherwise"),
+    }),
+}
+
+
+func entrySchema(name string) map[string]any {
+    return calls.Object(map[string]any{
+        "name":        calls.String(name),
+        "description": calls.String("One sentence"),
+    })
+}
+
+
+/**
+ * CreateManPage writes a section 1 man page for the project into the output
+ * directory and returns its path. OPTIONS, FILES and ENVIRONMENT come from
+ * the flag definitions, config paths and os.LookupEnv/os.Getenv calls found
+ * in the code; the prose comes from the model. The page is checked with
+ * Validate before it is written.
+ */
+func CreateManPage(format Format) (string, error) {
+    projectFacts, err := facts.Collect(config.ProjectDirectory)
+    if err != nil {
+        log.Warnf("Not reading flags, files or environment from the code: %v", err)
+    }
+
+    name := config.ProgramName
+    if name == "" {
+        name = projectFacts.Program
+    }
+
+    flags := projectFacts.FlagDefinitions
+    envVars := projectFacts.EnvVars
+    paths := projectFacts.ConfigPaths
+
+    options := OptionsFromFlags(flags)
+
+    data, err := calls.ProjectContext()
+    if err != nil {
+        return "", fmt.Errorf("failed to format project files: %w", err)
+    }
+
+    promptData := prompts.Data{
+        Program:     name,
+        Options:     listOptions(options),
+        Paths:       paths,
+        Environment: envVars,
+        Files:       string(data),
+    }
+
+    system, err := prompts.Render("man_system", promptData)
+    if err != nil {
+        return "", err
+    }
+
+    prompt, err := prompts.Render("man", promptData)
+    if err != nil {
+        return "", err
+    }
+
+    log.Info("Querying ai for output...")
+    var resp modelResponse
+    if err := calls.QueryJSON("", system, prompt, responseSchema, &resp); err != nil {
+        return "", fmt.Errorf("failed to query 4.1 Nano: %w", err)
+    }
+
+    if len(options) == 0 {
+        options = resp.Options
+    }
+
+    page := Page{
+        Name:        name,
+        Section:     1,
+        Date:        time.Now(),
+        Summary:     strings.TrimSuffix(strings.TrimSpace(resp.Summary), "."),
+        Description: resp.Description,
+        Options:     options,
+        Examples:    resp.Examples,
+    }
+
+    for _, path := range paths {
+        page.Files = append(page.Files, Entry{ Name: path, Description: describe(resp.Files, path) })
+    }
+
+    for _, env := range envVars {
+        page.Environment = append(page.Environment, Entry{ Name: env, Description: describe(resp.Environment, env) })
+    }
+
+    text, err := page.Render(format)
+    if err != nil {
+        return "", err
+    }
+
+    if err := Validate(text, format); err != nil {
+        return "", fmt.Errorf("generated man page failed validation: %v", err)
+    }
+
+    outPath := filepath.Join(config.OutputDirectory, fmt.Sprintf("%v.%v", name, page.Section))
+
+    if err := os.WriteFile(outPath, []byte(text), 0644); err != nil {
+        return "", fmt.Errorf("failed to write %v: %v", outPath, err)
+    }
+
+    return outPath, nil
+}
+
+
+func OptionsFromFlags(flags []facts.FlagDefinition) []Option {
+    options := []Option{}
+
+    for _, f := range flags {
+        opt := Option{ Flag: f.Name, Description: f.Usage }
+
+        if f.TakesValue() {
+            switch {
+            case len(f.Values) > 0:
+                opt.Argument = strings.Join(f.Values, "|")
+            case f.PathKind() == facts.DirPath:
+                opt.Argument = "directory"
+            case f.PathKind() == facts.FilePath:
+                opt.Argument = "file"
+            default:
+                opt.Argument = f.Type
+            }
+        }
+
+        if f.TakesValue() && f.Default != "" {
+            opt.Description += fmt.Sprintf(" (default: %v)", f.Default)
+        }
+
+        opt.Description = strings.TrimSpace(opt.Description)
+
+        options = append(options, opt)
+    }
+
+    return options
+}
+
+
+func listOptions(options []Option) []string {
+    lines := []string{}
+
+    for _, o := range options {
+        lines = append(lines, strings.TrimSpace(fmt.Sprintf("-%v %v: %v", o.Flag, o.Argument, o.Description)))
+    }
+
+    return lines
+}
+
+
+// describe finds the model's description of name, which the page lists
+// whether or not the model described it
+func describe(entries []Entry, name string) string {
+    for _, e := range entries {
+        if e.Name == name {
+            return e.Description
+        }
+    }
+
+    return ""
+}
@@ -0,0 +1,262 @@
+package manpage
+
+import (
+    "fmt"
+    "time"
+    "strings"
+)
+
+
+type Format string
+
+const (
+    Man  Format = "man"
+    Mdoc Format = "mdoc"
+)
+
+
+type Option struct {
+    Flag        string `json:"flag"`
+    Argument    string `json:"argument"`
+    Description string `json:"description"`
+}
+
+type Entry struct {
+    Name        string `json:"name"`
+    Description string `json:"description"`
+}
+
+type Example struct {
+    Command     string `json:"command"`
+    Description string `json:"description"`
+}
+
+// A Page holds everything that goes into a section 1 man page, independent
+// of which macro package it gets rendered with
+type Page struct {
+    Name        string
+    Section     int
+    Date        time.Time
+    Summary     string
+    Description []string
+    Options     []Option
+    Files       []Entry
+    Environment []Entry
+    Examples    []Example
+}
+
+
+// Sections in the order man-pages(7) expects them
+var SectionOrder = []string{
+    "NAME",
+    "SYNOPSIS",
+    "DESCRIPTION",
+    "OPTIONS",
+    "FILES",
+    "ENVIRONMENT",
+    "EXAMPLES",
+}
+
+
+func (p Page) Render(format Format) (string, error) {
+    switch format {
+    case Man:
+        return p.RenderMan(), nil
+    case Mdoc:
+        return p.RenderMdoc(), nil
+    }
+
+    return "", fmt.Errorf("unsupported man page format %v", format)
+}
+
+
+// RenderMan writes the page using the classic man(7) macros
+func (p Page) RenderMan() string {
+    var b strings.Builder
+
+    fmt.Fprintf(&b, ".TH %v %v \"%v\" \"%v\" \"User Commands\"\n", strings.ToUpper(p.Name), p.Section, p.Date.Format("2006-01-02"), p.Name)
+
+    b.WriteString(".SH NAME\n")
+    fmt.Fprintf(&b, "%v \\- %v\n", escapeText(p.Name), escapeText(p.Summary))
+
+    b.WriteString(".SH SYNOPSIS\n")
+    fmt.Fprintf(&b, ".B %v\n", escapeText(p.Name))
+    for _, o := range p.Options {
+        if o.Argument == "" {
+            fmt.Fprintf(&b, "[\\fB\\-%v\\fR]\n", escapeText(o.Flag))
+        } else {
+            fmt.Fprintf(&b, "[\\fB\\-%v\\fR \\fI%v\\fR]\n", escapeText(o.Flag), escapeText(o.Argument))
+        }
+    }
+
+    b.WriteString(".SH DESCRIPTION\n")
+    for i, para := range p.Description {
+        if i > 0 {
+            b.WriteString(".PP\n")
+        }
+        b.WriteString(escapeLines(para))
+    }
+
+    if len(p.Options) > 0 {
+        b.WriteString(".SH OPTIONS\n")
+        for _, o := range p.Options {
+            b.WriteString(".TP\n")
+            if o.Argument == "" {
+                fmt.Fprintf(&b, "\\fB\\-%v\\fR\n", escapeText(o.Flag))
+            } else {
+                fmt.Fprintf(&b, "\\fB\\-%v\\fR \\fI%v\\fR\n", escapeText(o.Flag), escapeText(o.Argument))
+            }
+            b.WriteString(escapeLines(o.Description))
+        }
+    }
+
+    if len(p.Files) > 0 {
+        b.WriteString(".SH FILES\n")
+        for _, f := range p.Files {
+            b.WriteString(".TP\n")
+            fmt.Fprintf(&b, "\\fI%v\\fR\n", escapeText(f.Name))
+            b.WriteString(escapeLines(f.Description))
+        }
+    }
+
+    if len(p.Environment) > 0 {
+        b.WriteString(".SH ENVIRONMENT\n")
+        for _, e := range p.Environment {
+            b.WriteString(".TP\n")
+            fmt.Fprintf(&b, "\\fB%v\\fR\n", escapeText(e.Name))
+            b.WriteString(escapeLines(e.Description))
+        }
+    }
+
+    if len(p.Examples) > 0 {
+        b.WriteString(".SH EXAMPLES\n")
+        for i, e := range p.Examples {
+            if i > 0 {
+                b.WriteString(".PP\n")
+            }
+            b.WriteString(escapeLines(e.Description))
+            b.WriteString(".PP\n.RS\n.nf\n")
+            b.WriteString(escapeLines(e.Command))
+            b.WriteString(".fi\n.RE\n")
+        }
+    }
+
+    return b.String()
+}
+
+
+// RenderMdoc writes the page using the semantic mdoc(7) macros
+func (p Page) RenderMdoc() string {
+    var b strings.Builder
+
+    fmt.Fprintf(&b, ".Dd %v\n", p.Date.Format("January 2, 2006"))
+    fmt.Fprintf(&b, ".Dt %v %v\n", strings.ToUpper(p.Name), p.Section)
+    b.WriteString(".Os\n")
+
+    b.WriteString(".Sh NAME\n")
+    fmt.Fprintf(&b, ".Nm %v\n", escapeArg(p.Name))
+    fmt.Fprintf(&b, ".Nd %v\n", escapeText(p.Summary))
+
+    b.WriteString(".Sh SYNOPSIS\n")
+    b.WriteString(".Nm\n")
+    for _, o := range p.Options {
+        if o.Argument == "" {
+            fmt.Fprintf(&b, ".Op Fl %v\n", escapeArg(o.Flag))
+        } else {
+            fmt.Fprintf(&b, ".Op Fl %v Ar %v\n", escapeArg(o.Flag), escapeArg(o.Argument))
+        }
+    }
+
+    b.WriteString(".Sh DESCRIPTION\n")
+    for i, para := range p.Description {
+        if i > 0 {
+            b.WriteString(".Pp\n")
+        }
+        b.WriteString(escapeLines(para))
+    }
+
+    if len(p.Options) > 0 {
+        b.WriteString(".Sh OPTIONS\n")
+        b.WriteString(".Bl -tag -width Ds\n")
+        for _, o := range p.Options {
+            if o.Argument == "" {
+                fmt.Fprintf(&b, ".It Fl %v\n", escapeArg(o.Flag))
+            } else {
+                fmt.Fprintf(&b, ".It Fl %v Ar %v\n", escapeArg(o.Flag), escapeArg(o.Argument))
+            }
+            b.WriteString(escapeLines(o.Description))
+        }
+        b.WriteString(".El\n")
+    }
+
+    if len(p.Files) > 0 {
+        b.WriteString(".Sh FILES\n")
+        b.WriteString(".Bl -tag -width Ds\n")
+        for _, f := range p.Files {
+            fmt.Fprintf(&b, ".It Pa %v\n", escapeArg(f.Name))
+            b.WriteString(escapeLines(f.Description))
+        }
+        b.WriteString(".El\n")
+    }
+
+    if len(p.Environment) > 0 {
+        b.WriteString(".Sh ENVIRONMENT\n")
+        b.WriteString(".Bl -tag -width Ds\n")
+        for _, e := range p.Environment {
+            fmt.Fprintf(&b, ".It Ev %v\n", escapeArg(e.Name))
+            b.WriteString(escapeLines(e.Description))
+        }
+        b.WriteString(".El\n")
+    }
+
+    if len(p.Examples) > 0 {
+        b.WriteString(".Sh EXAMPLES\n")
+        for i, e := range p.Examples {
+            if i > 0 {
+                b.WriteString(".Pp\n")
+            }
+            b.WriteString(escapeLines(e.Description))
+            b.WriteString(".Bd -literal -offset indent\n")
+            b.WriteString(escapeLines(e.Command))
+            b.WriteString(".Ed\n")
+        }
+    }
+
+    return b.String()
+}
+
+
+// escapeText makes s safe inside a roff text line
+func escapeText(s string) string {
+    s = strings.ReplaceAll(s, `\`, `\e`)
+    s = strings.ReplaceAll(s, "-", `\-`)
+    return s
+}
+
+
+// escapeArg makes s a single, non-callable mdoc macro argument
+func escapeArg(s string) string {
+    s = escapeText(strings.Join(strings.Fields(s), ""))
+    return `\&` + s
+}
+
+
+// escapeLines escapes a block of text, protecting lines which roff would
+// otherwise read as requests
+func escapeLines(s string) string {
+    var b strings.Builder
+
+    for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+        line = escapeText(line)
+        if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+            line = `\&` + line
+        }
+        // Blank lines are paragraph breaks in roff
+        if strings.TrimSpace(line) == "" {
+            continue
+        }
+        b.WriteString(line + "\n")
+    }
+
+    return b.String()
+}
@@ -0,0 +1,211 @@
+package manpage
+
+import (
+    "fmt"
+    "slices"
+    "strings"
+)
+
+
+var manMacros = []string{
+    "TH", "SH", "SS", "TP", "PP", "P", "LP", "IP", "HP", "RS", "RE",
+    "B", "I", "BR", "BI", "IB", "IR", "RB", "RI", "SM", "SB",
+    "nf", "fi", "br", "sp", "ad", "na", "ne", "in", "ti", "EX", "EE",
+}
+
+var mdocMacros = []string{
+    "Dd", "Dt", "Os", "Sh", "Ss", "Pp", "Nm", "Nd", "Op", "Fl", "Ar", "Cm",
+    "Bl", "El", "It", "Bd", "Ed", "Pa", "Ev", "Xr", "Em", "Sy", "Li", "Ql",
+    "Dl", "Ic", "Va", "Ns", "No", "Oo", "Oc", "Sq", "Dq", "Pq", "Ex", "Lk",
+    "br", "sp",
+}
+
+// Block macros which have to be closed, and the macro closing them
+var blockPairs = map[Format]map[string]string{
+    Man:  { "RS": "RE", "nf": "fi", "EX": "EE" },
+    Mdoc: { "Bl": "El", "Bd": "Ed", "Oo": "Oc" },
+}
+
+
+type Problem struct {
+    Line    int
+    Message string
+}
+
+func (p Problem) String() string {
+    if p.Line == 0 {
+        return p.Message
+    }
+
+    return fmt.Sprintf("line %v: %v", p.Line, p.Message)
+}
+
+
+/**
+ * Validate runs a small roff parser over text: every request must be a known
+ * macro for the format, escapes and quotes must be well formed, blocks must be
+ * closed, and the NAME, SYNOPSIS and DESCRIPTION sections must be present in
+ * the standard order.
+ */
+func Validate(text string, format Format) error {
+    known := manMacros
+    sectionMacro := "SH"
+    if format == Mdoc {
+        known = mdocMacros
+        sectionMacro = "Sh"
+    }
+
+    problems := []Problem{}
+    report := func(line int, msg string, args ...any) {
+        problems = append(problems, Problem{ Line: line, Message: fmt.Sprintf(msg, args...) })
+    }
+
+    open := []string{}
+    sections := []string{}
+    first := []string{}
+
+    for i, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+        n := i + 1
+
+        if msg := checkEscapes(line); msg != "" {
+            report(n, "%v", msg)
+        }
+
+        if !strings.HasPrefix(line, ".") && !strings.HasPrefix(line, "'") {
+            continue
+        }
+
+        fields := strings.Fields(line[1:])
+        if len(fields) == 0 || strings.HasPrefix(fields[0], `\"`) {
+            continue
+        }
+
+        macro := fields[0]
+        if len(first) < 3 {
+            first = append(first, macro)
+        }
+
+        if !slices.Contains(known, macro) {
+            report(n, "unknown %v macro .%v", format, macro)
+            continue
+        }
+
+        if strings.Count(strings.ReplaceAll(line, `\"`, ""), `"`) % 2 != 0 {
+            report(n, "unbalanced quotes in .%v", macro)
+        }
+
+        if closer, ok := blockPairs[format][macro]; ok {
+            open = append(open, closer)
+        }
+
+        for _, closer := range blockPairs[format] {
+            if macro != closer {
+                continue
+            }
+            if len(open) == 0 || open[len(open) - 1] != closer {
+                report(n, ".%v without a matching open block", macro)
+                continue
+            }
+            open = open[:len(open) - 1]
+        }
+
+        if macro == sectionMacro {
+            if len(open) > 0 {
+                report(n, "section starts while .%v is still expected", open[len(open) - 1])
+                open = []string{}
+            }
+            sections = append(sections, strings.Trim(strings.Join(fields[1:], " "), `"`))
+        }
+    }
+
+    for _, closer := range open {
+        report(0, "block not closed, missing .%v", closer)
+    }
+
+    switch format {
+    case Man:
+        if len(first) == 0 || first[0] != "TH" {
+            report(1, "page must start with .TH")
+        }
+    case Mdoc:
+        if !slices.Equal(first, []string{ "Dd", "Dt", "Os" }) {
+            report(1, "page must start with .Dd, .Dt and .Os")
+        }
+    }
+
+    for _, required := range SectionOrder[:3] {
+        if !slices.Contains(sections, required) {
+            report(0, "missing required section %v", required)
+        }
+    }
+
+    last := -1
+    for _, section := range sections {
+        idx := slices.Index(SectionOrder, section)
+        if idx == -1 {
+            continue
+        }
+        if idx < last {
+            report(0, "section %v is out of order", section)
+        }
+        last = idx
+    }
+
+    if len(problems) == 0 {
+        return nil
+    }
+
+    msgs := []string{}
+    for _, p := range problems {
+        msgs = append(msgs, p.String())
+    }
+
+    return fmt.Errorf("invalid %v page:\n%v", format, strings.Join(msgs, "\n"))
+}
+
+
+// checkEscapes returns a description of the first malformed escape in line
+func checkEscapes(line string) string {
+    for i := 0; i < len(line); i++ {
+        if line[i] != '\\' {
+            continue
+        }
+
+        if i + 1 >= len(line) {
+            return "line ends with a bare backslash"
+        }
+
+        i++
+        switch c := line[i]; c {
+        case '-', '\\', 'e', '&', '|', '^', ' ', '.', '\'', '`', '0', '%', 'c', '~', ',', '/', ':':
+        case '"':
+            // Comment to end of line
+            return ""
+        case 'f':
+            if i + 1 >= len(line) || !strings.ContainsRune("BIRP", rune(line[i + 1])) {
+                return "unknown font escape"
+            }
+            i++
+        case '(':
+            if i + 2 >= len(line) {
+                return "truncated \\( escape"
+            }
+            i += 2
+        case '[':
+            end := strings.IndexByte(line[i:], ']')
+            if end == -1 {
+                return "unterminated \\[ escape"
+            }
+            i += end
+        case '*':
+            if i + 1 >= len(line) {
+                return "truncated \\* escape"
+            }
+            i++
+        default:
+            return fmt.Sprintf("unknown escape \\%c", c)
+        }
+    }
+
+    return ""
+}
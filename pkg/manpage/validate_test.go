@@ -0,0 +1,87 @@
+package manpage
+
+import (
+    "time"
+    "strings"
+    "testing"
+)
+
+
+var page = Page{
+    Name:        "tool",
+    Section:     1,
+    Date:        time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+    Summary:     "does things with \"quotes\" and back\\slashes",
+    Description: []string{ "It does things.", ".starts with a dot" },
+    Options:     []Option{
+        { Flag: "config", Argument: "file", Description: "Config file" },
+        { Flag: "v", Description: "Log more" },
+    },
+    Files:       []Entry{ { Name: "/etc/tool.conf", Description: "Config." } },
+    Environment: []Entry{ { Name: "TOOL_HOME", Description: "Where it lives." } },
+    Examples:    []Example{ { Command: "tool -v -config x.conf", Description: "Verbose." } },
+}
+
+
+func TestRenderedPagesValidate(t *testing.T) {
+    for _, format := range []Format{ Man, Mdoc } {
+        t.Run(string(format), func(t *testing.T) {
+            text, err := page.Render(format)
+            if err != nil {
+                t.Fatalf("Render() error = %v", err)
+            }
+            if err := Validate(text, format); err != nil {
+                t.Errorf("Validate() of a rendered page error = %v\n%v", err, text)
+            }
+        })
+    }
+}
+
+
+func TestValidate(t *testing.T) {
+    man := ".TH TOOL 1\n.SH NAME\ntool \\- does things\n.SH SYNOPSIS\n.B tool\n.SH DESCRIPTION\nText.\n"
+    mdoc := ".Dd May 1, 2024\n.Dt TOOL 1\n.Os\n.Sh NAME\n.Nm tool\n.Nd does things\n.Sh SYNOPSIS\n.Nm\n.Sh DESCRIPTION\nText.\n"
+
+    tests := []struct {
+        name    string
+        format  Format
+        text    string
+        wantErr string
+    }{
+        { name: "minimal man page", format: Man, text: man },
+        { name: "minimal mdoc page", format: Mdoc, text: mdoc },
+        { name: "comments are ignored", format: Man, text: ".\\\" generated\n" + man + ".\\\" unknown \\q in a comment\n" },
+        { name: "named and two character escapes", format: Man, text: man + "\\(em \\[u2014] \\fBbold\\fR \\*(Tm\n" },
+
+        { name: "unknown macro", format: Man, text: man + ".XY foo\n", wantErr: "unknown man macro .XY" },
+        { name: "mdoc macro in a man page", format: Man, text: man + ".Fl v\n", wantErr: "unknown man macro .Fl" },
+        { name: "unbalanced quotes", format: Man, text: man + ".B \"tool\n", wantErr: "unbalanced quotes" },
+        { name: "unknown escape", format: Man, text: man + "bad \\q escape\n", wantErr: "unknown escape \\q" },
+        { name: "unknown font", format: Man, text: man + "\\fXodd\n", wantErr: "unknown font escape" },
+        { name: "unterminated named escape", format: Man, text: man + "\\[u2014\n", wantErr: "unterminated \\[ escape" },
+        { name: "bare backslash", format: Man, text: man + "trailing \\\n", wantErr: "bare backslash" },
+        { name: "block not closed", format: Man, text: man + ".RS\nindented\n", wantErr: "missing .RE" },
+        { name: "closed without opening", format: Man, text: man + ".RE\n", wantErr: ".RE without a matching open block" },
+        { name: "section inside a block", format: Man, text: ".TH TOOL 1\n.SH NAME\n.nf\n.SH SYNOPSIS\n.SH DESCRIPTION\n", wantErr: "section starts while .fi" },
+        { name: "missing .TH", format: Man, text: strings.TrimPrefix(man, ".TH TOOL 1\n"), wantErr: "must start with .TH" },
+        { name: "missing section", format: Man, text: ".TH TOOL 1\n.SH NAME\n.SH DESCRIPTION\n", wantErr: "missing required section SYNOPSIS" },
+        { name: "sections out of order", format: Man, text: man + ".SH EXAMPLES\n.SH OPTIONS\n", wantErr: "section OPTIONS is out of order" },
+        { name: "mdoc without prologue", format: Mdoc, text: ".Dd May 1, 2024\n.Sh NAME\n.Sh SYNOPSIS\n.Sh DESCRIPTION\n", wantErr: "must start with .Dd, .Dt and .Os" },
+        { name: "mdoc list not closed", format: Mdoc, text: mdoc + ".Bl -tag -width Ds\n.It Fl v\n", wantErr: "missing .El" },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            err := Validate(tt.text, tt.format)
+            if tt.wantErr == "" {
+                if err != nil {
+                    t.Errorf("Validate() error = %v", err)
+                }
+                return
+            }
+            if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                t.Errorf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
+            }
+        })
+    }
+}
@@ -0,0 +1,220 @@
+package markdown
+
+import (
+    "fmt"
+    "sort"
+    "regexp"
+    "strings"
+)
+
+
+var (
+    atxHeading  = regexp.MustCompile(`^ {0,3}(#{1,6})[ \t]+(.*?)[ \t#]*$`)
+    beginMarker = regexp.MustCompile(`^\s*<!--\s*autoscribe:begin\s+([\w.-]+)\s*-->\s*$`)
+    endMarker   = regexp.MustCompile(`^\s*<!--\s*autoscribe:end(?:\s+[\w.-]+)?\s*-->\s*$`)
+    fence       = regexp.MustCompile("^ {0,3}(```|~~~)")
+)
+
+
+type Heading struct {
+    Level int
+    Title string
+    // Byte offsets: the heading line starts at Start, its body runs from
+    // BodyStart up to End (the next heading of the same or higher level)
+    Start     int
+    BodyStart int
+    End       int
+}
+
+
+// A Region is a span of the document AutoScribe is allowed to rewrite. The
+// markers or heading around it are never part of the span.
+type Region struct {
+    Name    string
+    Managed bool
+    Start   int
+    End     int
+}
+
+
+type Document struct {
+    Source   []byte
+    Headings []Heading
+    Managed  []Region
+}
+
+
+/**
+ * Parse splits a markdown document into ATX headed sections and
+ * `<!-- autoscribe:begin name -->` ... `<!-- autoscribe:end -->` managed
+ * blocks. Headings and markers inside fenced code blocks are ignored.
+ */
+func Parse(src []byte) (*Document, error) {
+    doc := &Document{ Source: src }
+
+    inFence := ""
+    var open *Region
+    offset := 0
+
+    for _, line := range strings.SplitAfter(string(src), "\n") {
+        start := offset
+        offset += len(line)
+        text := strings.TrimRight(line, "\r\n")
+
+        if m := fence.FindStringSubmatch(text); m != nil {
+            if inFence == "" {
+                inFence = m[1]
+            } else if inFence == m[1] {
+                inFence = ""
+            }
+            continue
+        }
+
+        if inFence != "" {
+            continue
+        }
+
+        if m := beginMarker.FindStringSubmatch(text); m != nil {
+            if open != nil {
+                return nil, fmt.Errorf("autoscribe:begin %v starts inside the unclosed managed section %v", m[1], open.Name)
+            }
+            open = &Region{ Name: m[1], Managed: true, Start: offset }
+            continue
+        }
+
+        if endMarker.MatchString(text) {
+            if open == nil {
+                return nil, fmt.Errorf("autoscribe:end at byte %v has no matching autoscribe:begin", start)
+            }
+            open.End = start
+            doc.Managed = append(doc.Managed, *open)
+            open = nil
+            continue
+        }
+
+        if m := atxHeading.FindStringSubmatch(text); m != nil {
+            doc.Headings = append(doc.Headings, Heading{
+                Level:     len(m[1]),
+                Title:     strings.TrimSpace(m[2]),
+                Start:     start,
+                BodyStart: offset,
+            })
+        }
+    }
+
+    if open != nil {
+        return nil, fmt.Errorf("managed section %v is missing its autoscribe:end marker", open.Name)
+    }
+
+    // A section runs until the next heading which isn't nested under it
+    for i := range doc.Headings {
+        doc.Headings[i].End = len(src)
+        for _, next := range doc.Headings[i + 1:] {
+            if next.Level <= doc.Headings[i].Level {
+                doc.Headings[i].End = next.Start
+                break
+            }
+        }
+    }
+
+    return doc, nil
+}
+
+
+func (d *Document) FindHeading(title string) (Heading, bool) {
+    for _, h := range d.Headings {
+        if strings.EqualFold(h.Title, strings.TrimSpace(title)) {
+            return h, true
+        }
+    }
+
+    return Heading{}, false
+}
+
+
+/**
+ * Regions returns what should be regenerated: every managed block plus the
+ * body of each named section. Regions nested inside another region are
+ * dropped since the outer one gets rewritten anyway. Naming a section which
+ * doesn't exist is an error.
+ */
+func (d *Document) Regions(sectionNames []string) ([]Region, error) {
+    regions := append([]Region{}, d.Managed...)
+
+    for _, name := range sectionNames {
+        if strings.TrimSpace(name) == "" {
+            continue
+        }
+
+        h, ok := d.FindHeading(name)
+        if !ok {
+            return nil, fmt.Errorf("no section titled %q", name)
+        }
+
+        regions = append(regions, Region{ Name: h.Title, Start: h.BodyStart, End: h.End })
+    }
+
+    sort.SliceStable(regions, func(i, j int) bool {
+        if regions[i].Start != regions[j].Start {
+            return regions[i].Start < regions[j].Start
+        }
+        return regions[i].End > regions[j].End
+    })
+
+    outer := []Region{}
+    for _, r := range regions {
+        if len(outer) > 0 && r.Start < outer[len(outer) - 1].End {
+            continue
+        }
+        outer = append(outer, r)
+    }
+
+    return outer, nil
+}
+
+
+func (d *Document) Content(r Region) string {
+    return string(d.Source[r.Start:r.End])
+}
+
+
+/**
+ * Replace returns the source with each region's span swapped for the text in
+ * replacements (keyed by region name). Bytes outside the regions are left
+ * exactly as they were.
+ */
+func (d *Document) Replace(regions []Region, replacements map[string]string) []byte {
+    out := []byte{}
+    last := 0
+
+    for _, r := range regions {
+        text, ok := replacements[r.Name]
+        if !ok {
+            continue
+        }
+
+        out = append(out, d.Source[last:r.Start]...)
+        out = append(out, []byte(fitRegion(text, r.Managed, r.End == len(d.Source)))...)
+        last = r.End
+    }
+
+    return append(out, d.Source[last:]...)
+}
+
+
+// fitRegion normalises the blank lines around generated text so it sits
+// between markers, or between a heading and the next one, like hand written
+// markdown would
+func fitRegion(text string, managed bool, atEOF bool) string {
+    text = strings.Trim(text, "\n")
+
+    if managed {
+        return text + "\n"
+    }
+
+    if atEOF {
+        return "\n" + text + "\n"
+    }
+
+    return "\n" + text + "\n\n"
+}
@@ -0,0 +1,123 @@
+package markdown
+
+import (
+    "strings"
+    "testing"
+)
+
+
+const readme = "# Tool\n\nIntro.\n\n## Install\n\n```sh\n# not a heading\n<!-- autoscribe:begin fake -->\n```\n\nmake\n\n### From source\n\ngo build\n\n## Usage\n\n<!-- autoscribe:begin usage -->\nold usage\n<!-- autoscribe:end -->\n\nHand written.\n"
+
+
+func TestParse(t *testing.T) {
+    doc, err := Parse([]byte(readme))
+    if err != nil {
+        t.Fatalf("Parse() error = %v", err)
+    }
+
+    titles := []string{}
+    for _, h := range doc.Headings {
+        titles = append(titles, strings.Repeat("#", h.Level) + " " + h.Title)
+    }
+    if got, want := strings.Join(titles, "|"), "# Tool|## Install|### From source|## Usage"; got != want {
+        t.Errorf("headings = %v, want %v", got, want)
+    }
+
+    install, _ := doc.FindHeading("install")
+    if got := doc.Content(Region{ Start: install.BodyStart, End: install.End }); !strings.Contains(got, "go build") || strings.Contains(got, "## Usage") {
+        t.Errorf("Install section runs over %q, want it to include its subsection and stop at Usage", got)
+    }
+
+    if len(doc.Managed) != 1 || doc.Managed[0].Name != "usage" {
+        t.Fatalf("managed blocks = %+v, want only usage", doc.Managed)
+    }
+    if got := doc.Content(doc.Managed[0]); got != "old usage\n" {
+        t.Errorf("usage block = %q, want the text between the markers", got)
+    }
+}
+
+
+func TestParseErrors(t *testing.T) {
+    tests := []struct {
+        name    string
+        src     string
+        wantErr string
+    }{
+        { name: "unclosed", src: "<!-- autoscribe:begin a -->\ntext\n", wantErr: "missing its autoscribe:end" },
+        { name: "nested", src: "<!-- autoscribe:begin a -->\n<!-- autoscribe:begin b -->\n<!-- autoscribe:end -->\n", wantErr: "inside the unclosed managed section a" },
+        { name: "end without begin", src: "text\n<!-- autoscribe:end -->\n", wantErr: "no matching autoscribe:begin" },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := Parse([]byte(tt.src))
+            if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                t.Errorf("Parse() error = %v, want it to contain %q", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+
+func TestRegionsAndReplace(t *testing.T) {
+    tests := []struct {
+        name         string
+        sections     []string
+        replacements map[string]string
+        want         string
+        wantErr      string
+    }{
+        {
+            name:         "managed block only",
+            replacements: map[string]string{ "usage": "\n\nnew usage\n\n" },
+            want:         strings.Replace(readme, "old usage\n", "new usage\n", 1),
+        },
+        {
+            name:         "named section and managed block",
+            sections:     []string{ "From source" },
+            replacements: map[string]string{ "usage": "new usage", "From source": "go install" },
+            want:         strings.Replace(strings.Replace(readme, "old usage\n", "new usage\n", 1), "### From source\n\ngo build\n\n", "### From source\n\ngo install\n\n", 1),
+        },
+        {
+            name:         "a managed block inside a named section goes with it",
+            sections:     []string{ "Usage" },
+            replacements: map[string]string{ "Usage": "all new" },
+            want:         readme[:strings.Index(readme, "## Usage\n") + len("## Usage\n")] + "\nall new\n",
+        },
+        {
+            name:         "regions without a replacement are left alone",
+            sections:     []string{ "Install" },
+            replacements: map[string]string{},
+            want:         readme,
+        },
+        {
+            name:     "unknown section",
+            sections: []string{ "Missing" },
+            wantErr:  "no section titled",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            doc, err := Parse([]byte(readme))
+            if err != nil {
+                t.Fatalf("Parse() error = %v", err)
+            }
+
+            regions, err := doc.Regions(tt.sections)
+            if tt.wantErr != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                    t.Fatalf("Regions() error = %v, want it to contain %q", err, tt.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("Regions() error = %v", err)
+            }
+
+            if got := string(doc.Replace(regions, tt.replacements)); got != tt.want {
+                t.Errorf("Replace() =\n%v\nwant\n%v", got, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,56 @@
+package calls
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+)
+
+
+// Extra guidelines for each language in the project, added to the prompts
+// whenever files of that language are sent
+var LanguageRules = map[types.SupportedFormat]string{
+    types.Golang: `- Go: build and install with the go tool (or the Makefile, if there is one) at the go version from go.mod. Describe flags exactly as the flag definitions declare them`,
+    types.Shell: `- Shell: say which interpreter each script needs, how it's invoked and with which arguments, and any environment variables or privileges (e.g. sudo) it relies on`,
+    types.Bash: `- Bash: say which interpreter each script needs, how it's invoked and with which arguments, and any environment variables or privileges (e.g. sudo) it relies on. Mention when a script needs bash rather than any POSIX sh`,
+    types.Python: `- Python: give the Python version and install steps the project itself uses (pyproject.toml, setup.py, requirements files). Show how to run it as a module or a script`,
+    types.JavaScript: `- JavaScript: use the package manager and the scripts from package.json. Mention the Node version if one is pinned`,
+    types.TypeScript: `- TypeScript: use the package manager and the scripts from package.json, including the build step which compiles the TypeScript`,
+    types.Rust: `- Rust: build, test and install with cargo. Mention features and the minimum Rust version if the crate declares them`,
+    types.C: `- C: describe the build with the project's own build system and the compiler or libraries it needs`,
+    types.Cpp: `- C++: describe the build with the project's own build system, the C++ standard and the libraries it needs`,
+    types.Makefile: `- Makefile: document the targets a user would run, by their exact names`,
+    types.Dockerfile: `- Dockerfile: show how to build and run the image, with the ports, volumes and environment variables the Dockerfile declares`,
+    types.Yaml: `- YAML: these are configuration files. Document the settings a user would change, by their exact keys`,
+    types.Json: `- JSON: these are configuration or data files. Only document the keys a user would change`,
+    types.Toml: `- TOML: these are configuration or manifest files. Use their exact keys`,
+    types.Text: `- Text: these are existing documentation or data. Remain as faithful to their content as possible`,
+    types.MarkDown: `- Markdown: this is existing documentation. Reuse what's still accurate instead of contradicting it, and don't copy it wholesale`,
+}
+
+
+// LanguageGuidelines lists the languages in the project followed by their
+// rules, for the prompts
+func LanguageGuidelines(languages []types.SupportedFormat) string {
+    if len(languages) == 0 {
+        return ""
+    }
+
+    names := []string{}
+    rules := []string{}
+
+    for _, lang := range languages {
+        names = append(names, lang.Name())
+        if rule, ok := LanguageRules[lang]; ok {
+            rules = append(rules, rule)
+        }
+    }
+
+    guidelines := fmt.Sprintf("- The project files are labeled with their language: %v. Only describe languages, tools and interpreters the files actually use", strings.Join(names, ", "))
+    if len(rules) > 0 {
+        guidelines += "\n" + strings.Join(rules, "\n")
+    }
+
+    return guidelines
+}
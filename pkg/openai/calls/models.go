@@ -3,36 +3,193 @@ package calls
 import (
     // "os"
     "fmt"
+    "time"
     "context"
+    "encoding/json"
 
     "github.com/openai/openai-go/v2"
     "github.com/openai/openai-go/v2/option"
-    // "github.com/openai/openai-go/v2/shared"
+    "github.com/openai/openai-go/v2/shared"
 
     "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/redact"
 )
 
+// ProviderError is a request to the model provider which failed, as opposed
+// to a problem with the project or with what the model answered
+type ProviderError struct {
+    Err error
+}
+
+func (e *ProviderError) Error() string {
+    return e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+    return e.Err
+}
+
+
 func Query4_1Nano(msg string) (string, error) {
-    // Load API key
-    client := openai.NewClient(
-        option.WithAPIKey(config.OpenAIKey),
-    )
+    return QueryFile("", msg)
+}
+
+
+/**
+ * QueryFile sends msg to config.Model and returns the answer, recording the
+ * tokens used against file, the project file the prompt is about (empty for
+ * the whole project). With -estimate nothing is sent: the expected usage is
+ * recorded and ErrEstimated returned. A call which could go over -max-cost or
+ * -max-tokens isn't made, and ErrBudget is returned.
+ */
+func QueryFile(file string, msg string) (string, error) {
+    return query(file, "", msg, nil)
+}
+
+
+// Schema is the JSON Schema an answer must match, under a name the provider
+// shows the model
+type Schema struct {
+    Name   string
+    Schema map[string]any
+}
 
+
+/**
+ * QueryJSON sends system as the system message and msg as the user message,
+ * with the answer held to schema by the provider's structured output, and
+ * decodes the answer into out. Otherwise it works like QueryFile.
+ */
+func QueryJSON(file string, system string, msg string, schema Schema, out any) error {
+    answer, err := query(file, system, msg, &schema)
+    if err != nil {
+        return err
+    }
+
+    if err := json.Unmarshal([]byte(answer), out); err != nil {
+        return fmt.Errorf("failed to parse the %v answer of %v: %v", schema.Name, config.Model, err)
+    }
+
+    return nil
+}
+
+
+/**
+ * Context cancels the calls in flight once it's done. main ends it on Ctrl-C,
+ * so an interrupted call returns an error wrapping context.Canceled instead
+ * of a provider error.
+ */
+var Context = context.Background()
+
+
+// request builds what's sent for msg, with system as the system message when
+// it's set and the answer held to schema when that is. It returns everything
+// sent too, which is what counts towards the budget
+func request(system string, msg string, schema *Schema) (openai.ChatCompletionNewParams, string, error) {
     if config.AdditionalPrompt != "" {
         msg += fmt.Sprintf("\n-----------------------\nAdditionally:\n%v\n", config.AdditionalPrompt)
     }
 
-    chatCompletion, err := client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
+    // Nothing leaves the machine before secrets are taken out
+    msg, err := redact.Prompt(msg)
+    if err != nil {
+        return openai.ChatCompletionNewParams{}, "", fmt.Errorf("failed to redact the prompt: %v", err)
+    }
+
+    params := openai.ChatCompletionNewParams{
         Messages: []openai.ChatCompletionMessageParamUnion{
             openai.UserMessage(msg),
         },
-        Model: openai.ChatModelGPT4_1Nano,
-    })
+        Model: openai.ChatModel(config.Model),
+    }
 
-    if err != nil {
-        return "", fmt.Errorf("failed to query 4.1 nano : %v", err)
+    // Everything sent counts towards the budget, not just the user message
+    sent := msg
+
+    if system != "" {
+        params.Messages = append([]openai.ChatCompletionMessageParamUnion{ openai.SystemMessage(system) }, params.Messages...)
+        sent = system + "\n" + sent
+    }
+
+    if schema != nil {
+        params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+            OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
+                JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+                    Name:   schema.Name,
+                    Schema: schema.Schema,
+                    Strict: openai.Bool(true),
+                },
+            },
+        }
+
+        encoded, err := json.Marshal(schema.Schema)
+        if err != nil {
+            return openai.ChatCompletionNewParams{}, "", fmt.Errorf("failed to encode the %v schema: %v", schema.Name, err)
+        }
+        sent += "\n" + string(encoded)
+    }
+
+    return params, sent, nil
+}
+
+
+// providerError wraps an error from the provider, unless the call failed
+// because the run was cancelled
+func providerError(err error) error {
+    if Context.Err() != nil {
+        return fmt.Errorf("%v was interrupted: %w", config.Model, Context.Err())
     }
 
-    return chatCompletion.Choices[0].Message.Content, nil
+    return &ProviderError{ Err: fmt.Errorf("failed to query %v : %v", config.Model, err) }
 }
 
+
+func query(file string, system string, msg string, schema *Schema) (string, error) {
+    started := time.Now()
+
+    // Load API key
+    client := openai.NewClient(
+        option.WithAPIKey(config.OpenAIKey),
+    )
+
+    params, sent, err := request(system, msg, schema)
+    if err != nil {
+        return "", err
+    }
+
+    if config.Estimate {
+        estimateUsage(file, sent, started)
+        return "", ErrEstimated
+    }
+
+    release, err := reserve(sent)
+    if err != nil {
+        return "", err
+    }
+    defer release()
+
+    chatCompletion, err := client.Chat.Completions.New(Context, params)
+
+    if err != nil {
+        return "", providerError(err)
+    }
+
+    recordUsage(Usage{
+        File:             file,
+        Model:            config.Model,
+        PromptTokens:     int(chatCompletion.Usage.PromptTokens),
+        CompletionTokens: int(chatCompletion.Usage.CompletionTokens),
+        LatencyMs:        time.Since(started).Milliseconds(),
+    })
+
+    if len(chatCompletion.Choices) == 0 {
+        return "", &ProviderError{ Err: fmt.Errorf("%v returned no choices", config.Model) }
+    }
+
+    message := chatCompletion.Choices[0].Message
+    if message.Refusal != "" {
+        return "", fmt.Errorf("%v refused to answer: %v", config.Model, message.Refusal)
+    }
+
+    return message.Content, nil
+}
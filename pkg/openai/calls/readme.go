@@ -3,66 +3,185 @@ package calls
 import (
     "os"
     "fmt"
+    "errors"
+    "strings"
+    "path/filepath"
 
     log "github.com/sirupsen/logrus"
 
-    "github.com/BlankCanvasStudio/AutoScribe/pkg/files"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/facts"
     "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
     "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/prompts"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/markdown"
 )
 
 
 // Maybe this is bytes
 // func CreateReadme(data types.ConcatenatedFileContents, fileFormat types.SupportedFormat) error {
-func CreateReadme(fileFormat types.SupportedFormat) error {
-    data, err := files.FormatCodeFilesForContext()
+// CreateReadme writes or updates the README, returning its path and the
+// claims in it which contradict the project
+func CreateReadme(languages []types.SupportedFormat) (string, []facts.Contradiction, error) {
+    data, err := ProjectContext()
+    if err != nil {
+        return "", nil, fmt.Errorf("failed to format project files: %w", err)
+    }
 
-    buildData, err := files.FormatBuildFilesForContext()
+    projectFacts, err := facts.Collect(config.ProjectDirectory)
+    if err != nil {
+        log.Warnf("Some project facts could not be read: %v", err)
+    }
 
-    data += buildData
+    base := prompts.Data{
+        Guidelines: LanguageGuidelines(languages),
+        Facts:      projectFacts.Format(),
+        Files:      string(data),
+    }
 
-    readmePrompt := fmt.Sprintf(
-`You are an expert technical writer and software engineer.
+    inputFile := config.EditFile
+    if inputFile == "" {
+        inputFile = "README.md"
+    }
 
-ONLY OUTPUT A SINGLE FILE CALLED 'README.md' — **do not include any other text, commentary, or explanation**.
+    ReadmePath := filepath.Join(config.OutputDirectory, inputFile)
 
-The README should:
-- Clearly describe the purpose of the project.
-- Explain how to install, configure, and run it if those details are provided. Only write about details you can read in the code.
-- Include examples of usage, if applicable.
-- Document any important dependencies or architectures in the codebase, if any are provided.
-- Use proper Markdown formatting.
-- If there is a Makefile or other build system, include the configuration, building, and installation steps using that system
-- Do not write your own shell script to install the code, unless no build system is provided
-- If available, use resources in the project and detail how to: install dependencies, build code, install necessary parts of the package
-- If the installation and build process includes multiple steps, you can add extra documentation for those steps, but don't be too verbose
-- Do not include *End of README.* or any similar stort of annotations
-- Make sure to document all command line options and their effect, if applicable
-- Make the output pretty, but not over the top
-- If you are translating txt files, remain as faithful to the source content as possible
+    log.Infof("Outputting to file: %v", ReadmePath)
 
-Here are the project files:
+    existing, err := os.ReadFile(ReadmePath)
+    if os.IsNotExist(err) {
+        err = CreateFullReadme(ReadmePath, base)
+    } else if err != nil {
+        return "", nil, fmt.Errorf("failed to read %v: %v", ReadmePath, err)
+    } else {
+        err = UpdateReadmeSections(ReadmePath, existing, base)
+    }
 
-%v`, data)
+    if err != nil {
+        return "", nil, err
+    }
 
+    contradictions, err := CheckReadmeFacts(ReadmePath, projectFacts)
+
+    return ReadmePath, contradictions, err
+}
 
 
-    log.Infof("Outputting to file: %v", config.EditFile)
+// CheckReadmeFacts warns about every claim in the README contradicting the
+// facts extracted from the project, and returns them
+func CheckReadmeFacts(path string, projectFacts *facts.Facts) ([]facts.Contradiction, error) {
+    readme, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read %v: %v", path, err)
+    }
+
+    contradictions := projectFacts.Check(string(readme))
+
+    for _, c := range contradictions {
+        log.Warnf("%v: %v", path, c)
+    }
+
+    if len(contradictions) > 0 {
+        log.Warnf("%v has %v claim(s) contradicting the project. Please review them", path, len(contradictions))
+    }
+
+    return contradictions, nil
+}
+
+
+// CreateFullReadme writes a new README from the readme prompt filled in with
+// data, once the whole answer is in
+func CreateFullReadme(path string, data prompts.Data) error {
+    readmePrompt, err := prompts.Render("readme", data)
+    if err != nil {
+        return err
+    }
+
     log.Info("Querying ai for output...")
-    readmeText, err := Query4_1Nano(readmePrompt)
+    readmeText, err := QueryStream("", readmePrompt, path)
     if err != nil {
-        return fmt.Errorf("failed to query 4.1 Nano: %v", err)
+        return fmt.Errorf("failed to query 4.1 Nano: %w", err)
     }
 
-    inputFile := config.EditFile
-    if inputFile == "" {
-        inputFile = "README.md"
+    if err := os.WriteFile(path, []byte(readmeText), 0644); err != nil {
+        return fmt.Errorf("failed to write %v: %v", path, err)
     }
 
-    ReadmePath := fmt.Sprintf("%v/%v", config.OutputDirectory, inputFile)
+    return nil
+}
+
+
+/**
+ * UpdateReadmeSections regenerates only the managed blocks
+ * (<!-- autoscribe:begin name --> ... <!-- autoscribe:end -->) and the
+ * sections named with -sections in an existing README. Everything else in the
+ * file is written back byte for byte, and nothing at all is written unless
+ * every section was answered.
+ */
+func UpdateReadmeSections(path string, existing []byte, data prompts.Data) error {
+    doc, err := markdown.Parse(existing)
+    if err != nil {
+        return fmt.Errorf("failed to parse %v: %v", path, err)
+    }
+
+    regions, err := doc.Regions(config.ReadmeSections)
+    if err != nil {
+        return fmt.Errorf("failed to find sections to update in %v: %v", path, err)
+    }
+
+    if len(regions) == 0 {
+        return fmt.Errorf("%v has no autoscribe managed sections and none were named with -sections, leaving it untouched: %w", path, types.ErrNothingToDo)
+    }
+
+    replacements := map[string]string{}
+    estimated := false
+
+    for _, region := range regions {
+        log.Infof("Regenerating section %v...", region.Name)
 
-    os.WriteFile(ReadmePath, []byte(readmeText), 0644)
+        data.Section = region.Name
+        data.Readme = string(existing)
+        data.Current = doc.Content(region)
+
+        sectionPrompt, err := prompts.Render("readme_section", data)
+        if err != nil {
+            return err
+        }
+
+        sectionText, err := QueryStream("", sectionPrompt, path)
+        if errors.Is(err, ErrEstimated) {
+            estimated = true
+            continue
+        } else if err != nil {
+            return fmt.Errorf("failed to query 4.1 Nano for section %v: %w", region.Name, err)
+        }
+
+        replacements[region.Name] = stripMarkdownFence(sectionText)
+    }
+
+    if estimated {
+        return ErrEstimated
+    }
+
+    if err := os.WriteFile(path, doc.Replace(regions, replacements), 0644); err != nil {
+        return fmt.Errorf("failed to write %v: %v", path, err)
+    }
 
     return nil
 }
 
+
+// stripMarkdownFence removes a ```markdown fence wrapped around the whole
+// response
+func stripMarkdownFence(text string) string {
+    trimmed := strings.TrimSpace(text)
+    if !strings.HasPrefix(trimmed, "```") || !strings.HasSuffix(trimmed, "```") {
+        return text
+    }
+
+    lines := strings.Split(trimmed, "\n")
+    if len(lines) < 2 {
+        return text
+    }
+
+    return strings.Join(lines[1:len(lines) - 1], "\n")
+}
@@ -0,0 +1,47 @@
+package calls
+
+import (
+    "sort"
+)
+
+// Helpers building the JSON Schemas of structured answers. Strict structured
+// output needs every property required and no others allowed, so optional
+// fields are left empty by the model instead of being left out.
+
+
+// Object is an object with exactly properties
+func Object(properties map[string]any) map[string]any {
+    required := []string{}
+    for name := range properties {
+        required = append(required, name)
+    }
+    // Sorted so the same schema is sent every time
+    sort.Strings(required)
+
+    return map[string]any{
+        "type":                 "object",
+        "properties":           properties,
+        "required":             required,
+        "additionalProperties": false,
+    }
+}
+
+
+// String is a string, described to the model unless description is empty
+func String(description string) map[string]any {
+    if description == "" {
+        return map[string]any{ "type": "string" }
+    }
+
+    return map[string]any{ "type": "string", "description": description }
+}
+
+
+func Bool(description string) map[string]any {
+    return map[string]any{ "type": "boolean", "description": description }
+}
+
+
+func Array(items map[string]any, description string) map[string]any {
+    return map[string]any{ "type": "array", "items": items, "description": description }
+}
@@ -0,0 +1,215 @@
+package calls
+
+import (
+    "io"
+    "os"
+    "fmt"
+    "time"
+    "slices"
+    "strings"
+    "path/filepath"
+
+    log "github.com/sirupsen/logrus"
+    "github.com/openai/openai-go/v2"
+    "github.com/openai/openai-go/v2/option"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/tokens"
+)
+
+
+// Providers which can send an answer as it's written. The others are asked
+// with QueryFile and answer all at once
+var streamingProviders = []string{ "openai" }
+
+// How often the progress line is logged when stderr isn't a terminal
+var progressInterval = 5 * time.Second
+
+
+/**
+ * QueryStream works like QueryFile, but for long answers: the answer is
+ * shown as it arrives, live on a terminal and as a progress line otherwise,
+ * and written as it arrives to a temporary file next to target. Nothing is
+ * written to target itself, that's left to the caller once the whole answer
+ * is in. When the run is interrupted the temporary file is kept, and its path
+ * is in the error.
+ */
+func QueryStream(file string, msg string, target string) (string, error) {
+    if ! slices.Contains(streamingProviders, config.Provider) {
+        return QueryFile(file, msg)
+    }
+
+    started := time.Now()
+
+    client := openai.NewClient(
+        option.WithAPIKey(config.OpenAIKey),
+    )
+
+    params, sent, err := request("", msg, nil)
+    if err != nil {
+        return "", err
+    }
+
+    if config.Estimate {
+        estimateUsage(file, sent, started)
+        return "", ErrEstimated
+    }
+
+    release, err := reserve(sent)
+    if err != nil {
+        return "", err
+    }
+    defer release()
+
+    partial, err := partialFile(target)
+    if err != nil {
+        return "", err
+    }
+
+    // Usage only comes in the last chunk
+    params.StreamOptions = openai.ChatCompletionStreamOptionsParam{ IncludeUsage: openai.Bool(true) }
+
+    stream := client.Chat.Completions.NewStreaming(Context, params)
+    defer stream.Close()
+
+    out := newProgress()
+    acc := openai.ChatCompletionAccumulator{}
+    var answer strings.Builder
+
+    for stream.Next() {
+        chunk := stream.Current()
+        acc.AddChunk(chunk)
+
+        if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+            continue
+        }
+
+        text := chunk.Choices[0].Delta.Content
+        answer.WriteString(text)
+        out.add(text)
+
+        if _, err := partial.WriteString(text); err != nil {
+            out.done()
+            partial.Close()
+            return "", fmt.Errorf("failed to write %v: %v", partial.Name(), err)
+        }
+    }
+    out.done()
+    partial.Close()
+
+    if err := stream.Err(); err != nil {
+        // The provider doesn't say what an unfinished answer used
+        recordUsage(Usage{
+            File:             file,
+            Model:            config.Model,
+            PromptTokens:     tokens.Count(sent),
+            CompletionTokens: tokens.Count(answer.String()),
+            LatencyMs:        time.Since(started).Milliseconds(),
+            Estimated:        true,
+        })
+
+        if answer.Len() == 0 {
+            os.Remove(partial.Name())
+            return "", providerError(err)
+        }
+
+        return "", fmt.Errorf("%w, the partial answer is in %v", providerError(err), partial.Name())
+    }
+
+    os.Remove(partial.Name())
+
+    recordUsage(Usage{
+        File:             file,
+        Model:            config.Model,
+        PromptTokens:     int(acc.Usage.PromptTokens),
+        CompletionTokens: int(acc.Usage.CompletionTokens),
+        LatencyMs:        time.Since(started).Milliseconds(),
+    })
+
+    if len(acc.Choices) == 0 {
+        return "", &ProviderError{ Err: fmt.Errorf("%v returned no choices", config.Model) }
+    }
+
+    if refusal := acc.Choices[0].Message.Refusal; refusal != "" {
+        return "", fmt.Errorf("%v refused to answer: %v", config.Model, refusal)
+    }
+
+    return answer.String(), nil
+}
+
+
+// partialFile creates the temporary file an answer for target is written to,
+// in the same directory so it's easy to find. Without a target it goes in the
+// system's temporary directory
+func partialFile(target string) (*os.File, error) {
+    dir, name := "", "autoscribe"
+    if target != "" {
+        dir, name = filepath.Dir(target), filepath.Base(target)
+    }
+
+    f, err := os.CreateTemp(dir, "." + name + ".*.partial")
+    if err != nil {
+        return nil, fmt.Errorf("failed to create a file for the answer: %v", err)
+    }
+
+    return f, nil
+}
+
+
+// progress shows an answer as it arrives
+type progress struct {
+    // Where the answer is written live, nil when stderr isn't a terminal
+    live     io.Writer
+    received int
+    last     time.Time
+    started  time.Time
+}
+
+
+func newProgress() *progress {
+    p := &progress{ started: time.Now(), last: time.Now() }
+
+    if isTerminal(os.Stderr) {
+        p.live = os.Stderr
+    } else {
+        log.Info("Receiving the answer...")
+    }
+
+    return p
+}
+
+
+func (p *progress) add(text string) {
+    p.received += tokens.Count(text)
+
+    if p.live != nil {
+        fmt.Fprint(p.live, text)
+        return
+    }
+
+    if time.Since(p.last) >= progressInterval {
+        p.last = time.Now()
+        log.Infof("Received about %v tokens in %.0fs...", p.received, time.Since(p.started).Seconds())
+    }
+}
+
+
+func (p *progress) done() {
+    if p.live != nil {
+        fmt.Fprintln(p.live)
+        return
+    }
+
+    log.Infof("Received about %v tokens in %.1fs", p.received, time.Since(p.started).Seconds())
+}
+
+
+// isTerminal is whether f is a terminal rather than a file or a pipe
+func isTerminal(f *os.File) bool {
+    info, err := f.Stat()
+    if err != nil {
+        return false
+    }
+
+    return info.Mode() & os.ModeCharDevice != 0
+}
@@ -0,0 +1,141 @@
+package calls
+
+import (
+    "os"
+    "fmt"
+    "time"
+    "errors"
+    "context"
+    "strings"
+    "testing"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+)
+
+
+// chunk is one server sent event of a streamed answer
+func chunk(content string) string {
+    return fmt.Sprintf(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-x","choices":[{"index":0,"delta":{"content":%q},"finish_reason":null}]}` + "\n\n", content)
+}
+
+
+// fakeProvider points the client at handler instead of the provider
+func fakeProvider(t *testing.T, handler http.HandlerFunc) {
+    t.Helper()
+
+    srv := httptest.NewServer(handler)
+    t.Cleanup(srv.Close)
+
+    t.Setenv("OPENAI_BASE_URL", srv.URL)
+
+    key, ctx := config.OpenAIKey, Context
+    t.Cleanup(func() { config.OpenAIKey, Context = key, ctx })
+    config.OpenAIKey = "sk-test"
+}
+
+
+// partials lists the answers being written next to target
+func partials(t *testing.T, target string) []string {
+    t.Helper()
+
+    found, err := filepath.Glob(filepath.Join(filepath.Dir(target), "." + filepath.Base(target) + ".*.partial"))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    return found
+}
+
+
+func TestQueryStream(t *testing.T) {
+    freshUsage(t)
+    target := filepath.Join(t.TempDir(), "README.md")
+
+    fakeProvider(t, func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/event-stream")
+        for _, text := range []string{ "# Tool\n", "Does things." } {
+            fmt.Fprint(w, chunk(text))
+            w.(http.Flusher).Flush()
+        }
+
+        // The answer is written as it arrives
+        if found := partials(t, target); len(found) != 1 {
+            t.Errorf("partial answers while streaming = %v, want one", found)
+        }
+
+        fmt.Fprint(w, `data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-x","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":5,"total_tokens":17}}` + "\n\n")
+        fmt.Fprint(w, "data: [DONE]\n\n")
+    })
+
+    answer, err := QueryStream("", "Write a README.", target)
+    if err != nil {
+        t.Fatalf("QueryStream() error = %v", err)
+    }
+    if answer != "# Tool\nDoes things." {
+        t.Errorf("QueryStream() = %q", answer)
+    }
+
+    if found := partials(t, target); len(found) != 0 {
+        t.Errorf("partial answers left = %v, want none", found)
+    }
+    if _, err := os.Stat(target); !os.IsNotExist(err) {
+        t.Errorf("QueryStream() wrote %v, which is left to the caller", target)
+    }
+
+    // A finished answer uses what the provider reports
+    if got := Summary().Total; got.PromptTokens != 12 || got.CompletionTokens != 5 || got.Estimated {
+        t.Errorf("Summary().Total = %+v, want 12 + 5 tokens reported", got)
+    }
+}
+
+
+func TestQueryStreamCanceled(t *testing.T) {
+    freshUsage(t)
+    target := filepath.Join(t.TempDir(), "README.md")
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    fakeProvider(t, func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/event-stream")
+        fmt.Fprint(w, chunk("# Half a README"))
+        w.(http.Flusher).Flush()
+
+        // Ctrl-C once the first part is written
+        for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+            if found := partials(t, target); len(found) == 1 {
+                if data, _ := os.ReadFile(found[0]); len(data) > 0 {
+                    break
+                }
+            }
+        }
+        cancel()
+
+        <-r.Context().Done()
+    })
+    Context = ctx
+
+    _, err := QueryStream("", "Write a README.", target)
+    if !errors.Is(err, context.Canceled) {
+        t.Fatalf("QueryStream() error = %v, want context.Canceled", err)
+    }
+
+    found := partials(t, target)
+    if len(found) != 1 {
+        t.Fatalf("partial answers kept = %v, want one", found)
+    }
+    if !strings.Contains(err.Error(), found[0]) {
+        t.Errorf("QueryStream() error = %v, want it to name %v", err, found[0])
+    }
+    if data, _ := os.ReadFile(found[0]); string(data) != "# Half a README" {
+        t.Errorf("partial answer = %q, want %q", data, "# Half a README")
+    }
+
+    // The provider never reported the usage, so it's counted locally
+    if got := Summary().Total; got.Calls != 1 || !got.Estimated {
+        t.Errorf("Summary().Total = %+v, want one estimated call", got)
+    }
+}
@@ -0,0 +1,306 @@
+package calls
+
+import (
+    "os"
+    "fmt"
+    "sort"
+    "sync"
+    "errors"
+    "strings"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "path/filepath"
+
+    log "github.com/sirupsen/logrus"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/files"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/types"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/tokens"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/prompts"
+)
+
+
+type DirectorySummary struct {
+    Directory string `json:"directory"`
+    Hash      string `json:"hash"`
+    Summary   string `json:"summary"`
+    // Version of the summary prompt it was written with
+    Prompt    string `json:"prompt,omitempty"`
+}
+
+
+type SummaryCache struct {
+    Path    string                      `json:"-"`
+    Entries map[string]DirectorySummary `json:"entries"`
+    mu      sync.Mutex
+}
+
+
+// ProjectContext is the code and build files of the project, formatted for
+// a prompt with ContextForFiles
+func ProjectContext() (types.ConcatenatedFileContents, error) {
+    paths, err := files.FilterForProjectFiles()
+    if err != nil {
+        return "", err
+    }
+
+    return ContextForFiles(paths)
+}
+
+
+// CodeContext is the code files of the project, formatted for a prompt with
+// ContextForFiles
+func CodeContext() (types.ConcatenatedFileContents, error) {
+    paths, err := files.FilterForCodeFiles(config.ProjectDirectory)
+    if err != nil {
+        return "", fmt.Errorf("failed to filter for code files in %v: %v", config.ProjectDirectory, err)
+    }
+
+    return ContextForFiles(paths)
+}
+
+
+/**
+ * ContextForFiles formats paths for a prompt. When the ranked files fit in
+ * the context budget they're sent as they are. Otherwise every directory is
+ * summarized separately (in parallel, reusing summaries of unchanged
+ * directories from earlier runs) and the prompt gets the key files (build
+ * files and entry points) plus those summaries.
+ */
+func ContextForFiles(paths []string) (types.ConcatenatedFileContents, error) {
+    ranked, err := files.RankFiles(paths)
+    if err != nil {
+        return "", err
+    }
+
+    budget := files.ContextBudget()
+
+    total := 0
+    for _, f := range ranked {
+        total += f.Tokens
+    }
+
+    if total <= budget {
+        return files.BuildContext(ranked, budget), nil
+    }
+
+    log.Infof("Project is ~%v tokens, over the %v token budget. Summarizing each directory first", total, budget)
+
+    summaries, err := SummarizeDirectories(ranked, budget)
+    if err != nil {
+        return "", fmt.Errorf("failed to summarize directories: %w", err)
+    }
+
+    key := []files.ContextFile{}
+    for _, f := range ranked {
+        if f.Tier == files.TierBuild || f.Tier == files.TierEntryPoint {
+            key = append(key, f)
+        }
+    }
+
+    // Key files get at most half the budget, the summaries the rest
+    data := files.BuildContext(key, budget / 2)
+    remaining := budget - tokens.Count(string(data))
+
+    var b strings.Builder
+    b.WriteString("Directory summaries:\n\n")
+    used := tokens.Count(b.String())
+
+    for i, s := range summaries {
+        entry := fmt.Sprintf("Directory:\n%v\nSummary:\n%v\n\n", s.Directory, strings.TrimSpace(s.Summary))
+        cost := tokens.Count(entry)
+        if used + cost > remaining {
+            log.Warnf("Context budget reached, %v directory summaries left out", len(summaries) - i)
+            break
+        }
+        b.WriteString(entry)
+        used += cost
+    }
+
+    return data + types.ConcatenatedFileContents(b.String()), nil
+}
+
+
+/**
+ * SummarizeDirectories asks the model for a summary of each directory's
+ * files, config.Parallelism at a time. Summaries are cached by a hash of the
+ * files' paths and contents, so only changed directories are sent again.
+ */
+func SummarizeDirectories(ranked []files.ContextFile, budget int) ([]DirectorySummary, error) {
+    byDir := map[string][]files.ContextFile{}
+    for _, f := range ranked {
+        dir := filepath.Dir(f.Path)
+        byDir[dir] = append(byDir[dir], f)
+    }
+
+    dirs := []string{}
+    for dir := range byDir {
+        dirs = append(dirs, dir)
+    }
+    sort.Strings(dirs)
+
+    cache, err := LoadSummaryCache()
+    if err != nil {
+        return nil, err
+    }
+
+    // Part of the hash, so changing the prompt regenerates the summaries
+    version, err := prompts.Version("summary")
+    if err != nil {
+        return nil, err
+    }
+
+    summaries := make([]DirectorySummary, len(dirs))
+    errs := make([]error, len(dirs))
+
+    workers := config.Parallelism
+    if workers < 1 {
+        workers = 1
+    }
+    sem := make(chan struct{}, workers)
+    var wg sync.WaitGroup
+
+    for i, dir := range dirs {
+        hash := hashFiles(byDir[dir], version)
+
+        if cached, ok := cache.Get(dir); ok && cached.Hash == hash {
+            log.Debugf("Reusing summary of %v", dir)
+            summaries[i] = cached
+            continue
+        }
+
+        wg.Add(1)
+        go func(i int, dir string, hash string) {
+            defer wg.Done()
+            sem <- struct{}{}
+            defer func() { <-sem }()
+
+            log.Infof("Summarizing %v...", dir)
+
+            prompt, err := prompts.Render("summary", prompts.Data{
+                Directory: dir,
+                Files:     string(files.BuildContext(byDir[dir], budget)),
+            })
+            if err != nil {
+                errs[i] = err
+                return
+            }
+
+            summary, err := QueryFile(dir, prompt)
+            if errors.Is(err, ErrEstimated) {
+                // Not cached, and enough for the prompt using it to be estimated too
+                summaries[i] = DirectorySummary{ Directory: dir, Summary: "(summary not written, estimating)" }
+                return
+            } else if err != nil {
+                errs[i] = fmt.Errorf("failed to summarize %v: %w", dir, err)
+                return
+            }
+
+            summaries[i] = DirectorySummary{ Directory: dir, Hash: hash, Summary: summary, Prompt: version }
+            cache.Put(summaries[i])
+        }(i, dir, hash)
+    }
+
+    wg.Wait()
+
+    // Keep whatever was summarized, even if some directories failed
+    if err := cache.Save(); err != nil {
+        log.Warnf("Failed to save summaries: %v", err)
+    }
+
+    for _, err := range errs {
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    return summaries, nil
+}
+
+
+func hashFiles(group []files.ContextFile, version string) string {
+    sorted := append([]files.ContextFile{}, group...)
+    sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+    h := sha256.New()
+    fmt.Fprintf(h, "prompt:%v\nmodel:%v\n", version, config.Model)
+
+    for _, f := range sorted {
+        content := sha256.Sum256([]byte(f.Contents))
+        fmt.Fprintf(h, "%v %x\n", f.Path, content)
+    }
+
+    return hex.EncodeToString(h.Sum(nil))
+}
+
+
+// SummaryCachePath is where the summaries of the current project are kept,
+// in config.ProjectCacheDirectory
+func SummaryCachePath() (string, error) {
+    dir, err := config.ProjectCacheDirectory()
+    if err != nil {
+        return "", err
+    }
+
+    return filepath.Join(dir, "summaries.json"), nil
+}
+
+
+func LoadSummaryCache() (*SummaryCache, error) {
+    path, err := SummaryCachePath()
+    if err != nil {
+        return nil, err
+    }
+
+    cache := &SummaryCache{ Path: path, Entries: map[string]DirectorySummary{} }
+
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return cache, nil
+    } else if err != nil {
+        return nil, fmt.Errorf("failed to read summary cache %v: %v", path, err)
+    }
+
+    if err := json.Unmarshal(data, cache); err != nil {
+        log.Warnf("Ignoring unreadable summary cache %v: %v", path, err)
+        cache.Entries = map[string]DirectorySummary{}
+    }
+
+    return cache, nil
+}
+
+
+func (c *SummaryCache) Get(dir string) (DirectorySummary, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    s, ok := c.Entries[dir]
+    return s, ok
+}
+
+
+func (c *SummaryCache) Put(s DirectorySummary) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    c.Entries[s.Directory] = s
+}
+
+
+func (c *SummaryCache) Save() error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if err := os.MkdirAll(filepath.Dir(c.Path), 0755); err != nil {
+        return fmt.Errorf("failed to create %v: %v", filepath.Dir(c.Path), err)
+    }
+
+    data, err := json.MarshalIndent(c, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to encode summaries: %v", err)
+    }
+
+    return os.WriteFile(c.Path, data, 0644)
+}
@@ -0,0 +1,104 @@
+package calls
+
+import (
+    "fmt"
+    "sync"
+    "slices"
+    "testing"
+    "net/http"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/files"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+)
+
+
+func TestHashFiles(t *testing.T) {
+    defer func(model string) { config.Model = model }(config.Model)
+    config.Model = "gpt-x"
+
+    group := []files.ContextFile{ { Path: "a/x.go", Contents: "package a" }, { Path: "a/y.go", Contents: "package a\n" } }
+    base := hashFiles(group, "summary@1")
+
+    tests := []struct {
+        name    string
+        group   []files.ContextFile
+        version string
+        model   string
+        same    bool
+    }{
+        { name: "same files", group: group, version: "summary@1", model: "gpt-x", same: true },
+        { name: "other order", group: []files.ContextFile{ group[1], group[0] }, version: "summary@1", model: "gpt-x", same: true },
+        { name: "changed prompt", group: group, version: "summary@2", model: "gpt-x" },
+        { name: "changed model", group: group, version: "summary@1", model: "gpt-y" },
+        { name: "changed file", group: []files.ContextFile{ group[0], { Path: "a/y.go", Contents: "package b\n" } }, version: "summary@1", model: "gpt-x" },
+        { name: "renamed file", group: []files.ContextFile{ group[0], { Path: "a/z.go", Contents: "package a\n" } }, version: "summary@1", model: "gpt-x" },
+        { name: "new file", group: append(slices.Clone(group), files.ContextFile{ Path: "a/z.go" }), version: "summary@1", model: "gpt-x" },
+    }
+
+    for _, tt := range tests {
+        config.Model = tt.model
+        if got := hashFiles(tt.group, tt.version); (got == base) != tt.same {
+            t.Errorf("hashFiles() with %v = %v, want same as before %v", tt.name, got, tt.same)
+        }
+    }
+}
+
+
+func TestSummarizeDirectoriesCache(t *testing.T) {
+    freshUsage(t)
+
+    defer func(cache, project string, parallelism int) {
+        config.CacheDirectory, config.ProjectDirectory, config.Parallelism = cache, project, parallelism
+    }(config.CacheDirectory, config.ProjectDirectory, config.Parallelism)
+    config.CacheDirectory, config.ProjectDirectory, config.Parallelism = t.TempDir(), t.TempDir(), 2
+
+    var mu sync.Mutex
+    asked := 0
+    fakeProvider(t, func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock()
+        asked++
+        n := asked
+        mu.Unlock()
+
+        w.Header().Set("Content-Type", "application/json")
+        fmt.Fprintf(w, `{"id":"1","object":"chat.completion","created":0,"model":"gpt-x","choices":[{"index":0,"message":{"role":"assistant","content":"summary %v"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12}}`, n)
+    })
+
+    ranked := []files.ContextFile{
+        { Path: "a/x.go", Contents: "package a" },
+        { Path: "b/y.go", Contents: "package b" },
+    }
+
+    summarize := func() []DirectorySummary {
+        t.Helper()
+        summaries, err := SummarizeDirectories(ranked, 1000)
+        if err != nil {
+            t.Fatalf("SummarizeDirectories() error = %v", err)
+        }
+        return summaries
+    }
+
+    first := summarize()
+    if asked != 2 || len(first) != 2 {
+        t.Fatalf("first run asked %v time(s) for %v, want 2 summaries", asked, first)
+    }
+
+    // Nothing changed, so the cache saved by the first run answers
+    if second := summarize(); asked != 2 || !slices.Equal(second, first) {
+        t.Errorf("second run asked %v time(s) for %v, want the cached %v", asked, second, first)
+    }
+
+    // Only the directory with the changed file is summarized again
+    ranked[1].Contents = "package b\n\nfunc B() {}"
+    third := summarize()
+    if asked != 3 || third[0] != first[0] || third[1].Summary != "summary 3" {
+        t.Errorf("after a change asked %v time(s) for %v, want b summarized again", asked, third)
+    }
+
+    // As does every directory when the model changes
+    config.Model = "gpt-y"
+    summarize()
+    if asked != 5 {
+        t.Errorf("after changing the model asked %v time(s), want 5", asked)
+    }
+}
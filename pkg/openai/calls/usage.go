@@ -0,0 +1,236 @@
+package calls
+
+import (
+    "fmt"
+    "sync"
+    "time"
+    "errors"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/tokens"
+)
+
+
+// ErrBudget is returned instead of making a call which could take the run
+// over -max-cost or -max-tokens
+var ErrBudget = errors.New("budget exceeded")
+
+// ErrEstimated is returned by every query with -estimate, once the cost of
+// the prompt has been recorded
+var ErrEstimated = errors.New("estimated only, nothing was sent")
+
+
+// Usage is one call to the model
+type Usage struct {
+    Command          string  `json:"command,omitempty"`
+    File             string  `json:"file,omitempty"`
+    Model            string  `json:"model"`
+    PromptTokens     int     `json:"prompt_tokens"`
+    CompletionTokens int     `json:"completion_tokens"`
+    LatencyMs        int64   `json:"latency_ms"`
+    Cost             float64 `json:"cost"`
+    // Whether Cost is known, i.e. the model has a price
+    Priced           bool    `json:"priced"`
+    // Counted locally with -estimate rather than reported by the provider
+    Estimated        bool    `json:"estimated,omitempty"`
+}
+
+
+// Totals adds up a group of calls
+type Totals struct {
+    Name             string  `json:"name,omitempty"`
+    Calls            int     `json:"calls"`
+    PromptTokens     int     `json:"prompt_tokens"`
+    CompletionTokens int     `json:"completion_tokens"`
+    LatencyMs        int64   `json:"latency_ms"`
+    Cost             float64 `json:"cost"`
+    // Some calls were to models without a price, so Cost is too low
+    Unpriced         bool    `json:"unpriced,omitempty"`
+    Estimated        bool    `json:"estimated,omitempty"`
+}
+
+
+// UsageSummary is what a run used, overall, per command and per file
+type UsageSummary struct {
+    Total    Totals   `json:"total"`
+    Commands []Totals `json:"commands,omitempty"`
+    Files    []Totals `json:"files,omitempty"`
+}
+
+
+var usageMu sync.Mutex
+var usage = []Usage{}
+// Tokens and dollars set aside for calls in flight, so parallel calls can't
+// go over the budget together
+var reservedTokens = 0
+var reservedCost = 0.0
+
+// Command the calls being made belong to, for the summary
+var CurrentCommand = ""
+
+
+func price(model string) (config.Price, bool) {
+    p, ok := config.Prices[model]
+    return p, ok
+}
+
+
+func cost(model string, promptTokens int, completionTokens int) (float64, bool) {
+    p, ok := price(model)
+    if !ok {
+        return 0, false
+    }
+
+    return (float64(promptTokens) * p.INPUT + float64(completionTokens) * p.OUTPUT) / 1e6, true
+}
+
+
+// expectedCompletion guesses the length of the answer to a prompt before
+// it's sent: a quarter of the prompt, within reason
+func expectedCompletion(promptTokens int) int {
+    return min(max(promptTokens / 4, 100), 2000)
+}
+
+
+/**
+ * reserve sets aside what a call with msg is expected to use, or returns
+ * ErrBudget when that could take the run over -max-cost or -max-tokens. The
+ * returned release must be called once the call is recorded.
+ */
+func reserve(msg string) (func(), error) {
+    promptTokens := tokens.Count(msg)
+    expected := promptTokens + expectedCompletion(promptTokens)
+    expectedCost, priced := cost(config.Model, promptTokens, expectedCompletion(promptTokens))
+
+    usageMu.Lock()
+    defer usageMu.Unlock()
+
+    total := totals(usage, "")
+
+    if config.MaxTokens > 0 && total.PromptTokens + total.CompletionTokens + reservedTokens + expected > config.MaxTokens {
+        return nil, fmt.Errorf("the next prompt needs about %v tokens and %v of the %v allowed are used: %w",
+            expected, total.PromptTokens + total.CompletionTokens + reservedTokens, config.MaxTokens, ErrBudget)
+    }
+
+    if config.MaxCost > 0 {
+        if !priced {
+            return nil, fmt.Errorf("can't keep to -max-cost, %v has no price. Add it to PRICES in the config: %w", config.Model, ErrBudget)
+        }
+        if total.Cost + reservedCost + expectedCost > config.MaxCost {
+            return nil, fmt.Errorf("the next prompt costs about $%.4f and $%.4f of the $%.4f allowed is spent: %w",
+                expectedCost, total.Cost + reservedCost, config.MaxCost, ErrBudget)
+        }
+    }
+
+    reservedTokens += expected
+    reservedCost += expectedCost
+
+    return func() {
+        usageMu.Lock()
+        defer usageMu.Unlock()
+        reservedTokens -= expected
+        reservedCost -= expectedCost
+    }, nil
+}
+
+
+func recordUsage(u Usage) {
+    u.Command = CurrentCommand
+    u.Cost, u.Priced = cost(u.Model, u.PromptTokens, u.CompletionTokens)
+
+    usageMu.Lock()
+    defer usageMu.Unlock()
+
+    usage = append(usage, u)
+}
+
+
+// estimateUsage records what sending msg is expected to use, with -estimate
+func estimateUsage(file string, msg string, started time.Time) {
+    promptTokens := tokens.Count(msg)
+
+    recordUsage(Usage{
+        File:             file,
+        Model:            config.Model,
+        PromptTokens:     promptTokens,
+        CompletionTokens: expectedCompletion(promptTokens),
+        LatencyMs:        time.Since(started).Milliseconds(),
+        Estimated:        true,
+    })
+}
+
+
+func totals(calls []Usage, name string) Totals {
+    t := Totals{ Name: name }
+
+    for _, u := range calls {
+        t.Calls++
+        t.PromptTokens += u.PromptTokens
+        t.CompletionTokens += u.CompletionTokens
+        t.LatencyMs += u.LatencyMs
+        t.Cost += u.Cost
+        t.Unpriced = t.Unpriced || !u.Priced
+        t.Estimated = t.Estimated || u.Estimated
+    }
+
+    return t
+}
+
+
+// group adds up the calls by key, in the order each key was first seen
+func group(calls []Usage, key func(Usage) string) []Totals {
+    names := []string{}
+    byName := map[string][]Usage{}
+
+    for _, u := range calls {
+        name := key(u)
+        if _, ok := byName[name]; !ok {
+            names = append(names, name)
+        }
+        byName[name] = append(byName[name], u)
+    }
+
+    out := []Totals{}
+    for _, name := range names {
+        out = append(out, totals(byName[name], name))
+    }
+
+    return out
+}
+
+
+// Summary adds up every call made so far. Calls without a file count towards
+// the project
+func Summary() UsageSummary {
+    usageMu.Lock()
+    defer usageMu.Unlock()
+
+    return UsageSummary{
+        Total:    totals(usage, ""),
+        Commands: group(usage, func(u Usage) string { return u.Command }),
+        Files:    group(usage, func(u Usage) string {
+            if u.File == "" {
+                return "(project)"
+            }
+            return u.File
+        }),
+    }
+}
+
+
+// String describes t on one line
+func (t Totals) String() string {
+    spent := fmt.Sprintf("$%.4f", t.Cost)
+    if t.Unpriced {
+        spent += " plus calls to models without a price"
+    }
+
+    s := fmt.Sprintf("%v call(s), %v prompt + %v completion tokens, %v, %.1fs",
+        t.Calls, t.PromptTokens, t.CompletionTokens, spent, float64(t.LatencyMs) / 1000)
+
+    if t.Estimated {
+        s = "about " + s
+    }
+
+    return s
+}
@@ -0,0 +1,231 @@
+package calls
+
+import (
+    "errors"
+    "testing"
+    "strings"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/tokens"
+)
+
+
+// freshUsage empties the calls recorded and puts back the config the usage
+// is worked out from once the test is done
+func freshUsage(t *testing.T) {
+    t.Helper()
+
+    saved := struct {
+        model, command   string
+        prices           map[string]config.Price
+        maxCost          float64
+        maxTokens        int
+        estimate         bool
+    }{ config.Model, CurrentCommand, config.Prices, config.MaxCost, config.MaxTokens, config.Estimate }
+
+    t.Cleanup(func() {
+        config.Model, CurrentCommand, config.Prices = saved.model, saved.command, saved.prices
+        config.MaxCost, config.MaxTokens, config.Estimate = saved.maxCost, saved.maxTokens, saved.estimate
+        usage, reservedTokens, reservedCost = []Usage{}, 0, 0
+    })
+
+    usage, reservedTokens, reservedCost = []Usage{}, 0, 0
+    config.MaxCost, config.MaxTokens, config.Estimate = 0, 0, false
+    // A dollar a token keeps the sums whole
+    config.Model = "gpt-x"
+    config.Prices = map[string]config.Price{ "gpt-x": { INPUT: 1e6, OUTPUT: 1e6 } }
+}
+
+
+func TestReserve(t *testing.T) {
+    msg := "Summarise what this project does, in a paragraph."
+    promptTokens := tokens.Count(msg)
+    expected := promptTokens + expectedCompletion(promptTokens)
+
+    tests := []struct {
+        name      string
+        model     string
+        maxTokens int
+        maxCost   float64
+        wantErr   bool
+    }{
+        { name: "no caps" },
+        { name: "under the token cap", maxTokens: 10 + expected + 1 },
+        { name: "at the token cap", maxTokens: 10 + expected },
+        { name: "over the token cap", maxTokens: 10 + expected - 1, wantErr: true },
+        { name: "under the cost cap", maxCost: float64(10 + expected + 1) },
+        { name: "at the cost cap", maxCost: float64(10 + expected) },
+        { name: "over the cost cap", maxCost: float64(10 + expected - 1), wantErr: true },
+        // Tokens are counted whether or not the model has a price
+        { name: "tokens only", model: "unpriced", maxTokens: 10 + expected },
+        { name: "cost only", model: "unpriced", maxCost: 1000, wantErr: true },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            freshUsage(t)
+            if tt.model != "" {
+                config.Model = tt.model
+            }
+            config.MaxTokens, config.MaxCost = tt.maxTokens, tt.maxCost
+
+            // 10 tokens, and so $10, already used
+            usage = []Usage{ { Model: "gpt-x", PromptTokens: 6, CompletionTokens: 4, Cost: 10, Priced: true } }
+
+            release, err := reserve(msg)
+            if (err != nil) != tt.wantErr {
+                t.Fatalf("reserve() error = %v, want error %v", err, tt.wantErr)
+            }
+            if err != nil {
+                if !errors.Is(err, ErrBudget) {
+                    t.Errorf("reserve() error = %v, want ErrBudget", err)
+                }
+                return
+            }
+
+            release()
+            if reservedTokens != 0 || reservedCost != 0 {
+                t.Errorf("release() left %v tokens and $%v reserved", reservedTokens, reservedCost)
+            }
+        })
+    }
+}
+
+
+// Calls in flight count against the cap together
+func TestReserveInFlight(t *testing.T) {
+    freshUsage(t)
+
+    msg := "Summarise what this project does, in a paragraph."
+    promptTokens := tokens.Count(msg)
+    config.MaxTokens = 2 * (promptTokens + expectedCompletion(promptTokens))
+
+    first, err := reserve(msg)
+    if err != nil {
+        t.Fatalf("reserve() error = %v", err)
+    }
+    second, err := reserve(msg)
+    if err != nil {
+        t.Fatalf("reserve() error = %v", err)
+    }
+
+    if _, err := reserve(msg); !errors.Is(err, ErrBudget) {
+        t.Errorf("reserve() with two calls in flight error = %v, want ErrBudget", err)
+    }
+
+    first()
+    third, err := reserve(msg)
+    if err != nil {
+        t.Errorf("reserve() after a release error = %v", err)
+    } else {
+        third()
+    }
+    second()
+}
+
+
+func TestCost(t *testing.T) {
+    freshUsage(t)
+    config.Prices = map[string]config.Price{ "gpt-4o": config.DefaultPrices["gpt-4o"], "tuned": { INPUT: 0.30, OUTPUT: 1.20 } }
+
+    tests := []struct {
+        model  string
+        want   float64
+        priced bool
+    }{
+        { model: "gpt-4o", want: 2.50 + 10.00 / 2, priced: true },
+        { model: "tuned", want: 0.30 + 1.20 / 2, priced: true },
+        { model: "gpt-unknown", want: 0, priced: false },
+    }
+
+    for _, tt := range tests {
+        got, priced := cost(tt.model, 1000000, 500000)
+        if priced != tt.priced || got < tt.want - 1e-9 || got > tt.want + 1e-9 {
+            t.Errorf("cost(%v) = %v, %v, want %v, %v", tt.model, got, priced, tt.want, tt.priced)
+        }
+    }
+}
+
+
+func TestSummary(t *testing.T) {
+    freshUsage(t)
+
+    CurrentCommand = "readme"
+    recordUsage(Usage{ Model: "gpt-x", PromptTokens: 10, CompletionTokens: 5, LatencyMs: 100 })
+    recordUsage(Usage{ File: "a.go", Model: "gpt-x", PromptTokens: 20, CompletionTokens: 5, LatencyMs: 200 })
+    CurrentCommand = "docs"
+    recordUsage(Usage{ File: "a.go", Model: "unpriced", PromptTokens: 30, CompletionTokens: 10, LatencyMs: 300 })
+    recordUsage(Usage{ File: "b.go", Model: "gpt-x", PromptTokens: 1, CompletionTokens: 1, LatencyMs: 400 })
+
+    got := Summary()
+
+    want := Totals{ Calls: 4, PromptTokens: 61, CompletionTokens: 21, LatencyMs: 1000, Cost: 15 + 25 + 2, Unpriced: true }
+    if got.Total != want {
+        t.Errorf("Summary().Total = %+v, want %+v", got.Total, want)
+    }
+
+    commands := []Totals{
+        { Name: "readme", Calls: 2, PromptTokens: 30, CompletionTokens: 10, LatencyMs: 300, Cost: 40 },
+        { Name: "docs", Calls: 2, PromptTokens: 31, CompletionTokens: 11, LatencyMs: 700, Cost: 2, Unpriced: true },
+    }
+    if len(got.Commands) != len(commands) {
+        t.Fatalf("Summary().Commands = %+v, want %+v", got.Commands, commands)
+    }
+    for i := range commands {
+        if got.Commands[i] != commands[i] {
+            t.Errorf("Summary().Commands[%v] = %+v, want %+v", i, got.Commands[i], commands[i])
+        }
+    }
+
+    // Calls about the whole project are grouped under (project)
+    files := []Totals{
+        { Name: "(project)", Calls: 1, PromptTokens: 10, CompletionTokens: 5, LatencyMs: 100, Cost: 15 },
+        { Name: "a.go", Calls: 2, PromptTokens: 50, CompletionTokens: 15, LatencyMs: 500, Cost: 25, Unpriced: true },
+        { Name: "b.go", Calls: 1, PromptTokens: 1, CompletionTokens: 1, LatencyMs: 400, Cost: 2 },
+    }
+    if len(got.Files) != len(files) {
+        t.Fatalf("Summary().Files = %+v, want %+v", got.Files, files)
+    }
+    for i := range files {
+        if got.Files[i] != files[i] {
+            t.Errorf("Summary().Files[%v] = %+v, want %+v", i, got.Files[i], files[i])
+        }
+    }
+}
+
+
+// With -estimate the prompt is counted but never sent, so there's no client
+// or key needed
+func TestQueryEstimate(t *testing.T) {
+    freshUsage(t)
+    config.Estimate = true
+    CurrentCommand = "readme"
+
+    msg := "Summarise what this project does, in a paragraph."
+    if _, err := QueryFile("a.go", msg); !errors.Is(err, ErrEstimated) {
+        t.Fatalf("QueryFile() error = %v, want ErrEstimated", err)
+    }
+
+    got := Summary().Total
+    promptTokens := tokens.Count(msg)
+    if got.Calls != 1 || !got.Estimated || got.PromptTokens != promptTokens || got.CompletionTokens != expectedCompletion(promptTokens) {
+        t.Errorf("Summary().Total = %+v, want one estimated call of %v prompt tokens", got, promptTokens)
+    }
+    if s := got.String(); !strings.HasPrefix(s, "about ") {
+        t.Errorf("Totals.String() = %q, want it marked as an estimate", s)
+    }
+}
+
+
+// A call over the cap is stopped before it's sent
+func TestQueryBudget(t *testing.T) {
+    freshUsage(t)
+    config.MaxTokens = 1
+
+    if _, err := QueryFile("a.go", "Summarise what this project does."); !errors.Is(err, ErrBudget) {
+        t.Errorf("QueryFile() error = %v, want ErrBudget", err)
+    }
+    if got := Summary().Total.Calls; got != 0 {
+        t.Errorf("Summary().Total.Calls = %v, want 0", got)
+    }
+}
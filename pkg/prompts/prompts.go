@@ -0,0 +1,315 @@
+package prompts
+
+import (
+    "os"
+    "fmt"
+    "sort"
+    "sync"
+    "embed"
+    "regexp"
+    "slices"
+    "strings"
+    "crypto/sha256"
+    "encoding/hex"
+    "path/filepath"
+    "text/template"
+
+    log "github.com/sirupsen/logrus"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+)
+
+
+// The built in prompts, one text/template per file named after the prompt
+//go:embed templates/*.tmpl
+var builtin embed.FS
+
+// Where a prompt came from, see Prompt.Source
+const SourceBuiltin = "built in"
+
+
+/**
+ * Data is what a prompt is filled in with. Each prompt only uses the fields
+ * that apply to it, the rest are left empty. A template naming a field Data
+ * doesn't have fails when it's loaded.
+ */
+type Data struct {
+    // Name of the language the code or project is written in
+    Language    string
+    // Source being documented, or the file a help menu is added to
+    Code        string
+    // Kind of declaration being documented, like function or method
+    Kind        string
+    // The declarations the code calls, with their documentation
+    Callees     []Callee
+    // Names of the types and globals the code relies on
+    Uses        []string
+    // Facts read from the project, formatted as the FACTS block
+    Facts       string
+    // Documentation style of the language, or its DOC_STYLE replacement
+    Style       string
+    // Rules for the languages of the project
+    Guidelines  string
+    // The project files, formatted with their headers
+    Files       string
+    // Directory being summarized
+    Directory   string
+    // Program a man page is written for, with its options, the paths it
+    // reads and its environment variables
+    Program     string
+    Options     []string
+    Paths       []string
+    Environment []string
+    // File a help menu is added to
+    EditFile    string
+    // README section being rewritten, the whole README and the section as it is
+    Section     string
+    Readme      string
+    Current     string
+}
+
+
+// Callee is a declaration called by the code being documented
+type Callee struct {
+    Name string
+    Doc  string
+}
+
+
+// Prompt is one of the prompts in use
+type Prompt struct {
+    Name    string `json:"name"`
+    Version string `json:"version"`
+    // SourceBuiltin, the file it was read from, or the config layer setting
+    // it in PROMPTS
+    Source  string `json:"source"`
+    Text    string `json:"text,omitempty"`
+}
+
+
+var mu sync.Mutex
+var loaded map[string]*Prompt
+var set *template.Template
+var used = []string{}
+
+
+var funcs = template.FuncMap{
+    "join": strings.Join,
+    "list": func(items []string) string {
+        if len(items) == 0 {
+            return "(none)"
+        }
+        return "- " + strings.Join(items, "\n- ")
+    },
+}
+
+// Calls to other templates, which a prompt's version has to cover too
+var templateCall = regexp.MustCompile(`{{-?\s*template\s+"([^"]+)"`)
+
+
+// UserDirectory holds the user's replacements for the built in prompts,
+// ~/.config/autoscribe/prompts on Linux
+func UserDirectory() string {
+    if file := config.UserConfigFile(); file != "" {
+        return filepath.Join(filepath.Dir(file), "prompts")
+    }
+
+    return ""
+}
+
+
+// ProjectDirectory holds the project's replacements for the built in prompts
+func ProjectDirectory() string {
+    return filepath.Join(config.ProjectDirectory, ".autoscribe", "prompts")
+}
+
+
+// Names lists the built in prompts
+func Names() []string {
+    entries, err := builtin.ReadDir("templates")
+    if err != nil {
+        log.Fatalf("Failed to list the built in prompts: %v", err)
+    }
+
+    names := []string{}
+    for _, e := range entries {
+        names = append(names, strings.TrimSuffix(e.Name(), ".tmpl"))
+    }
+    sort.Strings(names)
+
+    return names
+}
+
+
+/**
+ * load reads every prompt, each replacing the one before it: the built in
+ * one, <name>.tmpl in UserDirectory, <name>.tmpl in ProjectDirectory and
+ * finally PROMPTS in the config. Every template is checked against Data, so
+ * a mistake in a replacement shows up before anything is sent.
+ */
+func load() error {
+    if loaded != nil {
+        return nil
+    }
+
+    prompts := map[string]*Prompt{}
+
+    for _, name := range Names() {
+        text, err := builtin.ReadFile("templates/" + name + ".tmpl")
+        if err != nil {
+            return fmt.Errorf("failed to read the built in %v prompt: %v", name, err)
+        }
+        prompts[name] = &Prompt{ Name: name, Source: SourceBuiltin, Text: string(text) }
+    }
+
+    for _, dir := range []string{ UserDirectory(), ProjectDirectory() } {
+        if dir == "" {
+            continue
+        }
+
+        for name, p := range prompts {
+            path := filepath.Join(dir, name + ".tmpl")
+            text, err := os.ReadFile(path)
+            if os.IsNotExist(err) {
+                continue
+            } else if err != nil {
+                return fmt.Errorf("failed to read prompt %v: %v", path, err)
+            }
+            log.Debugf("Using the %v prompt from %v", name, path)
+            p.Text, p.Source = string(text), path
+        }
+    }
+
+    for name, text := range config.PromptOverrides {
+        p, ok := prompts[name]
+        if !ok {
+            return fmt.Errorf("unknown prompt %v in PROMPTS, use one of %v", name, strings.Join(Names(), ", "))
+        }
+        p.Text, p.Source = text, config.Sources["PROMPTS"]
+    }
+
+    t := template.New("").Funcs(funcs)
+    for name, p := range prompts {
+        // Files end with a newline the prompt shouldn't
+        p.Text = strings.TrimSuffix(p.Text, "\n")
+        if _, err := t.New(name).Parse(p.Text); err != nil {
+            return fmt.Errorf("bad %v prompt from %v: %v", name, p.Source, err)
+        }
+    }
+
+    for name, p := range prompts {
+        if err := t.ExecuteTemplate(&strings.Builder{}, name, Data{}); err != nil {
+            return fmt.Errorf("bad %v prompt from %v: %v", name, p.Source, err)
+        }
+        p.Version = version(prompts, name)
+    }
+
+    loaded, set = prompts, t
+
+    return nil
+}
+
+
+// version is a short hash of the text of a prompt and the prompts it calls,
+// so it changes whenever what's sent does
+func version(prompts map[string]*Prompt, name string) string {
+    h := sha256.New()
+
+    seen := []string{}
+    var add func(name string)
+    add = func(name string) {
+        p, ok := prompts[name]
+        if !ok || slices.Contains(seen, name) {
+            return
+        }
+        seen = append(seen, name)
+        fmt.Fprintf(h, "%v\n%v\n", name, p.Text)
+
+        for _, call := range templateCall.FindAllStringSubmatch(p.Text, -1) {
+            add(call[1])
+        }
+    }
+    add(name)
+
+    return name + "@" + hex.EncodeToString(h.Sum(nil))[:8]
+}
+
+
+// Render fills in the prompt called name with data
+func Render(name string, data Data) (string, error) {
+    mu.Lock()
+    defer mu.Unlock()
+
+    if err := load(); err != nil {
+        return "", err
+    }
+
+    p, ok := loaded[name]
+    if !ok {
+        return "", fmt.Errorf("no prompt called %v", name)
+    }
+
+    var b strings.Builder
+    if err := set.ExecuteTemplate(&b, name, data); err != nil {
+        return "", fmt.Errorf("failed to fill in the %v prompt: %v", name, err)
+    }
+
+    if ! slices.Contains(used, name) {
+        log.Debugf("Using prompt %v", p.Version)
+        used = append(used, name)
+    }
+
+    return b.String(), nil
+}
+
+
+// Version is the version of the prompt called name in use
+func Version(name string) (string, error) {
+    mu.Lock()
+    defer mu.Unlock()
+
+    if err := load(); err != nil {
+        return "", err
+    }
+
+    p, ok := loaded[name]
+    if !ok {
+        return "", fmt.Errorf("no prompt called %v", name)
+    }
+
+    return p.Version, nil
+}
+
+
+// All lists every prompt in use, with its text
+func All() ([]Prompt, error) {
+    mu.Lock()
+    defer mu.Unlock()
+
+    if err := load(); err != nil {
+        return nil, err
+    }
+
+    out := []Prompt{}
+    for _, name := range Names() {
+        out = append(out, *loaded[name])
+    }
+
+    return out, nil
+}
+
+
+// Used lists the prompts filled in this run, without their text
+func Used() []Prompt {
+    mu.Lock()
+    defer mu.Unlock()
+
+    out := []Prompt{}
+    for _, name := range used {
+        p := *loaded[name]
+        p.Text = ""
+        out = append(out, p)
+    }
+
+    return out
+}
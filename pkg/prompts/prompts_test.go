@@ -0,0 +1,178 @@
+package prompts
+
+import (
+    "os"
+    "strings"
+    "testing"
+    "path/filepath"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+)
+
+
+// fresh makes the prompts load again, with no replacements but the ones the
+// test sets up
+func fresh(t *testing.T) {
+    t.Helper()
+
+    overrides, project, sources := config.PromptOverrides, config.ProjectDirectory, config.Sources
+    t.Cleanup(func() {
+        config.PromptOverrides, config.ProjectDirectory, config.Sources = overrides, project, sources
+        loaded, set, used = nil, nil, []string{}
+    })
+
+    t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+    t.Setenv("HOME", t.TempDir())
+    config.PromptOverrides, config.ProjectDirectory, config.Sources = map[string]string{}, t.TempDir(), map[string]string{}
+    loaded, set, used = nil, nil, []string{}
+}
+
+
+// full sets every field, each to text naming it
+var full = Data{
+    Language:    "LANGUAGE",
+    Code:        "CODE",
+    Kind:        "KIND",
+    Callees:     []Callee{ { Name: "CALLEE", Doc: "CALLEE_DOC" } },
+    Uses:        []string{ "USES" },
+    Facts:       "FACTS",
+    Style:       "STYLE",
+    Guidelines:  "GUIDELINES",
+    Files:       "FILES",
+    Directory:   "DIRECTORY",
+    Program:     "PROGRAM",
+    Options:     []string{ "OPTIONS" },
+    Paths:       []string{ "PATHS" },
+    Environment: []string{ "ENVIRONMENT" },
+    EditFile:    "EDIT_FILE",
+    Section:     "SECTION",
+    Readme:      "README",
+    Current:     "CURRENT",
+}
+
+
+func TestRenderBuiltin(t *testing.T) {
+    fresh(t)
+
+    for _, name := range Names() {
+        for _, data := range []Data{ {}, full } {
+            got, err := Render(name, data)
+            if err != nil {
+                t.Errorf("Render(%v) error = %v", name, err)
+                continue
+            }
+            if strings.TrimSpace(got) == "" || strings.Contains(got, "<no value>") {
+                t.Errorf("Render(%v) = %q", name, got)
+            }
+        }
+    }
+}
+
+
+// Each prompt names the things it's about
+func TestRenderFields(t *testing.T) {
+    fresh(t)
+
+    tests := []struct {
+        name string
+        want []string
+    }{
+        { name: "summary", want: []string{ "DIRECTORY", "FILES" } },
+        { name: "document", want: []string{ "CODE", "CALLEE", "USES" } },
+        { name: "readme_section", want: []string{ "SECTION", "CURRENT" } },
+        { name: "man", want: []string{ "PROGRAM", "OPTIONS", "PATHS", "ENVIRONMENT" } },
+    }
+
+    for _, tt := range tests {
+        got, err := Render(tt.name, full)
+        if err != nil {
+            t.Fatalf("Render(%v) error = %v", tt.name, err)
+        }
+        for _, want := range tt.want {
+            if !strings.Contains(got, want) {
+                t.Errorf("Render(%v) = %q, want %v in it", tt.name, got, want)
+            }
+        }
+    }
+}
+
+
+func TestOverride(t *testing.T) {
+    fresh(t)
+
+    builtinSummary, _ := Version("summary")
+    builtinReadme, _ := Version("readme")
+
+    // A prompt file in the project replaces the built in prompt, and PROMPTS
+    // in the config replaces that
+    dir := ProjectDirectory()
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        t.Fatal(err)
+    }
+    for name, text := range map[string]string{ "summary": "File {{.Directory}}\n", "readme_guidelines": "- Be brief." } {
+        if err := os.WriteFile(filepath.Join(dir, name + ".tmpl"), []byte(text), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    loaded = nil
+
+    if got, _ := Render("summary", Data{ Directory: "pkg" }); got != "File pkg" {
+        t.Errorf("Render(summary) with a prompt file = %q, want %q", got, "File pkg")
+    }
+
+    config.PromptOverrides = map[string]string{ "summary": "Config {{.Directory}}" }
+    config.Sources["PROMPTS"] = "a.yaml"
+    loaded = nil
+
+    if got, _ := Render("summary", Data{ Directory: "pkg" }); got != "Config pkg" {
+        t.Errorf("Render(summary) with PROMPTS = %q, want %q", got, "Config pkg")
+    }
+
+    all, err := All()
+    if err != nil {
+        t.Fatalf("All() error = %v", err)
+    }
+    for _, p := range all {
+        switch p.Name {
+        case "summary":
+            if p.Source != "a.yaml" || p.Version == builtinSummary {
+                t.Errorf("summary = %v from %v, want a new version from a.yaml", p.Version, p.Source)
+            }
+        case "readme":
+            // Its guidelines were replaced
+            if p.Source != SourceBuiltin || p.Version == builtinReadme {
+                t.Errorf("readme = %v from %v, want a new version of the built in prompt", p.Version, p.Source)
+            }
+        case "readme_guidelines":
+            if p.Source != filepath.Join(dir, "readme_guidelines.tmpl") {
+                t.Errorf("readme_guidelines from %v, want the project's file", p.Source)
+            }
+        default:
+            if p.Source != SourceBuiltin {
+                t.Errorf("%v from %v, want the built in prompt", p.Name, p.Source)
+            }
+        }
+    }
+}
+
+
+func TestOverrideErrors(t *testing.T) {
+    tests := []struct {
+        name      string
+        overrides map[string]string
+        want      string
+    }{
+        { name: "unknown prompt", overrides: map[string]string{ "intro": "x" }, want: "unknown prompt intro" },
+        { name: "bad template", overrides: map[string]string{ "summary": "{{.Directory" }, want: "bad summary prompt" },
+        { name: "unknown field", overrides: map[string]string{ "summary": "{{.Folder}}" }, want: "bad summary prompt" },
+    }
+
+    for _, tt := range tests {
+        fresh(t)
+        config.PromptOverrides = tt.overrides
+
+        if _, err := Render("summary", Data{}); err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("Render() with %v error = %v, want %v", tt.name, err, tt.want)
+        }
+    }
+}
@@ -0,0 +1,279 @@
+package redact
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "sync"
+    "regexp"
+    "slices"
+    "strings"
+    "unicode"
+
+    log "github.com/sirupsen/logrus"
+
+    "github.com/BlankCanvasStudio/AutoScribe/pkg/config"
+)
+
+
+/**
+ * Detector finds one kind of secret or personal data. When Pattern has a
+ * capture group only the group is redacted, so `password = "..."` keeps its
+ * name. Check, when set, must also accept a match, for detectors a regex
+ * alone is too loose for.
+ */
+type Detector struct {
+    Name    string
+    Pattern *regexp.Regexp
+    Check   func(match string) bool
+}
+
+
+// Detectors are the built in ones, in the order they run. The names are
+// listed in config.RedactDetectors for REDACT_SKIP
+var Detectors = []Detector{
+    {
+        Name:    "private-key",
+        Pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY(?: BLOCK)?-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY(?: BLOCK)?-----`),
+    },
+    {
+        Name:    "aws-access-key",
+        Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA)[0-9A-Z]{16}\b`),
+    },
+    {
+        Name:    "aws-secret-key",
+        Pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`),
+    },
+    {
+        Name:    "api-token",
+        Pattern: regexp.MustCompile(`\b(?:sk-(?:proj-)?[A-Za-z0-9_-]{20,}|gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,}|xox[abprs]-[A-Za-z0-9-]{10,}|glpat-[A-Za-z0-9_-]{20,}|AIza[0-9A-Za-z_-]{35})`),
+    },
+    {
+        Name:    "jwt",
+        Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`),
+    },
+    {
+        Name:    "email",
+        Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}\b`),
+        Check:   func(match string) bool {
+            // Examples in docs and tests aren't anyone's address
+            domain := strings.ToLower(match[strings.LastIndex(match, "@") + 1:])
+            return ! slices.Contains([]string{ "example.com", "example.org", "example.net" }, domain)
+        },
+    },
+    {
+        Name:    "high-entropy",
+        Pattern: regexp.MustCompile(`[A-Za-z0-9+/_-]{24,}={0,2}`),
+        Check:   looksRandom,
+    },
+}
+
+
+// Finding is text replaced in the prompts sent to the model
+type Finding struct {
+    Detector    string `json:"detector"`
+    Placeholder string `json:"placeholder"`
+    // The project file the text was in, when the prompt says
+    File        string `json:"file,omitempty"`
+    Count       int    `json:"count"`
+}
+
+
+var mu sync.Mutex
+// The placeholder of each redacted value, so the same value always gets the
+// same one and the model can still tell values apart
+var placeholders = map[string]string{}
+var counts = map[string]int{}
+var findings = []*Finding{}
+
+
+// Headers of the project files in a prompt, see files.formatEntry
+var fileHeader = regexp.MustCompile(`(?m)^File:\n([^\n(]+?)(?: \(.*\))?\n`)
+
+
+/**
+ * Prompt replaces secrets and personal data in a prompt with placeholders
+ * like [redacted email 1], and records what it replaced for Findings. Text
+ * under a project file header is reported against that file.
+ */
+func Prompt(prompt string) (string, error) {
+    detectors, err := active()
+    if err != nil {
+        return "", err
+    }
+
+    headers := fileHeader.FindAllStringSubmatchIndex(prompt, -1)
+    if len(headers) == 0 {
+        return redactText(prompt, "", detectors), nil
+    }
+
+    var b strings.Builder
+    b.WriteString(redactText(prompt[:headers[0][0]], "", detectors))
+
+    for i, h := range headers {
+        end := len(prompt)
+        if i + 1 < len(headers) {
+            end = headers[i + 1][0]
+        }
+        file := prompt[h[2]:h[3]]
+        b.WriteString(prompt[h[0]:h[1]])
+        b.WriteString(redactText(prompt[h[1]:end], file, detectors))
+    }
+
+    return b.String(), nil
+}
+
+
+// active lists the built in detectors not skipped, then the configured ones
+func active() ([]Detector, error) {
+    detectors := []Detector{}
+    for _, d := range Detectors {
+        if ! slices.Contains(config.RedactSkip, d.Name) {
+            detectors = append(detectors, d)
+        }
+    }
+
+    names := []string{}
+    for name := range config.RedactPatterns {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    for _, name := range names {
+        pattern, err := regexp.Compile(config.RedactPatterns[name])
+        if err != nil {
+            return nil, fmt.Errorf("bad REDACT_PATTERNS %v: %v", name, err)
+        }
+        detectors = append(detectors, Detector{ Name: name, Pattern: pattern })
+    }
+
+    return detectors, nil
+}
+
+
+func redactText(text string, file string, detectors []Detector) string {
+    for _, d := range detectors {
+        text = d.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+            // ReplaceAllStringFunc doesn't give the groups, so match again
+            secret := match
+            if groups := d.Pattern.FindStringSubmatch(match); len(groups) > 1 && groups[1] != "" {
+                secret = groups[1]
+            }
+
+            if d.Check != nil && !d.Check(secret) {
+                return match
+            }
+
+            return strings.Replace(match, secret, placeholder(d.Name, secret, file), 1)
+        })
+    }
+
+    return text
+}
+
+
+func placeholder(detector string, secret string, file string) string {
+    mu.Lock()
+    defer mu.Unlock()
+
+    p, ok := placeholders[secret]
+    if !ok {
+        counts[detector]++
+        // Spaces keep the other detectors from matching a placeholder
+        p = fmt.Sprintf("[redacted %v %v]", detector, counts[detector])
+        placeholders[secret] = p
+        log.Debugf("Redacted a match of %v in %v as %v", detector, orPrompt(file), p)
+    }
+
+    for _, f := range findings {
+        if f.Placeholder == p && f.File == file {
+            f.Count++
+            return p
+        }
+    }
+    findings = append(findings, &Finding{ Detector: detector, Placeholder: p, File: file, Count: 1 })
+
+    return p
+}
+
+
+func orPrompt(file string) string {
+    if file == "" {
+        return "the prompt"
+    }
+
+    return file
+}
+
+
+// Findings lists everything redacted this run
+func Findings() []Finding {
+    mu.Lock()
+    defer mu.Unlock()
+
+    out := []Finding{}
+    for _, f := range findings {
+        out = append(out, *f)
+    }
+
+    return out
+}
+
+
+// String describes f without the redacted text
+func (f Finding) String() string {
+    return fmt.Sprintf("%v in %v, sent as %v (%v times)", f.Detector, orPrompt(f.File), f.Placeholder, f.Count)
+}
+
+
+/**
+ * looksRandom reports whether s is likely a generated token rather than an
+ * identifier, path or hex digest: it must mix letters and digits and have
+ * close to the Shannon entropy of random text.
+ */
+func looksRandom(s string) bool {
+    s = strings.TrimRight(s, "=")
+
+    letters, digits, upper, lower := 0, 0, 0, 0
+    freq := map[rune]int{}
+    for _, r := range s {
+        freq[r]++
+        switch {
+        case unicode.IsDigit(r):
+            digits++
+        case unicode.IsUpper(r):
+            letters++
+            upper++
+        case unicode.IsLower(r):
+            letters++
+            lower++
+        }
+    }
+
+    if letters == 0 || digits == 0 {
+        return false
+    }
+
+    // Paths and snake case identifiers
+    if strings.Count(s, "/") > 2 || strings.Count(s, "_") > 2 {
+        return false
+    }
+
+    // Hex digests, like commit hashes and checksums, are random but public
+    if strings.Trim(strings.ToLower(s), "0123456789abcdef") == "" {
+        return false
+    }
+
+    entropy := 0.0
+    for _, n := range freq {
+        p := float64(n) / float64(len(s))
+        entropy -= p * math.Log2(p)
+    }
+
+    // Single case text has at most 5 bits per character
+    if upper == 0 || lower == 0 {
+        return entropy > 3.5 && digits * 5 > len(s)
+    }
+
+    return entropy > 4.0
+}
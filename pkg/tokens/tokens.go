@@ -0,0 +1,82 @@
+package tokens
+
+import (
+    "regexp"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+
+// Pre-tokenisation close to the one used by OpenAI's cl100k/o200k encodings
+// (Go's regexp has no lookahead, so trailing whitespace is grouped slightly
+// differently). Each piece is then costed by pieceCost.
+var pieces = regexp.MustCompile(`'(?:[sdmtSDMT]|ll|ve|re|LL|VE|RE)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+
+// Context windows, in tokens, of the models we know about
+var ContextWindows = map[string]int{
+    "gpt-4.1":      1047576,
+    "gpt-4.1-mini": 1047576,
+    "gpt-4.1-nano": 1047576,
+    "gpt-4o":       128000,
+    "gpt-4o-mini":  128000,
+    "o3":           200000,
+    "o4-mini":      200000,
+}
+
+const defaultContextWindow = 128000
+
+
+/**
+ * Count approximates the number of tokens text takes up without calling out
+ * to a provider. There's no BPE table behind it, so it's an estimate: within
+ * about 10% of cl100k on prose and code, see tokens_test.go. Everything
+ * counted with it, the context budget, -max-tokens and -estimate, is
+ * approximate too. For a model it misjudges, set its budget in
+ * CONTEXT_BUDGETS.
+ */
+func Count(text string) int {
+    n := 0
+
+    for _, p := range pieces.FindAllString(text, -1) {
+        n += pieceCost(p)
+    }
+
+    return n
+}
+
+
+// pieceCost is how many tokens one pre-tokenised piece is likely to take:
+// words up to 6 bytes, with their leading space, are usually a single token
+// and longer ones split about every 4 bytes. Runs of digits are at most 3
+// long, so always one token, and punctuation merges in twos and threes.
+func pieceCost(p string) int {
+    last, _ := utf8.DecodeLastRuneInString(p)
+
+    switch {
+    case strings.TrimSpace(p) == "":
+        return (len(p) + 15) / 16
+    case unicode.IsLetter(last) && len(p) <= 6:
+        return 1
+    case unicode.IsLetter(last):
+        return (len(p) + 3) / 4
+    case unicode.IsNumber(last):
+        return 1
+    }
+
+    return (len(strings.TrimRight(p, "\r\n")) + 2) / 3
+}
+
+
+// DefaultBudget is how many tokens of project files we send to model by
+// default: 60% of its context window, leaving room for the instructions and
+// the response
+func DefaultBudget(model string) int {
+    window, ok := ContextWindows[model]
+    if !ok {
+        window = defaultContextWindow
+    }
+
+    return window * 6 / 10
+}
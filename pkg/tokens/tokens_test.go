@@ -0,0 +1,66 @@
+package tokens
+
+import (
+    "testing"
+)
+
+
+// Counts from OpenAI's cl100k_base encoding
+var known = []struct {
+    text string
+    want int
+}{
+    { "hello world", 2 },
+    { "tiktoken is great!", 6 },
+    { "The quick brown fox jumps over the lazy dog.", 10 },
+    { "1234567890", 4 },
+    { "func main() {", 4 },
+    { "    return nil\n", 4 },
+    { "Hello, world!", 4 },
+}
+
+
+func TestCountKnownStrings(t *testing.T) {
+    got, want := 0, 0
+
+    for _, k := range known {
+        n := Count(k.text)
+        got += n
+        want += k.want
+
+        // Each string on its own may be off by a token or a quarter
+        diff := n - k.want
+        if diff < 0 {
+            diff = -diff
+        }
+        if diff > 1 && diff * 4 > k.want {
+            t.Errorf("Count(%q) = %v, cl100k says %v", k.text, n, k.want)
+        }
+    }
+
+    // Together they should be within 10%
+    diff := got - want
+    if diff < 0 {
+        diff = -diff
+    }
+    if diff * 10 > want {
+        t.Errorf("Count() = %v in total, cl100k says %v", got, want)
+    }
+}
+
+
+func TestCountEmpty(t *testing.T) {
+    if n := Count(""); n != 0 {
+        t.Errorf("Count(\"\") = %v, want 0", n)
+    }
+}
+
+
+func TestDefaultBudget(t *testing.T) {
+    if got := DefaultBudget("gpt-4o"); got != 76800 {
+        t.Errorf("DefaultBudget(gpt-4o) = %v, want 76800", got)
+    }
+    if got := DefaultBudget("unknown-model"); got != defaultContextWindow * 6 / 10 {
+        t.Errorf("DefaultBudget(unknown-model) = %v, want the default window's budget", got)
+    }
+}
@@ -0,0 +1,13 @@
+package types
+
+import (
+    "errors"
+)
+
+
+// ErrNothingToDo is returned when a command had no work to do, e.g. every
+// declaration in a file is already documented
+var ErrNothingToDo = errors.New("nothing to do")
+
+// ErrInvalid is returned when generated or existing output fails a check
+var ErrInvalid = errors.New("validation failed")
@@ -0,0 +1,320 @@
+package types
+
+import (
+    "io"
+    "os"
+    "bytes"
+    "regexp"
+    "strings"
+    "encoding/json"
+    "path/filepath"
+)
+
+
+/**
+ * Language describes how to recognize the files of one language: by
+ * extension, by file name (Makefile, Dockerfile.dev), by the interpreter in
+ * a shebang, and as a last resort by sniffing the start of the contents.
+ * A language with a Parent is also selected when its parent is, so -l sh
+ * picks up bash scripts too.
+ */
+type Language struct {
+    Format       SupportedFormat
+    Name         string
+    Parent       SupportedFormat
+    Extensions   []string
+    Filenames    []string
+    Interpreters []string
+    Sniff        *regexp.Regexp
+}
+
+
+// How much of a file is read to check its shebang and sniff its contents
+const sniffLength = 4096
+
+
+// Languages is the registry, in the order they're detected and listed in
+var Languages = []*Language{
+    {
+        Format:     Golang,
+        Name:       "Go",
+        Extensions: []string{ "go" },
+        Sniff:      regexp.MustCompile(`(?m)^package [A-Za-z_]\w*\s*$`),
+    },
+    {
+        Format:       Shell,
+        Name:         "Shell",
+        Extensions:   []string{ "sh", "ksh", "zsh" },
+        Interpreters: []string{ "sh", "dash", "ash", "ksh", "mksh", "zsh" },
+        Sniff:        regexp.MustCompile(`(?m)^\s*(?:set -[euxo]+|export [A-Za-z_]\w*=|if \[\[? |[A-Za-z_]\w*\(\) *\{)`),
+    },
+    {
+        Format:       Bash,
+        Name:         "Bash",
+        Parent:       Shell,
+        Extensions:   []string{ "bash" },
+        Interpreters: []string{ "bash" },
+    },
+    {
+        Format:       Python,
+        Name:         "Python",
+        Extensions:   []string{ "py", "pyi", "pyw" },
+        Interpreters: []string{ "python", "python2", "python3", "pypy", "pypy3" },
+        Sniff:        regexp.MustCompile(`(?m)^(?:from [\w.]+ import |import [\w.]+\s*$|def \w+\(.*\)\s*(?:->.*)?:\s*$|if __name__ == )`),
+    },
+    {
+        Format:       JavaScript,
+        Name:         "JavaScript",
+        Extensions:   []string{ "js", "mjs", "cjs", "jsx" },
+        Interpreters: []string{ "node", "nodejs" },
+    },
+    {
+        Format:       TypeScript,
+        Name:         "TypeScript",
+        Extensions:   []string{ "ts", "mts", "cts", "tsx" },
+        Interpreters: []string{ "ts-node", "tsx", "deno", "bun" },
+    },
+    {
+        Format:     Rust,
+        Name:       "Rust",
+        Extensions: []string{ "rs" },
+    },
+    {
+        Format:     C,
+        Name:       "C",
+        Extensions: []string{ "c", "h" },
+    },
+    {
+        Format:     Cpp,
+        Name:       "C++",
+        Extensions: []string{ "cpp", "cc", "cxx", "c++", "hpp", "hh", "hxx", "h++" },
+    },
+    {
+        Format:     Makefile,
+        Name:       "Makefile",
+        Extensions: []string{ "mk", "mak" },
+        Filenames:  []string{ "Makefile", "makefile", "GNUmakefile", "Makefile.*" },
+        Interpreters: []string{ "make" },
+    },
+    {
+        Format:     Dockerfile,
+        Name:       "Dockerfile",
+        Extensions: []string{ "dockerfile" },
+        Filenames:  []string{ "Dockerfile", "Dockerfile.*", "Containerfile", "Containerfile.*" },
+    },
+    {
+        Format:     Yaml,
+        Name:       "YAML",
+        Extensions: []string{ "yaml", "yml" },
+    },
+    {
+        Format:     Json,
+        Name:       "JSON",
+        Extensions: []string{ "json" },
+    },
+    {
+        Format:     Toml,
+        Name:       "TOML",
+        Extensions: []string{ "toml" },
+    },
+    {
+        Format:     MarkDown,
+        Name:       "Markdown",
+        Extensions: []string{ "md", "markdown" },
+    },
+    {
+        Format:     Text,
+        Name:       "Text",
+        Extensions: []string{ "txt" },
+    },
+}
+
+
+// LookupLanguage finds a language by its format or any of its extensions,
+// e.g. both "yaml" and "yml" find YAML
+func LookupLanguage(name string) (*Language, bool) {
+    name = strings.ToLower(strings.TrimPrefix(name, "."))
+
+    for _, lang := range Languages {
+        if string(lang.Format) == name {
+            return lang, true
+        }
+    }
+
+    for _, lang := range Languages {
+        for _, ext := range lang.Extensions {
+            if ext == name {
+                return lang, true
+            }
+        }
+    }
+
+    return nil, false
+}
+
+
+// Is reports whether the language is format or one of its dialects
+func (l *Language) Is(format SupportedFormat) bool {
+    return l.Format == format || (l.Parent != "" && l.Parent == format)
+}
+
+
+/**
+ * DetectLanguage works out which language the file at path is written in.
+ * File names are checked first, then the shebang (so a .sh file run by bash
+ * is Bash), then the extension, and finally the contents are sniffed. Files
+ * that look binary are never detected.
+ */
+func DetectLanguage(path string) (*Language, bool) {
+    base := filepath.Base(path)
+
+    for _, lang := range Languages {
+        for _, pattern := range lang.Filenames {
+            if matched, _ := filepath.Match(pattern, base); matched {
+                return lang, true
+            }
+        }
+    }
+
+    byExtension := languageForExtension(filepath.Ext(base))
+
+    // Only scripts need their contents read. Everything else is known by
+    // its extension
+    if byExtension != nil && len(byExtension.Interpreters) == 0 {
+        return byExtension, true
+    }
+
+    head, err := readHead(path)
+    if err != nil || bytes.IndexByte(head, 0) >= 0 {
+        return byExtension, byExtension != nil
+    }
+
+    if interpreter, _, ok := ParseShebang(firstLine(head)); ok {
+        if lang := languageForInterpreter(interpreter); lang != nil {
+            return lang, true
+        }
+    }
+
+    if byExtension != nil {
+        return byExtension, true
+    }
+
+    if filepath.Ext(base) != "" {
+        return nil, false
+    }
+
+    return SniffLanguage(head)
+}
+
+
+// SniffLanguage guesses the language of a file without an extension or a
+// shebang from its first few KB
+func SniffLanguage(head []byte) (*Language, bool) {
+    trimmed := bytes.TrimSpace(head)
+
+    if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+        lang, _ := LookupLanguage(string(Json))
+        return lang, true
+    }
+
+    for _, lang := range Languages {
+        if lang.Sniff != nil && lang.Sniff.Match(head) {
+            return lang, true
+        }
+    }
+
+    return nil, false
+}
+
+
+/**
+ * ParseShebang splits a "#!" line into the interpreter's name and its
+ * arguments. "#!/usr/bin/env -S bash -e" and "#!/bin/bash -e" both give
+ * bash with ["-e"]. Versioned names like python3.11 keep their version.
+ */
+func ParseShebang(line string) (string, []string, bool) {
+    line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+    if !strings.HasPrefix(line, "#!") {
+        return "", nil, false
+    }
+
+    fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+    if len(fields) == 0 {
+        return "", nil, false
+    }
+
+    interpreter := filepath.Base(fields[0])
+    args := fields[1:]
+
+    if interpreter == "env" {
+        // Skip env's own options and variable assignments
+        for len(args) > 0 && (strings.HasPrefix(args[0], "-") || strings.Contains(args[0], "=")) {
+            args = args[1:]
+        }
+        if len(args) == 0 {
+            return "", nil, false
+        }
+        interpreter = filepath.Base(args[0])
+        args = args[1:]
+    }
+
+    return interpreter, args, true
+}
+
+
+func languageForExtension(ext string) *Language {
+    ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+    if ext == "" {
+        return nil
+    }
+
+    for _, lang := range Languages {
+        for _, e := range lang.Extensions {
+            if e == ext {
+                return lang
+            }
+        }
+    }
+
+    return nil
+}
+
+
+func languageForInterpreter(interpreter string) *Language {
+    for _, lang := range Languages {
+        for _, name := range lang.Interpreters {
+            // python3.11 is run by python3
+            if interpreter == name || strings.HasPrefix(interpreter, name + ".") {
+                return lang
+            }
+        }
+    }
+
+    return nil
+}
+
+
+func readHead(path string) ([]byte, error) {
+    fd, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer fd.Close()
+
+    head := make([]byte, sniffLength)
+    n, err := io.ReadFull(fd, head)
+    if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+        return nil, err
+    }
+
+    return head[:n], nil
+}
+
+
+func firstLine(head []byte) string {
+    if i := bytes.IndexByte(head, '\n'); i >= 0 {
+        return string(head[:i])
+    }
+
+    return string(head)
+}
@@ -0,0 +1,134 @@
+package types
+
+import (
+    "os"
+    "slices"
+    "testing"
+    "path/filepath"
+)
+
+
+func TestParseShebang(t *testing.T) {
+    tests := []struct {
+        line        string
+        interpreter string
+        args        []string
+        ok          bool
+    }{
+        { line: "#!/bin/bash", interpreter: "bash", args: []string{}, ok: true },
+        { line: "#!/bin/bash -e", interpreter: "bash", args: []string{ "-e" }, ok: true },
+        { line: "#! /bin/sh\r", interpreter: "sh", args: []string{}, ok: true },
+        { line: "#!/usr/bin/env python3", interpreter: "python3", args: []string{}, ok: true },
+        { line: "#!/usr/bin/env -S bash -e", interpreter: "bash", args: []string{ "-e" }, ok: true },
+        { line: "#!/usr/bin/env LANG=C node --harmony", interpreter: "node", args: []string{ "--harmony" }, ok: true },
+        { line: "#!/usr/local/bin/python3.11", interpreter: "python3.11", args: []string{}, ok: true },
+        { line: "#!/usr/bin/env", ok: false },
+        { line: "#!", ok: false },
+        { line: "# just a comment", ok: false },
+        { line: "echo hi", ok: false },
+    }
+
+    for _, tt := range tests {
+        interpreter, args, ok := ParseShebang(tt.line)
+        if ok != tt.ok {
+            t.Errorf("ParseShebang(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+            continue
+        }
+        if !ok {
+            continue
+        }
+        if interpreter != tt.interpreter || !slices.Equal(args, tt.args) {
+            t.Errorf("ParseShebang(%q) = %v %v, want %v %v", tt.line, interpreter, args, tt.interpreter, tt.args)
+        }
+    }
+}
+
+
+func TestDetectLanguage(t *testing.T) {
+    tests := []struct {
+        name    string
+        content string
+        want    SupportedFormat
+        ok      bool
+    }{
+        { name: "main.go", content: "package main\n", want: Golang, ok: true },
+        { name: "lib.PY", content: "", want: Python, ok: true },
+        { name: "config.yml", content: "a: 1\n", want: Yaml, ok: true },
+        // File names win over everything else
+        { name: "Makefile", content: "all:\n", want: Makefile, ok: true },
+        { name: "Dockerfile.dev", content: "FROM scratch\n", want: Dockerfile, ok: true },
+        // The shebang wins over a script's extension
+        { name: "install.sh", content: "#!/bin/bash\necho hi\n", want: Bash, ok: true },
+        { name: "install.sh", content: "echo hi\n", want: Shell, ok: true },
+        { name: "tool", content: "#!/usr/bin/env python3.11\nprint(1)\n", want: Python, ok: true },
+        { name: "serve", content: "#!/usr/bin/env node\n", want: JavaScript, ok: true },
+        // Without either, the contents are sniffed
+        { name: "build", content: "set -eu\nmake\n", want: Shell, ok: true },
+        { name: "manage", content: "import os\n\nif __name__ == '__main__':\n    pass\n", want: Python, ok: true },
+        { name: "settings", content: "{ \"a\": [1, 2] }\n", want: Json, ok: true },
+        { name: "gen", content: "// Code generated\npackage gen\n", want: Golang, ok: true },
+        { name: "notes", content: "remember the milk\n", ok: false },
+        // Unknown extensions aren't sniffed, and binaries are never detected
+        { name: "data.bin", content: "package main\n", ok: false },
+        { name: "blob", content: "#!/bin/sh\n\x00\x01", ok: false },
+    }
+
+    dir := t.TempDir()
+    for i, tt := range tests {
+        sub := filepath.Join(dir, string(rune('a' + i)))
+        if err := os.Mkdir(sub, 0755); err != nil {
+            t.Fatal(err)
+        }
+        path := filepath.Join(sub, tt.name)
+        if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+            t.Fatal(err)
+        }
+
+        lang, ok := DetectLanguage(path)
+        if ok != tt.ok {
+            t.Errorf("DetectLanguage(%v with %q) ok = %v, want %v", tt.name, tt.content, ok, tt.ok)
+            continue
+        }
+        if ok && lang.Format != tt.want {
+            t.Errorf("DetectLanguage(%v with %q) = %v, want %v", tt.name, tt.content, lang.Format, tt.want)
+        }
+    }
+}
+
+
+func TestLookupLanguage(t *testing.T) {
+    tests := []struct {
+        name string
+        want SupportedFormat
+    }{
+        { "go", Golang },
+        { ".yml", Yaml },
+        { "hpp", Cpp },
+        { "make", Makefile },
+        { "TSX", TypeScript },
+    }
+
+    for _, tt := range tests {
+        lang, ok := LookupLanguage(tt.name)
+        if !ok || lang.Format != tt.want {
+            t.Errorf("LookupLanguage(%q) = %v, %v, want %v", tt.name, lang, ok, tt.want)
+        }
+    }
+
+    if _, ok := LookupLanguage("cobol"); ok {
+        t.Error("LookupLanguage(cobol) found a language")
+    }
+}
+
+
+func TestLanguageIs(t *testing.T) {
+    bash, _ := LookupLanguage("bash")
+    if !bash.Is(Bash) || !bash.Is(Shell) || bash.Is(Python) {
+        t.Errorf("Bash.Is() doesn't follow its parent")
+    }
+
+    sh, _ := LookupLanguage("sh")
+    if sh.Is(Bash) {
+        t.Errorf("Shell.Is(Bash) = true, only dialects match their parent")
+    }
+}
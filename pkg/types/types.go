@@ -1,74 +1,104 @@
 package types
 
 import (
-    "os"
-    "bufio"
+    "fmt"
     "slices"
     "strings"
-    "path/filepath"
 
     // log "github.com/sirupsen/logrus"
 )
 
 type ConcatenatedFileContents string
 
+// SupportedFormat names a language in the registry, see Languages
 type SupportedFormat string
 const (
-    Shell    SupportedFormat  = "sh"
-    Golang   SupportedFormat = "go"
-    Bash     SupportedFormat   = "sh"
-    Text     SupportedFormat   = "txt"
-    MarkDown SupportedFormat   = "md"
+    Shell      SupportedFormat = "sh"
+    Golang     SupportedFormat = "go"
+    Bash       SupportedFormat = "bash"
+    Text       SupportedFormat = "txt"
+    MarkDown   SupportedFormat = "md"
+    Python     SupportedFormat = "py"
+    JavaScript SupportedFormat = "js"
+    TypeScript SupportedFormat = "ts"
+    Rust       SupportedFormat = "rs"
+    C          SupportedFormat = "c"
+    Cpp        SupportedFormat = "cpp"
+    Makefile   SupportedFormat = "make"
+    Dockerfile SupportedFormat = "docker"
+    Yaml       SupportedFormat = "yaml"
+    Json       SupportedFormat = "json"
+    Toml       SupportedFormat = "toml"
 )
 
-var SupportedFormats = []SupportedFormat {
-    Shell,
-    Golang,
-    Bash,
-    Text,
-    MarkDown,
-};
+var SupportedFormats = func() []SupportedFormat {
+    formats := []SupportedFormat{}
+    for _, lang := range Languages {
+        formats = append(formats, lang.Format)
+    }
+    return formats
+}()
+
+// Name is how the language is referred to in prompts
+func (f SupportedFormat) Name() string {
+    if lang, ok := LookupLanguage(string(f)); ok {
+        return lang.Name
+    }
+
+    return string(f)
+}
+
 
+// IsSupportedFormat accepts a language's format or any of its extensions
 func IsSupportedFormat(val string) (bool) {
-    return slices.Contains(SupportedFormats, SupportedFormat(val))
+    _, ok := LookupLanguage(val)
+    return ok
 }
 
-func (f SupportedFormat) FileIsThisFormat(path string) (string, bool) {
-    ext := filepath.Ext(path)
-
-    // If there's no file extension, verify it with the shebang
-    if len(ext) == 0 {
-        fd, _ := os.Open(path)
-        first_line, err := bufio.NewReader(fd).ReadString('\n')
-        if err != nil {
-            return "", false
+/**
+ * ParseFormats parses a comma separated list of languages, e.g. "go,sh,md".
+ * "auto" returns no languages, meaning they should be detected from the
+ * project's files.
+ */
+func ParseFormats(val string) ([]SupportedFormat, error) {
+    formats := []SupportedFormat{}
+
+    for _, name := range strings.Split(val, ",") {
+        name = strings.TrimSpace(name)
+
+        if name == "" {
+            continue
         }
 
-        if slices.Contains(GenerateShebangs(f), strings.TrimSpace(first_line)) {
-            return string(f), true
+        if name == "auto" {
+            return nil, nil
         }
 
-        return "", false
-    }
+        lang, ok := LookupLanguage(name)
+        if ! ok {
+            return nil, fmt.Errorf("unsupported language format %v", name)
+        }
 
-    if ext[0] == '.' {
-        ext = ext[1:]
+        if ! slices.Contains(formats, lang.Format) {
+            formats = append(formats, lang.Format)
+        }
     }
 
-    if SupportedFormat(ext) != f {
-        return ext, false
-    } 
-
-    return ext, true
-}
-
-func GenerateShebangs(program SupportedFormat) []string {
-    return []string {
-        "#!/usr/bin/env " + string(program), 
-        "#!/bin/" + string(program), 
+    if len(formats) == 0 {
+        return nil, fmt.Errorf("no language given")
     }
-}
 
+    return formats, nil
+}
 
 
+// FileIsThisFormat detects the language of path, reporting it and whether
+// it's f (or a dialect of f)
+func (f SupportedFormat) FileIsThisFormat(path string) (string, bool) {
+    lang, ok := DetectLanguage(path)
+    if ! ok {
+        return "", false
+    }
 
+    return string(lang.Format), lang.Is(f)
+}
@@ -0,0 +1,56 @@
+package types
+
+import (
+    "slices"
+    "strings"
+    "testing"
+)
+
+
+func TestParseFormats(t *testing.T) {
+    tests := []struct {
+        val     string
+        want    []SupportedFormat
+        wantErr string
+    }{
+        { val: "go", want: []SupportedFormat{ Golang } },
+        { val: "go, sh,md", want: []SupportedFormat{ Golang, Shell, MarkDown } },
+        // Extensions work too, and repeats are dropped
+        { val: "yml,yaml,py", want: []SupportedFormat{ Yaml, Python } },
+        { val: "GO", want: []SupportedFormat{ Golang } },
+        { val: "auto", want: nil },
+        { val: "go,auto", want: nil },
+        { val: "go,cobol", wantErr: "unsupported language format cobol" },
+        { val: " , ", wantErr: "no language given" },
+    }
+
+    for _, tt := range tests {
+        got, err := ParseFormats(tt.val)
+        if tt.wantErr != "" {
+            if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                t.Errorf("ParseFormats(%q) error = %v, want %q", tt.val, err, tt.wantErr)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("ParseFormats(%q) error = %v", tt.val, err)
+            continue
+        }
+        if !slices.Equal(got, tt.want) {
+            t.Errorf("ParseFormats(%q) = %v, want %v", tt.val, got, tt.want)
+        }
+    }
+}
+
+
+func TestIsSupportedFormat(t *testing.T) {
+    for _, val := range []string{ "go", "sh", "md", ".yml" } {
+        if !IsSupportedFormat(val) {
+            t.Errorf("IsSupportedFormat(%q) = false", val)
+        }
+    }
+
+    if IsSupportedFormat("cobol") {
+        t.Error("IsSupportedFormat(cobol) = true")
+    }
+}